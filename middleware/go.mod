@@ -0,0 +1,8 @@
+module bangkokguy.dev/middleware
+
+go 1.16
+
+require (
+	github.com/go-chi/chi/v5 v5.0.7
+	github.com/go-chi/cors v1.2.0
+)
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Logger logs every request like chi's Logger, or with formatter if it
+// isn't nil, except on the paths in sample: a path mapped to N only has
+// every Nth request logged, so noisy routes such as streams and probes
+// don't flood the log. Server errors (5xx) and panics are always logged.
+func Logger(sample map[string]int, formatter middleware.LogFormatter) func(http.Handler) http.Handler {
+	if formatter == nil {
+		if len(sample) == 0 {
+			return middleware.Logger
+		}
+		formatter = &middleware.DefaultLogFormatter{Logger: log.New(os.Stdout, "", log.LstdFlags)}
+	}
+	if len(sample) == 0 {
+		return middleware.RequestLogger(formatter)
+	}
+	f := &sampledLogFormatter{
+		LogFormatter: formatter,
+		every:        map[string]int{},
+		counts:       map[string]*uint64{},
+	}
+	for path, n := range sample {
+		if n > 1 {
+			f.every[path] = n
+			f.counts[path] = new(uint64)
+		}
+	}
+	return middleware.RequestLogger(f)
+}
+
+type sampledLogFormatter struct {
+	middleware.LogFormatter
+	every  map[string]int
+	counts map[string]*uint64
+}
+
+func (f *sampledLogFormatter) NewLogEntry(r *http.Request) middleware.LogEntry {
+	entry := f.LogFormatter.NewLogEntry(r)
+	n, ok := f.every[r.URL.Path]
+	if !ok {
+		return entry
+	}
+	count := atomic.AddUint64(f.counts[r.URL.Path], 1)
+	return &sampledLogEntry{LogEntry: entry, log: count%uint64(n) == 1}
+}
+
+// sampledLogEntry only writes its entry if it was sampled, or if the
+// request failed.
+type sampledLogEntry struct {
+	middleware.LogEntry
+	log bool
+}
+
+func (e *sampledLogEntry) Write(status, bytes int, header http.Header, elapsed time.Duration, extra interface{}) {
+	if e.log || status >= 500 {
+		e.LogEntry.Write(status, bytes, header, elapsed, extra)
+	}
+}
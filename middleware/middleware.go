@@ -0,0 +1,168 @@
+// Package middleware holds the middleware stack shared by the webserver
+// and thermoman entry points, so both are configured the same way.
+package middleware
+
+import (
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
+)
+
+// Config configures the default stack.
+type Config struct {
+	// AllowedOrigins enables CORS for the given origins ("*" for any).
+	// CORS is left out of the stack when it's empty.
+	AllowedOrigins []string
+	// CORSDebug logs every CORS decision.
+	CORSDebug bool
+	// PreflightMaxAge maps path prefixes to how many seconds browsers may
+	// cache the preflight responses of the paths under them, overriding
+	// the default of 300. The longest matching prefix wins.
+	PreflightMaxAge map[string]int
+	// LogSample maps noisy paths to N, to only log one in N requests to
+	// them. See Logger.
+	LogSample map[string]int
+	// LogFormatter, if set, formats the request log entries instead of
+	// chi's default formatter, for instance to log them with levels.
+	LogFormatter middleware.LogFormatter
+	// OnPanic, if set, is called with every panic the recoverer catches,
+	// along with its stack. See Recoverer.
+	OnPanic func(r *http.Request, rvr interface{}, stack []byte)
+	// PanicResponse, if set, writes the response to a request whose
+	// handler panicked, instead of the bare 500 of chi's Recoverer.
+	PanicResponse func(w http.ResponseWriter, r *http.Request, rvr interface{})
+}
+
+// DefaultStack returns the common middlewares, in the order they should be
+// used: request ID, logger, recoverer and, if configured, CORS.
+//
+//	r.Use(middleware.DefaultStack(cfg)...)
+func DefaultStack(cfg Config) []func(http.Handler) http.Handler {
+	stack := []func(http.Handler) http.Handler{
+		middleware.RequestID,
+		Logger(cfg.LogSample, cfg.LogFormatter),
+		recoverer(cfg.OnPanic, cfg.PanicResponse),
+	}
+	if len(cfg.AllowedOrigins) > 0 {
+		stack = append(stack, CORS(cfg))
+	}
+	return stack
+}
+
+// CORS allows cross origin requests from the configured origins. This is
+// here for local development, where a web UI running on a different port
+// uses the API without a reverse proxy.
+func CORS(cfg Config) func(http.Handler) http.Handler {
+	handler := cors.New(cors.Options{
+		AllowedOrigins:     cfg.AllowedOrigins,
+		AllowedMethods:     []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
+		AllowedHeaders:     []string{"Accept", "Authorization", "Content-Length", "Cache-Control", "Accept-Encoding", "Content-Type", "X-CSRF-Token"},
+		AllowCredentials:   true,
+		MaxAge:             300, // Maximum value not ignored by any of major browsers
+		OptionsPassthrough: false,
+		Debug:              cfg.CORSDebug,
+	}).Handler
+	if len(cfg.PreflightMaxAge) == 0 {
+		return handler
+	}
+	return func(next http.Handler) http.Handler {
+		h := handler(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				if maxAge, ok := preflightMaxAge(cfg.PreflightMaxAge, r.URL.Path); ok {
+					w = &maxAgeWriter{ResponseWriter: w, maxAge: strconv.Itoa(maxAge)}
+				}
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// preflightMaxAge returns the max age of the longest prefix of path in
+// maxAges.
+func preflightMaxAge(maxAges map[string]int, path string) (int, bool) {
+	best := ""
+	for prefix := range maxAges {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	maxAge, ok := maxAges[best]
+	return maxAge, ok
+}
+
+// maxAgeWriter replaces the Access-Control-Max-Age of a preflight response
+// as it's written.
+type maxAgeWriter struct {
+	http.ResponseWriter
+	maxAge string
+}
+
+func (w *maxAgeWriter) WriteHeader(status int) {
+	if w.Header().Get("Access-Control-Max-Age") != "" {
+		w.Header().Set("Access-Control-Max-Age", w.maxAge)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Recoverer logs panics and answers them with a 500 the way chi's
+// Recoverer does, calling onPanic first if it's set.
+func Recoverer(onPanic func(r *http.Request, rvr interface{}, stack []byte)) func(http.Handler) http.Handler {
+	return recoverer(onPanic, nil)
+}
+
+// recoverer logs panics the way chi's Recoverer does, calls onPanic if
+// it's set, and has respond write the response if that's set. Like chi's,
+// it lets http.ErrAbortHandler through, so the server aborts the response
+// rather than have it look complete, and it doesn't answer a connection
+// that was upgraded, as a WebSocket, which isn't HTTP any more.
+func recoverer(onPanic func(r *http.Request, rvr interface{}, stack []byte), respond func(w http.ResponseWriter, r *http.Request, rvr interface{})) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rvr := recover()
+				if rvr == nil {
+					return
+				}
+				if rvr == http.ErrAbortHandler {
+					panic(rvr)
+				}
+				stack := debug.Stack()
+				if entry := middleware.GetLogEntry(r); entry != nil {
+					entry.Panic(rvr, stack)
+				} else {
+					middleware.PrintPrettyStack(rvr)
+				}
+				if onPanic != nil {
+					onPanic(r, rvr, stack)
+				}
+				if upgraded(r) {
+					return
+				}
+				if respond != nil {
+					respond(w, r, rvr)
+					return
+				}
+				w.WriteHeader(http.StatusInternalServerError)
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// upgraded tells whether r asked for its connection to be upgraded, with
+// an Upgrade token anywhere in its Connection headers.
+func upgraded(r *http.Request) bool {
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "Upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,252 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// headerWriter records whether a status was written.
+type headerWriter struct {
+	*httptest.ResponseRecorder
+	wrote bool
+}
+
+func (w *headerWriter) WriteHeader(status int) {
+	w.wrote = true
+	w.ResponseRecorder.WriteHeader(status)
+}
+
+func panicking(rvr interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(rvr)
+	})
+}
+
+func TestRecovererPanicResponse(t *testing.T) {
+	var seen interface{}
+	var stack []byte
+	onPanic := func(r *http.Request, rvr interface{}, s []byte) { seen, stack = rvr, s }
+	respond := func(w http.ResponseWriter, r *http.Request, rvr interface{}) {
+		w.WriteHeader(http.StatusTeapot)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		respond func(w http.ResponseWriter, r *http.Request, rvr interface{})
+		want    int
+	}{
+		{"bare", nil, http.StatusInternalServerError},
+		{"PanicResponse", respond, http.StatusTeapot},
+	} {
+		seen, stack = nil, nil
+		rec := httptest.NewRecorder()
+		recoverer(onPanic, tc.respond)(panicking("boom")).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, rec.Code, tc.want)
+		}
+		if seen != "boom" || len(stack) == 0 {
+			t.Errorf("%s: OnPanic got %v with a %d byte stack", tc.name, seen, len(stack))
+		}
+	}
+}
+
+func TestRecovererLetsAbortHandlerThrough(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		mw   func(http.Handler) http.Handler
+	}{
+		{"Recoverer", Recoverer(nil)},
+		{"PanicResponse", recoverer(nil, func(w http.ResponseWriter, r *http.Request, rvr interface{}) {})},
+	} {
+		func() {
+			defer func() {
+				if rvr := recover(); rvr != http.ErrAbortHandler {
+					t.Errorf("%s: recovered %v, want http.ErrAbortHandler passed on", tc.name, rvr)
+				}
+			}()
+			tc.mw(panicking(http.ErrAbortHandler)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}()
+	}
+}
+
+func TestRecovererLeavesUpgradedConnections(t *testing.T) {
+	responded := false
+	for _, connection := range []string{"Upgrade", "keep-alive, Upgrade", "upgrade"} {
+		for _, mw := range []func(http.Handler) http.Handler{
+			Recoverer(nil),
+			recoverer(nil, func(w http.ResponseWriter, r *http.Request, rvr interface{}) { responded = true }),
+		} {
+			w := &headerWriter{ResponseRecorder: httptest.NewRecorder()}
+			r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			r.Header.Set("Connection", connection)
+			r.Header.Set("Upgrade", "websocket")
+			mw(panicking("boom")).ServeHTTP(w, r)
+			if w.wrote || responded {
+				t.Errorf("answered a panic on a connection upgraded with Connection: %s", connection)
+			}
+		}
+	}
+
+	// A Connection header without the Upgrade token is answered.
+	w := &headerWriter{ResponseRecorder: httptest.NewRecorder()}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Connection", "keep-alive")
+	Recoverer(nil)(panicking("boom")).ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("panic with Connection: keep-alive answered with %d, want 500", w.Code)
+	}
+}
+
+func TestCORSPreflightMaxAge(t *testing.T) {
+	cfg := Config{
+		AllowedOrigins:  []string{"*"},
+		PreflightMaxAge: map[string]int{"/rest/v1/device": 3600, "/rest/v1/device/wifi": 60},
+	}
+	handler := CORS(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{"/rest/v1/device", "3600"},
+		{"/rest/v1/device/wifi/scan", "60"},
+		{"/rest/v1/temp", "300"},
+	} {
+		r := httptest.NewRequest(http.MethodOptions, tc.path, nil)
+		r.Header.Set("Origin", "https://panel.example")
+		r.Header.Set("Access-Control-Request-Method", "PUT")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if got := rec.Header().Get("Access-Control-Max-Age"); got != tc.want {
+			t.Errorf("preflight of %s: Access-Control-Max-Age %q, want %s", tc.path, got, tc.want)
+		}
+	}
+}
+
+// recordingFormatter records the status of every log entry written.
+type recordingFormatter struct {
+	mu       sync.Mutex
+	statuses []int
+}
+
+func (f *recordingFormatter) NewLogEntry(r *http.Request) middleware.LogEntry {
+	return &recordingEntry{f: f}
+}
+
+func (f *recordingFormatter) logged() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]int(nil), f.statuses...)
+}
+
+type recordingEntry struct {
+	f *recordingFormatter
+}
+
+func (e *recordingEntry) Write(status, bytes int, header http.Header, elapsed time.Duration, extra interface{}) {
+	e.f.mu.Lock()
+	defer e.f.mu.Unlock()
+	e.f.statuses = append(e.f.statuses, status)
+}
+
+func (e *recordingEntry) Panic(v interface{}, stack []byte) {}
+
+func TestDefaultStackOrder(t *testing.T) {
+	logs := &recordingFormatter{}
+	r := chi.NewRouter()
+	r.Use(DefaultStack(Config{AllowedOrigins: []string{"*"}, LogFormatter: logs})...)
+	authed := false
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authed = true
+			if r.Header.Get("Authorization") == "" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	var requestID string
+	r.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		requestID = middleware.GetReqID(r.Context())
+		panic("boom")
+	})
+
+	// The recoverer is inside the request ID and the logger, so a panic
+	// anywhere after them has an ID and is logged as the 500 it's
+	// answered with.
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("GET /panic: %d, want 500", rec.Code)
+	}
+	if requestID == "" {
+		t.Error("GET /panic had no request ID")
+	}
+	if got := logs.logged(); len(got) != 1 || got[0] != http.StatusInternalServerError {
+		t.Errorf("GET /panic logged %v, want one 500", got)
+	}
+
+	// CORS answers preflights before the middlewares used after the
+	// stack, such as auth, see them.
+	authed = false
+	req = httptest.NewRequest(http.MethodOptions, "/panic", nil)
+	req.Header.Set("Origin", "https://panel.example")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code >= 300 || rec.Header().Get("Access-Control-Allow-Origin") == "" || authed {
+		t.Errorf("preflight: %d with Access-Control-Allow-Origin %q, reaching auth %t; want it answered before auth",
+			rec.Code, rec.Header().Get("Access-Control-Allow-Origin"), authed)
+	}
+}
+
+func TestLoggerSample(t *testing.T) {
+	logs := &recordingFormatter{}
+	status := http.StatusOK
+	h := Logger(map[string]int{"/stream": 3}, logs)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	get := func(path string) {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// One in three requests to a sampled path is logged, starting with
+	// the first.
+	for i := 0; i < 9; i++ {
+		get("/stream")
+	}
+	if got := len(logs.logged()); got != 3 {
+		t.Errorf("9 requests to /stream sampled 1 in 3: %d logged, want 3", got)
+	}
+
+	// Other paths aren't sampled.
+	for i := 0; i < 4; i++ {
+		get("/temp")
+	}
+	if got := len(logs.logged()); got != 3+4 {
+		t.Errorf("4 requests to /temp: %d more logged, want 4", got-3)
+	}
+
+	// Server errors are logged even when they aren't sampled.
+	status = http.StatusServiceUnavailable
+	for i := 0; i < 3; i++ {
+		get("/stream")
+	}
+	got := logs.logged()
+	if len(got) != 3+4+3 {
+		t.Fatalf("3 failing requests to /stream: %d more logged, want 3", len(got)-7)
+	}
+	for _, s := range got[7:] {
+		if s != http.StatusServiceUnavailable {
+			t.Errorf("logged %d, want the 503s", s)
+		}
+	}
+}
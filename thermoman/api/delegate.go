@@ -1,5 +1,8 @@
 package api
 
+//go:generate go run ../cmd/genwrapper -root .. -resource temp -path /temp -op GET:GetTemp -param GetTemp.unit:string -param GetTemp.precision:int32
+
 type ThermoManDelegate struct {
   DeviceDelegate DeviceStub
+  TempDelegate TempStub
 }
\ No newline at end of file
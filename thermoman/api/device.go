@@ -20,8 +20,13 @@ type DeviceWrapper struct {
 func(stub *DeviceWrapper) GetIP(w http.ResponseWriter, r *http.Request) {
 
 
-  /*getipParams := types.GetIPParams {
-    Id: convertStringToInt32(r.URL.Query().Get("Id")),
+  /*id, err := convertStringToInt32(r.URL.Query().Get("Id"))
+  if err != nil {
+    http.Error(w, "Id: " + err.Error(), http.StatusBadRequest)
+    return
+  }
+  getipParams := types.GetIPParams {
+    Id: id,
                               }  */
 
   stub.DeviceDelegate.GetIP(w, r/*, getipParams*/)
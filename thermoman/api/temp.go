@@ -0,0 +1,35 @@
+// Code generated by genwrapper; DO NOT EDIT.
+
+package api
+
+import (
+	"net/http"
+
+	"ThermoMan/types"
+)
+
+// TempStub is implemented by the delegate of /temp.
+type TempStub interface {
+	GetTemp(w http.ResponseWriter, r *http.Request, params types.GetTempParams)
+}
+
+// TempWrapper parses the parameters of the /temp operations and
+// calls the delegate with them.
+type TempWrapper struct {
+	TempDelegate TempStub
+}
+
+func (stub *TempWrapper) GetTemp(w http.ResponseWriter, r *http.Request) {
+	unit := r.URL.Query().Get("unit")
+	precision, err := convertStringToInt32(r.URL.Query().Get("precision"))
+	if err != nil {
+		http.Error(w, "precision: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := types.GetTempParams{
+		Unit:      unit,
+		Precision: precision,
+	}
+
+	stub.TempDelegate.GetTemp(w, r, params)
+}
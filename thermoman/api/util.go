@@ -8,9 +8,17 @@ func convertStringToInt64(val string) int64 {
   return i64
 }
 
-// Converts a string to an int32 value
-func convertStringToInt32(val string) int32 {
-  return int32(convertStringToInt64(val))
+// Converts a string to an int32 value. An empty string is 0, values
+// outside the int32 range are an error rather than being wrapped.
+func convertStringToInt32(val string) (int32, error) {
+  if val == "" {
+    return 0, nil
+  }
+  i64, err := strconv.ParseInt(val, 10, 32)
+  if err != nil {
+    return 0, err
+  }
+  return int32(i64), nil
 }
 
 // Converts a string to an float64 value
@@ -0,0 +1,28 @@
+package api
+
+import "testing"
+
+func TestConvertStringToInt32(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    int32
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0", 0, false},
+		{"42", 42, false},
+		{"-7", -7, false},
+		{"2147483647", 2147483647, false},
+		{"-2147483648", -2147483648, false},
+		{"2147483648", 0, true},
+		{"-2147483649", 0, true},
+		{"abc", 0, true},
+		{"1.5", 0, true},
+		{" 1", 0, true},
+	} {
+		got, err := convertStringToInt32(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("convertStringToInt32(%q) = %d, %v, want %d with error %t", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+// Command genwrapper generates the boilerplate of a ThermoMan resource, the
+// way api/device.go is laid out: the stub interface its delegate
+// implements, the wrapper that parses the operations' parameters and calls
+// the delegate, the parameter types, and the router registration. The
+// delegate itself goes in impl/ by hand. It's meant to be run by go
+// generate, from a directive such as the ones in api/delegate.go:
+//
+//	go run ../cmd/genwrapper -root .. -resource temp -path /temp -op GET:GetTemp -param GetTemp.unit:string
+//
+// which writes api/temp.go, types/TempParams.go and router/temp_routes.go.
+// Parameters are query parameters of type string, int32, int64, float32 or
+// float64; an int32 that doesn't parse is a 400, the others fall back to
+// 0 as the converters in api/util.go do.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// listFlag collects a flag given more than once.
+type listFlag []string
+
+func (l *listFlag) String() string     { return strings.Join(*l, ",") }
+func (l *listFlag) Set(v string) error { *l = append(*l, v); return nil }
+
+var (
+	root     = flag.String("root", ".", "Root of the ThermoMan module, where api/, types/ and router/ are")
+	resource = flag.String("resource", "", "Name of the resource, e.g. temp")
+	path     = flag.String("path", "", "Path of the resource, e.g. /temp")
+	ops      listFlag
+	params   listFlag
+)
+
+func init() {
+	flag.Var(&ops, "op", "An operation as METHOD:Name, e.g. GET:GetTemp; repeat for more")
+	flag.Var(&params, "param", "A query parameter of an operation as Name.param:type, e.g. GetTemp.unit:string; repeat for more")
+}
+
+// Param is a query parameter of an operation.
+type Param struct {
+	Query string // name in the query string
+	Field string // field of the parameter type
+	Var   string // local variable in the wrapper
+	Type  string
+}
+
+// Op is an operation of the resource.
+type Op struct {
+	Method string // as chi names its methods: Get, Put, ...
+	Name   string
+	Params []Param
+}
+
+// Resource is what the templates are executed with.
+type Resource struct {
+	Command string
+	Name    string // exported, e.g. Temp
+	Path    string
+	Ops     []Op
+}
+
+// HasParams tells whether any operation takes parameters, so the wrapper
+// needs the types package.
+func (r Resource) HasParams() bool {
+	for _, op := range r.Ops {
+		if len(op.Params) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+var converters = map[string]string{
+	"int64":   "convertStringToInt64",
+	"float32": "convertStringToFloat32",
+	"float64": "convertStringToFloat64",
+}
+
+var funcs = template.FuncMap{
+	"lower":     strings.ToLower,
+	"converter": func(t string) string { return converters[t] },
+}
+
+var wrapperTemplate = template.Must(template.New("wrapper").Funcs(funcs).Parse(`// Code generated by {{.Command}}; DO NOT EDIT.
+
+package api
+
+import (
+	"net/http"
+{{if .HasParams}}
+	"ThermoMan/types"
+{{- end}}
+)
+
+// {{.Name}}Stub is implemented by the delegate of {{.Path}}.
+type {{.Name}}Stub interface {
+{{- range .Ops}}
+	{{.Name}}(w http.ResponseWriter, r *http.Request{{if .Params}}, params types.{{.Name}}Params{{end}})
+{{- end}}
+}
+
+// {{.Name}}Wrapper parses the parameters of the {{.Path}} operations and
+// calls the delegate with them.
+type {{.Name}}Wrapper struct {
+	{{.Name}}Delegate {{.Name}}Stub
+}
+{{range $op := .Ops}}
+func (stub *{{$.Name}}Wrapper) {{$op.Name}}(w http.ResponseWriter, r *http.Request) {
+{{- range $op.Params}}
+{{- if eq .Type "string"}}
+	{{.Var}} := r.URL.Query().Get("{{.Query}}")
+{{- else if eq .Type "int32"}}
+	{{.Var}}, err := convertStringToInt32(r.URL.Query().Get("{{.Query}}"))
+	if err != nil {
+		http.Error(w, "{{.Query}}: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+{{- else}}
+	{{.Var}} := {{converter .Type}}(r.URL.Query().Get("{{.Query}}"))
+{{- end}}
+{{- end}}
+{{- if $op.Params}}
+	params := types.{{$op.Name}}Params{
+{{- range $op.Params}}
+		{{.Field}}: {{.Var}},
+{{- end}}
+	}
+
+	stub.{{$.Name}}Delegate.{{$op.Name}}(w, r, params)
+{{- else}}
+	stub.{{$.Name}}Delegate.{{$op.Name}}(w, r)
+{{- end}}
+}
+{{end}}`))
+
+var paramsTemplate = template.Must(template.New("params").Parse(`// Code generated by {{.Command}}; DO NOT EDIT.
+
+package types
+{{range .Ops}}{{if .Params}}
+// {{.Name}}Params are the query parameters of {{.Name}}.
+type {{.Name}}Params struct {
+{{- range .Params}}
+	{{.Field}} {{.Type}}
+{{- end}}
+}
+{{end}}{{end}}`))
+
+var routesTemplate = template.Must(template.New("routes").Parse(`// Code generated by {{.Command}}; DO NOT EDIT.
+
+package router
+
+import (
+	"github.com/go-chi/chi/v5"
+
+	"ThermoMan/api"
+)
+
+// register{{.Name}} registers the {{.Path}} operations, calling delegate.
+func register{{.Name}}(r chi.Router, delegate api.{{.Name}}Stub) {
+	wrapper := api.{{.Name}}Wrapper{
+		{{.Name}}Delegate: delegate,
+	}
+{{range .Ops}}
+	r.{{.Method}}("{{$.Path}}", wrapper.{{.Name}})
+{{- end}}
+}
+`))
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("genwrapper: ")
+	flag.Parse()
+
+	res, err := parse()
+	if err != nil {
+		log.Fatal(err)
+	}
+	name := strings.ToLower(*resource)
+	files := []struct {
+		path string
+		tmpl *template.Template
+	}{
+		{filepath.Join(*root, "api", name+".go"), wrapperTemplate},
+		{filepath.Join(*root, "router", name+"_routes.go"), routesTemplate},
+	}
+	if res.HasParams() {
+		files = append(files, struct {
+			path string
+			tmpl *template.Template
+		}{filepath.Join(*root, "types", res.Name+"Params.go"), paramsTemplate})
+	}
+	for _, f := range files {
+		if err := generate(f.path, f.tmpl, res); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// parse checks the flags and turns them into the resource to generate.
+func parse() (Resource, error) {
+	if *resource == "" || *path == "" || len(ops) == 0 {
+		return Resource{}, fmt.Errorf("-resource, -path and at least one -op are needed")
+	}
+	if !strings.HasPrefix(*path, "/") {
+		return Resource{}, fmt.Errorf("-path %q must start with /", *path)
+	}
+	res := Resource{
+		Command: "genwrapper",
+		Name:    exported(*resource),
+		Path:    *path,
+	}
+
+	byName := map[string]*Op{}
+	for _, o := range ops {
+		parts := strings.SplitN(o, ":", 2)
+		if len(parts) != 2 || !isIdent(parts[1]) {
+			return Resource{}, fmt.Errorf("-op %q must be METHOD:Name", o)
+		}
+		method := exported(strings.ToLower(parts[0]))
+		switch method {
+		case "Get", "Head", "Post", "Put", "Patch", "Delete", "Options":
+		default:
+			return Resource{}, fmt.Errorf("-op %q: unknown method %s", o, parts[0])
+		}
+		name := exported(parts[1])
+		if byName[name] != nil {
+			return Resource{}, fmt.Errorf("-op %q: %s given twice", o, name)
+		}
+		res.Ops = append(res.Ops, Op{Method: method, Name: name})
+		byName[name] = &res.Ops[len(res.Ops)-1]
+	}
+	// The pointers stay valid, as Ops isn't appended to anymore.
+	for _, p := range params {
+		dot := strings.Index(p, ".")
+		colon := strings.LastIndex(p, ":")
+		if dot < 0 || colon < dot {
+			return Resource{}, fmt.Errorf("-param %q must be Name.param:type", p)
+		}
+		op, query, typ := exported(p[:dot]), p[dot+1:colon], p[colon+1:]
+		if byName[op] == nil {
+			return Resource{}, fmt.Errorf("-param %q: no -op %s", p, op)
+		}
+		if !isIdent(query) {
+			return Resource{}, fmt.Errorf("-param %q: %q isn't a usable name", p, query)
+		}
+		if typ != "string" && typ != "int32" && converters[typ] == "" {
+			return Resource{}, fmt.Errorf("-param %q: unsupported type %s", p, typ)
+		}
+		byName[op].Params = append(byName[op].Params, Param{
+			Query: query,
+			Field: exported(query),
+			Var:   unexported(query),
+			Type:  typ,
+		})
+	}
+	return res, nil
+}
+
+// generate writes the template, executed with res and formatted, to path.
+func generate(path string, tmpl *template.Template, res Resource) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, res); err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("%s: %s\n%s", path, err, buf.Bytes())
+	}
+	return ioutil.WriteFile(path, src, 0644)
+}
+
+func isIdent(s string) bool {
+	for i, c := range s {
+		if !unicode.IsLetter(c) && c != '_' && (i == 0 || !unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func exported(s string) string {
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// unexported names the local variable of a parameter, which mustn't
+// shadow err or params.
+func unexported(s string) string {
+	v := strings.ToLower(s[:1]) + s[1:]
+	if v == "err" || v == "params" || v == "r" || v == "w" || v == "stub" {
+		v += "Param"
+	}
+	return v
+}
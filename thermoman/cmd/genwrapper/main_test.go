@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// directive returns the arguments of the go:generate directive for
+// resource in api/delegate.go.
+func directive(t *testing.T, resource string) []string {
+	t.Helper()
+	f, err := os.Open(filepath.Join("..", "..", "api", "delegate.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[0] != "//go:generate" || fields[3] != "../cmd/genwrapper" {
+			continue
+		}
+		args := fields[4:]
+		for i := 0; i+1 < len(args); i++ {
+			if args[i] == "-resource" && args[i+1] == resource {
+				return args
+			}
+		}
+	}
+	t.Fatalf("no go:generate directive for %s in api/delegate.go", resource)
+	return nil
+}
+
+// run runs the generator with args, writing under a new root, which it
+// returns.
+func run(t *testing.T, args []string) string {
+	t.Helper()
+	ops, params = nil, nil
+	if err := flag.CommandLine.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	*root = dir
+	for _, sub := range []string{"api", "types", "router"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	main()
+	return dir
+}
+
+func TestGenerateTemp(t *testing.T) {
+	dir := run(t, directive(t, "temp"))
+	for _, name := range []string{"api/temp.go", "types/TempParams.go", "router/temp_routes.go"} {
+		got, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := ioutil.ReadFile(filepath.Join("..", "..", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("generated %s differs from the committed one, run go generate ./api:\n%s", name, got)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, args := range [][]string{
+		{"-path", "/temp", "-op", "GET:GetTemp"},
+		{"-resource", "temp", "-path", "temp", "-op", "GET:GetTemp"},
+		{"-resource", "temp", "-path", "/temp", "-op", "GetTemp"},
+		{"-resource", "temp", "-path", "/temp", "-op", "FETCH:GetTemp"},
+		{"-resource", "temp", "-path", "/temp", "-op", "GET:GetTemp", "-op", "PUT:GetTemp"},
+		{"-resource", "temp", "-path", "/temp", "-op", "GET:GetTemp", "-param", "SetTemp.unit:string"},
+		{"-resource", "temp", "-path", "/temp", "-op", "GET:GetTemp", "-param", "GetTemp.unit:bool"},
+	} {
+		*resource, *path = "", ""
+		ops, params = nil, nil
+		if err := flag.CommandLine.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := parse(); err == nil {
+			t.Errorf("parse with %q: no error", args)
+		}
+	}
+}
@@ -3,6 +3,8 @@ module ThermoMan
 go 1.16
 
 require (
-  github.com/go-chi/chi/v5 v5.0.2
-  github.com/go-chi/cors v1.2.0
+	bangkokguy.dev/middleware v0.0.0
+	github.com/go-chi/chi/v5 v5.0.7
 )
+
+replace bangkokguy.dev/middleware => ../middleware
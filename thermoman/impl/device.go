@@ -1,15 +1,77 @@
 package impl
 
 import (
+  "encoding/json"
+  "net"
   "net/http"
+
+  "ThermoMan/types"
 )
 
-type DeviceImpl struct {}
+type DeviceImpl struct {
+  // StaticIP is reported when the host has no usable IPv4 address.
+  StaticIP string
+  // ListAddrs lists the host's addresses, it defaults to interfaceAddrs.
+  ListAddrs func() ([]net.Addr, error)
+}
 
 // Get /device
 func (device *DeviceImpl) GetIP(w http.ResponseWriter, r *http.Request){ //, params types.GetIPParams) {
-  // Implement your logic here
+  listAddrs := device.ListAddrs
+  if listAddrs == nil {
+    listAddrs = interfaceAddrs
+  }
+
+  ip := device.StaticIP
+  if addrs, err := listAddrs(); err == nil {
+    if primary := primaryIPv4(addrs); primary != "" {
+      ip = primary
+    }
+  }
+
   w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(200)
+  json.NewEncoder(w).Encode(types.Device{IP: ip})
+}
+
+// interfaceAddrs lists the addresses of the interfaces that are up, leaving
+// out loopback interfaces.
+func interfaceAddrs() ([]net.Addr, error) {
+  ifaces, err := net.Interfaces()
+  if err != nil {
+    return nil, err
+  }
+  var addrs []net.Addr
+  for _, iface := range ifaces {
+    if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+      continue
+    }
+    a, err := iface.Addrs()
+    if err != nil {
+      continue
+    }
+    addrs = append(addrs, a...)
+  }
+  return addrs, nil
+}
 
-  w.WriteHeader(200)                                                                 
+// primaryIPv4 returns the first non-loopback IPv4 address, or "" if there
+// is none.
+func primaryIPv4(addrs []net.Addr) string {
+  for _, addr := range addrs {
+    var ip net.IP
+    switch a := addr.(type) {
+    case *net.IPNet:
+      ip = a.IP
+    case *net.IPAddr:
+      ip = a.IP
+    }
+    if ip == nil || ip.IsLoopback() {
+      continue
+    }
+    if ip4 := ip.To4(); ip4 != nil {
+      return ip4.String()
+    }
+  }
+  return ""
 }
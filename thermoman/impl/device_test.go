@@ -0,0 +1,49 @@
+package impl
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ThermoMan/types"
+)
+
+func ipNet(s string) net.Addr {
+	return &net.IPNet{IP: net.ParseIP(s), Mask: net.CIDRMask(24, 32)}
+}
+
+func TestGetIP(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		addrs []net.Addr
+		err   error
+		want  string
+	}{
+		{"first IPv4", []net.Addr{ipNet("fe80::1"), ipNet("10.0.0.7"), &net.IPAddr{IP: net.ParseIP("10.0.0.8")}}, nil, "10.0.0.7"},
+		{"IPAddr", []net.Addr{&net.IPAddr{IP: net.ParseIP("10.0.0.8")}}, nil, "10.0.0.8"},
+		{"IPv6 only", []net.Addr{ipNet("fe80::1"), ipNet("2001:db8::1")}, nil, "192.168.1.123"},
+		{"loopback only", []net.Addr{ipNet("127.0.0.1"), ipNet("::1")}, nil, "192.168.1.123"},
+		{"no addresses", nil, nil, "192.168.1.123"},
+		{"listing fails", nil, errors.New("no network"), "192.168.1.123"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			device := &DeviceImpl{
+				StaticIP:  "192.168.1.123",
+				ListAddrs: func() ([]net.Addr, error) { return tt.addrs, tt.err },
+			}
+			rec := httptest.NewRecorder()
+			device.GetIP(rec, httptest.NewRequest(http.MethodGet, "/device", nil))
+
+			var got types.Device
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || rec.Code != http.StatusOK {
+				t.Fatalf("GET /device: %d %s", rec.Code, rec.Body)
+			}
+			if got.IP != tt.want {
+				t.Errorf("ip %q, want %q", got.IP, tt.want)
+			}
+		})
+	}
+}
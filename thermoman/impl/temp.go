@@ -0,0 +1,53 @@
+package impl
+
+import (
+  "encoding/json"
+  "net/http"
+  "strconv"
+
+  "ThermoMan/types"
+)
+
+type TempImpl struct {
+  // StaticTemp is reported, in Celsius, when there's no sensor to read.
+  StaticTemp float64
+  // ReadTemp reads the sensor, in Celsius.
+  ReadTemp func() (float64, error)
+}
+
+// Get /temp
+func (temp *TempImpl) GetTemp(w http.ResponseWriter, r *http.Request, params types.GetTempParams) {
+  if params.Precision < 0 || params.Precision > 3 {
+    http.Error(w, "precision: must be between 0 and 3", http.StatusBadRequest)
+    return
+  }
+  unit := params.Unit
+  if unit == "" {
+    unit = "C"
+  }
+  if unit != "C" && unit != "F" {
+    http.Error(w, "unit: must be C or F", http.StatusBadRequest)
+    return
+  }
+
+  celsius := temp.StaticTemp
+  if temp.ReadTemp != nil {
+    t, err := temp.ReadTemp()
+    if err != nil {
+      http.Error(w, err.Error(), http.StatusServiceUnavailable)
+      return
+    }
+    celsius = t
+  }
+  value := celsius
+  if unit == "F" {
+    value = celsius*9/5 + 32
+  }
+
+  w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(200)
+  json.NewEncoder(w).Encode(types.Temp{
+    CurrentTemp: strconv.FormatFloat(value, 'f', int(params.Precision), 64),
+    Unit: unit,
+  })
+}
@@ -2,7 +2,7 @@ package router
 
 import (
   "github.com/go-chi/chi/v5"
-  "github.com/go-chi/cors"
+  "bangkokguy.dev/middleware"
   "net/http"
   "ThermoMan/api"
   "ThermoMan/impl"
@@ -11,7 +11,8 @@ import (
 // Handler creates http.Handler with routing matching OpenAPI spec.
 func Handler() http.Handler {
     serviceImpl := api.ThermoManDelegate{
-            DeviceDelegate: &impl.DeviceImpl{},
+            DeviceDelegate: &impl.DeviceImpl{StaticIP: "192.168.1.123"},
+            TempDelegate: &impl.TempImpl{StaticTemp: 21.5},
     }
 
   return RouterHandler(serviceImpl, chi.NewRouter())
@@ -19,19 +20,15 @@ func Handler() http.Handler {
 
 // HandlerFromMux creates http.Handler with routing matching OpenAPI spec based on the provided mux.
 func RouterHandler(serviceImpl api.ThermoManDelegate, r chi.Router) http.Handler {
-    // This is here for local dev server development where the client consuming the API is NOT the same IP
-    // and/or PORT as the server is running on. This allows local development from say, a web UI that runs
-    // on a different port, accessing the API, so a reverse proxy is not needed.
-    cors := cors.New(cors.Options{
-        AllowedOrigins:     []string{"*"},
-        AllowedMethods:     []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
-        AllowedHeaders:     []string{"Accept", "Authorization", "Content-Length", "Cache-Control", "Accept-Encoding", "Content-Type", "X-CSRF-Token"},
-        AllowCredentials:   true,
-        MaxAge:             300, // Maximum value not ignored by any of major browsers
-        OptionsPassthrough: false,
-        Debug:              true,
-    })
-    r.Use(cors.Handler)
+    // CORS is enabled for local dev server development where the client consuming the API is NOT the
+    // same IP and/or PORT as the server is running on.
+    r.Use(middleware.DefaultStack(middleware.Config{
+        AllowedOrigins:  []string{"*"},
+        CORSDebug:       true,
+        // The device's address hardly ever changes, so its preflights can
+        // be cached for an hour.
+        PreflightMaxAge: map[string]int{"/device": 3600},
+    })...)
 
     deviceWrapper := api.DeviceWrapper {
       DeviceDelegate: serviceImpl.DeviceDelegate,
@@ -39,6 +36,7 @@ func RouterHandler(serviceImpl api.ThermoManDelegate, r chi.Router) http.Handler
 
     r.Group(func(r chi.Router) {
       r.Get("/device", deviceWrapper.GetIP)
+      registerTemp(r, serviceImpl.TempDelegate)
     })
 
     return r
@@ -0,0 +1,18 @@
+// Code generated by genwrapper; DO NOT EDIT.
+
+package router
+
+import (
+	"github.com/go-chi/chi/v5"
+
+	"ThermoMan/api"
+)
+
+// registerTemp registers the /temp operations, calling delegate.
+func registerTemp(r chi.Router, delegate api.TempStub) {
+	wrapper := api.TempWrapper{
+		TempDelegate: delegate,
+	}
+
+	r.Get("/temp", wrapper.GetTemp)
+}
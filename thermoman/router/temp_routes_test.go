@@ -0,0 +1,45 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"ThermoMan/types"
+)
+
+// tempStub records the calls of the generated wrapper.
+type tempStub struct {
+	calls  int
+	params types.GetTempParams
+}
+
+func (s *tempStub) GetTemp(w http.ResponseWriter, r *http.Request, params types.GetTempParams) {
+	s.calls++
+	s.params = params
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestRegisterTemp(t *testing.T) {
+	stub := &tempStub{}
+	r := chi.NewRouter()
+	registerTemp(r, stub)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/temp?unit=F&precision=2", nil))
+	if rec.Code != http.StatusNoContent || stub.calls != 1 {
+		t.Fatalf("GET /temp: %d with %d delegate calls, want the delegate's 204 from one call", rec.Code, stub.calls)
+	}
+	if want := (types.GetTempParams{Unit: "F", Precision: 2}); stub.params != want {
+		t.Errorf("delegate got %+v, want %+v", stub.params, want)
+	}
+
+	// A precision that isn't an int32 is rejected before the delegate.
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/temp?precision=x", nil))
+	if rec.Code != http.StatusBadRequest || stub.calls != 1 {
+		t.Errorf("GET /temp?precision=x: %d with %d delegate calls, want 400 without calling it", rec.Code, stub.calls)
+	}
+}
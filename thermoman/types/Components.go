@@ -11,3 +11,12 @@ type User struct {
   Tag string  `json:"tag"`
 }
 
+type Device struct {
+  IP string  `json:"ip"`
+}
+
+type Temp struct {
+  CurrentTemp string  `json:"currenttemp"`
+  Unit string  `json:"unit"`
+}
+
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+var (
+	logLevel        = flag.String("log-level", "info", "Lowest level logged: debug, info, warn or error")
+	accessLogLevels = flag.String("access-log-levels", "GET /rest/v1/temp/=debug,HEAD /rest/v1/temp/=debug,GET /rest/v1/status=debug,GET /livez=debug,GET /readyz=debug",
+		"Levels requests are logged at by route, as [METHOD ]ROUTE=LEVEL pairs separated by commas; the rest are logged at info")
+)
+
+// parseLevel parses a level name as -log-level takes it.
+func parseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown level %q, must be debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// parseAccessLogLevels parses the -access-log-levels pairs into levels by
+// "METHOD ROUTE", or by ROUTE alone for any method.
+func parseAccessLogLevels(s string) (map[string]slog.Level, error) {
+	levels := map[string]slog.Level{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		route, name, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("-access-log-levels: %q must be [METHOD ]ROUTE=LEVEL", pair)
+		}
+		level, err := parseLevel(name)
+		if err != nil {
+			return nil, fmt.Errorf("-access-log-levels: %q: %w", pair, err)
+		}
+		route = strings.Join(strings.Fields(route), " ")
+		if method, path, ok := strings.Cut(route, " "); ok {
+			route = strings.ToUpper(method) + " " + path
+		}
+		levels[route] = level
+	}
+	return levels, nil
+}
+
+// accessLogFormatter logs requests through slog, at the level of their
+// route, or info. Server errors are logged as errors whatever the route.
+type accessLogFormatter struct {
+	levels map[string]slog.Level
+}
+
+func (f *accessLogFormatter) NewLogEntry(r *http.Request) middleware.LogEntry {
+	return &accessLogEntry{levels: f.levels, r: r}
+}
+
+type accessLogEntry struct {
+	levels map[string]slog.Level
+	r      *http.Request
+}
+
+// level returns the level of route, which is only known once the request
+// has been routed.
+func (e *accessLogEntry) level(route string) slog.Level {
+	if level, ok := e.levels[e.r.Method+" "+route]; ok {
+		return level
+	}
+	if level, ok := e.levels[route]; ok {
+		return level
+	}
+	return slog.LevelInfo
+}
+
+func (e *accessLogEntry) Write(status, bytes int, header http.Header, elapsed time.Duration, extra interface{}) {
+	var route string
+	if rctx := chi.RouteContext(e.r.Context()); rctx != nil {
+		route = rctx.RoutePattern()
+	}
+	level := e.level(route)
+	if status >= 500 {
+		level = slog.LevelError
+	}
+	slog.Default().Log(e.r.Context(), level, "request",
+		slog.String("request_id", middleware.GetReqID(e.r.Context())),
+		slog.String("method", e.r.Method),
+		slog.String("path", e.r.URL.Path),
+		slog.String("route", route),
+		slog.String("remote", e.r.RemoteAddr),
+		slog.Int("status", status),
+		slog.Int("bytes", bytes),
+		slog.Duration("elapsed", elapsed),
+	)
+}
+
+func (e *accessLogEntry) Panic(v interface{}, stack []byte) {
+	middleware.PrintPrettyStack(v)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestParseAccessLogLevels(t *testing.T) {
+	levels, err := parseAccessLogLevels(" get  /livez=debug, /rest/v1/temp/=warn,,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(levels) != 2 || levels["GET /livez"] != slog.LevelDebug || levels["/rest/v1/temp/"] != slog.LevelWarn {
+		t.Errorf("parsed %v", levels)
+	}
+	for _, s := range []string{"/livez", "/livez=loud"} {
+		if _, err := parseAccessLogLevels(s); err == nil {
+			t.Errorf("%q accepted", s)
+		}
+	}
+}
+
+func TestAccessLogLevels(t *testing.T) {
+	h := newHarness(t, 20)
+	logs := recordLogs(t)
+	slog.SetDefault(slog.New(slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	h.Do(http.MethodGet, "/rest/v1/temp", nil)
+	h.Do(http.MethodPut, "/rest/v1/temp", map[string]string{"daytemp": "23", "nighttemp": "18", "thereshold": "0.2"})
+	h.Do(http.MethodGet, "/rest/v1/status", nil)
+	var got []string
+	for _, rec := range logs.records("request") {
+		got = append(got, rec["method"].(string)+" "+rec["route"].(string)+" "+rec["level"].(string))
+	}
+	want := []string{"GET /rest/v1/temp/ DEBUG", "PUT /rest/v1/temp/ INFO", "GET /rest/v1/status DEBUG"}
+	if len(got) != len(want) {
+		t.Fatalf("logged %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("logged %q, want %q", got[i], want[i])
+		}
+	}
+}
+
+func TestAccessLogServerErrors(t *testing.T) {
+	logs := recordLogs(t)
+	f := &accessLogFormatter{levels: map[string]slog.Level{"/livez": slog.LevelDebug}}
+	rctx := chi.NewRouteContext()
+	rctx.RoutePatterns = []string{"/livez"}
+	r := httptest.NewRequest(http.MethodGet, "/livez", nil)
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+
+	f.NewLogEntry(r).Write(http.StatusServiceUnavailable, 0, nil, 0, nil)
+	if recs := logs.records("request"); len(recs) != 1 || recs[0]["level"] != "ERROR" {
+		t.Errorf("logged %v for a 503 on a debug route, want an error", recs)
+	}
+}
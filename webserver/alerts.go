@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+/**-----------------------------------------------------------------------------------
+ * temperature alerts
+ * ==================
+ * $ curl -X PUT -H 'Content-Type: application/json' -d '{"low":"16","high":"28","hysteresis":"0.5"}' http://bangkokguy.ddns.net/rest/v1/temp/alerts
+ *   {"low":"16","high":"28","hysteresis":"0.5"}
+ * $ curl http://bangkokguy.ddns.net/rest/v1/temp/alerts/events?type=low&limit=2
+ *   {"items":[{"at":"...","kind":"low","state":"cleared","temp":16.6,"threshold":16},{"at":"...","kind":"low","state":"fired","temp":15.9,"threshold":16}],"next_cursor":"Mw"}
+ *------------------------------------------------------------------------------------*/
+
+// AlertConfig sets the thresholds, in Celsius, below and above which an
+// alert fires. An alert clears once the reading is back inside by the
+// hysteresis, so a reading hovering at a threshold doesn't flap. An empty
+// threshold disables its alert.
+type AlertConfig struct {
+	Low        TempValue `json:"low,omitempty" validate:"min=-10,max=40"`
+	High       TempValue `json:"high,omitempty" validate:"min=-10,max=40"`
+	Hysteresis TempValue `json:"hysteresis,omitempty" validate:"min=0,max=10"`
+}
+
+func (c *AlertConfig) Bind(r *http.Request) error {
+	if c.Low != "" && c.High != "" {
+		low, err1 := strconv.ParseFloat(string(c.Low), 64)
+		high, err2 := strconv.ParseFloat(string(c.High), 64)
+		if err1 == nil && err2 == nil && low >= high {
+			return withCode(CodeOutOfRange, errors.New("low must be below high"))
+		}
+	}
+	return nil
+}
+
+func (c *AlertConfig) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// AlertEvent records an alert firing or clearing.
+type AlertEvent struct {
+	At        time.Time `json:"at"`
+	Kind      string    `json:"kind"`  // "low" or "high"
+	State     string    `json:"state"` // "fired" or "cleared"
+	Temp      float64   `json:"temp"`
+	Threshold float64   `json:"threshold"`
+}
+
+func (e *AlertEvent) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+const maxAlertEvents = 200
+
+// alerts holds the alert config, which alerts are active, and the most
+// recent alert events, oldest first.
+var alerts struct {
+	mu      sync.Mutex
+	config  AlertConfig
+	low     bool
+	high    bool
+	events  []AlertEvent
+	dropped int64 // events dropped so far, so events[0] is the dropped-th ever
+}
+
+func alertConfig() AlertConfig {
+	alerts.mu.Lock()
+	defer alerts.mu.Unlock()
+	return alerts.config
+}
+
+// setAlertConfig replaces the alert config. Active alerts stay active
+// until the next reading decides against the new thresholds.
+func setAlertConfig(c AlertConfig) {
+	alerts.mu.Lock()
+	alerts.config = c
+	alerts.mu.Unlock()
+}
+
+// resetAlerts disables the alerts and forgets which are active and the
+// events recorded.
+func resetAlerts() {
+	alerts.mu.Lock()
+	defer alerts.mu.Unlock()
+	alerts.config = AlertConfig{}
+	alerts.low, alerts.high = false, false
+	alerts.events, alerts.dropped = nil, 0
+}
+
+// checkAlerts fires or clears the alerts for a reading, in Celsius. Each
+// crossing records one event, which is also published to live clients and
+// the webhook.
+func checkAlerts(current float64, now time.Time) {
+	alerts.mu.Lock()
+	c := alerts.config
+	hysteresis, _ := strconv.ParseFloat(string(c.Hysteresis), 64)
+	var fired []AlertEvent
+	if low, err := strconv.ParseFloat(string(c.Low), 64); err == nil {
+		switch {
+		case !alerts.low && current < low:
+			alerts.low = true
+			fired = append(fired, AlertEvent{At: now, Kind: "low", State: "fired", Temp: current, Threshold: low})
+		case alerts.low && current >= low+hysteresis:
+			alerts.low = false
+			fired = append(fired, AlertEvent{At: now, Kind: "low", State: "cleared", Temp: current, Threshold: low})
+		}
+	} else {
+		alerts.low = false
+	}
+	if high, err := strconv.ParseFloat(string(c.High), 64); err == nil {
+		switch {
+		case !alerts.high && current > high:
+			alerts.high = true
+			fired = append(fired, AlertEvent{At: now, Kind: "high", State: "fired", Temp: current, Threshold: high})
+		case alerts.high && current <= high-hysteresis:
+			alerts.high = false
+			fired = append(fired, AlertEvent{At: now, Kind: "high", State: "cleared", Temp: current, Threshold: high})
+		}
+	} else {
+		alerts.high = false
+	}
+	alerts.events = append(alerts.events, fired...)
+	if drop := len(alerts.events) - maxAlertEvents; drop > 0 {
+		alerts.events = append(alerts.events[:0], alerts.events[drop:]...)
+		alerts.dropped += int64(drop)
+	}
+	alerts.mu.Unlock()
+
+	for _, a := range fired {
+		e := Event{Type: "alert", Source: "poller", Data: a}
+		broker.Publish(e)
+		postWebhook(e)
+	}
+}
+
+func GetAlerts(w http.ResponseWriter, r *http.Request) {
+	c := alertConfig()
+	if err := render.Render(w, r, &c); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+func UpdateAlerts(w http.ResponseWriter, r *http.Request) {
+	data := &AlertConfig{}
+	if err := decode(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	setAlertConfig(*data)
+	persistState(r.Context())
+	LoggerFrom(r.Context()).Info("alerts set", "low", string(data.Low), "high", string(data.High))
+
+	GetAlerts(w, r)
+}
+
+func ListAlertEvents(w http.ResponseWriter, r *http.Request) {
+	q, err := parseHistoryQuery(r, "low", "high")
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	alerts.mu.Lock()
+	picked, next := q.page(len(alerts.events), alerts.dropped, func(i int) (time.Time, string) {
+		return alerts.events[i].At, alerts.events[i].Kind
+	})
+	list := make([]AlertEvent, len(picked))
+	for j, i := range picked {
+		list[j] = alerts.events[i]
+	}
+	alerts.mu.Unlock()
+
+	if err := render.Render(w, r, &HistoryPage{Items: list, NextCursor: next}); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// alertPage is the envelope of GET /rest/v1/temp/alerts/events.
+type alertPage struct {
+	Items      []AlertEvent `json:"items"`
+	NextCursor string       `json:"next_cursor"`
+}
+
+func TestAlertsHysteresis(t *testing.T) {
+	h := newHarness(t, 20)
+	config := map[string]string{"low": "16", "high": "28", "hysteresis": "0.5"}
+	if resp, body := h.Do(http.MethodPut, "/rest/v1/temp/alerts", config); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /rest/v1/temp/alerts: %s %s", resp.Status, body)
+	}
+	events, unsubscribe, err := broker.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsubscribe()
+
+	var got []string
+	for _, temp := range []float64{15.9, 15.5, 16.3, 16.5, 28.5, 27.8, 27.4} {
+		h.SetReading(temp)
+		h.Advance(10 * time.Second)
+		for done := false; !done; {
+			select {
+			case e := <-events:
+				if a, ok := e.Data.(AlertEvent); ok && e.Type == "alert" {
+					got = append(got, fmt.Sprintf("%s %s at %g", a.Kind, a.State, a.Temp))
+				}
+			default:
+				done = true
+			}
+		}
+	}
+	want := "low fired at 15.9, low cleared at 16.5, high fired at 28.5, high cleared at 27.4"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("alerts published: %s\nwant %s", strings.Join(got, ", "), want)
+	}
+
+	var low alertPage
+	h.GetJSON("/rest/v1/temp/alerts/events?type=low", &low)
+	if len(low.Items) != 2 || low.Items[0].State != "cleared" || low.Items[1].State != "fired" {
+		t.Errorf("low alert events %+v, want cleared then fired, newest first", low.Items)
+	}
+}
+
+func TestAlertConfigValidation(t *testing.T) {
+	h := newHarness(t, 20)
+	for _, config := range []map[string]string{
+		{"low": "20", "high": "20"},
+		{"low": "-11"},
+		{"high": "20", "hysteresis": "11"},
+	} {
+		if resp, body := h.Do(http.MethodPut, "/rest/v1/temp/alerts", config); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("PUT /rest/v1/temp/alerts %v: %s %s, want 400", config, resp.Status, body)
+		}
+	}
+	if c := alertConfig(); c != (AlertConfig{}) {
+		t.Errorf("alerts %+v after rejected configs, want none", c)
+	}
+}
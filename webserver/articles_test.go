@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestUpdateArticleVersion(t *testing.T) {
+	h := newHarness(t, 20)
+	resp, body := h.Do(http.MethodPost, "/rest/v1", map[string]interface{}{"title": "Version Test", "user_id": 100})
+	var created Article
+	if err := json.Unmarshal(body, &created); err != nil || resp.StatusCode >= 300 {
+		t.Fatalf("creating: %s %s", resp.Status, body)
+	}
+	get := func() Article {
+		t.Helper()
+		var a Article
+		h.GetJSON("/rest/v1/"+created.ID, &a)
+		return a
+	}
+
+	// Without a version, nothing is changed.
+	resp, body = h.Do(http.MethodPut, "/rest/v1/"+created.ID, map[string]interface{}{"title": "No Version"})
+	if resp.StatusCode != http.StatusBadRequest || !jsonHasCode(body, CodeVersionRequired) {
+		t.Errorf("updating without a version: %s %s, want 400 %s", resp.Status, body, CodeVersionRequired)
+	}
+	if a := get(); a.Title != created.Title || a.Version != created.Version {
+		t.Errorf("after the update without a version: %+v, want %+v", a, created)
+	}
+
+	// With the current version, the fields sent are changed and the rest
+	// kept.
+	resp, body = h.Do(http.MethodPut, "/rest/v1/"+created.ID, map[string]interface{}{"title": "Renamed", "version": created.Version})
+	var updated Article
+	if err := json.Unmarshal(body, &updated); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("updating: %s %s", resp.Status, body)
+	}
+	if updated.Title != "renamed" || updated.UserID != 100 || updated.Version != created.Version+1 {
+		t.Errorf("updated %+v, want the title renamed, user 100 and version %d", updated, created.Version+1)
+	}
+
+	// The version it was based on is stale now.
+	resp, body = h.Do(http.MethodPut, "/rest/v1/"+created.ID, map[string]interface{}{"title": "Stale", "version": created.Version})
+	if resp.StatusCode != http.StatusConflict || !jsonHasCode(body, CodeVersionConflict) {
+		t.Errorf("updating a stale version: %s %s, want 409 %s", resp.Status, body, CodeVersionConflict)
+	}
+	if a := get(); a.Title != "renamed" || a.Version != updated.Version {
+		t.Errorf("after the stale update: %+v, want %+v", a, updated)
+	}
+}
+
+func TestSoftDeleteArticle(t *testing.T) {
+	h := newHarness(t, 20)
+	setAdminKey("admin-key")
+	listed := func(query string) map[string]*ArticleResponse {
+		t.Helper()
+		var page ArticlePageResponse
+		h.GetJSON("/rest/v1"+query, &page)
+		ids := map[string]*ArticleResponse{}
+		for _, a := range page.Items {
+			ids[a.ID] = a
+		}
+		return ids
+	}
+
+	resp, body := h.Do(http.MethodDelete, "/rest/v1/1", nil)
+	var deleted Article
+	if err := json.Unmarshal(body, &deleted); err != nil || resp.StatusCode != http.StatusOK || deleted.DeletedAt == nil {
+		t.Fatalf("DELETE /rest/v1/1: %s %s, want it with deleted_at", resp.Status, body)
+	}
+
+	// Deleting hides the article.
+	if resp, _ := h.Do(http.MethodGet, "/rest/v1/1", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET a deleted article: %s, want 404", resp.Status)
+	}
+	if _, ok := listed("")["1"]; ok {
+		t.Error("a deleted article is listed")
+	}
+	// It's listed with ?include_deleted=true.
+	if a, ok := listed("?include_deleted=true")["1"]; !ok || a.DeletedAt == nil {
+		t.Errorf("?include_deleted=true lists the deleted article as %+v, want it with deleted_at", a)
+	}
+	if resp, _ := h.Do(http.MethodGet, "/rest/v1?include_deleted=maybe", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("?include_deleted=maybe: %s, want 400", resp.Status)
+	}
+
+	// Restoring brings it back.
+	resp, body = h.Do(http.MethodPost, "/rest/v1/1/restore", nil)
+	var restored Article
+	if err := json.Unmarshal(body, &restored); err != nil || resp.StatusCode != http.StatusOK || restored.DeletedAt != nil {
+		t.Fatalf("POST /rest/v1/1/restore: %s %s", resp.Status, body)
+	}
+	if resp, _ := h.Do(http.MethodGet, "/rest/v1/1", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET a restored article: %s, want 200", resp.Status)
+	}
+	if _, ok := listed("")["1"]; !ok {
+		t.Error("a restored article isn't listed")
+	}
+
+	// Purging it drops it for good, and only admins can.
+	if resp, _ := h.Do(http.MethodDelete, "/admin/articles/1", nil); resp.StatusCode == http.StatusOK {
+		t.Errorf("purging without the admin key: %s", resp.Status)
+	}
+	if resp, body := h.Do(http.MethodDelete, "/admin/articles/1", nil, "Authorization", "Bearer admin-key"); resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE /admin/articles/1: %s %s", resp.Status, body)
+	}
+	if _, ok := listed("?include_deleted=true")["1"]; ok {
+		t.Error("a purged article is listed with ?include_deleted=true")
+	}
+	if resp, _ := h.Do(http.MethodPost, "/rest/v1/1/restore", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("restoring a purged article: %s, want 404", resp.Status)
+	}
+}
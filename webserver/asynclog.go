@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var logBuffer = flag.Int("log-buffer", 1024, "Log lines buffered for writing in the background, dropping lines rather than stalling requests when the buffer is full; 0 writes them as they are logged")
+
+// asyncWriter writes log lines to a slow sink, such as a file on a busy SD
+// card, in the background. Lines logged while its buffer is full are
+// dropped and counted, so logging never waits for the sink.
+type asyncWriter struct {
+	sink    io.Writer
+	lines   chan []byte
+	done    chan struct{}
+	dropped atomic.Int64
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// newAsyncWriter starts writing to sink, buffering up to size lines.
+func newAsyncWriter(sink io.Writer, size int) *asyncWriter {
+	a := &asyncWriter{sink: sink, lines: make(chan []byte, size), done: make(chan struct{})}
+	go a.run()
+	return a
+}
+
+func (a *asyncWriter) run() {
+	defer close(a.done)
+	var reported int64
+	for line := range a.lines {
+		a.sink.Write(line)
+		// Say how many went missing once there's room for it again.
+		if n := a.dropped.Load(); n > reported && len(a.lines) == 0 {
+			fmt.Fprintf(a.sink, "%s Log buffer full, dropped %d lines\n", time.Now().Format("2006/01/02 15:04:05"), n-reported)
+			reported = n
+		}
+	}
+}
+
+// Write queues p, which the log package hands over a line at a time, or
+// drops it if the buffer is full. After Close it writes to the sink
+// directly.
+func (a *asyncWriter) Write(p []byte) (int, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return a.sink.Write(p)
+	}
+	line := make([]byte, len(p))
+	copy(line, p)
+	select {
+	case a.lines <- line:
+	default:
+		a.dropped.Add(1)
+	}
+	return len(p), nil
+}
+
+// Dropped returns how many lines were dropped so far.
+func (a *asyncWriter) Dropped() int64 {
+	return a.dropped.Load()
+}
+
+// Close writes out the lines still buffered, waiting up to timeout for the
+// sink.
+func (a *asyncWriter) Close(timeout time.Duration) error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.lines)
+	a.mu.Unlock()
+
+	select {
+	case <-a.done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("log lines not written after %s", timeout)
+	}
+}
+
+// logs is the background writer of the log output, nil with -log-buffer=0.
+var logs *asyncWriter
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowSink takes its time over every write, like a file on a busy SD card.
+type slowSink struct {
+	mu    sync.Mutex
+	delay time.Duration
+	buf   bytes.Buffer
+}
+
+func (s *slowSink) Write(p []byte) (int, error) {
+	time.Sleep(s.delay)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *slowSink) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.String()
+}
+
+func TestAsyncWriterDoesNotBlockHandlers(t *testing.T) {
+	sink := &slowSink{delay: 20 * time.Millisecond}
+	w := newAsyncWriter(sink, 4)
+	l := log.New(w, "", 0)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.Printf("served %s", r.URL.Path)
+	})
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ping", nil))
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("100 requests took %s with a 20ms log sink, the handlers waited for it", elapsed)
+	}
+	// The writer may have taken the first line off the buffer already.
+	n := w.Dropped()
+	if n < 95 || n > 96 {
+		t.Errorf("dropped %d lines, want 95 or 96 of 100 with a buffer of 4", n)
+	}
+	old := logs
+	logs = w
+	defer func() { logs = old }()
+	rec := httptest.NewRecorder()
+	GetMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if want := fmt.Sprintf("log_lines_dropped_total %d\n", n); !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("/metrics doesn't have %q:\n%s", want, rec.Body)
+	}
+
+	if err := w.Close(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	out := sink.String()
+	if got, want := strings.Count(out, "served /ping"), 100-int(n); got != want {
+		t.Errorf("%d lines written, want the %d not dropped:\n%s", got, want, out)
+	}
+	if !strings.Contains(out, "Log buffer full, dropped") {
+		t.Errorf("the drops weren't noted:\n%s", out)
+	}
+
+	// After Close, lines go straight to the sink.
+	l.Print("after close")
+	if !strings.Contains(sink.String(), "after close") {
+		t.Error("a line logged after Close was lost")
+	}
+}
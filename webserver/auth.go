@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+var authSecret = flag.String("auth-secret", "", "Key signing session tokens; random on every start if empty, which invalidates earlier tokens")
+var adminKey = flag.String("admin-key", "", "API key granting admin access, to issue the first session tokens; admin routes stay closed if empty")
+var tokenTTL = flag.Duration("token-ttl", 24*time.Hour, "How long issued session tokens are valid")
+
+var (
+	errTokenInvalid = errors.New("invalid token")
+	errTokenExpired = errors.New("token expired")
+	errTokenRevoked = errors.New("token revoked")
+)
+
+// Claims are the payload of a session token, a JWT signed with HS256.
+type Claims struct {
+	ID        string `json:"jti"`
+	Subject   string `json:"sub"`
+	Admin     bool   `json:"adm,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Session describes an issued token, without the token itself.
+type Session struct {
+	ID      string    `json:"id"`
+	Subject string    `json:"subject"`
+	Admin   bool      `json:"admin"`
+	Issued  time.Time `json:"issued"`
+	Expiry  time.Time `json:"expiry"`
+}
+
+func (s *Session) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Sessions keeps track of the issued tokens, and of the revoked ones until
+// they would have expired anyway.
+type Sessions struct {
+	key []byte
+
+	mu      sync.Mutex
+	issued  map[string]*Session
+	revoked map[string]time.Time // token ID -> expiry
+}
+
+var sessions = NewSessions(nil)
+
+// NewSessions returns a Sessions signing with key, or with a random key if
+// key is empty.
+func NewSessions(key []byte) *Sessions {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic(err)
+		}
+	}
+	return &Sessions{
+		key:     key,
+		issued:  map[string]*Session{},
+		revoked: map[string]time.Time{},
+	}
+}
+
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Issue signs a token for subject, valid for ttl from now.
+func (s *Sessions) Issue(subject string, admin bool, ttl time.Duration, now time.Time) (string, *Session, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+	claims := Claims{
+		ID:        hex.EncodeToString(id),
+		Subject:   subject,
+		Admin:     admin,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	token := unsigned + "." + s.sign(unsigned)
+
+	session := &Session{
+		ID:      claims.ID,
+		Subject: subject,
+		Admin:   admin,
+		Issued:  time.Unix(claims.IssuedAt, 0).UTC(),
+		Expiry:  time.Unix(claims.ExpiresAt, 0).UTC(),
+	}
+	s.mu.Lock()
+	s.issued[session.ID] = session
+	s.mu.Unlock()
+	return token, session, nil
+}
+
+func (s *Sessions) sign(unsigned string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and expiry of token, and that it hasn't been
+// revoked.
+func (s *Sessions) Verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return nil, errTokenInvalid
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(s.sign(parts[0]+"."+parts[1]))) {
+		return nil, errTokenInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errTokenInvalid
+	}
+	claims := &Claims{}
+	if err := json.Unmarshal(payload, claims); err != nil || claims.ID == "" {
+		return nil, errTokenInvalid
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, errTokenExpired
+	}
+
+	s.mu.Lock()
+	_, revoked := s.revoked[claims.ID]
+	s.mu.Unlock()
+	if revoked {
+		return nil, errTokenRevoked
+	}
+	return claims, nil
+}
+
+// List returns the sessions that are neither expired nor revoked, oldest
+// first.
+func (s *Sessions) List(now time.Time) []*Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []*Session{}
+	for _, session := range s.issued {
+		if now.Before(session.Expiry) {
+			list = append(list, session)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Issued.Equal(list[j].Issued) {
+			return list[i].Issued.Before(list[j].Issued)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// Revoke puts the token with the given ID on the denylist until it
+// expires. It returns the revoked session, or nil if there is no such
+// session.
+func (s *Sessions) Revoke(id string) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.issued[id]
+	if !ok {
+		return nil
+	}
+	delete(s.issued, id)
+	s.revoked[id] = session.Expiry
+	return session
+}
+
+// Sweep drops expired sessions, and denylist entries whose tokens have
+// expired, since those are rejected anyway.
+func (s *Sessions) Sweep(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, session := range s.issued {
+		if !now.Before(session.Expiry) {
+			delete(s.issued, id)
+		}
+	}
+	for id, expiry := range s.revoked {
+		if !now.Before(expiry) {
+			delete(s.revoked, id)
+		}
+	}
+}
+
+// sweepSessions sweeps the sessions, and the nonces of signed requests,
+// every interval until ctx is done.
+func sweepSessions(ctx context.Context, interval time.Duration) error {
+	ticks, stop := clock.NewTicker(interval)
+	defer stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticks:
+			sessions.Sweep(now)
+			nonces.Sweep(now)
+		}
+	}
+}
+
+type sessionCtxKey struct{}
+
+// Authenticate middleware checks the bearer token of requests that send
+// one, either a session token or the admin key, and rejects them with a
+// 401 if it's invalid, expired or revoked. Requests signed with the
+// -signing-key instead may change the settings, like the owner, if the
+// signature checks out, and are rejected if it doesn't, is stale or is
+// replayed. They aren't admin. Requests without either pass on
+// unauthenticated; routes needing more are guarded by AdminOnly.
+func Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Signature") != "" {
+			err := errSigningDisabled
+			if *signingKey != "" {
+				err = verifySignature(r, []byte(*signingKey), *signatureMaxSkew, clockOf(r.Context()).Now())
+			}
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", "Signature")
+				render.Render(w, r, ErrUnauthorized(err))
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), signedCtxKey{}, true)))
+			return
+		}
+
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			render.Render(w, r, ErrUnauthorized(errTokenInvalid))
+			return
+		}
+
+		ctx := r.Context()
+		if key := currentAdminKey(); key != "" && subtle.ConstantTimeCompare([]byte(token), []byte(key)) == 1 {
+			ctx = context.WithValue(ctx, "acl.admin", true)
+		} else if isOwnerToken(token) {
+			ctx = withOwner(ctx)
+		} else {
+			claims, err := sessions.Verify(token, clockOf(r.Context()).Now())
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				render.Render(w, r, ErrUnauthorized(err))
+				return
+			}
+			ctx = context.WithValue(ctx, "acl.admin", claims.Admin)
+			ctx = context.WithValue(ctx, sessionCtxKey{}, claims)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+/**-----------------------------------------------------------------------------------
+ * issue session token
+ * ===================
+ * $ curl -X POST -H 'Authorization: Bearer <admin-key>' -d '{"subject":"kitchen-panel"}' http://bangkokguy.ddns.net/admin/sessions
+ *   {"token":"eyJhbGciOi...","session":{"id":"9f2c...","subject":"kitchen-panel","admin":false,...}}
+ *------------------------------------------------------------------------------------*/
+
+// SessionRequest asks for a session token.
+type SessionRequest struct {
+	Subject string `json:"subject" validate:"required"`
+	Admin   bool   `json:"admin"`
+}
+
+func (s *SessionRequest) Bind(r *http.Request) error {
+	return nil
+}
+
+// SessionResponse carries a freshly issued token. It is the only time the
+// token is shown.
+type SessionResponse struct {
+	Token   string   `json:"token"`
+	Session *Session `json:"session"`
+}
+
+func (s *SessionResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// IssueSession issues a session token.
+func IssueSession(w http.ResponseWriter, r *http.Request) {
+	data := &SessionRequest{}
+	if err := decode(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	token, session, err := sessions.Issue(data.Subject, data.Admin, *tokenTTL, clockOf(r.Context()).Now())
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, &SessionResponse{Token: token, Session: session})
+}
+
+/**-----------------------------------------------------------------------------------
+ * list and revoke sessions
+ * ========================
+ * $ curl -H 'Authorization: Bearer <admin-key>' http://bangkokguy.ddns.net/admin/sessions
+ *   [{"id":"9f2c...","subject":"kitchen-panel","admin":false,"issued":"...","expiry":"..."}]
+ * $ curl -X DELETE -H 'Authorization: Bearer <admin-key>' http://bangkokguy.ddns.net/admin/sessions/9f2c...
+ *------------------------------------------------------------------------------------*/
+
+// ListSessions lists the active sessions.
+func ListSessions(w http.ResponseWriter, r *http.Request) {
+	list := []render.Renderer{}
+	for _, session := range sessions.List(clockOf(r.Context()).Now()) {
+		list = append(list, session)
+	}
+	if err := render.RenderList(w, r, list); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// RevokeSession revokes a session, so its token is rejected from now on.
+func RevokeSession(w http.ResponseWriter, r *http.Request) {
+	session := sessions.Revoke(chi.URLParam(r, "sessionID"))
+	if session == nil {
+		render.Render(w, r, ErrNotFound)
+		return
+	}
+	render.Render(w, r, session)
+}
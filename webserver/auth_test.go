@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	h := newHarness(t, 20)
+	setAdminKey("admin-key")
+	admin := []string{"Authorization", "Bearer admin-key"}
+
+	resp, body := h.Do(http.MethodPost, "/admin/sessions", map[string]string{"subject": "kitchen-panel"}, admin...)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /admin/sessions: %s %s", resp.Status, body)
+	}
+	var issued SessionResponse
+	if err := json.Unmarshal(body, &issued); err != nil {
+		t.Fatalf("%s: %s", err, body)
+	}
+	bearer := []string{"Authorization", "Bearer " + issued.Token}
+
+	if resp, body := h.Do(http.MethodGet, "/rest/v1/status", nil, bearer...); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /rest/v1/status with the token: %s %s", resp.Status, body)
+	}
+	if resp, _ := h.Do(http.MethodGet, "/admin/sessions", nil, bearer...); resp.StatusCode == http.StatusOK {
+		t.Errorf("a non-admin token listed the sessions")
+	}
+	var list []Session
+	resp, body = h.Do(http.MethodGet, "/admin/sessions", nil, admin...)
+	if err := json.Unmarshal(body, &list); err != nil || len(list) != 1 || list[0].ID != issued.Session.ID {
+		t.Errorf("GET /admin/sessions: %s %s, want the one issued", resp.Status, body)
+	}
+
+	if resp, body := h.Do(http.MethodDelete, "/admin/sessions/"+issued.Session.ID, nil, admin...); resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE /admin/sessions/%s: %s %s", issued.Session.ID, resp.Status, body)
+	}
+	resp, body = h.Do(http.MethodGet, "/rest/v1/status", nil, bearer...)
+	if resp.StatusCode != http.StatusUnauthorized || !jsonHasCode(body, CodeTokenRevoked) {
+		t.Errorf("GET /rest/v1/status with the revoked token: %s %s, want 401 %s", resp.Status, body, CodeTokenRevoked)
+	}
+	if resp, _ := h.Do(http.MethodDelete, "/admin/sessions/"+issued.Session.ID, nil, admin...); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("revoking twice: %s, want 404", resp.Status)
+	}
+}
+
+func TestSessionTokens(t *testing.T) {
+	now := harnessStart
+	s := NewSessions([]byte("secret"))
+	token, session, err := s.Issue("panel", true, time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, err := s.Verify(token, now.Add(59*time.Minute)); err != nil || claims.Subject != "panel" || !claims.Admin {
+		t.Errorf("Verify = %+v, %v; want the admin claims for panel", claims, err)
+	}
+	if _, err := s.Verify(token, now.Add(time.Hour)); err != errTokenExpired {
+		t.Errorf("Verify after the TTL: %v, want errTokenExpired", err)
+	}
+	if _, err := NewSessions([]byte("other")).Verify(token, now); err != errTokenInvalid {
+		t.Errorf("Verify with another key: %v, want errTokenInvalid", err)
+	}
+	if _, err := s.Verify(token[:len(token)-2], now); err != errTokenInvalid {
+		t.Errorf("Verify of a cut token: %v, want errTokenInvalid", err)
+	}
+
+	s.Revoke(session.ID)
+	s.Sweep(now.Add(30 * time.Minute))
+	if _, err := s.Verify(token, now.Add(30*time.Minute)); err != errTokenRevoked {
+		t.Errorf("a revoked token before its expiry: %v, want errTokenRevoked", err)
+	}
+	s.Sweep(now.Add(time.Hour))
+	if len(s.revoked) != 0 {
+		t.Errorf("%d denylist entries kept past their expiry", len(s.revoked))
+	}
+}
+
+// jsonHasCode tells whether body is an error response with code.
+func jsonHasCode(body []byte, code ErrorCode) bool {
+	var e struct {
+		Code ErrorCode `json:"code"`
+	}
+	return json.Unmarshal(body, &e) == nil && e.Code == code
+}
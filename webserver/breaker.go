@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+var errBreakerOpen = errors.New("circuit breaker open")
+
+// Breaker is a circuit breaker. It is closed, letting calls through, until
+// threshold calls in a row have failed. Then it's open, failing calls
+// right away, for the cooldown. After that it's half-open: it lets a
+// single call through as a probe, which closes it again if it succeeds and
+// opens it for another cooldown if it doesn't.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string // "closed", "open" or "half-open"
+	failures int    // failed calls in a row
+	openedAt time.Time
+	probing  bool
+	rejected int64
+}
+
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, cooldown: cooldown, state: "closed"}
+}
+
+// Allow tells if a call may go ahead, failing with errBreakerOpen if not.
+// A call that was allowed has to be reported with Done.
+func (b *Breaker) Allow(now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == "open" && now.Sub(b.openedAt) >= b.cooldown {
+		b.state = "half-open"
+	}
+	switch {
+	case b.state == "open", b.state == "half-open" && b.probing:
+		b.rejected++
+		return errBreakerOpen
+	case b.state == "half-open":
+		b.probing = true
+	}
+	return nil
+}
+
+// Done reports the outcome of an allowed call.
+func (b *Breaker) Done(err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+	if err == nil {
+		b.state, b.failures = "closed", 0
+		return
+	}
+	b.failures++
+	if b.state == "half-open" || b.failures >= b.threshold {
+		b.state, b.openedAt = "open", now
+	}
+}
+
+// BreakerStats is the state of a breaker.
+type BreakerStats struct {
+	State    string     `json:"state"`
+	Failures int        `json:"consecutive_failures"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+	Rejected int64      `json:"rejected"` // calls failed right away since the start
+}
+
+func (s *BreakerStats) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func (b *Breaker) Stats(now time.Time) BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := b.state
+	if state == "open" && now.Sub(b.openedAt) >= b.cooldown {
+		state = "half-open"
+	}
+	stats := BreakerStats{State: state, Failures: b.failures, Rejected: b.rejected}
+	if state != "closed" {
+		openedAt := b.openedAt
+		stats.OpenedAt = &openedAt
+	}
+	return stats
+}
+
+/**-----------------------------------------------------------------------------------
+ * webhook diagnostics
+ * ===================
+ * $ curl http://bangkokguy.ddns.net/rest/v1/diagnostics/webhook
+ *   {"state":"open","consecutive_failures":5,"opened_at":"...","rejected":12}
+ *------------------------------------------------------------------------------------*/
+
+// GetWebhookDiagnostics serves the state of the webhook's breaker.
+func GetWebhookDiagnostics(w http.ResponseWriter, r *http.Request) {
+	stats := webhookBreaker.Stats(clockOf(r.Context()).Now())
+	if err := render.Render(w, r, &stats); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
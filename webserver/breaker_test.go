@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := harnessStart
+	b := NewBreaker(3, 30*time.Second)
+	fail := errors.New("webhook down")
+
+	for i := 0; i < 3; i++ {
+		if err := b.Allow(now); err != nil {
+			t.Fatalf("call %d rejected after %d failures, want it let through", i+1, i)
+		}
+		b.Done(fail, now)
+	}
+	if s := b.Stats(now); s.State != "open" || s.Failures != 3 {
+		t.Fatalf("after 3 failures: %+v, want open with 3 failures", s)
+	}
+	if err := b.Allow(now.Add(29 * time.Second)); err != errBreakerOpen {
+		t.Errorf("a call within the cooldown got %v, want errBreakerOpen", err)
+	}
+
+	// Past the cooldown, one probe goes through and the rest wait for it.
+	now = now.Add(30 * time.Second)
+	if err := b.Allow(now); err != nil {
+		t.Fatalf("the probe was rejected: %v", err)
+	}
+	if err := b.Allow(now); err != errBreakerOpen {
+		t.Errorf("a second call while probing got %v, want errBreakerOpen", err)
+	}
+	b.Done(fail, now)
+	if s := b.Stats(now); s.State != "open" || !s.OpenedAt.Equal(now) {
+		t.Fatalf("after a failed probe: %+v, want open again from %s", s, now)
+	}
+
+	now = now.Add(30 * time.Second)
+	if err := b.Allow(now); err != nil {
+		t.Fatalf("the second probe was rejected: %v", err)
+	}
+	b.Done(nil, now)
+	if s := b.Stats(now); s.State != "closed" || s.Failures != 0 || s.OpenedAt != nil {
+		t.Errorf("after a good probe: %+v, want closed", s)
+	}
+	if s := b.Stats(now); s.Rejected != 2 {
+		t.Errorf("%d calls counted as rejected, want 2", s.Rejected)
+	}
+	if err := b.Allow(now); err != nil {
+		t.Errorf("a call after recovering got %v", err)
+	}
+}
+
+func TestWebhookSkippedWhileBreakerOpen(t *testing.T) {
+	resetState(t)
+	var calls atomic.Int64
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(hook.Close)
+	withFlag(t, webhookURL, hook.URL)
+	withFlag(t, &webhookBreaker, NewBreaker(2, time.Hour))
+
+	for i := 0; i < 2; i++ {
+		sendWebhook(Event{Type: "test"})
+		deadline := time.Now().Add(5 * time.Second)
+		for webhookBreaker.Stats(time.Now()).Failures != i+1 {
+			if time.Now().After(deadline) {
+				t.Fatalf("delivery %d never failed", i+1)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		sendWebhook(Event{Type: "test"})
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := calls.Load(); n != 2 {
+		t.Errorf("the webhook was called %d times, want 2 before the breaker opened", n)
+	}
+	if s := webhookBreaker.Stats(time.Now()); s.State != "open" || s.Rejected != 3 {
+		t.Errorf("breaker %+v, want open with 3 deliveries skipped", s)
+	}
+}
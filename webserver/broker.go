@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+var maxSubscribers = flag.Int("max-subscribers", 32, "Most live clients, event streams and WebSockets together, subscribed at once; 0 for no limit")
+
+var errTooManySubscribers = errors.New("too many live clients")
+
+// Event is a message pushed to live clients.
+type Event struct {
+	Type   string      `json:"type"`             // "config_changed", "telemetry", "error", "shutdown"
+	Source string      `json:"source,omitempty"` // who caused it, e.g. "rest:<request id>" or "ws:<client>"
+	Data   interface{} `json:"data,omitempty"`
+}
+
+// Broker fans events out to its subscribers. Publishing is serialized, so
+// every subscriber sees events in the same order. A subscriber that falls
+// behind misses events rather than holding everyone else up.
+type Broker struct {
+	mu       sync.Mutex
+	subs     map[chan Event]struct{}
+	active   int           // subscriptions not yet ended
+	limit    int           // most active subscriptions, 0 for no limit
+	rejected int64         // subscriptions refused for the limit
+	closed   bool          // Shutdown has been called
+	drained  chan struct{} // closed once active drops to 0 after Shutdown
+}
+
+func NewBroker() *Broker {
+	return &Broker{subs: map[chan Event]struct{}{}, drained: make(chan struct{})}
+}
+
+// SetLimit sets how many subscriptions may be active at once, 0 meaning
+// any number.
+func (b *Broker) SetLimit(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limit = n
+}
+
+// Subscribe returns a channel receiving the events published from now on,
+// and a function that ends the subscription and closes the channel. The
+// channel is also closed by Shutdown, and comes closed after it. With the
+// limit reached, it fails with errTooManySubscribers. The subscription
+// counts until it's ended, so the function is best deferred, to run even
+// if the subscriber panics.
+func (b *Broker) Subscribe() (<-chan Event, func(), error) {
+	ch := make(chan Event, 16)
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		close(ch)
+		return ch, func() {}, nil
+	}
+	if b.limit > 0 && b.active >= b.limit {
+		b.rejected++
+		b.mu.Unlock()
+		return nil, nil, errTooManySubscribers
+	}
+	b.subs[ch] = struct{}{}
+	b.active++
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.subs[ch]; ok {
+				delete(b.subs, ch)
+				close(ch)
+			}
+			b.active--
+			if b.closed && b.active == 0 {
+				close(b.drained)
+			}
+		})
+	}, nil
+}
+
+// Stats returns the number of active subscriptions, the limit, and how
+// many were refused for it.
+func (b *Broker) Stats() SubscriberStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return SubscriberStats{Active: b.active, Limit: b.limit, Rejected: b.rejected}
+}
+
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Shutdown sends e to the subscribers as their last event and closes their
+// channels, then waits until they have all unsubscribed, or ctx is done.
+func (b *Broker) Shutdown(ctx context.Context, e Event) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	for ch := range b.subs {
+		sendLast(ch, e)
+		delete(b.subs, ch)
+		close(ch)
+	}
+	if b.active == 0 {
+		close(b.drained)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-b.drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sendLast queues e on ch without blocking. If ch is full, the oldest
+// event queued is dropped to make room: e is the one the subscriber
+// mustn't miss. Only the broker sends, under its lock, so the room stays.
+func sendLast(ch chan Event, e Event) {
+	select {
+	case ch <- e:
+		return
+	default:
+	}
+	select {
+	case <-ch:
+	default:
+	}
+	select {
+	case ch <- e:
+	default:
+	}
+}
+
+var broker = NewBroker()
+
+/**-----------------------------------------------------------------------------------
+ * get subscriber diagnostics
+ * ==========================
+ * $ curl http://bangkokguy.ddns.net/rest/v1/diagnostics/subscribers
+ *   {"active":3,"limit":32,"rejected":0}
+ *------------------------------------------------------------------------------------*/
+
+// SubscriberStats tells how many live clients there are, event streams and
+// WebSockets together.
+type SubscriberStats struct {
+	Active   int   `json:"active"`
+	Limit    int   `json:"limit"` // 0 for no limit
+	Rejected int64 `json:"rejected"`
+}
+
+func (s *SubscriberStats) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func GetSubscriberDiagnostics(w http.ResponseWriter, r *http.Request) {
+	stats := broker.Stats()
+	if err := render.Render(w, r, &stats); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// publishConfig announces the current config to live clients as changed
+// by source.
+func publishConfig(ctx context.Context, source string) {
+	config, err := loadConfig(ctx)
+	if err != nil {
+		log.Printf("Publishing config failed: %s", err)
+		return
+	}
+	broker.Publish(Event{Type: "config_changed", Source: source, Data: config})
+}
+
+// requestSource names the REST request r as the source of a change.
+func requestSource(r *http.Request) string {
+	return "rest:" + middleware.GetReqID(r.Context())
+}
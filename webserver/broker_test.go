@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestShutdownReachesSubscribersThatFellBehind(t *testing.T) {
+	b := NewBroker()
+	events, unsubscribe, err := b.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < cap(events)+4; i++ {
+		b.Publish(Event{Type: "telemetry"})
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		done <- b.Shutdown(ctx, Event{Type: "shutdown"})
+	}()
+
+	var last Event
+	n := 0
+	for e := range events {
+		last = e
+		n++
+	}
+	unsubscribe()
+	if last.Type != "shutdown" {
+		t.Errorf("last of %d events is %q, want shutdown", n, last.Type)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Shutdown: %s", err)
+	}
+}
+
+func TestBrokerLimit(t *testing.T) {
+	b := NewBroker()
+	b.SetLimit(2)
+	_, first, err := b.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := b.Subscribe(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := b.Subscribe(); err != errTooManySubscribers {
+		t.Fatalf("subscribing over the limit: %v", err)
+	}
+
+	// Ending a subscription twice frees one slot, not two.
+	first()
+	first()
+	if _, _, err := b.Subscribe(); err != nil {
+		t.Errorf("subscribing after one ended: %v", err)
+	}
+	if _, _, err := b.Subscribe(); err != errTooManySubscribers {
+		t.Errorf("subscribing over the limit again: %v", err)
+	}
+	if got, want := b.Stats(), (SubscriberStats{Active: 2, Limit: 2, Rejected: 2}); got != want {
+		t.Errorf("stats %+v, want %+v", got, want)
+	}
+}
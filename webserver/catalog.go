@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/go-chi/render"
+)
+
+// ErrorCode identifies an error for clients, which can branch on it instead
+// of parsing the error text. Codes are stable: they are only ever added.
+type ErrorCode string
+
+// The general codes each error response constructor falls back on.
+const (
+	CodeInvalidRequest       ErrorCode = "request.invalid"
+	CodeUnauthorized         ErrorCode = "request.unauthorized"
+	CodeUnsupportedMediaType ErrorCode = "request.unsupported_media_type"
+	CodeTooLarge             ErrorCode = "request.too_large"
+	CodeURITooLong           ErrorCode = "request.uri_too_long"
+	CodeRender               ErrorCode = "render.failed"
+	CodeNotFound             ErrorCode = "resource.not_found"
+	CodeConflict             ErrorCode = "resource.conflict"
+	CodeInternal             ErrorCode = "server.internal"
+	CodeUnavailable          ErrorCode = "server.unavailable"
+	CodeTimeout              ErrorCode = "server.timeout"
+)
+
+// More specific codes, carried by the errors themselves.
+const (
+	CodeBodyRequired         ErrorCode = "request.body_required"
+	CodeRequired             ErrorCode = "validation.required"
+	CodeOutOfRange           ErrorCode = "validation.out_of_range"
+	CodeNotAllowed           ErrorCode = "validation.not_allowed"
+	CodeBadFormat            ErrorCode = "validation.bad_format"
+	CodeTempOutOfRange       ErrorCode = "temp.out_of_range"
+	CodeUnknownUnit          ErrorCode = "temp.unknown_unit"
+	CodeVersionConflict      ErrorCode = "article.version_conflict"
+	CodeVersionRequired      ErrorCode = "article.version_required"
+	CodeScheduled            ErrorCode = "schedule.governs"
+	CodeOverloaded           ErrorCode = "server.overloaded"
+	CodeNotReady             ErrorCode = "server.not_ready"
+	CodeChaos                ErrorCode = "chaos.injected"
+	CodeTokenInvalid         ErrorCode = "auth.token_invalid"
+	CodeTokenExpired         ErrorCode = "auth.token_expired"
+	CodeTokenRevoked         ErrorCode = "auth.token_revoked"
+	CodeUnsupportedEncoding  ErrorCode = "request.unsupported_encoding"
+	CodeDecompressedTooLarge ErrorCode = "request.decompressed_too_large"
+	CodeExportVersion        ErrorCode = "import.unsupported_version"
+	CodeNoReading            ErrorCode = "sensor.no_reading"
+	CodeStaleReading         ErrorCode = "sensor.stale"
+	CodeSignatureInvalid     ErrorCode = "auth.signature_invalid"
+	CodeSignatureStale       ErrorCode = "auth.signature_stale"
+	CodeNonceReused          ErrorCode = "auth.nonce_reused"
+	CodeNoManifestURL        ErrorCode = "firmware.no_manifest_url"
+	CodeManifestUnreachable  ErrorCode = "firmware.manifest_unreachable"
+	CodeSlugTaken            ErrorCode = "article.slug_taken"
+	CodeFieldImmutable       ErrorCode = "device.field_immutable"
+	CodeIdentifyActive       ErrorCode = "device.identify_active"
+	CodeIdentifyCooldown     ErrorCode = "device.identify_cooldown"
+	CodeStreamUnsupported    ErrorCode = "server.stream_unsupported"
+	CodeTooManySubscribers   ErrorCode = "server.too_many_subscribers"
+	CodeClaimed              ErrorCode = "device.claimed"
+	CodeOwnerRequired        ErrorCode = "device.owner_required"
+	CodeTimesOverlap         ErrorCode = "time.overlap"
+)
+
+// ErrorDef documents an error code with its default HTTP status and
+// message.
+type ErrorDef struct {
+	Code    ErrorCode `json:"code"`
+	Status  int       `json:"status"`
+	Message string    `json:"message"`
+}
+
+func (d *ErrorDef) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+var errorCatalog = map[ErrorCode]ErrorDef{
+	CodeInvalidRequest:       {Status: 400, Message: "Invalid request."},
+	CodeUnauthorized:         {Status: 401, Message: "Unauthorized."},
+	CodeUnsupportedMediaType: {Status: 415, Message: "Unsupported media type."},
+	CodeTooLarge:             {Status: 413, Message: "Request body too large."},
+	CodeURITooLong:           {Status: 414, Message: "Request URI too long."},
+	CodeRender:               {Status: 422, Message: "Error rendering response."},
+	CodeNotFound:             {Status: 404, Message: "Resource not found."},
+	CodeConflict:             {Status: 409, Message: "Conflict."},
+	CodeInternal:             {Status: 500, Message: "Internal server error."},
+	CodeUnavailable:          {Status: 503, Message: "Service unavailable."},
+	CodeTimeout:              {Status: 504, Message: "Timed out."},
+
+	CodeBodyRequired:         {Status: 400, Message: "The request needs a body."},
+	CodeRequired:             {Status: 400, Message: "A required field is missing."},
+	CodeOutOfRange:           {Status: 400, Message: "A field is out of range."},
+	CodeNotAllowed:           {Status: 400, Message: "A field has a value that isn't allowed."},
+	CodeBadFormat:            {Status: 400, Message: "A field isn't formatted correctly."},
+	CodeTempOutOfRange:       {Status: 400, Message: "A target temperature is outside the allowed setpoints."},
+	CodeUnknownUnit:          {Status: 400, Message: "Unknown temperature unit."},
+	CodeVersionConflict:      {Status: 409, Message: "The article was modified concurrently."},
+	CodeVersionRequired:      {Status: 400, Message: "An article update has to send the version it's based on."},
+	CodeScheduled:            {Status: 409, Message: "The targets are governed by the schedule file."},
+	CodeOverloaded:           {Status: 503, Message: "Too many requests in flight."},
+	CodeNotReady:             {Status: 503, Message: "Not ready to serve requests."},
+	CodeChaos:                {Status: 500, Message: "Error injected by chaos mode."},
+	CodeTokenInvalid:         {Status: 401, Message: "The bearer token is malformed or badly signed."},
+	CodeTokenExpired:         {Status: 401, Message: "The bearer token has expired."},
+	CodeTokenRevoked:         {Status: 401, Message: "The bearer token has been revoked."},
+	CodeUnsupportedEncoding:  {Status: 415, Message: "The request body's Content-Encoding isn't supported."},
+	CodeDecompressedTooLarge: {Status: 413, Message: "The request body decompresses to more than allowed."},
+	CodeExportVersion:        {Status: 400, Message: "The export's version can't be imported."},
+	CodeNoReading:            {Status: 503, Message: "The sensor hasn't been read yet."},
+	CodeStaleReading:         {Status: 503, Message: "The sensor hasn't been read successfully for a while."},
+	CodeSignatureInvalid:     {Status: 401, Message: "The request signature is missing parts or doesn't match."},
+	CodeSignatureStale:       {Status: 401, Message: "The request signature's timestamp is too far from the server's clock."},
+	CodeNonceReused:          {Status: 401, Message: "The request signature's nonce has been used before."},
+	CodeNoManifestURL:        {Status: 503, Message: "No firmware update manifest is configured."},
+	CodeManifestUnreachable:  {Status: 503, Message: "The firmware update manifest couldn't be fetched."},
+	CodeSlugTaken:            {Status: 409, Message: "Another article has the slug."},
+	CodeFieldImmutable:       {Status: 400, Message: "A device field can't be changed by clients."},
+	CodeIdentifyActive:       {Status: 409, Message: "The device is identifying itself already."},
+	CodeIdentifyCooldown:     {Status: 409, Message: "The device identified itself too recently."},
+	CodeStreamUnsupported:    {Status: 500, Message: "The response can't be streamed through the server's middleware."},
+	CodeTooManySubscribers:   {Status: 503, Message: "Too many live clients are connected."},
+	CodeClaimed:              {Status: 409, Message: "The device is claimed already; reset it to claim it again."},
+	CodeOwnerRequired:        {Status: 401, Message: "The device is claimed; changes need its owner token."},
+	CodeTimesOverlap:         {Status: 400, Message: "The day and night times leave one of the periods empty."},
+}
+
+// codedError attaches an error code to an error.
+type codedError struct {
+	code ErrorCode
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+// withCode tags err with code, which ends up in the error response.
+func withCode(code ErrorCode, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+// sentinelCodes are the codes of errors that are compared by identity.
+var sentinelCodes = map[error]ErrorCode{
+	errBodyRequired:         CodeBodyRequired,
+	errVersionConflict:      CodeVersionConflict,
+	errVersionRequired:      CodeVersionRequired,
+	errScheduled:            CodeScheduled,
+	errChaos:                CodeChaos,
+	errTokenInvalid:         CodeTokenInvalid,
+	errTokenExpired:         CodeTokenExpired,
+	errTokenRevoked:         CodeTokenRevoked,
+	errDecompressedTooLarge: CodeDecompressedTooLarge,
+	errExportVersion:        CodeExportVersion,
+	errNoReading:            CodeNoReading,
+	errStaleReading:         CodeStaleReading,
+	errSigningDisabled:      CodeSignatureInvalid,
+	errSignatureInvalid:     CodeSignatureInvalid,
+	errSignatureStale:       CodeSignatureStale,
+	errNonceReused:          CodeNonceReused,
+	errNoManifestURL:        CodeNoManifestURL,
+	errManifestUnreachable:  CodeManifestUnreachable,
+	errIdentifying:          CodeIdentifyActive,
+	errIdentifyCooldown:     CodeIdentifyCooldown,
+	errSlugTaken:            CodeSlugTaken,
+	errNoFlush:              CodeStreamUnsupported,
+	errTooManySubscribers:   CodeTooManySubscribers,
+	errClaimed:              CodeClaimed,
+	errOwnerRequired:        CodeOwnerRequired,
+	errTimesOverlap:         CodeTimesOverlap,
+}
+
+// codeOf returns the code err carries, or fallback if it has none.
+func codeOf(err error, fallback ErrorCode) ErrorCode {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	for sentinel, code := range sentinelCodes {
+		if errors.Is(err, sentinel) {
+			return code
+		}
+	}
+	return fallback
+}
+
+// newErrResponse builds an error response with the most specific code
+// known for err, and the status and message the catalog has for it, or
+// for the general code if it has none.
+func newErrResponse(general ErrorCode, err error) *ErrResponse {
+	code := codeOf(err, general)
+	def, ok := errorCatalog[code]
+	if !ok {
+		def = errorCatalog[general]
+	}
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: def.Status,
+		StatusText:     def.Message,
+		AppCode:        code,
+		ErrorText:      err.Error(),
+	}
+}
+
+/**-----------------------------------------------------------------------------------
+ * get error catalog
+ * =================
+ * $ curl http://bangkokguy.ddns.net/rest/v1/errors
+ *   [{"code":"article.version_conflict","status":409,"message":"The article was modified concurrently."},...]
+ *------------------------------------------------------------------------------------*/
+
+// ListErrors serves the error catalog, ordered by code.
+func ListErrors(w http.ResponseWriter, r *http.Request) {
+	codes := make([]ErrorCode, 0, len(errorCatalog))
+	for code := range errorCatalog {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+
+	list := []render.Renderer{}
+	for _, code := range codes {
+		def := errorCatalog[code]
+		def.Code = code
+		list = append(list, &def)
+	}
+	if err := render.RenderList(w, r, list); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
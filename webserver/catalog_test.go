@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"testing"
+)
+
+func TestSentinelCodesAreCatalogued(t *testing.T) {
+	for err, code := range sentinelCodes {
+		if _, ok := errorCatalog[code]; !ok {
+			t.Errorf("%q has code %s, which isn't in the catalog", err, code)
+		}
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{"plain", errors.New("boom"), CodeInternal},
+		{"sentinel", errVersionConflict, CodeVersionConflict},
+		{"wrapped sentinel", fmt.Errorf("saving: %w", errVersionConflict), CodeVersionConflict},
+		{"tagged", withCode(CodeOutOfRange, errors.New("too hot")), CodeOutOfRange},
+		{"wrapped tag", fmt.Errorf("temp: %w", withCode(CodeOutOfRange, errors.New("too hot"))), CodeOutOfRange},
+		{"tagged sentinel", withCode(CodeNotAllowed, errVersionConflict), CodeNotAllowed},
+	} {
+		if got := codeOf(tc.err, CodeInternal); got != tc.want {
+			t.Errorf("%s: codeOf = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNewErrResponse(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		err        error
+		wantCode   ErrorCode
+		wantStatus int
+	}{
+		{"general", errors.New("bad"), CodeInvalidRequest, 400},
+		{"specific", fmt.Errorf("saving: %w", errVersionConflict), CodeVersionConflict, 409},
+		{"not in the catalog", withCode("test.uncatalogued", errors.New("bad")), "test.uncatalogued", 400},
+	} {
+		e := newErrResponse(CodeInvalidRequest, tc.err)
+		if e.AppCode != tc.wantCode || e.HTTPStatusCode != tc.wantStatus {
+			t.Errorf("%s: %s with %d, want %s with %d", tc.name, e.AppCode, e.HTTPStatusCode, tc.wantCode, tc.wantStatus)
+		}
+	}
+}
+
+func TestErrorResponsesCarryCodes(t *testing.T) {
+	h := newHarness(t, 20)
+
+	resp, body := h.Do(http.MethodGet, "/rest/v1/mode/history?cursor=!", nil)
+	var e struct {
+		Status string    `json:"status"`
+		Code   ErrorCode `json:"code"`
+	}
+	if err := json.Unmarshal(body, &e); err != nil {
+		t.Fatalf("%s: %s", err, body)
+	}
+	if resp.StatusCode != http.StatusBadRequest || e.Code != CodeBadFormat || e.Status != errorCatalog[CodeBadFormat].Message {
+		t.Errorf("a bad cursor: %s %s, want 400 with code %s", resp.Status, body, CodeBadFormat)
+	}
+
+	var list []struct {
+		Code   ErrorCode `json:"code"`
+		Status int       `json:"status"`
+	}
+	h.GetJSON("/rest/v1/errors", &list)
+	if len(list) != len(errorCatalog) {
+		t.Errorf("GET /rest/v1/errors lists %d codes, want %d", len(list), len(errorCatalog))
+	}
+	if !sort.SliceIsSorted(list, func(i, j int) bool { return list[i].Code < list[j].Code }) {
+		t.Errorf("GET /rest/v1/errors isn't ordered by code")
+	}
+	for _, def := range list {
+		if def.Status != errorCatalog[def.Code].Status {
+			t.Errorf("%s listed with status %d, want %d", def.Code, def.Status, errorCatalog[def.Code].Status)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+var chaos = flag.Bool("chaos", false, "Inject random errors and delays, for testing clients")
+var chaosErrorRate = flag.Float64("chaos-error-rate", 0.1, "Share of requests failed with a 500 in -chaos mode")
+var chaosMaxDelay = flag.Duration("chaos-max-delay", 500*time.Millisecond, "Longest random delay added to requests in -chaos mode")
+var chaosSeed = flag.Int64("chaos-seed", 0, "Seed for -chaos mode, to replay the same errors and delays; random if 0")
+
+var errChaos = errors.New("injected by -chaos")
+
+// Chaos middleware delays every request by a random time up to maxDelay,
+// and then fails it with a 500 with probability errorRate. The same seed
+// gives the same sequence of delays and errors. Health checks are left
+// alone.
+func Chaos(errorRate float64, maxDelay time.Duration, seed int64) func(http.Handler) http.Handler {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	var mu sync.Mutex
+	rnd := rand.New(rand.NewSource(seed))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if healthPaths[routePath(r)] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			mu.Lock()
+			var delay time.Duration
+			if maxDelay > 0 {
+				delay = time.Duration(rnd.Int63n(int64(maxDelay) + 1))
+			}
+			fail := rnd.Float64() < errorRate
+			mu.Unlock()
+
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+			if fail {
+				render.Render(w, r, ErrInternal(errChaos))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
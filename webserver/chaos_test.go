@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChaos(t *testing.T) {
+	const seed, rate, maxDelay = 42, 0.3, 2 * time.Millisecond
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	// Replay the draws Chaos makes per request from the same seed.
+	rnd := rand.New(rand.NewSource(seed))
+	h := Chaos(rate, maxDelay, seed)(ok)
+	failed := 0
+	for i := 0; i < 50; i++ {
+		delay := time.Duration(rnd.Int63n(int64(maxDelay) + 1))
+		fail := rnd.Float64() < rate
+
+		rec := httptest.NewRecorder()
+		start := time.Now()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rest/v1/temp", nil))
+		if elapsed := time.Since(start); elapsed < delay {
+			t.Errorf("request %d took %s, want a delay of at least %s", i, elapsed, delay)
+		}
+		switch {
+		case fail && (rec.Code != http.StatusInternalServerError || !jsonHasCode(rec.Body.Bytes(), CodeChaos)):
+			t.Errorf("request %d: %d %s, want an injected 500", i, rec.Code, rec.Body)
+		case !fail && rec.Code != http.StatusNoContent:
+			t.Errorf("request %d: %d %s, want it passed on", i, rec.Code, rec.Body)
+		}
+		if fail {
+			failed++
+		}
+	}
+	if failed == 0 || failed == 50 {
+		t.Errorf("%d of 50 requests failed at a rate of %g", failed, rate)
+	}
+
+	// Health checks are never delayed or failed.
+	h = Chaos(1, time.Hour, seed)(ok)
+	for _, path := range []string{"/livez", "/readyz", "/metrics"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNoContent {
+			t.Errorf("GET %s: %d, want it passed on", path, rec.Code)
+		}
+	}
+}
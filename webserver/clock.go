@@ -0,0 +1,29 @@
+package main
+
+import "time"
+
+// Clock tells the time to the parts of the server that act on it, the
+// evaluator and the schedule, so they can be driven by a fake clock.
+type Clock interface {
+	Now() time.Time
+	// NewTicker returns a channel that delivers the time every d, and a
+	// function that stops it.
+	NewTicker(d time.Duration) (<-chan time.Time, func())
+}
+
+var clock Clock = realClock{}
+
+type realClock struct{}
+
+// Now is in the time zone of -timezone, if set.
+func (realClock) Now() time.Time {
+	if loc := zone.Load(); loc != nil {
+		return time.Now().In(loc)
+	}
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"golang.org/x/sync/singleflight"
+)
+
+var collapsed singleflight.Group
+
+// recordedResponse is a response captured to be replayed to every caller
+// that shared it.
+type recordedResponse struct {
+	header http.Header
+	status int
+	body   []byte
+}
+
+// collapseKey identifies the requests that may share a response: the same
+// method, path and query, asking for the same indentation, and with the
+// same credentials, so no one gets a response made for someone else. The
+// credentials go in hashed.
+func collapseKey(r *http.Request) string {
+	creds := sha256.New()
+	for _, name := range []string{"Authorization", "Cookie", "X-Signature"} {
+		io.WriteString(creds, r.Header.Get(name))
+		creds.Write([]byte{0})
+	}
+	return fmt.Sprintf("%s %s?%s %t %x", r.Method, r.URL.Path, r.URL.RawQuery, wantsPretty(r), creds.Sum(nil))
+}
+
+// Collapse middleware merges identical concurrent GET requests: while one
+// is being handled, others with the same collapseKey wait for it and get
+// a copy of its response, so an expensive handler runs once. The handler doesn't end
+// with the request it's run for, as the others still wait for it; each
+// waits only until it's done itself.
+func Collapse(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		shared := r.WithContext(context.WithoutCancel(r.Context()))
+		done := collapsed.DoChan(collapseKey(r), func() (interface{}, error) {
+			buf := &bufferedWriter{header: http.Header{}, status: http.StatusOK}
+			next.ServeHTTP(buf, shared)
+			return &recordedResponse{header: buf.header, status: buf.status, body: buf.body.Bytes()}, nil
+		})
+		var res singleflight.Result
+		select {
+		case res = <-done:
+		case <-r.Context().Done():
+			render.Render(w, r, ErrTimeout(r.Context().Err()))
+			return
+		}
+
+		resp := res.Val.(*recordedResponse)
+		for k, vv := range resp.header {
+			w.Header()[k] = append([]string(nil), vv...)
+		}
+		w.WriteHeader(resp.status)
+		w.Write(resp.body)
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCollapseKeepsCredentialsApart(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	h := Collapse(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+
+	auths := []string{"Bearer alice", "Bearer alice", "Bearer bob", ""}
+	bodies := make([]string, len(auths))
+	var wg sync.WaitGroup
+	for i, auth := range auths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/rest/v1/status", nil)
+			if auth != "" {
+				req.Header.Set("Authorization", auth)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			bodies[i] = rec.Body.String()
+		}()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for calls.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond) // let the second alice join hers
+	close(release)
+	wg.Wait()
+
+	for i, auth := range auths {
+		if bodies[i] != auth {
+			t.Errorf("request with Authorization %q got the response for %q", auth, bodies[i])
+		}
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("the handler ran %d times for 3 distinct credentials, want 3", n)
+	}
+}
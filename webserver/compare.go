@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+/**-----------------------------------------------------------------------------------
+ * compare settings
+ * ================
+ * $ curl -X POST -H 'Content-Type: application/json' -d '{"night":"23:00","thereshold":"0.50","window":"24h"}' http://bangkokguy.ddns.net/rest/v1/temp/compare
+ *   {"window":"24h","from":"...","to":"...","samples":8640,
+ *    "current":{"heating_on_seconds":14400,"switches":12,"below_target_seconds":1800,"kwh":96,"cost":28.8},
+ *    "candidate":{"heating_on_seconds":12600,"switches":6,"below_target_seconds":2700,"kwh":84,"cost":25.2}}
+ *------------------------------------------------------------------------------------*/
+
+// CompareRequest is a candidate set of times, targets and threshold. What
+// it leaves out is taken from the current settings. Window is how far
+// back the temperature history is replayed, 24h if empty.
+type CompareRequest struct {
+	Times
+	Temp
+	Window string `json:"window,omitempty"`
+}
+
+func (c *CompareRequest) Bind(r *http.Request) error {
+	if err := c.Times.Bind(r); err != nil {
+		return err
+	}
+	return c.Temp.Bind(r)
+}
+
+// CompareResult is how the heating would have behaved with a set of
+// settings. BelowTargetSeconds is how long the room was under the target
+// less the threshold, a measure of comfort.
+type CompareResult struct {
+	HeatingOnSeconds   float64 `json:"heating_on_seconds"`
+	Switches           int     `json:"switches"`
+	BelowTargetSeconds float64 `json:"below_target_seconds"`
+	KWh                float64 `json:"kwh"`
+	Cost               float64 `json:"cost"`
+}
+
+// Comparison puts the current settings and a candidate side by side.
+type Comparison struct {
+	Window    string        `json:"window"`
+	From      time.Time     `json:"from"`
+	To        time.Time     `json:"to"`
+	Samples   int           `json:"samples"`
+	Current   CompareResult `json:"current"`
+	Candidate CompareResult `json:"candidate"`
+}
+
+func (c *Comparison) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// replay runs the thermostat with times and temp, in Celsius, over the
+// samples (oldest first), up to to. Each sample holds until the next one.
+// The readings are the ones recorded, so the estimate leaves out how
+// differently heating would have warmed the room.
+func replay(samples []Sample, to time.Time, times *Times, temp *Temp) (CompareResult, error) {
+	var res CompareResult
+	threshold, err := strconv.ParseFloat(string(temp.Thereshold), 64)
+	if err != nil {
+		return res, fmt.Errorf("thereshold: %w", err)
+	}
+
+	heating := "off"
+	for i, s := range samples {
+		day, err := resolveDayTime(times.Day, s.At)
+		if err != nil {
+			return res, err
+		}
+		night, err := resolveDayTime(times.Night, s.At)
+		if err != nil {
+			return res, err
+		}
+		target := temp.NightTemp
+		if schedulePhase(s.At, day, night) == "day" {
+			target = temp.DayTemp
+		}
+		if h, _ := thermostat(s.Temp, target, temp.Thereshold); h != "" && h != heating {
+			heating = h
+			res.Switches++
+		}
+
+		until := to
+		if i+1 < len(samples) {
+			until = samples[i+1].At
+		}
+		held := until.Sub(s.At).Seconds()
+		if heating == "on" {
+			res.HeatingOnSeconds += held
+		}
+		if t, err := strconv.ParseFloat(string(target), 64); err == nil && s.Temp < t-threshold {
+			res.BelowTargetSeconds += held
+		}
+	}
+	res.KWh = round2(res.HeatingOnSeconds / 3600 * *boilerKW)
+	res.Cost = round2(res.KWh * *tariff)
+	return res, nil
+}
+
+// CompareSettings replays the temperature history with the current
+// settings and with a candidate, without applying the candidate, to see
+// how it would have changed the heating.
+func CompareSettings(w http.ResponseWriter, r *http.Request) {
+	unit, err := requestUnit(r)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	times, err := storeOf(r.Context()).GetTime()
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	temp, err := storeOf(r.Context()).GetTemp()
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+
+	// The candidate starts out as the current settings, in the unit the
+	// request's values are in.
+	data := &CompareRequest{Times: *times, Temp: *temp}
+	data.Temp.convert("C", unit)
+	if err := decode(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	window := 24 * time.Hour
+	if data.Window != "" {
+		window, err = time.ParseDuration(data.Window)
+		if err != nil || window <= 0 {
+			render.Render(w, r, ErrInvalidRequest(errors.New("window must be a positive duration like 6h")))
+			return
+		}
+	}
+
+	now := clockOf(r.Context()).Now()
+	samples := tempHistory.Since(now.Add(-window))
+	c := &Comparison{Window: window.String(), From: now.Add(-window), To: now, Samples: len(samples)}
+	if c.Current, err = replay(samples, now, times, temp); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if c.Candidate, err = replay(samples, now, &data.Times, &data.Temp); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if err := render.Render(w, r, c); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
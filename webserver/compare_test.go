@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestReplay(t *testing.T) {
+	at := func(hour int) time.Time { return harnessStart.Truncate(24 * time.Hour).Add(time.Duration(hour) * time.Hour) }
+	samples := []Sample{{at(5), 17}, {at(6), 23}, {at(7), 24.5}}
+	temp := &Temp{DayTemp: "24.00", NightTemp: "18.00", Thereshold: "0.20"}
+
+	for _, tc := range []struct {
+		day  string
+		want CompareResult
+	}{
+		// Heating from 05:00, below the night then the day target, until
+		// the room is warm at 07:00.
+		{"06:00", CompareResult{HeatingOnSeconds: 7200, Switches: 2, BelowTargetSeconds: 7200, KWh: 48, Cost: 14.4}},
+		// With the day starting later, 23C at 06:00 is warm enough.
+		{"07:00", CompareResult{HeatingOnSeconds: 3600, Switches: 2, BelowTargetSeconds: 3600, KWh: 24, Cost: 7.2}},
+	} {
+		got, err := replay(samples, at(8), &Times{Day: tc.day, Night: "22:00"}, temp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("day at %s: %+v\nwant %+v", tc.day, got, tc.want)
+		}
+	}
+}
+
+func TestCompareSettings(t *testing.T) {
+	h := newHarness(t, 17)
+	h.SetReading(23)
+	h.Advance(time.Hour)
+	h.SetReading(24.5)
+	h.Advance(time.Hour)
+	h.Advance(time.Hour)
+
+	resp, body := h.Do(http.MethodPost, "/rest/v1/temp/compare", map[string]string{"day": "07:00", "window": "4h"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /rest/v1/temp/compare: %s %s", resp.Status, body)
+	}
+	var c Comparison
+	if err := json.Unmarshal(body, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Window != "4h0m0s" || c.Samples != 4 || c.Current.HeatingOnSeconds != 7200 || c.Candidate.HeatingOnSeconds != 3600 {
+		t.Errorf("compared %+v", c)
+	}
+	if temp, _ := h.Store.GetTemp(); temp.DayTemp != "24.00" {
+		t.Errorf("comparing changed the day temp to %s", temp.DayTemp)
+	}
+
+	for _, window := range []string{"-1h", "a day"} {
+		if resp, _ := h.Do(http.MethodPost, "/rest/v1/temp/compare", map[string]string{"window": window}); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("window %q: %s, want 400", window, resp.Status)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/go-chi/render"
+)
+
+/**-----------------------------------------------------------------------------------
+ * get/patch config
+ * ================
+ * $ curl http://bangkokguy.ddns.net/rest/v1/config
+ *   {"day":"06:00","night":"22:00","daytemp":"24.00","nighttemp":"18.00","thereshold":"0.20","mode":"auto","heating":"auto"}
+ * $ curl -X PATCH -H 'Content-Type: application/merge-patch+json' -d '{"daytemp":22,"heating":null}' http://bangkokguy.ddns.net/rest/v1/config
+ *   {"day":"06:00","night":"22:00","daytemp":"22.00","nighttemp":"18.00","thereshold":"0.20","mode":"auto","heating":"auto"}
+ *------------------------------------------------------------------------------------*/
+
+// Config combines the temperature, time and mode settings in one resource.
+// Temperatures are in Celsius.
+type Config struct {
+	Day        string    `json:"day"`
+	Night      string    `json:"night"`
+	DayTemp    TempValue `json:"daytemp"`
+	NightTemp  TempValue `json:"nighttemp"`
+	Thereshold TempValue `json:"thereshold"`
+	Mode       string    `json:"mode"`    // "auto", "day" or "night"
+	Heating    string    `json:"heating"` // "auto", "on" or "off"
+}
+
+func (c *Config) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func loadConfig(ctx context.Context) (*Config, error) {
+	s, err := snapshotState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Config{
+		Day:        s.Day,
+		Night:      s.Night,
+		DayTemp:    TempValue(s.DayTemp),
+		NightTemp:  TempValue(s.NightTemp),
+		Thereshold: TempValue(s.Thereshold),
+		Mode:       s.Mode,
+		Heating:    s.Heating,
+	}, nil
+}
+
+// state is the config as persisted state, with the given forced phase.
+func (c *Config) state(forced string) State {
+	return State{
+		Day:        c.Day,
+		Night:      c.Night,
+		DayTemp:    string(c.DayTemp),
+		NightTemp:  string(c.NightTemp),
+		Thereshold: string(c.Thereshold),
+		Mode:       c.Mode,
+		Heating:    c.Heating,
+		Forced:     forced,
+	}
+}
+
+// validate checks a config before it's committed. A mode or heating
+// cleared with null goes back to "auto"; everything else is required.
+func (c *Config) validate() error {
+	if c.Mode == "" {
+		c.Mode = "auto"
+	}
+	if c.Heating == "" {
+		c.Heating = "auto"
+	}
+
+	if err := checkDayTime(c.Day); err != nil {
+		return fmt.Errorf("day: %w", err)
+	}
+	if err := checkDayTime(c.Night); err != nil {
+		return fmt.Errorf("night: %w", err)
+	}
+	if c.DayTemp == "" || c.NightTemp == "" || c.Thereshold == "" {
+		return errors.New("daytemp, nighttemp and thereshold are required")
+	}
+	switch c.Mode {
+	case "auto", "day", "night":
+	default:
+		return errors.New("mode must be auto, day or night")
+	}
+	switch c.Heating {
+	case "auto", "on", "off":
+	default:
+		return errors.New("heating must be auto, on or off")
+	}
+	return checkSetpoints(&Temp{DayTemp: c.DayTemp, NightTemp: c.NightTemp})
+}
+
+func GetConfig(w http.ResponseWriter, r *http.Request) {
+	config, err := loadConfig(r.Context())
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if err := render.Render(w, r, config); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// PatchConfig applies a JSON Merge Patch (RFC 7386) to the config: fields
+// in the patch replace the current ones, null clears them, and fields left
+// out stay as they are. The merged config is validated as a whole before
+// anything is stored.
+func PatchConfig(w http.ResponseWriter, r *http.Request) {
+	if err := requireBody(r); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	var patch interface{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&patch); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if _, err := applyConfigPatch(r.Context(), patch, requestSource(r)); err != nil {
+		if errors.Is(err, errScheduled) {
+			render.Render(w, r, ErrConflict(err))
+		} else {
+			render.Render(w, r, ErrInvalidRequest(err))
+		}
+		return
+	}
+
+	GetConfig(w, r)
+}
+
+// configMu serializes config patches and imports, so they don't overwrite
+// each other's changes.
+var configMu sync.Mutex
+
+// applyConfigPatch merges the patch into the config, stores the result and
+// announces it as changed by source.
+func applyConfigPatch(ctx context.Context, patch interface{}, source string) (*Config, error) {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	current, err := loadConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	patch = canonicalKeys(patch, reflect.TypeOf(Config{}))
+	var doc interface{}
+	data, err := json.Marshal(current)
+	if err == nil {
+		err = json.Unmarshal(data, &doc)
+	}
+	if err == nil {
+		data, err = json.Marshal(mergePatch(doc, patch))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &Config{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(merged); err != nil {
+		return nil, err
+	}
+	if err := merged.validate(); err != nil {
+		return nil, err
+	}
+	if err := commitConfig(ctx, current, merged, forcedPhase(), source); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// commitConfig stores next, a validated config, in place of current along
+// with the forced phase, and announces it as changed by source. The caller
+// holds configMu.
+func commitConfig(ctx context.Context, current, next *Config, forced, source string) error {
+	if activeSchedule() != nil && (next.Day != current.Day || next.Night != current.Night ||
+		next.DayTemp != current.DayTemp || next.NightTemp != current.NightTemp) {
+		return errScheduled
+	}
+
+	if err := applyState(ctx, next.state(forced)); err != nil {
+		return err
+	}
+	rampTargets(&Temp{DayTemp: current.DayTemp, NightTemp: current.NightTemp},
+		&Temp{DayTemp: next.DayTemp, NightTemp: next.NightTemp}, clockOf(ctx).Now())
+	persistState(ctx)
+	evaluate(ctx, clockOf(ctx).Now())
+	publishConfig(ctx, source)
+	return nil
+}
+
+// mergePatch applies an RFC 7386 merge patch to target, which are both
+// decoded JSON values.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
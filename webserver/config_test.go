@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	// Cases from RFC 7386, appendix A.
+	for _, c := range []struct{ target, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	} {
+		var target, patch, want interface{}
+		for i, s := range []string{c.target, c.patch, c.want} {
+			if err := json.Unmarshal([]byte(s), []*interface{}{&target, &patch, &want}[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := mergePatch(target, patch); !reflect.DeepEqual(got, want) {
+			t.Errorf("mergePatch(%s, %s) = %v, want %s", c.target, c.patch, got, c.want)
+		}
+	}
+}
+
+func TestPatchConfig(t *testing.T) {
+	h := newHarness(t, 20)
+	asPatch := []string{"Content-Type", "application/merge-patch+json"}
+	var before Config
+	h.GetJSON("/rest/v1/config", &before)
+
+	// Fields in the patch are changed and the rest kept; null clears the
+	// heating back to auto.
+	resp, body := h.Do(http.MethodPut, "/rest/v1/mode", map[string]string{"mode": "auto", "heating": "off"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /rest/v1/mode: %s %s", resp.Status, body)
+	}
+	resp, body = h.Do(http.MethodPatch, "/rest/v1/config", map[string]interface{}{"daytemp": 22, "heating": nil}, asPatch...)
+	var patched Config
+	if err := json.Unmarshal(body, &patched); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("PATCH /rest/v1/config: %s %s", resp.Status, body)
+	}
+	want := before
+	want.DayTemp, want.Heating = "22.00", "auto"
+	if patched != want {
+		t.Errorf("patched config %+v, want %+v", patched, want)
+	}
+
+	// Required fields can't be cleared, and a rejected patch changes
+	// nothing.
+	for _, patch := range []map[string]interface{}{
+		{"daytemp": 23, "day": nil},
+		{"nighttemp": nil},
+		{"mode": "sometimes"},
+		{"daytemp": 23, "colour": "red"},
+	} {
+		if resp, body := h.Do(http.MethodPatch, "/rest/v1/config", patch, asPatch...); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("PATCH %v: %s %s, want 400", patch, resp.Status, body)
+		}
+	}
+	var after Config
+	if h.GetJSON("/rest/v1/config", &after); after != patched {
+		t.Errorf("after the rejected patches: %+v, want %+v", after, patched)
+	}
+
+	// A merge patch has to say so.
+	if resp, body := h.Do(http.MethodPatch, "/rest/v1/config", map[string]interface{}{"daytemp": 23}); resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("PATCH as application/json: %s %s, want 415", resp.Status, body)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireContentType(t *testing.T) {
+	h := RequireContentType("application/json")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tc := range []struct {
+		contentType string
+		body        string
+		want        int
+	}{
+		{"application/json", `{}`, http.StatusOK},
+		{"application/json; charset=utf-8", `{}`, http.StatusOK},
+		{"Application/JSON", `{}`, http.StatusOK},
+		{"", "", http.StatusOK},
+		{"", `{}`, http.StatusUnsupportedMediaType},
+		{"text/plain", `{}`, http.StatusUnsupportedMediaType},
+		{"application/x-www-form-urlencoded", "daytemp=24", http.StatusUnsupportedMediaType},
+		{"application/json;;", `{}`, http.StatusUnsupportedMediaType},
+	} {
+		r := httptest.NewRequest(http.MethodPut, "/rest/v1/temp", strings.NewReader(tc.body))
+		if tc.contentType != "" {
+			r.Header.Set("Content-Type", tc.contentType)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != tc.want {
+			t.Errorf("PUT with Content-Type %q and body %q: %d, want %d", tc.contentType, tc.body, rec.Code, tc.want)
+		}
+		if tc.want == http.StatusUnsupportedMediaType && !jsonHasCode(rec.Body.Bytes(), CodeUnsupportedMediaType) {
+			t.Errorf("PUT with Content-Type %q: %s, want code %s", tc.contentType, rec.Body, CodeUnsupportedMediaType)
+		}
+	}
+}
+
+func TestPutFormBody(t *testing.T) {
+	h := newHarness(t, 20)
+	req, err := http.NewRequest(http.MethodPut, h.Server.URL+"/rest/v1/temp", strings.NewReader("daytemp=24&nighttemp=18&thereshold=0.2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := h.Server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("PUT /rest/v1/temp with a form body: %s, want 415", resp.Status)
+	}
+}
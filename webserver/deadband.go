@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+/**-----------------------------------------------------------------------------------
+ * get deadband
+ * ============
+ * $ curl http://bangkokguy.ddns.net/rest/v1/temp/deadband?window=1h&precision=2
+ *   {"window":"1h0m0s","unit":"C","target":21,"threshold":0.2,"on_below":20.8,"off_above":21.2,
+ *    "phase":"day","heating":"on","current":20.75,"readings":[{"at":"...","temp":20.7},{"at":"...","temp":20.75}]}
+ *------------------------------------------------------------------------------------*/
+
+// Deadband is what a graph of the thermostat's hysteresis band needs: the
+// target of the current phase, with the lines the heating switches on
+// below and off above, and the readings over the window, all in Unit.
+type Deadband struct {
+	Window    string   `json:"window"`
+	Unit      string   `json:"unit"`
+	Target    float64  `json:"target"`
+	Threshold float64  `json:"threshold"`
+	OnBelow   float64  `json:"on_below"`
+	OffAbove  float64  `json:"off_above"`
+	Phase     string   `json:"phase"`
+	Heating   string   `json:"heating"`
+	Current   *float64 `json:"current,omitempty"` // the latest reading, if it isn't missing or stale
+	Readings  []Sample `json:"readings"`
+}
+
+func (d *Deadband) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// newDeadband works out the band around target, with threshold, both in
+// Celsius, and converts it and the readings to unit. The band is where
+// thermostat leaves the heating as it is. The readings are rounded to
+// decimals; the band, which comes from the settings, to two.
+func newDeadband(target, threshold float64, readings []Sample, unit string, decimals int) *Deadband {
+	d := &Deadband{
+		Unit:      unit,
+		Target:    convertFloat(target, unit, false, 2),
+		Threshold: convertFloat(threshold, unit, true, 2),
+		OnBelow:   convertFloat(target-threshold, unit, false, 2),
+		OffAbove:  convertFloat(target+threshold, unit, false, 2),
+		Readings:  make([]Sample, len(readings)),
+	}
+	for i, s := range readings {
+		d.Readings[i] = Sample{At: s.At, Temp: convertFloat(s.Temp, unit, false, decimals)}
+	}
+	return d
+}
+
+// GetDeadband serves the band over ?window= (an hour by default), in the
+// unit of ?unit=, with the readings to ?precision= decimals.
+func GetDeadband(w http.ResponseWriter, r *http.Request) {
+	window := time.Hour
+	if s := r.URL.Query().Get("window"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			render.Render(w, r, ErrInvalidRequest(errors.New("window must be a positive duration like 6h")))
+			return
+		}
+		window = d
+	}
+	unit, err := requestUnit(r)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	precision, err := requestPrecision(r)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	modes, err := storeOf(r.Context()).GetMode()
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	temp, err := storeOf(r.Context()).GetTemp()
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	now := clockOf(r.Context()).Now()
+	target, err := strconv.ParseFloat(string(phaseTarget(temp, modes.Mode[0], forcedPhase(), now)), 64)
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	threshold, err := strconv.ParseFloat(string(temp.Thereshold), 64)
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+
+	d := newDeadband(target, threshold, tempHistory.Since(now.Add(-window)), unit, precision)
+	d.Window, d.Phase, d.Heating = window.String(), modes.Mode[0], modes.Heating[0]
+	if reading, err := latestReading(r.Context()); err == nil {
+		current := convertFloat(reading.Temp, unit, false, precision)
+		d.Current = &current
+	}
+	if err := render.Render(w, r, d); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
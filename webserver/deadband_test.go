@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestDeadbandBand(t *testing.T) {
+	for _, tc := range []struct {
+		unit                            string
+		target, threshold, below, above float64
+	}{
+		{"C", 21, 0.2, 20.8, 21.2},
+		{"F", 69.8, 0.36, 69.44, 70.16},
+	} {
+		d := newDeadband(21, 0.2, nil, tc.unit, 1)
+		if d.Target != tc.target || d.Threshold != tc.threshold {
+			t.Errorf("%s: target %g, threshold %g; want %g, %g", tc.unit, d.Target, d.Threshold, tc.target, tc.threshold)
+		}
+		if d.OnBelow != tc.below || d.OffAbove != tc.above {
+			t.Errorf("%s: band %g to %g, want %g to %g", tc.unit, d.OnBelow, d.OffAbove, tc.below, tc.above)
+		}
+	}
+}
+
+// The band is where thermostat leaves the heating as it is.
+func TestDeadbandMatchesThermostat(t *testing.T) {
+	d := newDeadband(21, 0.2, nil, "C", 2)
+	for _, tc := range []struct {
+		current float64
+		want    string
+	}{
+		{d.OnBelow - 0.01, "on"},
+		{d.OnBelow + 0.01, ""},
+		{d.OffAbove - 0.01, ""},
+		{d.OffAbove + 0.01, "off"},
+	} {
+		if heating, _ := thermostat(tc.current, "21", "0.2"); heating != tc.want {
+			t.Errorf("thermostat at %g: %q, want %q", tc.current, heating, tc.want)
+		}
+	}
+}
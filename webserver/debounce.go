@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"sync"
+	"time"
+)
+
+var tempDebounce = flag.Duration("temp-debounce", 0, "Window in which successive PUTs to /temp are coalesced, each answered with a 202 and only the last one applied; 0 applies each right away")
+
+// Debouncer runs the last of a burst of submitted functions, once none has
+// been submitted for its window.
+type Debouncer struct {
+	window time.Duration
+
+	run     sync.Mutex // held while running, so runs don't overlap
+	mu      sync.Mutex
+	timer   *time.Timer
+	pending func()
+}
+
+func NewDebouncer(window time.Duration) *Debouncer {
+	return &Debouncer{window: window}
+}
+
+// Submit replaces the pending function with fn and restarts the window.
+func (d *Debouncer) Submit(fn func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending = fn
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.timer = time.AfterFunc(d.window, d.Flush)
+}
+
+// Flush runs the pending function, if any, right away.
+func (d *Debouncer) Flush() {
+	d.run.Lock()
+	defer d.run.Unlock()
+
+	d.mu.Lock()
+	fn := d.pending
+	d.pending = nil
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.mu.Unlock()
+
+	if fn != nil {
+		fn()
+	}
+}
+
+var tempWrites = NewDebouncer(0)
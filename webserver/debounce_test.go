@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDebouncerRunsTheLast(t *testing.T) {
+	d := NewDebouncer(50 * time.Millisecond)
+	var last, runs atomic.Int64
+	for i := 1; i <= 3; i++ {
+		i := i
+		d.Submit(func() { last.Store(int64(i)); runs.Add(1) })
+	}
+	if runs.Load() != 0 {
+		t.Fatalf("ran within the window")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if runs.Load() != 1 || last.Load() != 3 {
+		t.Errorf("%d runs, the last of #%d, want the third submitted only", runs.Load(), last.Load())
+	}
+
+	d.Submit(func() { runs.Add(1) })
+	d.Flush()
+	d.Flush()
+	if runs.Load() != 2 {
+		t.Errorf("%d runs after a Flush, want the pending one run once", runs.Load())
+	}
+	time.Sleep(100 * time.Millisecond)
+	if runs.Load() != 2 {
+		t.Errorf("the flushed function ran again when the window ended")
+	}
+}
+
+func TestTempDebounce(t *testing.T) {
+	h := newHarness(t, 20)
+	withFlag(t, tempDebounce, time.Hour)
+	withFlag(t, &tempWrites, NewDebouncer(time.Hour))
+
+	for _, day := range []string{"21", "22", "23"} {
+		resp, body := h.Do(http.MethodPut, "/rest/v1/temp", map[string]string{"daytemp": day, "nighttemp": "18", "thereshold": "0.2"})
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("PUT /rest/v1/temp within the window: %s %s, want 202", resp.Status, body)
+		}
+	}
+	if temp, _ := h.Store.GetTemp(); temp.DayTemp != "24.00" {
+		t.Errorf("day temp %s within the window, want 24.00 left alone", temp.DayTemp)
+	}
+	tempWrites.Flush()
+	if temp, _ := h.Store.GetTemp(); temp.DayTemp != "23.00" {
+		t.Errorf("day temp %s after the window, want the last PUT's 23.00", temp.DayTemp)
+	}
+}
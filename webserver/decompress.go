@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+var maxDecompressed = flag.Int64("max-decompressed-bytes", 1<<20, "Largest request body accepted after decompressing a gzip or deflate body")
+
+var errDecompressedTooLarge = errors.New("decompressed body too large")
+
+// DecompressBody middleware decompresses request bodies sent with a gzip
+// or deflate Content-Encoding, so handlers see them as if they had been
+// sent plain. Bodies that decompress to more than max bytes are rejected
+// with a 413, and other encodings with a 415.
+func DecompressBody(max int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+			if encoding == "" || encoding == "identity" || r.Body == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var zr io.ReadCloser
+			var err error
+			switch encoding {
+			case "gzip", "x-gzip":
+				zr, err = gzip.NewReader(r.Body)
+			case "deflate":
+				zr, err = zlib.NewReader(r.Body)
+			default:
+				render.Render(w, r, ErrUnsupportedMediaType(withCode(CodeUnsupportedEncoding,
+					fmt.Errorf("unsupported Content-Encoding %q, must be gzip or deflate", encoding))))
+				return
+			}
+			if err != nil {
+				render.Render(w, r, ErrInvalidRequest(fmt.Errorf("%s body: %w", encoding, err)))
+				return
+			}
+			defer zr.Close()
+
+			data, err := io.ReadAll(io.LimitReader(zr, max+1))
+			if err != nil {
+				render.Render(w, r, ErrInvalidRequest(fmt.Errorf("%s body: %w", encoding, err)))
+				return
+			}
+			if int64(len(data)) > max {
+				render.Render(w, r, ErrTooLarge(errDecompressedTooLarge))
+				return
+			}
+
+			r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(data))
+			r.ContentLength = int64(len(data))
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func compressed(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	var w io.WriteCloser = gzip.NewWriter(&b)
+	if encoding == "deflate" {
+		w = zlib.NewWriter(&b)
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestDecompressBody(t *testing.T) {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "" {
+			t.Errorf("Content-Encoding %q passed on", r.Header.Get("Content-Encoding"))
+		}
+		io.Copy(w, r.Body)
+	})
+	handler := DecompressBody(64)(echo)
+	body := []byte(`{"daytemp":"22"}`)
+
+	for _, tc := range []struct {
+		name, encoding string
+		body           []byte
+		status         int
+		code           ErrorCode
+	}{
+		{"plain", "", body, http.StatusOK, ""},
+		{"gzip", "gzip", compressed(t, "gzip", body), http.StatusOK, ""},
+		{"x-gzip", "X-Gzip", compressed(t, "gzip", body), http.StatusOK, ""},
+		{"deflate", "deflate", compressed(t, "deflate", body), http.StatusOK, ""},
+		{"brotli", "br", body, http.StatusUnsupportedMediaType, CodeUnsupportedEncoding},
+		{"corrupt", "gzip", body, http.StatusBadRequest, CodeInvalidRequest},
+		{"bomb", "gzip", compressed(t, "gzip", bytes.Repeat([]byte(" "), 65)), http.StatusRequestEntityTooLarge, CodeDecompressedTooLarge},
+	} {
+		req := httptest.NewRequest(http.MethodPut, "/rest/v1/temp", bytes.NewReader(tc.body))
+		req.Header.Set("Content-Encoding", tc.encoding)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%s: %d %s, want %d", tc.name, rec.Code, rec.Body, tc.status)
+			continue
+		}
+		if tc.code == "" && rec.Body.String() != string(body) {
+			t.Errorf("%s: the handler got %q, want %q", tc.name, rec.Body, body)
+		}
+		if tc.code != "" && !jsonHasCode(rec.Body.Bytes(), tc.code) {
+			t.Errorf("%s: %s, want code %s", tc.name, strings.TrimSpace(rec.Body.String()), tc.code)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Deps are what the handlers registered by RegisterRoutes work against.
+// Those left nil are the package's own: the store, sensor and clock main
+// sets up, and the configuration of the flags.
+type Deps struct {
+	Store  Store
+	Sensor Sensor
+	Clock  Clock
+	Config *RouterConfig
+}
+
+// RouterConfig configures the middlewares RegisterRoutes puts in front of
+// the routes.
+type RouterConfig struct {
+	LogSample        string // as -log-sample
+	AccessLogLevels  string // as -access-log-levels
+	PanicAlerts      bool
+	PanicAlertWindow time.Duration
+	MaxURLLength     int
+	MaxQueryLength   int
+	MaxInFlight      int
+	Chaos            bool
+	ChaosErrorRate   float64
+	ChaosMaxDelay    time.Duration
+	ChaosSeed        int64
+	MaxDecompressed  int64
+}
+
+// flagRouterConfig returns the router configuration the flags set.
+func flagRouterConfig() *RouterConfig {
+	return &RouterConfig{
+		LogSample:        *logSample,
+		AccessLogLevels:  *accessLogLevels,
+		PanicAlerts:      *panicAlerts,
+		PanicAlertWindow: *panicAlertWindow,
+		MaxURLLength:     *maxURLLength,
+		MaxQueryLength:   *maxQueryLength,
+		MaxInFlight:      *maxInFlight,
+		Chaos:            *chaos,
+		ChaosErrorRate:   *chaosErrorRate,
+		ChaosMaxDelay:    *chaosMaxDelay,
+		ChaosSeed:        *chaosSeed,
+		MaxDecompressed:  *maxDecompressed,
+	}
+}
+
+type depsCtxKey struct{}
+
+// provideDeps middleware puts deps on the request's context, where
+// storeOf, sensorOf and clockOf find them.
+func provideDeps(deps Deps) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), depsCtxKey{}, &deps)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func depsOf(ctx context.Context) *Deps {
+	deps, _ := ctx.Value(depsCtxKey{}).(*Deps)
+	if deps == nil {
+		return &Deps{}
+	}
+	return deps
+}
+
+// storeOf returns the store of the request ctx belongs to, or the
+// package's if ctx isn't a request's, as for the background workers.
+func storeOf(ctx context.Context) Store {
+	if s := depsOf(ctx).Store; s != nil {
+		return s
+	}
+	return store
+}
+
+// sensorOf returns the sensor the way storeOf returns the store.
+func sensorOf(ctx context.Context) Sensor {
+	if s := depsOf(ctx).Sensor; s != nil {
+		return s
+	}
+	return sensor
+}
+
+// clockOf returns the clock the way storeOf returns the store.
+func clockOf(ctx context.Context) Clock {
+	if c := depsOf(ctx).Clock; c != nil {
+		return c
+	}
+	return clock
+}
+
+// routePath returns the path of r below where the routes are mounted: a
+// router mounting them under a prefix leaves chi's RoutePath set to the
+// rest of the path. Middlewares that treat some routes apart go by it.
+func routePath(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePath != "" {
+		return rctx.RoutePath
+	}
+	return r.URL.Path
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+// ResourceDoc is the self-description a resource returns on OPTIONS,
+// listing the methods it supports and the shape of its payloads.
+type ResourceDoc struct {
+	Methods  []string   `json:"methods"`
+	Request  []FieldDoc `json:"request,omitempty"`
+	Response []FieldDoc `json:"response,omitempty"`
+}
+
+// FieldDoc describes one JSON field of a request or response payload.
+type FieldDoc struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func (rd *ResourceDoc) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// Describe returns an OPTIONS handler for a resource supporting the given
+// methods. The request and response payloads may be nil when the resource
+// doesn't accept or return a body.
+func Describe(methods []string, request, response interface{}) http.HandlerFunc {
+	doc := &ResourceDoc{
+		Methods:  append(append([]string{}, methods...), http.MethodOptions),
+		Request:  describeFields(request),
+		Response: describeFields(response),
+	}
+	allow := strings.Join(doc.Methods, ", ")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		if err := render.Render(w, r, doc); err != nil {
+			render.Render(w, r, ErrRender(err))
+			return
+		}
+	}
+}
+
+// describeFields reflects over a payload struct and lists its JSON fields
+// the way encoding/json would see them, flattening embedded structs.
+func describeFields(v interface{}) []FieldDoc {
+	if v == nil {
+		return nil
+	}
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	// Fields declared directly on the struct shadow promoted ones of the
+	// same name, e.g. ArticleRequest.ProtectedID over Article.ID.
+	direct := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		if name := fieldName(t.Field(i)); name != "" {
+			direct[name] = true
+		}
+	}
+
+	var fields []FieldDoc
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if name := fieldName(f); name != "" {
+			fields = append(fields, FieldDoc{Name: name, Type: jsonType(f.Type)})
+			continue
+		}
+		if !f.Anonymous {
+			continue
+		}
+		for _, e := range describeFields(reflect.Zero(f.Type).Interface()) {
+			if !direct[e.Name] {
+				fields = append(fields, e)
+			}
+		}
+	}
+	return fields
+}
+
+// fieldName returns the JSON name of a non-embedded exported field, or ""
+// if the field is embedded, unexported or skipped.
+func fieldName(f reflect.StructField) string {
+	name, _ := parseJSONTag(f.Tag.Get("json"))
+	if name == "-" || f.PkgPath != "" || (f.Anonymous && name == "") {
+		return ""
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name
+}
+
+func parseJSONTag(tag string) (name, opts string) {
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
+
+// jsonType maps a Go type to the JSON type it is encoded as.
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
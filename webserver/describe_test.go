@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	h := newHarness(t, 20)
+	temp := []FieldDoc{
+		{"currenttemp", "string"},
+		{"nighttemp", "string"},
+		{"daytemp", "string"},
+		{"thereshold", "string"},
+		{"unit", "string"},
+	}
+	for _, tc := range []struct {
+		path  string
+		allow string
+		want  ResourceDoc
+	}{
+		{"/rest/v1/temp", "GET, HEAD, PUT, OPTIONS", ResourceDoc{
+			Methods:  []string{"GET", "HEAD", "PUT", "OPTIONS"},
+			Request:  temp,
+			Response: temp,
+		}},
+		{"/rest/v1/mode", "GET, HEAD, PUT, OPTIONS", ResourceDoc{
+			Methods: []string{"GET", "HEAD", "PUT", "OPTIONS"},
+			Request: []FieldDoc{{"mode", "string"}, {"heating", "string"}},
+			Response: []FieldDoc{
+				{"Mode", "array"},
+				{"Heating", "array"},
+				{"forced", "string"},
+				{"safety_cutoff", "boolean"},
+				{"frost_protection", "boolean"},
+				{"pid_output", "number"},
+			},
+		}},
+	} {
+		resp, body := h.Do(http.MethodOptions, tc.path, nil)
+		var got ResourceDoc
+		if err := json.Unmarshal(body, &got); err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("OPTIONS %s: %s %s", tc.path, resp.Status, body)
+		}
+		if allow := resp.Header.Get("Allow"); allow != tc.allow {
+			t.Errorf("OPTIONS %s: Allow %q, want %q", tc.path, allow, tc.allow)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("OPTIONS %s: %+v, want %+v", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestDescribeFields(t *testing.T) {
+	// The request's own id shadows the embedded article's, and skipped
+	// fields are left out.
+	type payload struct {
+		*Article
+		ID      string `json:"id"`
+		Skipped int    `json:"-"`
+	}
+	want := []FieldDoc{
+		{"user_id", "integer"},
+		{"title", "string"},
+		{"slug", "string"},
+		{"version", "integer"},
+		{"deleted_at", "object"},
+		{"id", "string"},
+	}
+	if got := describeFields(&payload{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("describeFields = %+v, want %+v", got, want)
+	}
+	if got := describeFields(nil); got != nil {
+		t.Errorf("describeFields(nil) = %+v, want nil", got)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var serviceName = flag.String("service-name", "thermoman", "Service name reported by the root route")
+
+// version is the service version reported by the root route. Set it at
+// build time with -ldflags "-X main.version=1.2.3".
+var version = "dev"
+
+// Endpoint is a public route of the service.
+type Endpoint struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+// ServiceDescriptor is what the root route serves.
+type ServiceDescriptor struct {
+	Service   string     `json:"service"`
+	Version   string     `json:"version"`
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+func (d *ServiceDescriptor) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// NewServiceDescriptor walks the router, the same way docgen does, and lists
+// its routes. Admin and debugging routes are left out.
+func NewServiceDescriptor(r chi.Routes) (*ServiceDescriptor, error) {
+	d := &ServiceDescriptor{Service: *serviceName, Version: version, Endpoints: []Endpoint{}}
+	err := chi.Walk(r, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, "/admin") || route == "/panic" {
+			return nil
+		}
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+		d.Endpoints = append(d.Endpoints, Endpoint{Method: method, Path: route})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(d.Endpoints, func(i, j int) bool {
+		if d.Endpoints[i].Path != d.Endpoints[j].Path {
+			return d.Endpoints[i].Path < d.Endpoints[j].Path
+		}
+		return d.Endpoints[i].Method < d.Endpoints[j].Method
+	})
+	return d, nil
+}
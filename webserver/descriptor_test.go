@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestServiceDescriptor(t *testing.T) {
+	h := newHarness(t, 20)
+	var d ServiceDescriptor
+	h.GetJSON("/", &d)
+	if d.Service != *serviceName || d.Version != version {
+		t.Errorf("service %q version %q, want %q %q", d.Service, d.Version, *serviceName, version)
+	}
+
+	listed := map[Endpoint]bool{}
+	for _, e := range d.Endpoints {
+		listed[e] = true
+		if strings.HasPrefix(e.Path, "/admin") || e.Path == "/panic" {
+			t.Errorf("%s %s is listed, want admin and debugging routes left out", e.Method, e.Path)
+		}
+		if e.Path != "/" && strings.HasSuffix(e.Path, "/") {
+			t.Errorf("%s %s is listed with a trailing slash", e.Method, e.Path)
+		}
+	}
+	for _, want := range []Endpoint{
+		{"GET", "/"},
+		{"GET", "/ping"},
+		{"GET", "/livez"},
+		{"GET", "/readyz"},
+		{"GET", "/rest/v1"},
+		{"GET", "/rest/v1/temp"},
+		{"PUT", "/rest/v1/temp"},
+		{"GET", "/rest/v1/time"},
+		{"GET", "/rest/v1/mode"},
+		{"GET", "/rest/v1/device"},
+		{"GET", "/rest/v1/status"},
+	} {
+		if !listed[want] {
+			t.Errorf("%s %s isn't listed", want.Method, want.Path)
+		}
+	}
+
+	for i := 1; i < len(d.Endpoints); i++ {
+		a, b := d.Endpoints[i-1], d.Endpoints[i]
+		if a.Path > b.Path || (a.Path == b.Path && a.Method > b.Method) {
+			t.Errorf("%v listed before %v, want them ordered by path and method", a, b)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+/**-----------------------------------------------------------------------------------
+ * put device
+ * ==========
+ * $ curl -X PUT -H 'Content-Type: application/json' -d '{"ssid":"Faszom","passphrase":"f"}' http://bangkokguy.ddns.net/rest/v1/device
+ *   {"ip":"192.168.1.123","ssid":"Faszom","passphrase":"f","currenttime":"..."}
+ * $ curl -X PUT -H 'Content-Type: application/json' -d '{"ip":"10.0.0.1"}' http://bangkokguy.ddns.net/rest/v1/device
+ *   {"status":"Invalid request.","code":"device.field_immutable","error":"ip can't be changed",...}
+ *------------------------------------------------------------------------------------*/
+
+var deviceMutable = flag.String("device-mutable", "ssid,passphrase", "Comma separated device fields clients may change with PUT /device, out of "+strings.Join(deviceFieldNames(), ", "))
+
+// deviceFields are the device's fields by their JSON name, in order.
+// currenttime is reported by the server and can't be stored.
+var deviceFields = []struct {
+	name     string
+	field    func(*Device) *string
+	storable bool
+}{
+	{"ip", func(d *Device) *string { return &d.IP }, true},
+	{"ssid", func(d *Device) *string { return &d.SSID }, true},
+	{"passphrase", func(d *Device) *string { return &d.PassPhrase }, true},
+	{"currenttime", func(d *Device) *string { return &d.CurrentTime }, false},
+}
+
+func deviceFieldNames() []string {
+	var names []string
+	for _, f := range deviceFields {
+		if f.storable {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
+
+// mutableDeviceFields is -device-mutable as a set, once checked.
+var mutableDeviceFields = map[string]bool{}
+
+func checkDeviceFlags() error {
+	mutable := map[string]bool{}
+	for _, name := range strings.Split(*deviceMutable, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !contains(deviceFieldNames(), name) {
+			return fmt.Errorf("unknown -device-mutable field %q, must be one of %s", name, strings.Join(deviceFieldNames(), ", "))
+		}
+		mutable[name] = true
+	}
+	mutableDeviceFields = mutable
+	return nil
+}
+
+// checkDeviceChange fails, naming the field, if updated changes a field
+// of current that isn't in -device-mutable.
+func checkDeviceChange(current, updated *Device) error {
+	for _, f := range deviceFields {
+		if *f.field(current) != *f.field(updated) && !mutableDeviceFields[f.name] {
+			return withCode(CodeFieldImmutable, fmt.Errorf("%s can't be changed", f.name))
+		}
+	}
+	return nil
+}
+
+func (d *Device) Bind(r *http.Request) error {
+	return nil
+}
+
+// UpdateDevice changes the device fields in the body, which are applied
+// over the current ones, so fields left out or sent back unchanged are
+// fine whether they're mutable or not.
+func UpdateDevice(w http.ResponseWriter, r *http.Request) {
+	current, err := storeOf(r.Context()).GetDevice()
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	data := *current
+	if err := decode(r, &data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if err := checkDeviceChange(current, &data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if _, err := storeOf(r.Context()).UpdateDevice(&data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	LoggerFrom(r.Context()).Info("device updated", "ssid", data.SSID, "ip", data.IP)
+
+	GetDevice(w, r)
+}
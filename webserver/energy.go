@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+var boilerKW = flag.Float64("boiler-kw", 24, "Power rating of the boiler in kW, for the energy estimate")
+var tariff = flag.Float64("tariff", 0.30, "Price of a kWh, for the energy estimate")
+
+/**-----------------------------------------------------------------------------------
+ * get energy
+ * ==========
+ * $ curl http://bangkokguy.ddns.net/rest/v1/energy?window=today|24h
+ *   {"window":"24h","from":"...","to":"...","heating_on_seconds":5400,"kwh":36,"cost":10.8}
+ *------------------------------------------------------------------------------------*/
+
+// Energy estimates what the heating used over a window, from how long it
+// was on.
+type Energy struct {
+	Window           string    `json:"window"`
+	From             time.Time `json:"from"`
+	To               time.Time `json:"to"`
+	HeatingOnSeconds float64   `json:"heating_on_seconds"`
+	KWh              float64   `json:"kwh"`
+	Cost             float64   `json:"cost"`
+}
+
+func (e *Energy) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func GetEnergy(w http.ResponseWriter, r *http.Request) {
+	now := clockOf(r.Context()).Now()
+	window := r.URL.Query().Get("window")
+	var from time.Time
+	switch window {
+	case "", "24h":
+		window, from = "24h", now.Add(-24*time.Hour)
+	case "today":
+		y, m, d := now.Date()
+		from = time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	default:
+		render.Render(w, r, ErrInvalidRequest(errors.New("window must be today or 24h")))
+		return
+	}
+
+	modes, err := storeOf(r.Context()).GetMode()
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	on := heatingOnDuration(modeHistory.Since(time.Time{}, 0), modes.Heating[0], from, now)
+	kwh := on.Hours() * *boilerKW
+
+	e := &Energy{
+		Window:           window,
+		From:             from,
+		To:               now,
+		HeatingOnSeconds: on.Seconds(),
+		KWh:              round2(kwh),
+		Cost:             round2(kwh * *tariff),
+	}
+	if err := render.Render(w, r, e); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// heatingOnDuration adds up how long the heating was on between from and
+// to, given the transition log (oldest first) and the heating state now.
+func heatingOnDuration(transitions []Transition, current string, from, to time.Time) time.Duration {
+	var on time.Duration
+	for _, p := range heatingOnPeriods(transitions, current, from, to) {
+		on += p.to.Sub(p.from)
+	}
+	return on
+}
+
+// heatingOnPeriods returns when the heating was on between from and to,
+// given the transition log (oldest first) and the heating state now, with
+// the periods cut to the window. The state at from is taken from the last
+// heating transition before it, or the first one after it; if the heating
+// didn't change at all it has been in its current state all along. A
+// period still open at to ends at to.
+func heatingOnPeriods(transitions []Transition, current string, from, to time.Time) []heatingPeriod {
+	state, known := current, false
+	var changes []Transition
+	for _, t := range transitions {
+		if t.Type != "heating" {
+			continue
+		}
+		if !t.At.After(from) {
+			state, known = t.To, true
+			continue
+		}
+		if !t.At.Before(to) {
+			break
+		}
+		if !known {
+			state, known = t.From, true
+		}
+		changes = append(changes, t)
+	}
+
+	var periods []heatingPeriod
+	since := from
+	for _, t := range changes {
+		if state == "on" && t.To != "on" {
+			periods = append(periods, heatingPeriod{since, t.At})
+		}
+		if state != "on" && t.To == "on" {
+			since = t.At
+		}
+		state = t.To
+	}
+	if state == "on" {
+		periods = append(periods, heatingPeriod{since, to})
+	}
+	return periods
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHeatingOnDuration(t *testing.T) {
+	at := func(clock string) time.Time {
+		tm, _ := time.Parse("2006-01-02 15:04", "2024-01-15 "+clock)
+		return tm
+	}
+	heating := func(clock, from, to string) Transition {
+		return Transition{At: at(clock), Type: "heating", From: from, To: to}
+	}
+	log := []Transition{
+		heating("01:00", "off", "on"),
+		heating("02:00", "on", "off"),
+		{At: at("03:00"), Type: "mode", From: "night", To: "day"},
+		heating("04:00", "off", "on"),
+		heating("04:30", "on", "off"),
+		heating("06:00", "off", "on"),
+	}
+	for _, tc := range []struct {
+		desc     string
+		log      []Transition
+		current  string
+		from, to string
+		want     time.Duration
+	}{
+		{"all of it, on until now", log, "on", "00:00", "07:00", 2*time.Hour + 30*time.Minute},
+		{"cut to the window", log, "on", "01:30", "04:15", 45 * time.Minute},
+		{"on at the start of the window", log, "on", "04:10", "04:20", 10 * time.Minute},
+		{"off all through the window", log, "on", "02:10", "03:50", 0},
+		{"after the last change", log, "on", "06:30", "07:00", 30 * time.Minute},
+		{"no changes, on", nil, "on", "00:00", "07:00", 7 * time.Hour},
+		{"no changes, off", nil, "off", "00:00", "07:00", 0},
+	} {
+		if got := heatingOnDuration(tc.log, tc.current, at(tc.from), at(tc.to)); got != tc.want {
+			t.Errorf("%s: %s, want %s", tc.desc, got, tc.want)
+		}
+	}
+}
+
+func TestGetEnergy(t *testing.T) {
+	h := newHarness(t, 20)
+	withFlag(t, boilerKW, 10)
+	withFlag(t, tariff, 0.5)
+	energy := func(query string) Energy {
+		t.Helper()
+		var e Energy
+		h.GetJSON("/rest/v1/energy"+query, &e)
+		return e
+	}
+
+	// On from 05:01, and still on at 06:31.
+	h.SetReading(10)
+	h.Advance(time.Minute)
+	h.Advance(90 * time.Minute)
+	if e := energy(""); e.Window != "24h" || e.HeatingOnSeconds != 5400 || e.KWh != 15 || e.Cost != 7.5 {
+		t.Errorf("while on: %+v, want 5400s, 15kWh costing 7.5 over 24h", e)
+	}
+
+	// Off at 07:01.
+	h.SetReading(30)
+	h.Advance(30 * time.Minute)
+	h.Advance(time.Hour)
+	if e := energy("?window=today"); !e.From.Equal(harnessStart.Truncate(24*time.Hour)) || e.HeatingOnSeconds != 7200 || e.KWh != 20 || e.Cost != 10 {
+		t.Errorf("after switching off: %+v, want 7200s, 20kWh costing 10 since midnight", e)
+	}
+
+	if resp, body := h.Do(http.MethodGet, "/rest/v1/energy?window=week", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("?window=week: %s %s, want 400", resp.Status, body)
+	}
+}
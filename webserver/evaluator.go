@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var evalInterval = flag.Duration("eval-interval", 10*time.Second, "How often the heating evaluator runs")
+var minCycle = flag.Duration("min-cycle", 0, "Shortest time the heating stays on or off before it's switched again, except by the safety cutoff, against short cycling the boiler")
+
+// runEvaluator re-evaluates the mode and heating state on every tick until
+// ctx is done.
+func runEvaluator(ctx context.Context, interval time.Duration) error {
+	ticks, stop := clock.NewTicker(interval)
+	defer stop()
+
+	evaluate(ctx, clock.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticks:
+			evaluate(ctx, now)
+		}
+	}
+}
+
+// evalMu serializes evaluations, which run both on the ticker and right
+// after the mode is changed.
+var evalMu sync.Mutex
+
+// lastSwitch is when the evaluator last switched the heating, guarded by
+// evalMu.
+var lastSwitch time.Time
+
+// evaluate derives the current day/night phase from the schedule (unless
+// the phase is forced, or the mode is pinned to "day" or "night") and
+// switches the heating on or off when the temperature leaves the threshold
+// band around the phase's target, or the schedule file's target if there
+// is one (unless the heating is pinned to "on" or "off"). Below the frost
+// protection minimum the heating is on even if pinned off. The heating
+// isn't switched again within -min-cycle, except that above the safety
+// cutoff it's off, no matter what. With -preheat, the scheduled day starts
+// early enough to reach its target by the day time. Every change is
+// recorded in the mode history.
+func evaluate(ctx context.Context, now time.Time) {
+	evalMu.Lock()
+	defer evalMu.Unlock()
+
+	ctx, span := tracer.Start(ctx, "evaluate")
+	defer span.End()
+
+	reading, err := latestReading(ctx)
+	if err != nil {
+		log.Printf("Evaluating failed: %s", err)
+		return
+	}
+	current := reading.Temp
+	modes, err := storeOf(ctx).GetMode()
+	if err != nil {
+		log.Printf("Evaluating failed: %s", err)
+		return
+	}
+	times, err := storeOf(ctx).GetTime()
+	if err != nil {
+		log.Printf("Evaluating failed: %s", err)
+		return
+	}
+	temp, err := storeOf(ctx).GetTemp()
+	if err != nil {
+		log.Printf("Evaluating failed: %s", err)
+		return
+	}
+
+	forced := forcedPhase()
+	phase, reason := modes.Mode[1], "manual"
+	if forced != "" {
+		phase, reason = forced, "forced"
+	} else if phase != "day" && phase != "night" {
+		reason = "schedule"
+		day, err := resolveDayTime(times.Day, now)
+		if err == nil {
+			var night string
+			night, err = resolveDayTime(times.Night, now)
+			phase = schedulePhase(now, day, night)
+		}
+		if err != nil {
+			log.Printf("Evaluating failed: %s", err)
+			phase = modes.Mode[0]
+		} else if phase == "night" && activeSchedule() == nil && preheating(now, day, current, temp.DayTemp) {
+			phase, reason = "day", "preheat"
+		}
+	}
+	if phase != modes.Mode[0] {
+		modeHistory.Append(Transition{At: now, Type: "mode", From: modes.Mode[0], To: phase, Reason: reason})
+	}
+
+	heating, reason := modes.Heating[1], "manual"
+	if heating != "on" && heating != "off" {
+		target := phaseTarget(temp, phase, forced, now)
+		heating, reason = thermostat(current, target, temp.Thereshold)
+		if t, err := strconv.ParseFloat(string(target), 64); err == nil {
+			if h, ok := pidHeating(t, current, now); ok {
+				heating, reason = h, "pid"
+			}
+		}
+	}
+	if checkFrost(current) {
+		heating, reason = "on", "frost protection"
+	}
+	if heating != "" && heating != modes.Heating[0] && now.Sub(lastSwitch) < *minCycle {
+		heating = "" // too soon after the last switch
+	}
+	if checkSafety(current) {
+		heating, reason = "off", "safety cutoff"
+	}
+	if heating == "" {
+		heating = modes.Heating[0]
+	} else if heating != modes.Heating[0] {
+		t := Transition{At: now, Type: "heating", From: modes.Heating[0], To: heating, Reason: reason}
+		modeHistory.Append(t)
+		postWebhook(Event{Type: "heating", Source: "evaluator", Data: t})
+		lastSwitch = now
+	}
+
+	span.SetAttributes(
+		attribute.Float64("thermostat.current", current),
+		attribute.String("thermostat.phase", phase),
+		attribute.String("thermostat.heating", heating))
+	setPIDActive(reason == "pid")
+	driveRelay(heating, now)
+	driveDuty(heating, reason == "pid")
+	if err := storeOf(ctx).SetCurrentState(phase, heating); err != nil {
+		log.Printf("Evaluating failed: %s", err)
+		span.RecordError(err)
+	}
+	broker.Publish(Event{Type: "telemetry", Source: "evaluator", Data: Telemetry{
+		At: now, CurrentTemp: current, Mode: phase, Heating: heating,
+	}})
+}
+
+// Telemetry is what the evaluator saw and decided, pushed to live clients
+// after every evaluation.
+type Telemetry struct {
+	At          time.Time `json:"at"`
+	CurrentTemp float64   `json:"currenttemp"`
+	Mode        string    `json:"mode"`
+	Heating     string    `json:"heating"`
+}
+
+// withPrecision returns e with its telemetry reading rounded to decimals,
+// as the live client that asked for them with ?precision= gets it.
+func (e Event) withPrecision(decimals int) Event {
+	if t, ok := e.Data.(Telemetry); ok {
+		t.CurrentTemp = roundFloat(t.CurrentTemp, decimals)
+		e.Data = t
+	}
+	return e
+}
+
+// phaseTarget returns the target of phase: the schedule file's, unless
+// the phase is forced, or else the phase's setpoint, ramped.
+func phaseTarget(temp *Temp, phase, forced string, now time.Time) TempValue {
+	if s := activeSchedule(); s != nil && forced == "" {
+		return s.Target(now)
+	}
+	if phase == "day" {
+		return effectiveTarget("daytemp", temp.DayTemp, now)
+	}
+	return effectiveTarget("nighttemp", temp.NightTemp, now)
+}
+
+// schedulePhase reports whether now falls between the day and night
+// switch-over times ("HH:MM"), allowing for a day period that wraps
+// midnight.
+func schedulePhase(now time.Time, day, night string) string {
+	clock := now.Format("15:04")
+	if day <= night {
+		if clock >= day && clock < night {
+			return "day"
+		}
+		return "night"
+	}
+	if clock >= day || clock < night {
+		return "day"
+	}
+	return "night"
+}
+
+// thermostat decides the heating state for the given temperature. It
+// returns "" while the temperature is inside the threshold band, meaning
+// the heating should stay as it is.
+func thermostat(current float64, target, thereshold TempValue) (heating, reason string) {
+	t, err := strconv.ParseFloat(string(target), 64)
+	if err != nil {
+		return "", ""
+	}
+	threshold, err := strconv.ParseFloat(string(thereshold), 64)
+	if err != nil {
+		return "", ""
+	}
+
+	switch {
+	case current < t-threshold:
+		return "on", "below target"
+	case current > t+threshold:
+		return "off", "above target"
+	}
+	return "", ""
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// parseExpand reads ?expand=, the comma separated relations to include in
+// the response, any of allowed. Relations not asked for are left out, and
+// not looked up.
+func parseExpand(r *http.Request, allowed ...string) (map[string]bool, error) {
+	expand := map[string]bool{}
+	for _, name := range strings.Split(r.URL.Query().Get("expand"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		ok := false
+		for _, a := range allowed {
+			ok = ok || a == name
+		}
+		if !ok {
+			names := append([]string(nil), allowed...) // allowed is the caller's
+			sort.Strings(names)
+			return nil, withCode(CodeNotAllowed, fmt.Errorf("expand: %q isn't one of %s", name, strings.Join(names, ", ")))
+		}
+		expand[name] = true
+	}
+	return expand, nil
+}
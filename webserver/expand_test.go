@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// userCountingStore counts the user lookups.
+type userCountingStore struct {
+	Store
+	lookups atomic.Int32
+}
+
+func (s *userCountingStore) GetUser(id int64) (*User, error) {
+	s.lookups.Add(1)
+	return s.Store.GetUser(id)
+}
+
+func TestListArticlesExpandUser(t *testing.T) {
+	resetState(t)
+	store := &userCountingStore{Store: NewInMemoryStore()}
+	srv := mountRoutes(t, map[string]Deps{"/": {Store: store}})
+
+	for _, tc := range []struct {
+		query    string
+		withUser bool
+	}{
+		{"", false},
+		{"?expand=user", true},
+	} {
+		store.lookups.Store(0)
+		status, body := send(t, srv, http.MethodGet, "/rest/v1/"+tc.query, "")
+		if status != http.StatusOK {
+			t.Fatalf("GET /rest/v1/%s: %d %s", tc.query, status, body)
+		}
+		var page struct {
+			Items []struct {
+				User *UserPayload `json:"user"`
+			} `json:"items"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil || len(page.Items) == 0 {
+			t.Fatalf("GET /rest/v1/%s: %v %s", tc.query, err, body)
+		}
+		users := 0
+		for _, item := range page.Items {
+			if item.User != nil {
+				users++
+			}
+		}
+		if (users > 0) != tc.withUser {
+			t.Errorf("GET /rest/v1/%s: %d of %d articles with their user, want users only with expand=user", tc.query, users, len(page.Items))
+		}
+		if n := store.lookups.Load(); tc.withUser != (n > 0) {
+			t.Errorf("GET /rest/v1/%s: %d user lookups", tc.query, n)
+		}
+	}
+}
+
+func TestParseExpandLeavesAllowedAlone(t *testing.T) {
+	allowed := []string{"user", "comments"}
+	r := httptest.NewRequest(http.MethodGet, "/?expand=tags", nil)
+	if _, err := parseExpand(r, allowed...); err == nil {
+		t.Fatal("parseExpand took a relation not allowed")
+	}
+	if allowed[0] != "user" || allowed[1] != "comments" {
+		t.Errorf("parseExpand reordered the caller's slice to %v", allowed)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+/**-----------------------------------------------------------------------------------
+ * export/import settings
+ * ======================
+ * $ curl http://bangkokguy.ddns.net/rest/v1/export > backup.json
+ *   {"version":1,"exported":"...","device":{"ip":"192.168.1.1","ssid":"MrWhite"},"config":{"day":"06:00",...}}
+ * $ curl -X POST -H 'Content-Type: application/json' -d @backup.json http://bangkokguy.ddns.net/rest/v1/import
+ *------------------------------------------------------------------------------------*/
+
+// exportVersion is the version of the export document. Bump it whenever
+// the document changes in a way older imports can't read, and migrate
+// older versions in ImportSettings.
+const exportVersion = 1
+
+var errExportVersion = errors.New("unsupported export version")
+
+// Export holds all the settings, to back them up or move them to another
+// device.
+type Export struct {
+	Version  int           `json:"version"`
+	Exported time.Time     `json:"exported"`
+	Device   *ExportDevice `json:"device,omitempty"` // imported as far as -device-mutable allows
+	Config   *Config       `json:"config"`
+	Forced   string        `json:"forced,omitempty"`
+}
+
+// ExportDevice is the device without its secrets.
+type ExportDevice struct {
+	IP   string `json:"ip"`
+	SSID string `json:"ssid"`
+}
+
+func (e *Export) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func ExportSettings(w http.ResponseWriter, r *http.Request) {
+	device, err := storeOf(r.Context()).GetDevice()
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	config, err := loadConfig(r.Context())
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+
+	e := &Export{
+		Version:  exportVersion,
+		Exported: clockOf(r.Context()).Now().UTC(),
+		Device:   &ExportDevice{IP: device.IP, SSID: device.SSID},
+		Config:   config,
+		Forced:   forcedPhase(),
+	}
+	if err := render.Render(w, r, e); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// ImportSettings replaces the settings with those of an export. The whole
+// document is checked before anything is stored.
+func ImportSettings(w http.ResponseWriter, r *http.Request) {
+	if err := requireBody(r); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	e := &Export{}
+	if err := decodeJSON(io.LimitReader(r.Body, 1<<20), e, true); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if e.Version != exportVersion {
+		render.Render(w, r, ErrInvalidRequest(fmt.Errorf("%w %d, this server reads version %d", errExportVersion, e.Version, exportVersion)))
+		return
+	}
+	if err := importSettings(r.Context(), e, requestSource(r)); err != nil {
+		if errors.Is(err, errScheduled) {
+			render.Render(w, r, ErrConflict(err))
+		} else {
+			render.Render(w, r, ErrInvalidRequest(err))
+		}
+		return
+	}
+
+	ExportSettings(w, r)
+}
+
+// importedDevice returns current with the fields of d that -device-mutable
+// allows applied over it, and whether that changes anything. The other
+// fields stay as they are, so an export from another device doesn't fail
+// on, or take, that device's IP. Nothing is imported with the wifi
+// feature off, as PUT /device would refuse it.
+func importedDevice(current *Device, d *ExportDevice) (*Device, bool) {
+	if d == nil || !featureEnabled("wifi") {
+		return current, false
+	}
+	exported := map[string]string{"ip": d.IP, "ssid": d.SSID}
+	updated := *current
+	changed := false
+	for _, f := range deviceFields {
+		v, ok := exported[f.name]
+		if !ok || v == "" || !mutableDeviceFields[f.name] || *f.field(&updated) == v {
+			continue
+		}
+		*f.field(&updated) = v
+		changed = true
+	}
+	return &updated, changed
+}
+
+// importSettings validates and stores the settings of e, announcing them as
+// changed by source.
+func importSettings(ctx context.Context, e *Export, source string) error {
+	if e.Config == nil {
+		return errors.New("config: required")
+	}
+	if err := e.Config.validate(); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	switch e.Forced {
+	case "", "day", "night":
+	default:
+		return errors.New("forced must be day or night")
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+	current, err := loadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	device, err := storeOf(ctx).GetDevice()
+	if err != nil {
+		return err
+	}
+	if err := commitConfig(ctx, current, e.Config, e.Forced, source); err != nil {
+		return err
+	}
+	if device, changed := importedDevice(device, e.Device); changed {
+		if _, err := storeOf(ctx).UpdateDevice(device); err != nil {
+			return fmt.Errorf("device: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	h := newHarness(t, 20)
+	resp, body := h.Do(http.MethodGet, "/rest/v1/export", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /rest/v1/export: %s %s", resp.Status, body)
+	}
+	if strings.Contains(string(body), "passphrase") {
+		t.Errorf("the export holds the passphrase: %s", body)
+	}
+	var e Export
+	if err := json.Unmarshal(body, &e); err != nil || e.Version != exportVersion || e.Config == nil {
+		t.Fatalf("GET /rest/v1/export: %v %s", err, body)
+	}
+
+	e.Config.DayTemp, e.Config.Night, e.Forced = "22.50", "23:00", "night"
+	if resp, body := h.Do(http.MethodPost, "/rest/v1/import", e); resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /rest/v1/import: %s %s", resp.Status, body)
+	}
+	var got Export
+	h.GetJSON("/rest/v1/export", &got)
+	if *got.Config != *e.Config || got.Forced != "night" {
+		t.Errorf("exported %+v forced %q after the import, want %+v forced night", *got.Config, got.Forced, *e.Config)
+	}
+
+	e.Version = exportVersion + 1
+	e.Config.DayTemp = "19.00"
+	resp, body = h.Do(http.MethodPost, "/rest/v1/import", e)
+	if resp.StatusCode != http.StatusBadRequest || !jsonHasCode(body, CodeExportVersion) {
+		t.Errorf("importing version %d: %s %s, want 400 %s", e.Version, resp.Status, body, CodeExportVersion)
+	}
+	if temp, _ := h.Store.GetTemp(); temp.DayTemp != "22.50" {
+		t.Errorf("day temp %s after a rejected import, want 22.50 kept", temp.DayTemp)
+	}
+}
+
+func TestImportSettingsDevice(t *testing.T) {
+	h := newHarness(t, 20)
+	if err := checkDeviceFlags(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mutableDeviceFields = map[string]bool{} })
+	before, _ := h.Store.GetDevice()
+
+	var e Export
+	h.GetJSON("/rest/v1/export", &e)
+	e.Device = &ExportDevice{IP: "10.9.9.9", SSID: "Imported"}
+	resp, body := h.Do(http.MethodPost, "/rest/v1/import", e)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /rest/v1/import: %s %s", resp.Status, body)
+	}
+	var got Export
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Device.SSID != "Imported" {
+		t.Errorf("ssid %q after the import, want the imported one", got.Device.SSID)
+	}
+	if got.Device.IP != before.IP {
+		t.Errorf("ip %q after the import, want %q kept: it isn't in -device-mutable", got.Device.IP, before.IP)
+	}
+
+	// With the Wi-Fi settings locked, the device is left alone.
+	disabledFeatures.Lock()
+	disabledFeatures.m["wifi"] = true
+	disabledFeatures.Unlock()
+	t.Cleanup(func() {
+		disabledFeatures.Lock()
+		delete(disabledFeatures.m, "wifi")
+		disabledFeatures.Unlock()
+	})
+	e.Device.SSID = "Locked"
+	if resp, body := h.Do(http.MethodPost, "/rest/v1/import", e); resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /rest/v1/import: %s %s", resp.Status, body)
+	}
+	if d, _ := h.Store.GetDevice(); d.SSID != "Imported" {
+		t.Errorf("ssid %q imported with the wifi feature off", d.SSID)
+	}
+}
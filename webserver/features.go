@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/go-chi/render"
+)
+
+/**-----------------------------------------------------------------------------------
+ * get/put features
+ * ================
+ * $ curl -H 'Authorization: Bearer <admin-key>' http://bangkokguy.ddns.net/admin/features
+ *   {"firmware":true,"identify":true,"reset":true,"wifi":true}
+ * $ curl -X PUT -H 'Authorization: Bearer <admin-key>' -H 'Content-Type: application/json' -d '{"wifi":false}' http://bangkokguy.ddns.net/admin/features
+ *   {"firmware":true,"identify":true,"reset":true,"wifi":false}
+ * $ curl -X PUT -H 'Content-Type: application/json' -d '{"ssid":"Faszom","passphrase":"f"}' http://bangkokguy.ddns.net/rest/v1/device
+ *   {"status":"Resource not found.","code":"resource.not_found"}
+ *------------------------------------------------------------------------------------*/
+
+// features are the names of the routes that can be turned off at runtime,
+// with what they cover. A route is put under one with Feature.
+var features = map[string]string{
+	"wifi":     "changing the Wi-Fi settings and scanning for networks",
+	"firmware": "checking for firmware updates",
+	"identify": "blinking the LED to identify the device",
+	"reset":    "resetting the device to its defaults",
+}
+
+// disabledFeatures holds the features turned off; all are on by default.
+var disabledFeatures = struct {
+	sync.RWMutex
+	m map[string]bool
+}{m: map[string]bool{}}
+
+func featureEnabled(name string) bool {
+	disabledFeatures.RLock()
+	defer disabledFeatures.RUnlock()
+	return !disabledFeatures.m[name]
+}
+
+// featureStates returns whether each feature is on, by name.
+func featureStates() map[string]bool {
+	disabledFeatures.RLock()
+	defer disabledFeatures.RUnlock()
+	states := map[string]bool{}
+	for name := range features {
+		states[name] = !disabledFeatures.m[name]
+	}
+	return states
+}
+
+// disabledFeatureNames lists the features turned off, sorted, as the state
+// file keeps them.
+func disabledFeatureNames() []string {
+	disabledFeatures.RLock()
+	defer disabledFeatures.RUnlock()
+	var names []string
+	for name := range disabledFeatures.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setDisabledFeatures turns off the features named, and on all others.
+// Names no longer known are ignored.
+func setDisabledFeatures(names []string) {
+	disabledFeatures.Lock()
+	defer disabledFeatures.Unlock()
+	disabledFeatures.m = map[string]bool{}
+	for _, name := range names {
+		if _, ok := features[name]; ok {
+			disabledFeatures.m[name] = true
+		}
+	}
+}
+
+// Feature middleware answers 404 for the route while the feature is turned
+// off, as if the route didn't exist.
+func Feature(name string) func(http.Handler) http.Handler {
+	if _, ok := features[name]; !ok {
+		panic(fmt.Sprintf("no feature %q", name))
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !featureEnabled(name) {
+				render.Render(w, r, ErrNotFound)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// FeatureStates is whether each feature is on, by name.
+type FeatureStates map[string]bool
+
+func (f FeatureStates) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func (f FeatureStates) Bind(r *http.Request) error {
+	for name := range f {
+		if _, ok := features[name]; !ok {
+			return withCode(CodeNotAllowed, fmt.Errorf("%s: no such feature", name))
+		}
+	}
+	return nil
+}
+
+func GetFeatures(w http.ResponseWriter, r *http.Request) {
+	if err := render.Render(w, r, FeatureStates(featureStates())); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// UpdateFeatures turns the features in the body on or off, leaving the
+// others as they are, and persists them.
+func UpdateFeatures(w http.ResponseWriter, r *http.Request) {
+	var data FeatureStates
+	if err := decode(r, &data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	disabledFeatures.Lock()
+	for name, on := range data {
+		if on {
+			delete(disabledFeatures.m, name)
+		} else {
+			disabledFeatures.m[name] = true
+		}
+	}
+	disabledFeatures.Unlock()
+	persistState(r.Context())
+	LoggerFrom(r.Context()).Info("features changed", "disabled", disabledFeatureNames())
+
+	GetFeatures(w, r)
+}
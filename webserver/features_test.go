@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFeatureMiddleware(t *testing.T) {
+	resetState(t)
+	h := Feature("reset")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tc := range []struct {
+		disabled []string
+		want     int
+	}{
+		{nil, http.StatusOK},
+		{[]string{"reset"}, http.StatusNotFound},
+		{[]string{"wifi", "gone"}, http.StatusOK},
+	} {
+		setDisabledFeatures(tc.disabled)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rest/v1/device/reset", nil))
+		if rec.Code != tc.want {
+			t.Errorf("disabled %q: %d, want %d", tc.disabled, rec.Code, tc.want)
+		}
+	}
+	if got := disabledFeatureNames(); len(got) != 1 || got[0] != "wifi" {
+		t.Errorf("disabled %q, want the unknown name dropped", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Feature took an unknown name")
+		}
+	}()
+	Feature("teleport")
+}
+
+func TestUpdateFeatures(t *testing.T) {
+	h := newHarness(t, 20)
+	setAdminKey("admin-key")
+	admin := []string{"Authorization", "Bearer admin-key"}
+
+	resp, body := h.Do(http.MethodPut, "/admin/features", map[string]bool{"firmware": false}, admin...)
+	var states FeatureStates
+	if err := json.Unmarshal(body, &states); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /admin/features: %s %s", resp.Status, body)
+	}
+	if len(states) != len(features) || states["firmware"] || !states["wifi"] {
+		t.Errorf("features %v, want only firmware off", states)
+	}
+	resp, body = h.Do(http.MethodPost, "/rest/v1/device/firmware/check", nil)
+	if resp.StatusCode != http.StatusNotFound || !jsonHasCode(body, CodeNotFound) {
+		t.Errorf("POST /rest/v1/device/firmware/check turned off: %s %s, want 404", resp.Status, body)
+	}
+
+	resp, body = h.Do(http.MethodPut, "/admin/features", map[string]bool{"firmware": true, "teleport": false}, admin...)
+	if resp.StatusCode != http.StatusBadRequest || !jsonHasCode(body, CodeNotAllowed) {
+		t.Errorf("PUT /admin/features with an unknown one: %s %s, want 400", resp.Status, body)
+	}
+	if featureEnabled("firmware") {
+		t.Errorf("a rejected update turned firmware on")
+	}
+
+	if resp, _ := h.Do(http.MethodPut, "/admin/features", map[string]bool{"firmware": true}); resp.StatusCode == http.StatusOK {
+		t.Errorf("PUT /admin/features without the admin key: %s", resp.Status)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+/**-----------------------------------------------------------------------------------
+ * firmware
+ * ========
+ * $ curl http://bangkokguy.ddns.net/rest/v1/device/firmware
+ *   {"version":"1.2.0","update_available":false}
+ * $ curl -X POST http://bangkokguy.ddns.net/rest/v1/device/firmware/check
+ *   {"version":"1.2.0","latest":"1.3.0","update_available":true,"url":"https://.../1.3.0.bin","last_check":"..."}
+ *------------------------------------------------------------------------------------*/
+
+var firmwareManifestURL = flag.String("firmware-manifest-url", "", `URL of the update manifest, a JSON object like {"version":"1.3.0","url":"..."}; updates aren't checked for if empty`)
+
+var (
+	errNoManifestURL       = errors.New("no -firmware-manifest-url set")
+	errManifestUnreachable = errors.New("update manifest unreachable")
+)
+
+var firmwareClient = &http.Client{Timeout: 10 * time.Second}
+
+// Manifest describes the latest firmware release.
+type Manifest struct {
+	Version string `json:"version"`
+	URL     string `json:"url"`
+}
+
+// FirmwareStatus is the running version, and what the last update check
+// found. A failed check keeps what the last good one found.
+type FirmwareStatus struct {
+	Version         string     `json:"version"`
+	Latest          string     `json:"latest,omitempty"`
+	UpdateAvailable bool       `json:"update_available"`
+	URL             string     `json:"url,omitempty"`
+	LastCheck       *time.Time `json:"last_check,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+}
+
+func (s *FirmwareStatus) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+var firmware struct {
+	mu     sync.Mutex
+	status FirmwareStatus
+}
+
+func firmwareStatus() FirmwareStatus {
+	firmware.mu.Lock()
+	defer firmware.mu.Unlock()
+	status := firmware.status
+	status.Version = version
+	return status
+}
+
+// fetchManifest gets the update manifest at url.
+func fetchManifest(ctx context.Context, url string) (*Manifest, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := firmwareClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+	m := &Manifest{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(m); err != nil {
+		return nil, fmt.Errorf("decoding: %w", err)
+	}
+	if m.Version == "" {
+		return nil, errors.New("no version")
+	}
+	return m, nil
+}
+
+// newerVersion tells if latest is a later version than current, comparing
+// dot separated numbers with an optional leading "v". A version that isn't
+// one, like the "dev" of an unreleased build, is never older.
+func newerVersion(latest, current string) bool {
+	l, ok1 := versionNumbers(latest)
+	c, ok2 := versionNumbers(current)
+	if !ok1 || !ok2 {
+		return false
+	}
+	for i := 0; i < len(l) || i < len(c); i++ {
+		var a, b int
+		if i < len(l) {
+			a = l[i]
+		}
+		if i < len(c) {
+			b = c[i]
+		}
+		if a != b {
+			return a > b
+		}
+	}
+	return false
+}
+
+func versionNumbers(v string) ([]int, bool) {
+	parts := strings.Split(strings.TrimPrefix(v, "v"), ".")
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		numbers[i] = n
+	}
+	return numbers, true
+}
+
+// checkFirmware checks the manifest at url for an update, and records the
+// outcome in the status.
+func checkFirmware(ctx context.Context, url string, now time.Time) (FirmwareStatus, error) {
+	m, err := fetchManifest(ctx, url)
+
+	firmware.mu.Lock()
+	firmware.status.LastCheck = &now
+	if err != nil {
+		firmware.status.LastError = err.Error()
+	} else {
+		firmware.status.LastError = ""
+		firmware.status.Latest = m.Version
+		firmware.status.URL = m.URL
+		firmware.status.UpdateAvailable = newerVersion(m.Version, version)
+	}
+	firmware.mu.Unlock()
+
+	if err != nil {
+		return firmwareStatus(), fmt.Errorf("%w: %s", errManifestUnreachable, err)
+	}
+	return firmwareStatus(), nil
+}
+
+// GetFirmware serves the running version and what the last update check
+// found.
+func GetFirmware(w http.ResponseWriter, r *http.Request) {
+	status := firmwareStatus()
+	render.Render(w, r, &status)
+}
+
+// CheckFirmware checks the update manifest for a newer version. It only
+// reports it; nothing is installed.
+func CheckFirmware(w http.ResponseWriter, r *http.Request) {
+	if *firmwareManifestURL == "" {
+		render.Render(w, r, ErrUnavailable(errNoManifestURL))
+		return
+	}
+	status, err := checkFirmware(r.Context(), *firmwareManifestURL, clockOf(r.Context()).Now())
+	if err != nil {
+		LoggerFrom(r.Context()).Warn("firmware check failed", "error", err)
+		render.Render(w, r, ErrUnavailable(err))
+		return
+	}
+	render.Render(w, r, &status)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNewerVersion(t *testing.T) {
+	for _, tc := range []struct {
+		latest, current string
+		want            bool
+	}{
+		{"1.3.0", "1.2.0", true},
+		{"v1.10", "1.9.9", true},
+		{"1.2.0", "1.2", false},
+		{"1.2.1", "1.2", true},
+		{"1.2.0", "1.3.0", false},
+		{"2.0.0", "dev", false},
+		{"beta", "1.0.0", false},
+	} {
+		if got := newerVersion(tc.latest, tc.current); got != tc.want {
+			t.Errorf("newerVersion(%q, %q) = %t, want %t", tc.latest, tc.current, got, tc.want)
+		}
+	}
+}
+
+func TestCheckFirmware(t *testing.T) {
+	h := newHarness(t, 20)
+	withFlag(t, &version, "1.2.0")
+	t.Cleanup(func() {
+		firmware.mu.Lock()
+		firmware.status = FirmwareStatus{}
+		firmware.mu.Unlock()
+	})
+	var down atomic.Bool
+	manifest := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "oops", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"version":"1.3.0","url":"https://example.com/1.3.0.bin"}`))
+	}))
+	t.Cleanup(manifest.Close)
+
+	resp, body := h.Do(http.MethodPost, "/rest/v1/device/firmware/check", nil)
+	if resp.StatusCode != http.StatusServiceUnavailable || !jsonHasCode(body, CodeNoManifestURL) {
+		t.Errorf("checking without a manifest URL: %s %s, want 503 %s", resp.Status, body, CodeNoManifestURL)
+	}
+
+	withFlag(t, firmwareManifestURL, manifest.URL)
+	var status FirmwareStatus
+	resp, body = h.Do(http.MethodPost, "/rest/v1/device/firmware/check", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /rest/v1/device/firmware/check: %s %s", resp.Status, body)
+	}
+	h.GetJSON("/rest/v1/device/firmware", &status)
+	if status.Version != "1.2.0" || status.Latest != "1.3.0" || !status.UpdateAvailable || status.LastCheck == nil {
+		t.Errorf("after a check: %+v, want 1.3.0 available", status)
+	}
+
+	// A failed check keeps what the last good one found.
+	down.Store(true)
+	resp, body = h.Do(http.MethodPost, "/rest/v1/device/firmware/check", nil)
+	if resp.StatusCode != http.StatusServiceUnavailable || !jsonHasCode(body, CodeManifestUnreachable) {
+		t.Errorf("checking a failing manifest: %s %s, want 503 %s", resp.Status, body, CodeManifestUnreachable)
+	}
+	h.GetJSON("/rest/v1/device/firmware", &status)
+	if status.Latest != "1.3.0" || !status.UpdateAvailable || status.LastError == "" {
+		t.Errorf("after a failed check: %+v, want 1.3.0 still available and the error", status)
+	}
+}
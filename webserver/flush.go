@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/render"
+)
+
+var errNoFlush = errors.New("the response can't be flushed, so it can't be streamed")
+
+// canFlush tells whether flushing w reaches the connection. The middleware
+// writers are unwrapped the way http.ResponseController does, down to the
+// one the server made; a writer in between that can neither flush nor be
+// unwrapped, such as one compressing the response, holds the stream back.
+func canFlush(w http.ResponseWriter) bool {
+	for {
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			_, ok := w.(http.Flusher)
+			return ok
+		}
+		w = u.Unwrap()
+	}
+}
+
+// requireFlush answers with a 500 and returns false if w can't be
+// flushed, before a streaming handler writes anything, rather than have
+// the client see nothing until the stream ends.
+func requireFlush(w http.ResponseWriter, r *http.Request) bool {
+	if canFlush(w) {
+		return true
+	}
+	LoggerFrom(r.Context()).Error("streaming unsupported", "writer", fmt.Sprintf("%T", w))
+	render.Render(w, r, ErrInternal(errNoFlush))
+	return false
+}
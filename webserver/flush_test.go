@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// plainWriter hides every method of the writer it wraps but those of
+// http.ResponseWriter, like a middleware writer that doesn't pass Flush on.
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+// unwrapWriter passes the writer under it on through Unwrap.
+type unwrapWriter struct {
+	http.ResponseWriter
+}
+
+func (w unwrapWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func TestCanFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	for _, tc := range []struct {
+		name string
+		w    http.ResponseWriter
+		want bool
+	}{
+		{"recorder", rec, true},
+		{"unwrapped", unwrapWriter{unwrapWriter{rec}}, true},
+		{"plain", plainWriter{rec}, false},
+		{"plain under unwrapped", unwrapWriter{plainWriter{rec}}, false},
+	} {
+		if got := canFlush(tc.w); got != tc.want {
+			t.Errorf("%s: canFlush = %t, want %t", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestStreamsNeedFlush(t *testing.T) {
+	h := newHarness(t, 20)
+	handler := h.Server.Config.Handler
+	for _, path := range []string{"/rest/v1/events", "/rest/v1/articles.ndjson"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(plainWriter{rec}, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("GET %s without Flush: %d, want 500: %s", path, rec.Code, rec.Body)
+			continue
+		}
+		var e struct {
+			Code ErrorCode `json:"code"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &e); err != nil || e.Code != CodeStreamUnsupported {
+			t.Errorf("GET %s without Flush: %s, want code %s", path, rec.Body, CodeStreamUnsupported)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/render"
+)
+
+/**-----------------------------------------------------------------------------------
+ * force mode
+ * ==========
+ * $ curl -X PUT -H 'Content-Type: application/json' -d '{"phase":"day"}' http://bangkokguy.ddns.net/rest/v1/mode/force
+ *   {"Mode":["day","auto"],"Heating":["on","auto"],"forced":"day"}
+ * $ curl -X DELETE http://bangkokguy.ddns.net/rest/v1/mode/force
+ *   {"Mode":["day","auto"],"Heating":["on","auto"]}
+ *------------------------------------------------------------------------------------*/
+
+// forced is the phase the mode is pinned to until it's unpinned, whatever
+// the mode setting, the day/night times and the schedule file say; "" if
+// it isn't pinned.
+var forced struct {
+	mu    sync.Mutex
+	phase string
+}
+
+func forcedPhase() string {
+	forced.mu.Lock()
+	defer forced.mu.Unlock()
+	return forced.phase
+}
+
+func setForcedPhase(phase string) {
+	forced.mu.Lock()
+	forced.phase = phase
+	forced.mu.Unlock()
+}
+
+// ForceRequest pins the phase.
+type ForceRequest struct {
+	Phase string `json:"phase" validate:"required,oneof=day night"`
+}
+
+func (f *ForceRequest) Bind(r *http.Request) error {
+	return nil
+}
+
+func ForceMode(w http.ResponseWriter, r *http.Request) {
+	data := &ForceRequest{}
+	if err := decode(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	setForcedPhase(data.Phase)
+	LoggerFrom(r.Context()).Info("phase forced", "phase", data.Phase)
+	forceChanged(w, r)
+}
+
+func UnforceMode(w http.ResponseWriter, r *http.Request) {
+	setForcedPhase("")
+	LoggerFrom(r.Context()).Info("phase unforced")
+	forceChanged(w, r)
+}
+
+// forceChanged applies a change of the forced phase right away, and
+// answers with the mode.
+func forceChanged(w http.ResponseWriter, r *http.Request) {
+	persistState(r.Context())
+	evaluate(r.Context(), clockOf(r.Context()).Now())
+	publishConfig(r.Context(), requestSource(r))
+
+	GetMode(w, r)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestForceMode(t *testing.T) {
+	h := newHarness(t, 20)
+
+	resp, body := h.Do(http.MethodPut, "/rest/v1/mode/force", map[string]string{"phase": "day"})
+	var mode Modes
+	if err := json.Unmarshal(body, &mode); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /rest/v1/mode/force: %s %s", resp.Status, body)
+	}
+	// At 05:00 with 20C, pinned to day's 24C, the heating goes on at once.
+	if mode.Mode[0] != "day" || mode.Forced != "day" || mode.Heating[0] != "on" {
+		t.Errorf("forced to day at night: %s, want day, heating on", body)
+	}
+
+	h.Advance(18 * time.Hour) // 23:00, past the night time
+	h.GetJSON("/rest/v1/mode", &mode)
+	if mode.Mode[0] != "day" {
+		t.Errorf("at 23:00 while forced: mode %q, want day", mode.Mode[0])
+	}
+
+	resp, body = h.Do(http.MethodDelete, "/rest/v1/mode/force", nil)
+	mode = Modes{}
+	if err := json.Unmarshal(body, &mode); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE /rest/v1/mode/force: %s %s", resp.Status, body)
+	}
+	if mode.Mode[0] != "night" || mode.Forced != "" || mode.Heating[0] != "off" {
+		t.Errorf("unforced at 23:00: %s, want night, heating off", body)
+	}
+
+	for _, phase := range []string{"", "dusk"} {
+		if resp, body := h.Do(http.MethodPut, "/rest/v1/mode/force", map[string]string{"phase": phase}); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("forcing %q: %s %s, want 400", phase, resp.Status, body)
+		}
+	}
+}
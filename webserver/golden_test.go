@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Rewrite testdata/*.golden.json with the responses the golden tests get")
+
+// volatileKeys are the response keys whose values change from run to run,
+// the clock and the sensor reading, which normalize zeroes out.
+var volatileKeys = map[string]bool{
+	"currenttime": true,
+	"currenttemp": true,
+	"temp":        true,
+	"reading_at":  true,
+	"claimed_at":  true,
+}
+
+// normalize zeroes out the values of volatileKeys anywhere in v, a decoded
+// JSON document, keeping their type so a change of type still shows.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if volatileKeys[k] {
+				v[k] = zeroOf(e)
+				continue
+			}
+			v[k] = normalize(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalize(e)
+		}
+	}
+	return v
+}
+
+func zeroOf(v interface{}) interface{} {
+	switch v.(type) {
+	case string:
+		return ""
+	case float64:
+		return 0.0
+	case bool:
+		return false
+	}
+	return nil
+}
+
+// checkGolden compares the normalized JSON body with testdata/name.golden.json,
+// or rewrites that file with it under -update.
+func checkGolden(t *testing.T, name string, body []byte) {
+	t.Helper()
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("%s: %s: %s", name, err, body)
+	}
+	got, err := json.MarshalIndent(normalize(doc), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", name+".golden.json")
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%s (run go test -run Golden -update to write it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s doesn't match %s:\n%s\nwant:\n%s", name, path, got, want)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	for _, tc := range []struct{ name, path string }{
+		{"device", "/rest/v1/device"},
+		{"temp", "/rest/v1/temp"},
+		{"time", "/rest/v1/time"},
+		{"mode", "/rest/v1/mode"},
+		{"status", "/rest/v1/status"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newHarness(t, 19.25)
+			resp, body := h.Do(http.MethodGet, tc.path, nil)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET %s: %s: %s", tc.path, resp.Status, body)
+			}
+			checkGolden(t, tc.name, body)
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"temp":19.2,"reading_at":"2024-01-15T05:00:00Z","mode":"night","items":[{"currenttemp":"19.2","ok":true}]}`), &doc)
+	got, _ := json.Marshal(normalize(doc))
+	want := `{"items":[{"currenttemp":"","ok":true}],"mode":"night","reading_at":"","temp":0}`
+	if string(got) != want {
+		t.Errorf("normalize = %s, want %s", got, want)
+	}
+}
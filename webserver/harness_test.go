@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"bangkokguy.dev/webserver/internal/testutil"
+)
+
+// harness is the whole server on an httptest.Server, against an in-memory
+// store, a fake clock and a scripted sensor. The poller and the evaluator
+// don't run on their own: Advance runs them, so a test knows they're done.
+type harness struct {
+	t      *testing.T
+	Server *httptest.Server
+	Clock  *testutil.FakeClock
+	Sensor *testutil.ScriptedSensor
+	Store  Store
+
+	ctx context.Context // carries the deps to the poller and the evaluator
+}
+
+// harnessStart is a Monday in the night phase of the default times, day
+// at 06:00 and night at 22:00.
+var harnessStart = time.Date(2024, 1, 15, 5, 0, 0, 0, time.UTC)
+
+// newHarness starts the server at harnessStart with the sensor reading
+// initial, polled and evaluated once, like main does before serving. The
+// package's state is reset first, and again when the test is done.
+func newHarness(t *testing.T, initial float64) *harness {
+	t.Helper()
+	resetState(t)
+
+	h := &harness{
+		t:      t,
+		Clock:  testutil.NewFakeClock(harnessStart),
+		Sensor: testutil.NewScriptedSensor(initial),
+		Store:  NewInMemoryStore(),
+	}
+	deps := Deps{Store: h.Store, Sensor: h.Sensor, Clock: h.Clock}
+	h.ctx = context.WithValue(context.Background(), depsCtxKey{}, &deps)
+
+	r := chi.NewRouter()
+	if err := RegisterRoutes(r, deps); err != nil {
+		t.Fatal(err)
+	}
+	h.Server = httptest.NewServer(r)
+	t.Cleanup(h.Server.Close)
+
+	h.step(h.Clock.Now())
+	return h
+}
+
+// resetState puts the package's state back as a fresh process has it.
+func resetState(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		tempHistory = NewSampleLog(8640)
+		modeHistory = NewTransitionLog(500)
+		broker = NewBroker()
+		sessions = NewSessions(nil)
+		latest.mu.Lock()
+		latest.reading, latest.ok = Reading{}, false
+		latest.mu.Unlock()
+		remote.mu.Lock()
+		remote.readings = nil
+		remote.mu.Unlock()
+		lastSwitch = time.Time{}
+		setClaim("", time.Time{})
+		setForcedPhase("")
+		setAdminKey("")
+		resetAlerts()
+		setPIDConfig(PIDConfig{})
+		setDisabledFeatures(nil)
+		safety.mu.Lock()
+		safety.active = false
+		safety.mu.Unlock()
+		frost.mu.Lock()
+		frost.active = false
+		frost.mu.Unlock()
+		preheatLatch.Lock()
+		preheatLatch.day, preheatLatch.start = time.Time{}, time.Time{}
+		preheatLatch.Unlock()
+		ramps.Lock()
+		ramps.m = map[string]*Ramp{}
+		ramps.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// withFlag sets the flag behind p to v for the rest of the test.
+func withFlag[T any](t *testing.T, p *T, v T) {
+	t.Helper()
+	old := *p
+	*p = v
+	t.Cleanup(func() { *p = old })
+}
+
+// Advance moves the clock forward by d, then polls the sensor and
+// evaluates, as the background workers would on their ticks.
+func (h *harness) Advance(d time.Duration) {
+	h.t.Helper()
+	h.step(h.Clock.Advance(d))
+}
+
+// SetReading makes the sensor read v from the next poll on.
+func (h *harness) SetReading(v float64) {
+	h.Sensor.Set(v)
+}
+
+func (h *harness) step(now time.Time) {
+	pollSensor(h.ctx, now)
+	evaluate(h.ctx, now)
+}
+
+// Do sends a request with body, if it isn't nil, marshalled as JSON and
+// returns the response, with its body read.
+func (h *harness) Do(method, path string, body interface{}, header ...string) (*http.Response, []byte) {
+	h.t.Helper()
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		rd = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, h.Server.URL+path, rd)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := h.Server.Client().Do(req)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return resp, b
+}
+
+// GetJSON gets path, expecting a 200, and decodes the response into v.
+func (h *harness) GetJSON(path string, v interface{}) {
+	h.t.Helper()
+	resp, body := h.Do(http.MethodGet, path, nil)
+	if resp.StatusCode != http.StatusOK {
+		h.t.Fatalf("GET %s: %s: %s", path, resp.Status, body)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		h.t.Fatalf("GET %s: %s: %s", path, err, body)
+	}
+}
+
+func TestHarnessNightBoundary(t *testing.T) {
+	h := newHarness(t, 21)
+
+	var status Status
+	h.GetJSON("/rest/v1/status", &status)
+	if status.Mode != "night" || status.Heating != "off" {
+		t.Fatalf("at 05:00 with 21C: mode %q, heating %q; want night, off", status.Mode, status.Heating)
+	}
+
+	// Past 06:00 the target is the day's 24C.
+	h.Advance(90 * time.Minute)
+	h.SetReading(18.5)
+	h.Advance(10 * time.Second)
+
+	h.GetJSON("/rest/v1/status", &status)
+	if status.Mode != "day" || status.Heating != "on" {
+		t.Fatalf("at 06:30 with 18.5C: mode %q, heating %q; want day, on", status.Mode, status.Heating)
+	}
+}
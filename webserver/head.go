@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+)
+
+// ETag middleware buffers the response of the handler so it can set the
+// Content-Length and ETag headers. For HEAD requests only the headers are
+// sent, which lets a GET handler answer HEAD as well:
+//
+//	r.With(ETag).Get("/", GetTemp)
+//	r.With(ETag).Head("/", GetTemp)
+func ETag(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := &bufferedWriter{header: w.Header(), status: http.StatusOK}
+		next.ServeHTTP(buf, r)
+
+		body := buf.body.Bytes()
+		sum := sha1.Sum(body)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		if buf.status == http.StatusOK {
+			w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		}
+		w.WriteHeader(buf.status)
+		if r.Method != http.MethodHead {
+			w.Write(body)
+		}
+	})
+}
+
+// bufferedWriter collects the status and body written by a handler. The
+// headers go straight to the real writer's header map.
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedWriter) WriteHeader(status int) {
+	b.status = status
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHead(t *testing.T) {
+	h := newHarness(t, 20)
+	for _, path := range []string{"/rest/v1/time", "/rest/v1/temp", "/rest/v1/mode"} {
+		get, body := h.Do(http.MethodGet, path, nil)
+		if get.StatusCode != http.StatusOK || len(body) == 0 || get.Header.Get("ETag") == "" {
+			t.Fatalf("GET %s: %s with ETag %q: %s", path, get.Status, get.Header.Get("ETag"), body)
+		}
+
+		head, body := h.Do(http.MethodHead, path, nil)
+		if head.StatusCode != http.StatusOK || len(body) != 0 {
+			t.Errorf("HEAD %s: %s with a %d byte body, want 200 without one", path, head.Status, len(body))
+		}
+		if head.ContentLength != get.ContentLength || head.Header.Get("ETag") != get.Header.Get("ETag") {
+			t.Errorf("HEAD %s: Content-Length %d and ETag %q, want GET's %d and %q", path,
+				head.ContentLength, head.Header.Get("ETag"), get.ContentLength, get.Header.Get("ETag"))
+		}
+		if head.Header.Get("Content-Type") != get.Header.Get("Content-Type") {
+			t.Errorf("HEAD %s: Content-Type %q, want GET's %q", path, head.Header.Get("Content-Type"), get.Header.Get("Content-Type"))
+		}
+	}
+}
+
+func TestETagSkipsErrors(t *testing.T) {
+	h := ETag(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusServiceUnavailable)
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("ETag") != "" || rec.Body.String() != "nope\n" {
+		t.Errorf("failed GET: %d with ETag %q and body %q, want the 503 as is, without an ETag", rec.Code, rec.Header().Get("ETag"), rec.Body)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+// healthPaths are the health check and metrics routes, which middlewares
+// that hold back or fail requests leave alone: an overloaded server is
+// when they're needed most.
+var healthPaths = map[string]bool{
+	"/livez":   true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+var stateCheckTTL = flag.Duration("state-check-ttl", 10*time.Second, "How long /readyz reuses the result of checking that -state-file can be written and read back")
+
+// ready reports whether the server should receive traffic: it is set once
+// the listener is up and cleared again as soon as shutdown starts draining.
+var ready atomic.Bool
+
+// Livez answers as long as the process is able to serve requests at all.
+func Livez(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+}
+
+// Readyz answers 503 until the server has finished starting up, and again
+// once it is shutting down, so orchestration stops routing traffic to it.
+// It also answers 503 while the state file can't be written, as settings
+// changed then would be lost.
+func Readyz(w http.ResponseWriter, r *http.Request) {
+	if !ready.Load() {
+		render.Render(w, r, ErrUnavailable(withCode(CodeNotReady, errors.New("not ready"))))
+		return
+	}
+	if err := stateCheck.Check(*stateFile, clockOf(r.Context()).Now()); err != nil {
+		render.Render(w, r, ErrUnavailable(withCode(CodeNotReady, err)))
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+// stateChecker checks that the state file's directory takes a sentinel
+// file that reads back the same, and keeps the result for -state-check-ttl
+// so probes don't hit the disk every time.
+type stateChecker struct {
+	mu      sync.Mutex
+	checked time.Time
+	err     error
+}
+
+var stateCheck stateChecker
+
+// Check returns the result of the last check of path, or checks it again
+// if that's older than -state-check-ttl. Nothing is checked without a
+// path.
+func (c *stateChecker) Check(path string, now time.Time) error {
+	if path == "" {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.checked.IsZero() && now.Sub(c.checked) < *stateCheckTTL {
+		return c.err
+	}
+	c.checked, c.err = now, roundTripSentinel(path)
+	return c.err
+}
+
+// roundTripSentinel writes a random sentinel next to path, the way
+// saveState writes the state, reads it back and removes it.
+func roundTripSentinel(path string) error {
+	sentinel := make([]byte, 16)
+	if _, err := rand.Read(sentinel); err != nil {
+		return err
+	}
+	data := []byte(hex.EncodeToString(sentinel))
+
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, base+".probe*")
+	if err != nil {
+		return fmt.Errorf("state file not writable: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("state file not writable: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("state file not writable: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("state file not writable: %w", err)
+	}
+
+	back, err := os.ReadFile(f.Name())
+	if err != nil {
+		return fmt.Errorf("state file not readable: %w", err)
+	}
+	if !bytes.Equal(back, data) {
+		return errors.New("state file sentinel read back differently")
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLivezReadyz(t *testing.T) {
+	t.Cleanup(func() { ready.Store(false) })
+	probe := func(h http.HandlerFunc) int {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Code
+	}
+
+	if code := probe(Livez); code != http.StatusOK {
+		t.Errorf("/livez: %d, want 200", code)
+	}
+	if code := probe(Readyz); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz before startup: %d, want 503", code)
+	}
+	ready.Store(true)
+	if code := probe(Readyz); code != http.StatusOK {
+		t.Errorf("/readyz when ready: %d, want 200", code)
+	}
+}
+
+func TestServeReportsReady(t *testing.T) {
+	t.Cleanup(func() { ready.Store(false) })
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/readyz", Readyz)
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, &http.Server{Addr: addr, Handler: mux}) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !ready.Load() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	resp, err := http.Get("http://" + addr + "/readyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("/readyz while serving: %s, want 200", resp.Status)
+	}
+
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("serve didn't return after the context was done")
+	}
+	if ready.Load() {
+		t.Errorf("still ready after shutting down")
+	}
+}
+
+func TestStateCheck(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	path := filepath.Join(dir, "thermostat.json")
+	var c stateChecker
+
+	if err := c.Check("", harnessStart); err != nil {
+		t.Errorf("no state file: %v", err)
+	}
+	if err := c.Check(path, harnessStart); err == nil {
+		t.Fatalf("state file in a missing directory passed")
+	}
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Check(path, harnessStart.Add(*stateCheckTTL-time.Second)); err == nil {
+		t.Errorf("checked again within -state-check-ttl")
+	}
+	if err := c.Check(path, harnessStart.Add(*stateCheckTTL)); err != nil {
+		t.Errorf("after -state-check-ttl: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("%d files left behind by the check, want none", len(entries))
+	}
+}
+
+func TestReadyzChecksStateFile(t *testing.T) {
+	h := newHarness(t, 20)
+	dir := filepath.Join(t.TempDir(), "state")
+	withFlag(t, stateFile, filepath.Join(dir, "thermostat.json"))
+	withFlag(t, &stateCheck, stateChecker{})
+	ready.Store(true)
+	t.Cleanup(func() { ready.Store(false) })
+
+	resp, body := h.Do(http.MethodGet, "/readyz", nil)
+	if resp.StatusCode != http.StatusServiceUnavailable || !jsonHasCode(body, CodeNotReady) {
+		t.Errorf("GET /readyz with the state file unwritable: %s %s, want 503 %s", resp.Status, body, CodeNotReady)
+	}
+
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	h.Advance(*stateCheckTTL)
+	if resp, body := h.Do(http.MethodGet, "/readyz", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /readyz with the state file writable: %s %s, want 200", resp.Status, body)
+	}
+}
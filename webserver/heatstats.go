@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+/**-----------------------------------------------------------------------------------
+ * get heating stats
+ * =================
+ * $ curl http://bangkokguy.ddns.net/rest/v1/stats/heating?window=today|week
+ *   {"window":"week","from":"2021-11-29T00:00:00+01:00","to":"...","heating_hours":11.5,"cycles":14,
+ *    "average_cycle_minutes":49.29,"longest_on_minutes":95,"on":true}
+ *------------------------------------------------------------------------------------*/
+
+// HeatingStats sums up the heating's runtime over a window: today, or this
+// week from Monday, in -timezone. A cycle is a time the heating was on;
+// the ones running at either end of the window count with their part in
+// it, including the one still on now.
+type HeatingStats struct {
+	Window              string    `json:"window"`
+	From                time.Time `json:"from"`
+	To                  time.Time `json:"to"`
+	HeatingHours        float64   `json:"heating_hours"`
+	Cycles              int       `json:"cycles"`
+	AverageCycleMinutes float64   `json:"average_cycle_minutes"`
+	LongestOnMinutes    float64   `json:"longest_on_minutes"`
+	On                  bool      `json:"on"`
+}
+
+func (s *HeatingStats) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// windowStart returns the start of the window ending at now: midnight for
+// today, midnight on Monday for week.
+func windowStart(window string, now time.Time) (time.Time, error) {
+	y, m, d := now.Date()
+	switch window {
+	case "today":
+		return time.Date(y, m, d, 0, 0, 0, 0, now.Location()), nil
+	case "week":
+		back := (int(now.Weekday()) + 6) % 7 // days since Monday
+		return time.Date(y, m, d-back, 0, 0, 0, 0, now.Location()), nil
+	}
+	return time.Time{}, errors.New("window must be today or week")
+}
+
+// heatingStats works out the stats of window from the transition log
+// (oldest first) and the heating state now.
+func heatingStats(window string, transitions []Transition, current string, now time.Time) (*HeatingStats, error) {
+	from, err := windowStart(window, now)
+	if err != nil {
+		return nil, err
+	}
+	periods := heatingOnPeriods(transitions, current, from, now)
+
+	var total, longest time.Duration
+	for _, p := range periods {
+		d := p.to.Sub(p.from)
+		total += d
+		if d > longest {
+			longest = d
+		}
+	}
+	s := &HeatingStats{
+		Window:           window,
+		From:             from,
+		To:               now,
+		HeatingHours:     round2(total.Hours()),
+		Cycles:           len(periods),
+		LongestOnMinutes: round2(longest.Minutes()),
+		On:               current == "on",
+	}
+	if len(periods) > 0 {
+		s.AverageCycleMinutes = round2(total.Minutes() / float64(len(periods)))
+	}
+	return s, nil
+}
+
+func GetHeatingStats(w http.ResponseWriter, r *http.Request) {
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = "today"
+	}
+	modes, err := storeOf(r.Context()).GetMode()
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	s, err := heatingStats(window, modeHistory.Since(time.Time{}, 0), modes.Heating[0], clockOf(r.Context()).Now())
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(withCode(CodeNotAllowed, err)))
+		return
+	}
+	if err := render.Render(w, r, s); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
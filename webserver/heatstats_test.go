@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeatingStats(t *testing.T) {
+	at := func(day, hour, min int) time.Time { return time.Date(2024, 1, day, hour, min, 0, 0, time.UTC) }
+	heating := func(when time.Time, from, to string) Transition {
+		return Transition{At: when, Type: "heating", From: from, To: to}
+	}
+	// Monday the 15th starts the week; the first period runs across it.
+	log := []Transition{
+		heating(at(14, 20, 0), "on", "off"),
+		heating(at(14, 23, 0), "off", "on"),
+		heating(at(15, 1, 0), "on", "off"),
+		heating(at(15, 8, 0), "off", "on"),
+		{At: at(15, 9, 0), Type: "mode", From: "day", To: "night"},
+		heating(at(15, 9, 30), "on", "off"),
+		heating(at(16, 9, 0), "off", "on"),
+	}
+	now := at(16, 10, 0)
+
+	for _, tc := range []struct {
+		window string
+		log    []Transition
+		want   HeatingStats
+	}{
+		{"week", log, HeatingStats{From: at(15, 0, 0), HeatingHours: 3.5, Cycles: 3, AverageCycleMinutes: 70, LongestOnMinutes: 90, On: true}},
+		{"today", log, HeatingStats{From: at(16, 0, 0), HeatingHours: 1, Cycles: 1, AverageCycleMinutes: 60, LongestOnMinutes: 60, On: true}},
+		// Without the entries before the window, the first one after it
+		// tells the state at its start.
+		{"week", log[2:], HeatingStats{From: at(15, 0, 0), HeatingHours: 3.5, Cycles: 3, AverageCycleMinutes: 70, LongestOnMinutes: 90, On: true}},
+		{"week", nil, HeatingStats{From: at(15, 0, 0), HeatingHours: 34, Cycles: 1, AverageCycleMinutes: 2040, LongestOnMinutes: 2040, On: true}},
+	} {
+		got, err := heatingStats(tc.window, tc.log, "on", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tc.want.Window, tc.want.To = tc.window, now
+		if *got != tc.want {
+			t.Errorf("%s over %d transitions: %+v\nwant %+v", tc.window, len(tc.log), *got, tc.want)
+		}
+	}
+
+	if _, err := heatingStats("month", log, "on", now); err == nil {
+		t.Errorf("window month accepted")
+	}
+}
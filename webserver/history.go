@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+// Sample is a temperature reading, in Celsius.
+type Sample struct {
+	At   time.Time `json:"at"`
+	Temp float64   `json:"temp"`
+}
+
+// SampleLog is a bounded, oldest-first log of temperature readings. Once
+// full, appending drops the oldest sample. With a retention set, appending
+// also drops the samples older than the retention, counted back from the
+// new sample.
+type SampleLog struct {
+	mu        sync.Mutex
+	size      int
+	retention time.Duration
+	samples   []Sample
+}
+
+func NewSampleLog(size int) *SampleLog {
+	return &SampleLog{size: size}
+}
+
+func (l *SampleLog) Append(s Sample) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.samples = append(l.samples, s)
+	drop := 0
+	if len(l.samples) > l.size {
+		drop = len(l.samples) - l.size
+	}
+	if l.retention > 0 {
+		cutoff := s.At.Add(-l.retention)
+		for drop < len(l.samples) && l.samples[drop].At.Before(cutoff) {
+			drop++
+		}
+	}
+	if drop > 0 {
+		l.samples = append(l.samples[:0], l.samples[drop:]...)
+	}
+}
+
+// SetRetention sets how long samples are kept, 0 meaning until the log is
+// full.
+func (l *SampleLog) SetRetention(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.retention = d
+}
+
+// Stats returns the number of samples and the time of the oldest one.
+func (l *SampleLog) Stats() HistoryStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := HistoryStats{Count: len(l.samples), Capacity: l.size}
+	if len(l.samples) > 0 {
+		oldest := l.samples[0].At
+		stats.Oldest = &oldest
+	}
+	return stats
+}
+
+// Since returns the samples taken after since, oldest first.
+func (l *SampleLog) Since(since time.Time) []Sample {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	list := []Sample{}
+	for _, s := range l.samples {
+		if s.At.After(since) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// Span returns the first and the last sample taken from from to to, both
+// included, without copying the log. ok is false if there are none.
+func (l *SampleLog) Span(from, to time.Time) (first, last Sample, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	i := sort.Search(len(l.samples), func(i int) bool { return !l.samples[i].At.Before(from) })
+	j := sort.Search(len(l.samples), func(i int) bool { return l.samples[i].At.After(to) })
+	if i >= j {
+		return Sample{}, Sample{}, false
+	}
+	return l.samples[i], l.samples[j-1], true
+}
+
+var historyRetention = flag.Duration("history-retention", 24*time.Hour, "How long the temperature and mode histories keep entries, besides their size cap; 0 keeps them until full")
+
+// tempHistory keeps the sensor readings, a day's worth at the default
+// -poll-interval.
+var tempHistory = NewSampleLog(8640)
+
+/**-----------------------------------------------------------------------------------
+ * get history diagnostics
+ * =======================
+ * $ curl http://bangkokguy.ddns.net/rest/v1/diagnostics/history
+ *   {"retention":"24h0m0s","temp":{"count":8640,"capacity":8640,"oldest":"..."},"transitions":{"count":12,"capacity":500,"oldest":"..."}}
+ *------------------------------------------------------------------------------------*/
+
+// HistoryStats tells how full a history is.
+type HistoryStats struct {
+	Count    int        `json:"count"`
+	Capacity int        `json:"capacity"`
+	Oldest   *time.Time `json:"oldest,omitempty"`
+}
+
+// HistoryDiagnostics covers the histories kept in memory.
+type HistoryDiagnostics struct {
+	Retention   string       `json:"retention"`
+	Temp        HistoryStats `json:"temp"`
+	Transitions HistoryStats `json:"transitions"`
+}
+
+func (d *HistoryDiagnostics) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func GetHistoryDiagnostics(w http.ResponseWriter, r *http.Request) {
+	d := &HistoryDiagnostics{
+		Retention:   historyRetention.String(),
+		Temp:        tempHistory.Stats(),
+		Transitions: modeHistory.Stats(),
+	}
+	if err := render.Render(w, r, d); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+/**-----------------------------------------------------------------------------------
+ * get temperature histogram
+ * =========================
+ * $ curl http://bangkokguy.ddns.net/rest/v1/temp/histogram?buckets=0.5&window=24h&precision=1
+ *   {"window":"24h","bucket_size":0.5,"count":3,"min":20.1,"max":21.2,"mean":20.6,"median":20.5,
+ *    "buckets":[{"from":20,"to":20.5,"count":1},{"from":20.5,"to":21,"count":1},{"from":21,"to":21.5,"count":1}]}
+ *------------------------------------------------------------------------------------*/
+
+// Bucket counts the samples from From up to, but not including, To.
+type Bucket struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int     `json:"count"`
+}
+
+// Histogram is the distribution of the temperature readings over a
+// window, in Celsius.
+type Histogram struct {
+	Window     string   `json:"window"`
+	BucketSize float64  `json:"bucket_size"`
+	Count      int      `json:"count"`
+	Min        float64  `json:"min"`
+	Max        float64  `json:"max"`
+	Mean       float64  `json:"mean"`
+	Median     float64  `json:"median"`
+	Buckets    []Bucket `json:"buckets"`
+}
+
+func (h *Histogram) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// NewHistogram sorts samples into buckets of size bucketSize, aligned to
+// multiples of it, and sums them up, to decimals. Buckets between the
+// lowest and the highest one are listed even when empty.
+func NewHistogram(samples []Sample, bucketSize float64, decimals int) *Histogram {
+	h := &Histogram{BucketSize: bucketSize, Count: len(samples), Buckets: []Bucket{}}
+	if len(samples) == 0 {
+		return h
+	}
+
+	temps := make([]float64, len(samples))
+	sum := 0.0
+	for i, s := range samples {
+		temps[i] = s.Temp
+		sum += s.Temp
+	}
+	sort.Float64s(temps)
+	h.Min, h.Max = roundFloat(temps[0], decimals), roundFloat(temps[len(temps)-1], decimals)
+	h.Mean = roundFloat(sum/float64(len(temps)), decimals)
+	if n := len(temps); n%2 == 1 {
+		h.Median = roundFloat(temps[n/2], decimals)
+	} else {
+		h.Median = roundFloat((temps[n/2-1]+temps[n/2])/2, decimals)
+	}
+
+	first := bucketIndex(temps[0], bucketSize)
+	last := bucketIndex(temps[len(temps)-1], bucketSize)
+	for i := first; i <= last; i++ {
+		h.Buckets = append(h.Buckets, Bucket{
+			From: round2(i * bucketSize),
+			To:   round2((i + 1) * bucketSize),
+		})
+	}
+	for _, t := range temps {
+		h.Buckets[int(bucketIndex(t, bucketSize)-first)].Count++
+	}
+	return h
+}
+
+// bucketIndex returns the number of the bucket of size bucketSize t falls
+// in. 20.3/0.1 comes out as 202.99999999999997 in floating point, so a
+// value on a bucket's lower bound would land in the bucket below without
+// the slack.
+func bucketIndex(t, bucketSize float64) float64 {
+	return math.Floor(t/bucketSize + 1e-9)
+}
+
+func GetTempHistogram(w http.ResponseWriter, r *http.Request) {
+	bucketSize := 0.5
+	if s := r.URL.Query().Get("buckets"); s != "" {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || f < 0.1 || f > 10 {
+			render.Render(w, r, ErrInvalidRequest(withCode(CodeOutOfRange, errors.New("buckets must be a bucket size between 0.1 and 10"))))
+			return
+		}
+		bucketSize = f
+	}
+	window := 24 * time.Hour
+	if s := r.URL.Query().Get("window"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			render.Render(w, r, ErrInvalidRequest(errors.New("window must be a positive duration like 6h")))
+			return
+		}
+		window = d
+	}
+	precision, err := requestPrecision(r)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	h := NewHistogram(tempHistory.Since(clockOf(r.Context()).Now().Add(-window)), bucketSize, precision)
+	h.Window = window.String()
+	if err := render.Render(w, r, h); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
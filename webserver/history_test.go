@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogramBucketBounds(t *testing.T) {
+	for _, tc := range []struct {
+		temp, size float64
+		from       float64
+	}{
+		{20.3, 0.1, 20.3},
+		{0.3, 0.1, 0.3},
+		{20.5, 0.5, 20.5},
+		{20.49, 0.5, 20},
+		{-1.2, 0.1, -1.2},
+	} {
+		h := NewHistogram([]Sample{{Temp: tc.temp}}, tc.size, 2)
+		if len(h.Buckets) != 1 || h.Buckets[0].From != tc.from {
+			t.Errorf("%g in buckets of %g: %+v, want one from %g", tc.temp, tc.size, h.Buckets, tc.from)
+		}
+	}
+}
+
+func TestHistoryRetention(t *testing.T) {
+	samples, transitions := NewSampleLog(100), NewTransitionLog(100)
+	samples.SetRetention(time.Hour)
+	transitions.SetRetention(time.Hour)
+	var last time.Time
+	for i := 0; i <= 18; i++ { // 3h, every 10 minutes
+		last = harnessStart.Add(time.Duration(i) * 10 * time.Minute)
+		samples.Append(Sample{At: last, Temp: 20})
+		transitions.Append(Transition{At: last, Type: "heating"})
+	}
+	for name, stats := range map[string]HistoryStats{"samples": samples.Stats(), "transitions": transitions.Stats()} {
+		// The one exactly an hour back is kept.
+		if stats.Count != 7 || stats.Oldest == nil || !stats.Oldest.Equal(last.Add(-time.Hour)) {
+			t.Errorf("%s after 3h with 1h retention: %d from %v, want 7 from %s", name, stats.Count, stats.Oldest, last.Add(-time.Hour))
+		}
+	}
+
+	// The size cap still applies within the retention.
+	capped := NewSampleLog(5)
+	capped.SetRetention(time.Hour)
+	for i := 0; i < 10; i++ {
+		capped.Append(Sample{At: harnessStart.Add(time.Duration(i) * time.Minute)})
+	}
+	if stats := capped.Stats(); stats.Count != 5 || !stats.Oldest.Equal(harnessStart.Add(5*time.Minute)) {
+		t.Errorf("a log of 5 after 10 appends: %d from %v, want the last 5", stats.Count, stats.Oldest)
+	}
+}
+
+func TestHistoryDiagnostics(t *testing.T) {
+	h := newHarness(t, 20)
+	tempHistory.SetRetention(2 * time.Minute)
+	for i := 0; i < 5; i++ {
+		h.Advance(time.Minute)
+	}
+	var d HistoryDiagnostics
+	h.GetJSON("/rest/v1/diagnostics/history", &d)
+	if d.Retention != historyRetention.String() || d.Temp.Count != 3 || d.Temp.Capacity != 8640 {
+		t.Errorf("GET /rest/v1/diagnostics/history: %+v, want the 3 samples of the last 2 minutes", d)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultHistoryLimit = 50
+const maxHistoryLimit = 500
+
+var errBadCursor = withCode(CodeBadFormat, errors.New("cursor is invalid"))
+
+// HistoryQuery is what a request to a history endpoint asks for: entries
+// of the given types (all if none) in [Since, Until) (unbounded if zero),
+// at most Limit of them, newest first, older than the entry the cursor of
+// the previous page points to.
+type HistoryQuery struct {
+	Types  map[string]bool
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+	Before int64 // absolute index of the entry the page ends before; -1 for none
+}
+
+// HistoryPage is the envelope of a page of history entries. NextCursor,
+// passed back as ?cursor=, gets the page of older entries; it's empty on
+// the last page.
+type HistoryPage struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"next_cursor"`
+}
+
+func (p *HistoryPage) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// parseHistoryQuery reads ?type=, ?since=, ?until=, ?limit= and ?cursor=
+// from r. type is a comma separated list, of types only.
+func parseHistoryQuery(r *http.Request, types ...string) (HistoryQuery, error) {
+	q := HistoryQuery{Limit: defaultHistoryLimit, Before: -1}
+	query := r.URL.Query()
+
+	if s := query.Get("type"); s != "" {
+		q.Types = map[string]bool{}
+		for _, t := range strings.Split(s, ",") {
+			if !contains(types, t) {
+				return q, withCode(CodeNotAllowed, fmt.Errorf("type must be one of %s", strings.Join(types, ", ")))
+			}
+			q.Types[t] = true
+		}
+	}
+	for name, t := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if s := query.Get(name); s != "" {
+			at, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return q, withCode(CodeBadFormat, fmt.Errorf("%s must be an RFC 3339 timestamp", name))
+			}
+			*t = at
+		}
+	}
+	if !q.Since.IsZero() && !q.Until.IsZero() && !q.Since.Before(q.Until) {
+		return q, withCode(CodeOutOfRange, errors.New("since must be before until"))
+	}
+	if s := query.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxHistoryLimit {
+			return q, withCode(CodeOutOfRange, fmt.Errorf("limit must be an integer between 1 and %d", maxHistoryLimit))
+		}
+		q.Limit = n
+	}
+	if s := query.Get("cursor"); s != "" {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			return q, errBadCursor
+		}
+		n, err := strconv.ParseInt(string(b), 10, 64)
+		if err != nil || n < 0 {
+			return q, errBadCursor
+		}
+		q.Before = n
+	}
+	return q, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// page picks the entries of a log for the query. The log has n entries,
+// oldest first, the first of which is the log's first-th ever; entry
+// returns the time and type of its i-th. page returns the indexes of the
+// picked entries, newest first, and the cursor of the next page.
+func (q HistoryQuery) page(n int, first int64, entry func(i int) (time.Time, string)) ([]int, string) {
+	picked := []int{}
+	for i := n - 1; i >= 0; i-- {
+		if q.Before >= 0 && first+int64(i) >= q.Before {
+			continue
+		}
+		at, kind := entry(i)
+		if q.Types != nil && !q.Types[kind] {
+			continue
+		}
+		if !q.Until.IsZero() && !at.Before(q.Until) {
+			continue
+		}
+		if !q.Since.IsZero() && at.Before(q.Since) {
+			break // older entries are even further out
+		}
+		if len(picked) == q.Limit {
+			last := first + int64(picked[len(picked)-1])
+			return picked, base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(last, 10)))
+		}
+		picked = append(picked, i)
+	}
+	return picked, ""
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+var (
+	identifierKind   = flag.String("identifier", "log", "What makes the device noticeable for POST /device/identify: log, or gpio in builds with the pi tag")
+	identifyDuration = flag.Duration("identify-duration", 10*time.Second, "How long the device blinks or beeps when asked to identify itself")
+	identifyCooldown = flag.Duration("identify-cooldown", 30*time.Second, "How long after identifying ends before the device can be asked to again")
+)
+
+var (
+	errIdentifying      = errors.New("the device is identifying itself already")
+	errIdentifyCooldown = errors.New("the device identified itself just now")
+)
+
+/**-----------------------------------------------------------------------------------
+ * identify device
+ * ===============
+ * $ curl -X POST http://bangkokguy.ddns.net/rest/v1/device/identify
+ *   {"kind":"gpio","active":true,"started":"...","ends":"...","count":1}
+ * $ curl http://bangkokguy.ddns.net/rest/v1/diagnostics/identify
+ *   {"kind":"gpio","active":false,"started":"...","count":1}
+ *------------------------------------------------------------------------------------*/
+
+// Identifier makes the device noticeable among several, by blinking a LED
+// or beeping.
+type Identifier interface {
+	// Identify blinks or beeps until ctx is done.
+	Identify(ctx context.Context) error
+}
+
+// identifierFactories make the identifiers selectable with -identifier.
+// Hardware ones register themselves from files built only for their
+// platform.
+var identifierFactories = map[string]func() (Identifier, error){
+	"log": func() (Identifier, error) { return logIdentifier{}, nil },
+}
+
+// newIdentifier makes the identifier chosen with -identifier.
+func newIdentifier(kind string) (Identifier, error) {
+	f, ok := identifierFactories[kind]
+	if !ok {
+		kinds := make([]string, 0, len(identifierFactories))
+		for kind := range identifierFactories {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		return nil, fmt.Errorf("unknown identifier %q, must be one of %s", kind, strings.Join(kinds, ", "))
+	}
+	return f()
+}
+
+// logIdentifier only logs, for development without hardware.
+type logIdentifier struct{}
+
+func (logIdentifier) Identify(ctx context.Context) error {
+	log.Print("Identifying: *blink*")
+	<-ctx.Done()
+	log.Print("Identifying done")
+	return nil
+}
+
+var identifier Identifier = logIdentifier{}
+
+// IdentifyStatus is the diagnostics of identifying: whether it's going on,
+// since when and until when, and how it went last time.
+type IdentifyStatus struct {
+	Kind      string     `json:"kind"`
+	Active    bool       `json:"active"`
+	Started   *time.Time `json:"started,omitempty"`
+	Ends      *time.Time `json:"ends,omitempty"` // while active
+	Count     int        `json:"count"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+func (s *IdentifyStatus) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+var identifying struct {
+	sync.Mutex
+	IdentifyStatus
+	ended time.Time
+}
+
+// startIdentify has the identifier run for -identify-duration, unless it's
+// running already or ended less than -identify-cooldown ago, in which case
+// it also returns how long until it could run again.
+func startIdentify(now time.Time) (time.Duration, error) {
+	identifying.Lock()
+	defer identifying.Unlock()
+
+	s := &identifying.IdentifyStatus
+	if s.Active {
+		return s.Ends.Sub(now) + *identifyCooldown, errIdentifying
+	}
+	if next := identifying.ended.Add(*identifyCooldown); s.Started != nil && now.Before(next) {
+		return next.Sub(now), errIdentifyCooldown
+	}
+	ends := now.Add(*identifyDuration)
+	s.Active, s.Started, s.Ends, s.LastError = true, &now, &ends, ""
+	s.Count++
+
+	ctx, cancel := context.WithTimeout(context.Background(), *identifyDuration)
+	go func() {
+		defer cancel()
+		err := identifier.Identify(ctx)
+
+		identifying.Lock()
+		defer identifying.Unlock()
+		identifying.Active, identifying.Ends = false, nil
+		identifying.ended = clock.Now()
+		if err != nil {
+			identifying.LastError = err.Error()
+			log.Printf("Identifying failed: %s", err)
+		}
+	}()
+	return 0, nil
+}
+
+func identifyStatus() IdentifyStatus {
+	identifying.Lock()
+	defer identifying.Unlock()
+	status := identifying.IdentifyStatus
+	status.Kind = *identifierKind
+	return status
+}
+
+// Identify has the device blink or beep for -identify-duration, answering
+// 202 as it starts. It's rejected with a 409 while the device is at it,
+// and for -identify-cooldown after.
+func Identify(w http.ResponseWriter, r *http.Request) {
+	if wait, err := startIdentify(clockOf(r.Context()).Now()); err != nil {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Round(time.Second).Seconds())))
+		render.Render(w, r, ErrConflict(err))
+		return
+	}
+	LoggerFrom(r.Context()).Info("identifying")
+
+	status := identifyStatus()
+	render.Status(r, http.StatusAccepted)
+	if err := render.Render(w, r, &status); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+func GetIdentifyDiagnostics(w http.ResponseWriter, r *http.Request) {
+	status := identifyStatus()
+	if err := render.Render(w, r, &status); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
@@ -0,0 +1,61 @@
+//go:build pi
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+var identifyPin = flag.Int("identify-pin", 27, "GPIO pin (BCM numbering) the identify LED or buzzer is wired to, for -identifier=gpio")
+
+func init() {
+	identifierFactories["gpio"] = func() (Identifier, error) { return newGPIOIdentifier(*identifyPin) }
+}
+
+// gpioIdentifier blinks a LED, or sounds a buzzer in bursts, on a Raspberry
+// Pi GPIO pin through the sysfs interface.
+type gpioIdentifier struct {
+	value string
+}
+
+func newGPIOIdentifier(pin int) (*gpioIdentifier, error) {
+	dir := fmt.Sprintf("/sys/class/gpio/gpio%d", pin)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.WriteFile("/sys/class/gpio/export", []byte(strconv.Itoa(pin)), 0); err != nil {
+			return nil, err
+		}
+		// udev needs a moment to make the new files writable.
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "direction"), []byte("out"), 0); err != nil {
+		return nil, err
+	}
+	return &gpioIdentifier{value: filepath.Join(dir, "value")}, nil
+}
+
+func (g *gpioIdentifier) Identify(ctx context.Context) error {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	on := false
+	for {
+		select {
+		case <-ctx.Done():
+			return os.WriteFile(g.value, []byte("0"), 0)
+		case <-ticker.C:
+			on = !on
+			v := "0"
+			if on {
+				v = "1"
+			}
+			if err := os.WriteFile(g.value, []byte(v), 0); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// stuckIdentifier blinks until it's stopped, then fails.
+type stuckIdentifier struct{}
+
+func (stuckIdentifier) Identify(ctx context.Context) error {
+	<-ctx.Done()
+	return errors.New("LED stuck on")
+}
+
+func TestIdentify(t *testing.T) {
+	h := newHarness(t, 20)
+	withFlag(t, &clock, Clock(h.Clock))
+	withFlag(t, &identifier, Identifier(stuckIdentifier{}))
+	withFlag(t, identifyDuration, 20*time.Millisecond)
+	withFlag(t, identifyCooldown, time.Minute)
+	t.Cleanup(func() {
+		identifying.Lock()
+		identifying.IdentifyStatus, identifying.ended = IdentifyStatus{}, time.Time{}
+		identifying.Unlock()
+	})
+
+	identify := func(want int, retryAfter string) {
+		t.Helper()
+		resp, body := h.Do(http.MethodPost, "/rest/v1/device/identify", nil)
+		if resp.StatusCode != want || resp.Header.Get("Retry-After") != retryAfter {
+			t.Fatalf("POST /rest/v1/device/identify: %s, Retry-After %q: %s\nwant %d, Retry-After %q",
+				resp.Status, resp.Header.Get("Retry-After"), body, want, retryAfter)
+		}
+	}
+
+	identify(http.StatusAccepted, "")
+	var s IdentifyStatus
+	h.GetJSON("/rest/v1/diagnostics/identify", &s)
+	if !s.Active || s.Count != 1 || s.Ends == nil || !s.Ends.Equal(harnessStart.Add(*identifyDuration)) {
+		t.Errorf("identifying: %+v", s)
+	}
+	identify(http.StatusConflict, "60")
+
+	s = identified(h)
+	if s.Active || s.Ends != nil || s.LastError != "LED stuck on" {
+		t.Fatalf("after -identify-duration: %+v, want done with the identifier's error", s)
+	}
+	identify(http.StatusConflict, "60")
+
+	h.Advance(time.Minute)
+	identify(http.StatusAccepted, "")
+	s = IdentifyStatus{}
+	h.GetJSON("/rest/v1/diagnostics/identify", &s)
+	if s.Count != 2 || s.LastError != "" {
+		t.Errorf("identifying again: %+v", s)
+	}
+	identified(h)
+}
+
+// identified waits for the identifier to be done, as it runs on real time.
+func identified(h *harness) IdentifyStatus {
+	h.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var s IdentifyStatus
+		h.GetJSON("/rest/v1/diagnostics/identify", &s)
+		if !s.Active || time.Now().After(deadline) {
+			return s
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,81 @@
+// Package testutil has the fakes the server's tests drive it with: a clock
+// that only moves when told to, and a sensor that reads what it's told to.
+package testutil
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a clock that stands still until Advance or Set moves it.
+// Its tickers fire as it moves past their next tick, at most once per
+// move, dropping ticks nobody read like a time.Ticker does.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+type fakeTicker struct {
+	c    chan time.Time
+	d    time.Duration
+	next time.Time
+}
+
+// NewFakeClock returns a clock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker returns a channel that gets the time whenever the clock moves
+// past the next multiple of d from now, and a function that stops it.
+func (c *FakeClock) NewTicker(d time.Duration) (<-chan time.Time, func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{c: make(chan time.Time, 1), d: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t.c, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i, other := range c.tickers {
+			if other == t {
+				c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Advance moves the clock forward by d and returns the new time.
+func (c *FakeClock) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	now := c.now.Add(d)
+	c.mu.Unlock()
+	c.Set(now)
+	return now
+}
+
+// Set moves the clock to now, firing the tickers it passes.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+	for _, t := range c.tickers {
+		if now.Before(t.next) {
+			continue
+		}
+		for !now.Before(t.next) {
+			t.next = t.next.Add(t.d)
+		}
+		select {
+		case t.c <- now:
+		default:
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package testutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNoScript is what a ScriptedSensor reads before it's given a value.
+var ErrNoScript = errors.New("testutil: the sensor has nothing to read")
+
+// ScriptedSensor reads the values it's given, in order, and then keeps
+// reading the last one. It can be made to fail, or to take its time.
+type ScriptedSensor struct {
+	mu       sync.Mutex
+	values   []float64
+	err      error
+	delay    time.Duration
+	ignore   bool
+	reads    int
+	inflight int
+}
+
+// NewScriptedSensor returns a sensor that reads values, in order.
+func NewScriptedSensor(values ...float64) *ScriptedSensor {
+	return &ScriptedSensor{values: values}
+}
+
+// Set makes the sensor read v from now on, dropping what's left of the
+// script.
+func (s *ScriptedSensor) Set(v float64) {
+	s.Script(v)
+}
+
+// Script makes the sensor read values, in order, from now on.
+func (s *ScriptedSensor) Script(values ...float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values, s.err = values, nil
+}
+
+// Fail makes the sensor's reads fail with err, until it's given a value.
+func (s *ScriptedSensor) Fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// Delay makes every read take d of real time. A read gives up early when
+// its context is done, unless ignoreCtx, like a driver that can't be
+// interrupted.
+func (s *ScriptedSensor) Delay(d time.Duration, ignoreCtx bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay, s.ignore = d, ignoreCtx
+}
+
+// Reads returns how many reads were started.
+func (s *ScriptedSensor) Reads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reads
+}
+
+// InFlight returns how many reads haven't returned yet.
+func (s *ScriptedSensor) InFlight() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inflight
+}
+
+func (s *ScriptedSensor) Read(ctx context.Context) (float64, error) {
+	s.mu.Lock()
+	s.reads++
+	s.inflight++
+	delay, ignore := s.delay, s.ignore
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.inflight--
+		s.mu.Unlock()
+	}()
+
+	if delay > 0 {
+		t := time.NewTimer(delay)
+		defer t.Stop()
+		if ignore {
+			<-t.C
+		} else {
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return 0, s.err
+	}
+	if len(s.values) == 0 {
+		return 0, ErrNoScript
+	}
+	v := s.values[0]
+	if len(s.values) > 1 {
+		s.values = s.values[1:]
+	}
+	return v, nil
+}
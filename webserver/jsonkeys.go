@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/go-chi/render"
+)
+
+var jsonKeys = flag.String("json-keys", "default", "Naming of the keys in JSON responses: default (as tagged, e.g. daytemp), snake (day_temp) or camel (dayTemp)")
+
+// checkJSONKeys makes sure -json-keys names a known convention.
+func checkJSONKeys() error {
+	switch *jsonKeys {
+	case "default", "snake", "camel":
+		return nil
+	}
+	return fmt.Errorf("unknown -json-keys %q, must be default, snake or camel", *jsonKeys)
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// orderedObject is a JSON object that keeps its keys in the order of the
+// struct fields it was made from.
+type orderedObject []struct {
+	key   string
+	value interface{}
+}
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, kv := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(kv.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(kv.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// renameKeys returns v with the keys of the objects made from structs
+// renamed to style, "snake" or "camel". It marshals to the same JSON as v
+// otherwise. Keys of maps are data, so they are left alone.
+func renameKeys(v interface{}, style string) interface{} {
+	return renameValue(reflect.ValueOf(v), style)
+}
+
+func renameValue(v reflect.Value, style string) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	t := v.Type()
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return v.Interface()
+	}
+	if v.CanAddr() && (reflect.PointerTo(t).Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)) {
+		return v.Addr().Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return renameValue(v.Elem(), style)
+	case reflect.Struct:
+		obj := orderedObject{}
+		renameFields(v, style, &obj)
+		return obj
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = renameValue(iter.Value(), style)
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			return v.Interface() // []byte marshals to base64
+		}
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = renameValue(v.Index(i), style)
+		}
+		return list
+	}
+	return v.Interface()
+}
+
+// renameFields adds the fields of the struct v to obj, the way
+// encoding/json would, flattening embedded structs.
+func renameFields(v reflect.Value, style string, obj *orderedObject) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fv := v.Field(i)
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv, ft = fv.Elem(), ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				renameFields(fv, style, obj)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if strings.Contains(","+opts+",", ",omitempty,") && isEmptyValue(fv) {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		*obj = append(*obj, struct {
+			key   string
+			value interface{}
+		}{styleKey(keyWords(f.Name, name), style), renameValue(fv, style)})
+	}
+}
+
+// isEmptyValue tells the values omitempty leaves out, like encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// keyWords splits a JSON key into words: at underscores, or where the Go
+// field name has them if the key is just the field name run together, as
+// in DayTemp and daytemp. Any other key is a single word.
+func keyWords(field, key string) []string {
+	if strings.Contains(key, "_") {
+		return strings.Split(key, "_")
+	}
+	if strings.ToLower(field) != key {
+		return []string{key}
+	}
+
+	var words []string
+	runes := []rune(field)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		// A word starts at an upper case letter after a lower case one,
+		// or at the last capital of an acronym followed by lower case, as
+		// in the T of "IDTemp".
+		if unicode.IsUpper(runes[i]) && (unicode.IsLower(runes[i-1]) ||
+			i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			words = append(words, strings.ToLower(string(runes[start:i])))
+			start = i
+		}
+	}
+	return append(words, strings.ToLower(string(runes[start:])))
+}
+
+func styleKey(words []string, style string) string {
+	switch style {
+	case "camel":
+		for i := 1; i < len(words); i++ {
+			if words[i] != "" {
+				words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+			}
+		}
+		return strings.Join(words, "")
+	case "snake":
+		return strings.Join(words, "_")
+	}
+	return strings.Join(words, "")
+}
+
+// normalizeKey reduces a key to what all conventions have in common.
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", ""))
+}
+
+// canonicalKeys renames the keys of data, a decoded JSON value, to the
+// keys tagged on t where they only differ in convention, so "dayTemp" and
+// "day_temp" both decode into the field tagged "daytemp".
+func canonicalKeys(data interface{}, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch d := data.(type) {
+	case map[string]interface{}:
+		if t.Kind() == reflect.Map {
+			for k, v := range d {
+				d[k] = canonicalKeys(v, t.Elem())
+			}
+			return d
+		}
+		if t.Kind() != reflect.Struct {
+			return d
+		}
+		fields := map[string]reflect.StructField{}
+		collectFields(t, fields)
+		out := make(map[string]interface{}, len(d))
+		for k, v := range d {
+			if f, ok := fields[normalizeKey(k)]; ok {
+				name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+				if name == "" {
+					name = f.Name
+				}
+				out[name] = canonicalKeys(v, f.Type)
+				continue
+			}
+			out[k] = v
+		}
+		return out
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i := range d {
+				d[i] = canonicalKeys(d[i], t.Elem())
+			}
+		}
+	}
+	return data
+}
+
+// collectFields maps the normalized JSON keys of the struct t to their
+// fields, including those of embedded structs.
+func collectFields(t reflect.Type, fields map[string]reflect.StructField) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				collectFields(ft, fields)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[normalizeKey(name)] = f
+	}
+}
+
+// decodeJSON decodes the JSON in r into v, accepting keys in any naming
+// convention. With strict set, keys v has no field for are rejected.
+func decodeJSON(r io.Reader, v interface{}, strict bool) error {
+	var data interface{}
+	dec := json.NewDecoder(r)
+	dec.UseNumber() // keep numbers as they were written
+	if err := dec.Decode(&data); err != nil {
+		return err
+	}
+	canonical, err := json.Marshal(canonicalKeys(data, reflect.TypeOf(v)))
+	if err != nil {
+		return err
+	}
+	dec = json.NewDecoder(bytes.NewReader(canonical))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
+func init() {
+	render.Decode = func(r *http.Request, v interface{}) error {
+		if render.GetRequestContentType(r) != render.ContentTypeJSON {
+			return render.DefaultDecoder(r, v)
+		}
+		defer io.Copy(io.Discard, r.Body)
+		return decodeJSON(r.Body, v, false)
+	}
+}
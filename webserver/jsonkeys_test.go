@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestKeyWords(t *testing.T) {
+	for _, tc := range []struct {
+		field, key string
+		snake      string
+		camel      string
+	}{
+		{"DayTemp", "daytemp", "day_temp", "dayTemp"},
+		{"NextCursor", "next_cursor", "next_cursor", "nextCursor"},
+		{"IDTemp", "idtemp", "id_temp", "idTemp"},
+		{"SSID", "ssid", "ssid", "ssid"},
+		{"Mode", "Mode", "Mode", "Mode"},
+		{"Thereshold", "limit", "limit", "limit"},
+	} {
+		words := keyWords(tc.field, tc.key)
+		if got := styleKey(append([]string(nil), words...), "snake"); got != tc.snake {
+			t.Errorf("%s %q in snake case: %q, want %q", tc.field, tc.key, got, tc.snake)
+		}
+		if got := styleKey(words, "camel"); got != tc.camel {
+			t.Errorf("%s %q in camel case: %q, want %q", tc.field, tc.key, got, tc.camel)
+		}
+	}
+}
+
+func TestRenameKeys(t *testing.T) {
+	type inner struct {
+		NightTemp TempValue `json:"nighttemp"`
+	}
+	type outer struct {
+		inner
+		DayTemp  TempValue          `json:"daytemp"`
+		Skipped  string             `json:"skipped_value,omitempty"`
+		Readings map[string]float64 `json:"by_sensor"`
+		List     []inner            `json:"recent_items"`
+	}
+	v := outer{
+		inner:    inner{NightTemp: "18.00"},
+		DayTemp:  "24.00",
+		Readings: map[string]float64{"attic_room": 19.5},
+		List:     []inner{{NightTemp: "17.00"}},
+	}
+	b, err := json.Marshal(renameKeys(&v, "camel"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"nightTemp":"18.00","dayTemp":"24.00","bySensor":{"attic_room":19.5},"recentItems":[{"nightTemp":"17.00"}]}`
+	if string(b) != want {
+		t.Errorf("camel case:\n%s\nwant\n%s", b, want)
+	}
+}
+
+func TestJSONKeysFlag(t *testing.T) {
+	h := newHarness(t, 20)
+	withFlag(t, jsonKeys, "snake")
+	resp, body := h.Do(http.MethodGet, "/rest/v1/temp", nil)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"day_temp":`) {
+		t.Errorf("GET /rest/v1/temp with -json-keys=snake: %s %s, want day_temp", resp.Status, body)
+	}
+
+	withFlag(t, jsonKeys, "kebab")
+	if err := checkJSONKeys(); err == nil {
+		t.Errorf("-json-keys=kebab accepted")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var adminKeyFile = flag.String("admin-key-file", "", "File holding the admin API key, such as a mounted secret, reread when it changes; takes precedence over -admin-key, which keeps it out of process listings")
+
+var errEmptyKeyFile = errors.New("key file is empty")
+
+// activeAdminKey is the admin key in use: the one last read from
+// -admin-key-file if set, or else -admin-key.
+var activeAdminKey struct {
+	sync.RWMutex
+	key string
+}
+
+func currentAdminKey() string {
+	activeAdminKey.RLock()
+	defer activeAdminKey.RUnlock()
+	return activeAdminKey.key
+}
+
+// setAdminKey makes key, from -admin-key, the admin key, unless there's
+// -admin-key-file, which takes precedence.
+func setAdminKey(key string) {
+	if *adminKeyFile != "" {
+		return
+	}
+	activeAdminKey.Lock()
+	defer activeAdminKey.Unlock()
+	activeAdminKey.key = key
+}
+
+// loadAdminKey reads the admin key from path, trimming the whitespace and
+// newlines around it, and reports whether it changed. An empty file is
+// an error, leaving the key as it was, rather than closing the admin
+// routes mid-rotation.
+func loadAdminKey(path string) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return false, errEmptyKeyFile
+	}
+
+	activeAdminKey.Lock()
+	defer activeAdminKey.Unlock()
+	changed := activeAdminKey.key != key
+	activeAdminKey.key = key
+	return changed, nil
+}
+
+// watchAdminKey rereads the admin key whenever anything in the directory
+// of path changes, until ctx is done. The whole directory is watched, as
+// mounted secrets are swapped in through a symlink rather than written.
+func watchAdminKey(ctx context.Context, path string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			changed, err := loadAdminKey(path)
+			if err != nil {
+				log.Printf("Reloading the admin key failed, keeping the old one: %s", err)
+				continue
+			}
+			if changed {
+				log.Printf("Reloaded the admin key from %s", path)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("Watching the admin key failed: %s", err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadAdminKey(t *testing.T) {
+	resetState(t)
+	path := filepath.Join(t.TempDir(), "admin-key")
+	withFlag(t, adminKeyFile, path)
+
+	os.WriteFile(path, []byte("  first-key\n"), 0o600)
+	if changed, err := loadAdminKey(path); err != nil || !changed || currentAdminKey() != "first-key" {
+		t.Fatalf("loading: changed %t, %v, key %q", changed, err, currentAdminKey())
+	}
+	if changed, err := loadAdminKey(path); err != nil || changed {
+		t.Errorf("loading it again: changed %t, %v", changed, err)
+	}
+
+	os.WriteFile(path, []byte("\n"), 0o600)
+	if _, err := loadAdminKey(path); err != errEmptyKeyFile || currentAdminKey() != "first-key" {
+		t.Errorf("loading an empty file: %v, key %q, want the old one kept", err, currentAdminKey())
+	}
+
+	setAdminKey("flag-key")
+	if currentAdminKey() != "first-key" {
+		t.Errorf("-admin-key %q took precedence over -admin-key-file", currentAdminKey())
+	}
+}
+
+func TestWatchAdminKey(t *testing.T) {
+	h := newHarness(t, 20)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "admin-key")
+	withFlag(t, adminKeyFile, path)
+	os.WriteFile(path, []byte("first-key"), 0o600)
+	if _, err := loadAdminKey(path); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- watchAdminKey(ctx, path) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	// Swap the key in the way a mounted secret is, by renaming over it.
+	// The watcher may not be watching yet, so keep swapping until it sees
+	// one.
+	deadline := time.Now().Add(5 * time.Second)
+	for currentAdminKey() != "second-key" && time.Now().Before(deadline) {
+		tmp := filepath.Join(dir, "..data")
+		os.WriteFile(tmp, []byte("second-key\n"), 0o600)
+		os.Rename(tmp, path)
+		time.Sleep(20 * time.Millisecond)
+	}
+	if key := currentAdminKey(); key != "second-key" {
+		t.Fatalf("key %q after swapping the file, want it reread", key)
+	}
+
+	if resp, _ := h.Do(http.MethodGet, "/admin/sessions", nil, "Authorization", "Bearer first-key"); resp.StatusCode == http.StatusOK {
+		t.Errorf("the old key still works")
+	}
+	if resp, body := h.Do(http.MethodGet, "/admin/sessions", nil, "Authorization", "Bearer second-key"); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /admin/sessions with the new key: %s %s", resp.Status, body)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var logSample = flag.String("log-sample", "", "Only log one in N requests to noisy paths, as path=N pairs separated by commas, e.g. /readyz=10,/rest/v1/articles=5")
+
+// parseLogSample parses the -log-sample pairs.
+func parseLogSample(s string) (map[string]int, error) {
+	sample := map[string]int{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		path, every, ok := strings.Cut(pair, "=")
+		n, err := strconv.Atoi(every)
+		if !ok || !strings.HasPrefix(path, "/") || err != nil || n < 1 {
+			return nil, fmt.Errorf("-log-sample: %q must be path=N with N at least 1", pair)
+		}
+		sample[path] = n
+	}
+	return sample, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+/**-----------------------------------------------------------------------------------
+ * get metrics
+ * ===========
+ * $ curl http://bangkokguy.ddns.net/metrics
+ *   # HELP http_requests_total Requests served, by method, route template and status class.
+ *   # TYPE http_requests_total counter
+ *   http_requests_total{method="GET",route="/rest/v1/articles/{articleID}",status="2xx"} 2
+ *   http_requests_total{method="PUT",route="/rest/v1/temp",status="4xx"} 1
+ *   # HELP http_panics_total Panics recovered from in handlers.
+ *   # TYPE http_panics_total counter
+ *   http_panics_total 0
+ *   # HELP log_lines_dropped_total Log lines dropped because the log buffer was full.
+ *   # TYPE log_lines_dropped_total counter
+ *   log_lines_dropped_total 0
+ *------------------------------------------------------------------------------------*/
+
+// requestKey labels a request count. Route is the route template, not the
+// path, so there's a count per route rather than per article.
+type requestKey struct {
+	method, route, class string
+}
+
+var requestCounts = struct {
+	sync.Mutex
+	m map[requestKey]int64
+}{m: map[requestKey]int64{}}
+
+// CountRequests middleware counts the requests by method, route template
+// and status class. Requests that match no route count under "other", and
+// the ones that panic as 5xx, which the recoverer makes them.
+func CountRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		done := false
+		defer func() {
+			status := ww.Status()
+			if !done {
+				status = http.StatusInternalServerError
+			} else if status == 0 {
+				status = http.StatusOK
+			}
+			route := "other"
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			key := requestKey{method: r.Method, route: route, class: fmt.Sprintf("%dxx", status/100)}
+
+			requestCounts.Lock()
+			requestCounts.m[key]++
+			requestCounts.Unlock()
+		}()
+		next.ServeHTTP(ww, r)
+		done = true
+	})
+}
+
+// GetMetrics serves the request and panic counts, and the log lines
+// dropped with -log-buffer, in the Prometheus text format.
+func GetMetrics(w http.ResponseWriter, r *http.Request) {
+	requestCounts.Lock()
+	lines := make([]string, 0, len(requestCounts.m))
+	for key, n := range requestCounts.m {
+		lines = append(lines, fmt.Sprintf("http_requests_total{method=%q,route=%q,status=%q} %d", key.method, key.route, key.class, n))
+	}
+	requestCounts.Unlock()
+	sort.Strings(lines)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprintln(w, "# HELP http_requests_total Requests served, by method, route template and status class.")
+	fmt.Fprintln(w, "# TYPE http_requests_total counter")
+	fmt.Fprint(w, strings.Join(lines, "\n"))
+	if len(lines) > 0 {
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w, "# HELP http_panics_total Panics recovered from in handlers.")
+	fmt.Fprintln(w, "# TYPE http_panics_total counter")
+	fmt.Fprintf(w, "http_panics_total %d\n", panics.Load())
+	if logs != nil {
+		fmt.Fprintln(w, "# HELP log_lines_dropped_total Log lines dropped because the log buffer was full.")
+		fmt.Fprintln(w, "# TYPE log_lines_dropped_total counter")
+		fmt.Fprintf(w, "log_lines_dropped_total %d\n", logs.Dropped())
+	}
+}
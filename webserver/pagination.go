@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+const defaultPerPage = 20
+const maxPerPage = 100
+
+// Page is the page of a listing a request asks for with ?page= and
+// ?per_page=, counting from 1.
+type Page struct {
+	Number  int
+	PerPage int
+}
+
+type pageCtxKey struct{}
+
+// paginate middleware reads the page a request asks for onto its context,
+// rejecting page numbers and sizes that aren't positive integers.
+func paginate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := Page{Number: 1, PerPage: defaultPerPage}
+		if s := r.URL.Query().Get("page"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				render.Render(w, r, ErrInvalidRequest(errors.New("page must be a positive integer")))
+				return
+			}
+			page.Number = n
+		}
+		if s := r.URL.Query().Get("per_page"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || n > maxPerPage {
+				render.Render(w, r, ErrInvalidRequest(withCode(CodeOutOfRange,
+					fmt.Errorf("per_page must be an integer between 1 and %d", maxPerPage))))
+				return
+			}
+			page.PerPage = n
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), pageCtxKey{}, page)))
+	})
+}
+
+// pageOf returns the page put on the request context by paginate, or the
+// first page if there is none.
+func pageOf(r *http.Request) Page {
+	if page, ok := r.Context().Value(pageCtxKey{}).(Page); ok {
+		return page
+	}
+	return Page{Number: 1, PerPage: defaultPerPage}
+}
+
+// PageInfo is the pagination metadata of a listing.
+type PageInfo struct {
+	Page       int `json:"page"`
+	PerPage    int `json:"per_page"`
+	Total      int `json:"total"`
+	TotalPages int `json:"total_pages"`
+}
+
+// bounds returns the slice bounds of the page within total items, along
+// with its metadata. A page past the end is empty.
+func (p Page) bounds(total int) (start, end int, info PageInfo) {
+	info = PageInfo{Page: p.Number, PerPage: p.PerPage, Total: total}
+	info.TotalPages = (total + p.PerPage - 1) / p.PerPage
+	start = (p.Number - 1) * p.PerPage
+	if start > total {
+		start = total
+	}
+	end = start + p.PerPage
+	if end > total {
+		end = total
+	}
+	return start, end, info
+}
+
+// setLinkHeader sets a Link header with the first, last, and if there are
+// any, the previous and next pages, as URLs relative to the host that keep
+// the request's other query parameters.
+func setLinkHeader(w http.ResponseWriter, r *http.Request, info PageInfo) {
+	link := func(page int, rel string) string {
+		q := r.URL.Query()
+		q.Set("page", strconv.Itoa(page))
+		q.Set("per_page", strconv.Itoa(info.PerPage))
+		u := *r.URL
+		u.RawQuery = q.Encode()
+		return fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel)
+	}
+
+	last := info.TotalPages
+	if last < 1 {
+		last = 1
+	}
+	links := []string{link(1, "first")}
+	if info.Page > 1 {
+		prev := info.Page - 1
+		if prev > last {
+			prev = last
+		}
+		links = append(links, link(prev, "prev"))
+	}
+	if info.Page < last {
+		links = append(links, link(info.Page+1, "next"))
+	}
+	links = append(links, link(last, "last"))
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
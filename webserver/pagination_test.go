@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPageBounds(t *testing.T) {
+	for _, tc := range []struct {
+		page       Page
+		total      int
+		start, end int
+		pages      int
+	}{
+		{Page{1, 20}, 0, 0, 0, 0},
+		{Page{1, 20}, 5, 0, 5, 1},
+		{Page{2, 2}, 5, 2, 4, 3},
+		{Page{3, 2}, 5, 4, 5, 3},
+		{Page{9, 2}, 5, 5, 5, 3},
+	} {
+		start, end, info := tc.page.bounds(tc.total)
+		if start != tc.start || end != tc.end || info.TotalPages != tc.pages || info.Total != tc.total {
+			t.Errorf("%+v of %d: [%d:%d] of %d pages, want [%d:%d] of %d", tc.page, tc.total, start, end, info.TotalPages, tc.start, tc.end, tc.pages)
+		}
+	}
+}
+
+func TestListArticlesPages(t *testing.T) {
+	h := newHarness(t, 20)
+	articles, err := h.Store.ListArticles(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := len(articles)
+	if total < 3 {
+		t.Fatalf("the store has %d articles, want at least 3 to page through", total)
+	}
+	last := (total + 1) / 2
+
+	resp, body := h.Do(http.MethodGet, "/rest/v1/?page=2&per_page=2&expand=user", nil)
+	var page ArticlePageResponse
+	if err := json.Unmarshal(body, &page); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /rest/v1/?page=2: %s %s", resp.Status, body)
+	}
+	items := 2
+	if total == 3 {
+		items = 1
+	}
+	if len(page.Items) != items || page.Page != 2 || page.Total != total || page.TotalPages != last {
+		t.Errorf("page 2 of %d: %d items, %+v", total, len(page.Items), page.PageInfo)
+	}
+	// The trailing slash is gone by the time the links are made.
+	link := resp.Header.Get("Link")
+	for _, want := range []string{
+		`</rest/v1?expand=user&page=1&per_page=2>; rel="first"`,
+		`</rest/v1?expand=user&page=1&per_page=2>; rel="prev"`,
+		fmt.Sprintf(`</rest/v1?expand=user&page=%d&per_page=2>; rel="last"`, last),
+	} {
+		if !strings.Contains(link, want) {
+			t.Errorf("Link %s\nlacks %s", link, want)
+		}
+	}
+	if hasNext := strings.Contains(link, `rel="next"`); hasNext != (last > 2) {
+		t.Errorf("Link %s: next link %t on page 2 of %d", link, hasNext, last)
+	}
+
+	for _, query := range []string{"page=0", "page=x", "per_page=101"} {
+		if resp, body := h.Do(http.MethodGet, "/rest/v1/?"+query, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("GET /rest/v1/?%s: %s %s, want 400", query, resp.Status, body)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+var (
+	panicAlerts      = flag.Bool("panic-alerts", false, "Send the panics handlers recover from, with their stacks, to -webhook-url")
+	panicAlertWindow = flag.Duration("panic-alert-window", 10*time.Minute, "How long the same panic on the same path is only counted after it's been sent with -panic-alerts")
+)
+
+// PanicAlert reports a panic recovered from in a handler. Suppressed is
+// how many times the same panic happened since it was last reported.
+type PanicAlert struct {
+	At         time.Time `json:"at"`
+	Message    string    `json:"message"`
+	Stack      string    `json:"stack"`
+	RequestID  string    `json:"request_id"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Suppressed int       `json:"suppressed,omitempty"`
+}
+
+// panicAlerter sends panic alerts, at most one per window for the same
+// panic on the same path, counting the others.
+type panicAlerter struct {
+	window time.Duration
+	send   func(PanicAlert)
+
+	mu   sync.Mutex
+	seen map[string]*panicSeen
+}
+
+type panicSeen struct {
+	sent       time.Time
+	suppressed int
+}
+
+func newPanicAlerter(window time.Duration, send func(PanicAlert)) *panicAlerter {
+	return &panicAlerter{window: window, send: send, seen: map[string]*panicSeen{}}
+}
+
+// OnPanic is the hook the recoverer calls.
+func (a *panicAlerter) OnPanic(r *http.Request, rvr interface{}, stack []byte) {
+	now := clockOf(r.Context()).Now()
+	alert := PanicAlert{
+		At:        now,
+		Message:   fmt.Sprint(rvr),
+		Stack:     string(stack),
+		RequestID: middleware.GetReqID(r.Context()),
+		Method:    r.Method,
+		Path:      r.URL.Path,
+	}
+	key := alert.Path + "\x00" + alert.Message
+
+	a.mu.Lock()
+	for k, seen := range a.seen {
+		if now.Sub(seen.sent) >= a.window && seen.suppressed == 0 {
+			delete(a.seen, k)
+		}
+	}
+	seen, ok := a.seen[key]
+	if ok && now.Sub(seen.sent) < a.window {
+		seen.suppressed++
+		a.mu.Unlock()
+		return
+	}
+	if ok {
+		alert.Suppressed = seen.suppressed
+	}
+	a.seen[key] = &panicSeen{sent: now}
+	a.mu.Unlock()
+
+	a.send(alert)
+}
+
+// sendPanicAlert sends a panic alert to the webhook only. The stack and the
+// panic value are for the operator: the live clients of /rest/v1/events
+// and /rest/v1/ws aren't authenticated.
+func sendPanicAlert(alert PanicAlert) {
+	postWebhook(Event{Type: "panic", Source: "recoverer", Data: alert})
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPanicAlertsOnlyReachTheWebhook(t *testing.T) {
+	resetState(t)
+	hooked := make(chan Event, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e Event
+		json.NewDecoder(r.Body).Decode(&e)
+		hooked <- e
+	}))
+	t.Cleanup(hook.Close)
+	withFlag(t, webhookURL, hook.URL)
+
+	cfg := flagRouterConfig()
+	cfg.PanicAlerts = true
+	srv := mountRoutes(t, map[string]Deps{"/": {Store: NewInMemoryStore(), Config: cfg}})
+	events, unsubscribe, err := broker.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsubscribe()
+
+	if status, _ := send(t, srv, http.MethodGet, "/panic", ""); status != http.StatusInternalServerError {
+		t.Fatalf("GET /panic: %d, want 500", status)
+	}
+	select {
+	case e := <-hooked:
+		if e.Type != "panic" {
+			t.Errorf("webhook got a %q event, want panic", e.Type)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the webhook got no panic alert")
+	}
+	select {
+	case e := <-events:
+		if e.Type == "panic" {
+			t.Errorf("a live client got the panic alert: %+v", e)
+		}
+	default:
+	}
+}
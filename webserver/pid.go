@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+/**-----------------------------------------------------------------------------------
+ * PID control
+ * ===========
+ * $ curl -X PUT -H 'Content-Type: application/json' -d '{"enabled":true,"kp":40,"ki":0.02,"kd":600}' http://bangkokguy.ddns.net/rest/v1/temp/pid
+ *   {"enabled":true,"kp":40,"ki":0.02,"kd":600}
+ * $ curl http://bangkokguy.ddns.net/rest/v1/mode
+ *   {"Mode":["day","auto"],"Heating":["on","auto"],"pid_output":62.5}
+ *------------------------------------------------------------------------------------*/
+
+var pidCycle = flag.Duration("pid-cycle", 10*time.Minute, "Period over which a relay that can only switch on and off is kept on for the PID output's share of the time")
+
+// PIDConfig sets up PID control, which replaces the on/off threshold band
+// while the heating is on auto. The output, 0 to 100%, is Kp times the
+// error (target minus temperature, in Celsius), plus Ki times its integral
+// over seconds, plus Kd times its derivative per second.
+type PIDConfig struct {
+	Enabled bool    `json:"enabled"`
+	Kp      float64 `json:"kp" validate:"min=0,max=1000"`
+	Ki      float64 `json:"ki" validate:"min=0,max=10"`
+	Kd      float64 `json:"kd" validate:"min=0,max=100000"`
+}
+
+func (c *PIDConfig) Bind(r *http.Request) error {
+	return nil
+}
+
+func (c *PIDConfig) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// PID is a PID controller with an output of 0 to 100.
+type PID struct {
+	Kp, Ki, Kd float64
+
+	integral float64
+	prevErr  float64
+	prevAt   time.Time
+	output   float64
+}
+
+// Update computes the output for a measurement at now. Against windup, the
+// error isn't integrated while the output is saturated in its direction,
+// and the integral term alone is held to the output range.
+func (p *PID) Update(target, measured float64, now time.Time) float64 {
+	e := target - measured
+	var dt, derivative float64
+	if !p.prevAt.IsZero() {
+		dt = now.Sub(p.prevAt).Seconds()
+	}
+	if dt > 0 {
+		derivative = (e - p.prevErr) / dt
+	}
+
+	integral := p.integral + e*dt
+	out := p.Kp*e + p.Ki*integral + p.Kd*derivative
+	if out > 100 && e > 0 || out < 0 && e < 0 {
+		integral = p.integral
+	}
+	if p.Ki > 0 {
+		integral = math.Max(-100/p.Ki, math.Min(integral, 100/p.Ki))
+	}
+	out = p.Kp*e + p.Ki*integral + p.Kd*derivative
+
+	p.integral, p.prevErr, p.prevAt = integral, e, now
+	p.output = math.Max(0, math.Min(out, 100))
+	return p.output
+}
+
+// pid holds the PID config and the controller running it, which starts
+// over whenever the config changes.
+var pid struct {
+	mu         sync.Mutex
+	config     PIDConfig
+	controller PID
+	active     bool // the controller decided the last evaluation
+}
+
+func pidConfig() PIDConfig {
+	pid.mu.Lock()
+	defer pid.mu.Unlock()
+	return pid.config
+}
+
+func setPIDConfig(c PIDConfig) {
+	pid.mu.Lock()
+	defer pid.mu.Unlock()
+	pid.config = c
+	pid.controller = PID{Kp: c.Kp, Ki: c.Ki, Kd: c.Kd}
+	pid.active = false
+}
+
+// pidHeating runs the controller on a reading, if PID control is enabled,
+// and returns the heating state for its output. A relay that can be
+// driven with a duty cycle takes the output as it is; any other is on for
+// the output's share of every -pid-cycle.
+func pidHeating(target, current float64, now time.Time) (heating string, ok bool) {
+	pid.mu.Lock()
+	defer pid.mu.Unlock()
+	if !pid.config.Enabled {
+		return "", false
+	}
+	output := pid.controller.Update(target, current, now)
+
+	if _, ok := relay.(DutyRelay); ok {
+		if output > 0 {
+			return "on", true
+		}
+		return "off", true
+	}
+	cycle := *pidCycle
+	elapsed := time.Duration(now.UnixNano() % int64(cycle))
+	if float64(elapsed) < output/100*float64(cycle) {
+		return "on", true
+	}
+	return "off", true
+}
+
+// setPIDActive records whether the controller decided the heating in the
+// last evaluation, rather than the manual setting, the frost protection
+// or the safety cutoff.
+func setPIDActive(active bool) {
+	pid.mu.Lock()
+	pid.active = active
+	pid.mu.Unlock()
+}
+
+// pidOutput returns the output of the last evaluation the controller
+// decided.
+func pidOutput() (float64, bool) {
+	pid.mu.Lock()
+	defer pid.mu.Unlock()
+	return pid.controller.output, pid.active
+}
+
+// DutyRelay is a relay that can also be driven with a duty cycle, like a
+// PWM output.
+type DutyRelay interface {
+	Relay
+	SetDuty(percent float64) error
+}
+
+// driveDuty sets the duty cycle of relays that take one: the PID output if
+// the PID decided the heating, or else full or none.
+func driveDuty(heating string, pidDecided bool) {
+	r, ok := relay.(DutyRelay)
+	if !ok {
+		return
+	}
+	duty := 0.0
+	if heating == "on" {
+		duty = 100
+	}
+	if output, _ := pidOutput(); pidDecided {
+		duty = output
+	}
+	if err := r.SetDuty(duty); err != nil {
+		log.Printf("Setting the relay duty cycle failed: %s", err)
+	}
+}
+
+func GetPID(w http.ResponseWriter, r *http.Request) {
+	c := pidConfig()
+	if err := render.Render(w, r, &c); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+func UpdatePID(w http.ResponseWriter, r *http.Request) {
+	data := &PIDConfig{}
+	if err := decode(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	setPIDConfig(*data)
+	persistState(r.Context())
+	evaluate(r.Context(), clockOf(r.Context()).Now())
+	LoggerFrom(r.Context()).Info("pid set", "enabled", data.Enabled, "kp", data.Kp, "ki", data.Ki, "kd", data.Kd)
+
+	GetPID(w, r)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// room is a room warmed by a heater and losing heat to the outside in
+// proportion to the difference, both per second.
+type room struct {
+	temp, outside float64
+	heat, loss    float64
+}
+
+func (r *room) step(output float64, dt time.Duration) {
+	r.temp += (r.heat*output/100 - r.loss*(r.temp-r.outside)) * dt.Seconds()
+}
+
+func TestPIDSettlesOnTarget(t *testing.T) {
+	const target = 21
+	p := PID{Kp: 40, Ki: 0.02, Kd: 600}
+	// At 100% the heater would hold the room at 40C.
+	r := room{temp: 15, outside: 10, heat: 30.0 / 3600, loss: 1.0 / 3600}
+	now := harnessStart
+	for i := 0; i < 24*360; i++ {
+		r.step(p.Update(target, r.temp, now), 10*time.Second)
+		now = now.Add(10 * time.Second)
+	}
+	if math.Abs(r.temp-target) > 0.05 {
+		t.Errorf("after a day the room is at %.2fC, want %vC", r.temp, target)
+	}
+	// Holding 21C against 10C outside takes 11/30 of the heater.
+	if want := 100 * 11.0 / 30; math.Abs(p.output-want) > 1 {
+		t.Errorf("settled output %.1f%%, want about %.1f%%", p.output, want)
+	}
+}
+
+func TestPIDDoesNotWindUp(t *testing.T) {
+	// No derivative, which would hide a wound up integral at the end.
+	p := PID{Kp: 40, Ki: 0.02}
+	// At 100% the heater only holds the room at 18C.
+	r := room{temp: 15, outside: 10, heat: 8.0 / 3600, loss: 1.0 / 3600}
+	now := harnessStart
+	for i := 0; i < 12*360; i++ {
+		if out := p.Update(21, r.temp, now); out != 100 {
+			t.Fatalf("output %.1f%% at %.2fC, want the heater flat out", out, r.temp)
+		}
+		r.step(100, 10*time.Second)
+		now = now.Add(10 * time.Second)
+	}
+	if p.integral != 0 {
+		t.Errorf("integral %v after hours flat out, want it left alone", p.integral)
+	}
+
+	// Once the target is in reach the output drops at once, with no
+	// integral to unwind.
+	if out := p.Update(r.temp-0.5, r.temp, now.Add(10*time.Second)); out != 0 {
+		t.Errorf("output %.1f%% just above the target, want 0", out)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var pollInterval = flag.Duration("poll-interval", 10*time.Second, "How often the sensor is read; requests and the evaluator use the latest reading")
+var freshReadTimeout = flag.Duration("fresh-read-timeout", 2*time.Second, "How long a request with ?fresh=true waits for the sensor before it's served the latest reading")
+
+var (
+	errNoReading    = errors.New("no sensor reading yet")
+	errStaleReading = errors.New("sensor reading is stale")
+)
+
+// Reading is a sensor reading, in Celsius, and when it was taken.
+type Reading struct {
+	Temp float64
+	At   time.Time
+}
+
+// latest is the last good reading. The sensor is read by the poller, and
+// for requests with ?fresh=true; everyone else reads this.
+var latest struct {
+	mu      sync.Mutex
+	reading Reading
+	ok      bool
+}
+
+// readSensor reads the sensor, giving up when ctx is done even if the
+// sensor doesn't; its reading is then dropped.
+func readSensor(ctx context.Context) (float64, error) {
+	type result struct {
+		temp float64
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		temp, err := sensorOf(ctx).Read(ctx)
+		done <- result{temp, err}
+	}()
+	select {
+	case res := <-done:
+		return res.temp, res.err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// cacheReading makes reading the latest one.
+func cacheReading(reading Reading) {
+	latest.mu.Lock()
+	latest.reading, latest.ok = reading, true
+	latest.mu.Unlock()
+}
+
+// pollSensor reads the sensor and caches the reading, then records the
+// control temperature. A failed read, or one that takes longer than
+// -poll-interval, is logged and leaves the previous reading in place.
+func pollSensor(ctx context.Context, now time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, *pollInterval)
+	current, err := readSensor(ctx)
+	cancel()
+	if err != nil {
+		log.Printf("Reading the sensor failed: %s", err)
+	} else {
+		cacheReading(Reading{Temp: current, At: now})
+	}
+	recordControl(ctx, now)
+}
+
+// recordControl records the control temperature in the history and checks
+// it for alerts.
+func recordControl(ctx context.Context, now time.Time) {
+	control, err := latestReading(ctx)
+	if err != nil {
+		return
+	}
+	tempHistory.Append(Sample{At: now, Temp: control.Temp})
+	checkAlerts(control.Temp, now)
+}
+
+// latestReading returns the control temperature: that of the remote
+// sensors if any of them counts, or else the cached reading of the local
+// one. It fails if there is none yet, or if it's more than three polls
+// old, because the sensor kept failing.
+func latestReading(ctx context.Context) (Reading, error) {
+	if reading, ok := remoteReading(clockOf(ctx).Now()); ok {
+		return reading, nil
+	}
+	latest.mu.Lock()
+	defer latest.mu.Unlock()
+	if !latest.ok {
+		return Reading{}, errNoReading
+	}
+	if clockOf(ctx).Now().Sub(latest.reading.At) > 3**pollInterval {
+		return latest.reading, errStaleReading
+	}
+	return latest.reading, nil
+}
+
+// runPoller reads the sensor on every tick until ctx is done.
+func runPoller(ctx context.Context, interval time.Duration) error {
+	ticks, stop := clock.NewTicker(interval)
+	defer stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticks:
+			pollSensor(ctx, now)
+		}
+	}
+}
+
+// freshReads merges the sensor reads of concurrent ?fresh=true requests,
+// so there's at most one of them in flight however many requests ask.
+var freshReads singleflight.Group
+
+// refreshReading reads the sensor for a request with ?fresh=true, so it's
+// served a reading taken just now rather than the one of the last poll.
+// The request waits for the read until it's done itself, or for
+// -fresh-read-timeout; it's then served the latest reading, which
+// X-Reading tells apart as cached. Only a bad ?fresh= is an error.
+func refreshReading(w http.ResponseWriter, r *http.Request) error {
+	s := r.URL.Query().Get("fresh")
+	if s == "" {
+		return nil
+	}
+	fresh, err := strconv.ParseBool(s)
+	if err != nil {
+		return withCode(CodeBadFormat, errors.New("fresh must be true or false"))
+	}
+	if !fresh {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), *freshReadTimeout)
+	defer cancel()
+	stop := startPhase(ctx, "sensor")
+	// The read is shared, so it doesn't end with the request that started
+	// it; the sensor gets -fresh-read-timeout.
+	shared := context.WithoutCancel(r.Context())
+	key := fmt.Sprintf("%T %p", sensorOf(shared), sensorOf(shared)) // routers can have sensors of their own
+	done := freshReads.DoChan(key, func() (interface{}, error) {
+		return nil, readFresh(shared)
+	})
+	select {
+	case res := <-done:
+		err = res.Err
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	stop()
+	if err != nil {
+		LoggerFrom(r.Context()).Warn("fresh sensor read failed, serving the latest reading", "err", err)
+		w.Header().Set("X-Reading", "cached")
+		return nil
+	}
+	w.Header().Set("X-Reading", "fresh")
+	return nil
+}
+
+// readFresh reads the sensor within -fresh-read-timeout and records the
+// reading the way a poll does. It waits for the sensor to return even if
+// the sensor takes longer, so a sensor that doesn't give up holds up this
+// read rather than more of them piling up behind it.
+func readFresh(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, *freshReadTimeout)
+	defer cancel()
+	current, err := sensorOf(ctx).Read(ctx)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+	now := clockOf(ctx).Now()
+	cacheReading(Reading{Temp: current, At: now})
+	recordControl(ctx, now)
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFreshReadFallsBackToCached(t *testing.T) {
+	h := newHarness(t, 20)
+	withFlag(t, freshReadTimeout, 50*time.Millisecond)
+	h.SetReading(23)
+	h.Sensor.Delay(time.Second, false)
+
+	start := time.Now()
+	resp, body := h.Do(http.MethodGet, "/rest/v1/status?fresh=true", nil)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("the request took %s, the sensor wasn't given up on", elapsed)
+	}
+	var status Status
+	if err := json.Unmarshal(body, &status); err != nil {
+		t.Fatalf("%s: %s", err, body)
+	}
+	if got := resp.Header.Get("X-Reading"); got != "cached" {
+		t.Errorf("X-Reading = %q, want cached", got)
+	}
+	if status.Temp != 20 {
+		t.Errorf("temp = %v, want the cached 20", status.Temp)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := h.Sensor.InFlight(); n != 0 {
+		t.Errorf("%d sensor reads still in flight after -fresh-read-timeout, want them cancelled", n)
+	}
+}
+
+func TestFreshReadHonoursRequestDeadline(t *testing.T) {
+	h := newHarness(t, 20)
+	withFlag(t, freshReadTimeout, 5*time.Second)
+	h.Sensor.Delay(time.Second, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/rest/v1/temp?fresh=true", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	h.Server.Config.Handler.ServeHTTP(rec, req)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("the request took %s, past its own deadline", elapsed)
+	}
+	if rec.Code == http.StatusOK && rec.Header().Get("X-Reading") == "fresh" {
+		t.Errorf("served a fresh reading the sensor couldn't have taken yet")
+	}
+
+	// The read goes on for whoever else asks; this one waits it out.
+	resp, _ := h.Do(http.MethodGet, "/rest/v1/status?fresh=true", nil)
+	if got := resp.Header.Get("X-Reading"); got != "fresh" {
+		t.Errorf("X-Reading of the request joining the read = %q, want fresh", got)
+	}
+	if n := h.Sensor.Reads(); n != 2 {
+		t.Errorf("%d sensor reads, want the initial poll's and one fresh one", n)
+	}
+}
+
+func TestFreshReadsShareOneSensorRead(t *testing.T) {
+	h := newHarness(t, 20)
+	withFlag(t, freshReadTimeout, time.Second)
+	h.SetReading(22.5)
+	h.Sensor.Delay(200*time.Millisecond, true)
+	before := h.Sensor.Reads()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, body := h.Do(http.MethodGet, "/rest/v1/status?fresh=true", nil)
+			if got := resp.Header.Get("X-Reading"); got != "fresh" {
+				t.Errorf("X-Reading = %q, want fresh: %s", got, body)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := h.Sensor.Reads() - before; n != 1 {
+		t.Errorf("%d sensor reads for 8 concurrent fresh requests, want 1", n)
+	}
+
+	// The fresh reading counts like a polled one.
+	samples := tempHistory.Since(time.Time{})
+	if len(samples) == 0 || samples[len(samples)-1].Temp != 22.5 {
+		t.Errorf("history ends with %v, want the fresh 22.5", samples)
+	}
+}
+
+func TestRequestsServeThePolledReading(t *testing.T) {
+	h := newHarness(t, 20)
+	before := h.Sensor.Reads()
+	h.SetReading(25)
+	for i := 0; i < 3; i++ {
+		var temp Temp
+		h.GetJSON("/rest/v1/temp", &temp)
+		if !strings.HasPrefix(string(temp.CurrentTemp), "20") {
+			t.Errorf("currenttemp %s before the next poll, want the polled 20", temp.CurrentTemp)
+		}
+	}
+	if n := h.Sensor.Reads() - before; n != 0 {
+		t.Errorf("%d sensor reads for requests, want them served the polled reading", n)
+	}
+
+	// A failing sensor leaves the last reading in place, until it's three
+	// polls old.
+	h.Sensor.Fail(errors.New("i2c: no ack"))
+	for i := 0; i < 3; i++ {
+		h.Advance(*pollInterval)
+	}
+	if resp, body := h.Do(http.MethodGet, "/rest/v1/temp", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("three failed polls in: %s %s, want the last reading", resp.Status, body)
+	}
+	h.Advance(*pollInterval)
+	resp, body := h.Do(http.MethodGet, "/rest/v1/temp", nil)
+	if resp.StatusCode != http.StatusServiceUnavailable || !jsonHasCode(body, CodeStaleReading) {
+		t.Errorf("four failed polls in: %s %s, want 503 %s", resp.Status, body, CodeStaleReading)
+	}
+
+	h.SetReading(21)
+	h.Advance(*pollInterval)
+	var temp Temp
+	h.GetJSON("/rest/v1/temp", &temp)
+	if !strings.HasPrefix(string(temp.CurrentTemp), "21") {
+		t.Errorf("currenttemp %s once the sensor is back, want 21", temp.CurrentTemp)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+var (
+	preheat    = flag.Bool("preheat", false, "Switch to day early, so the day target is reached by the day time, going by how fast the heating warmed the room before")
+	preheatMax = flag.Duration("preheat-max", 3*time.Hour, "Longest a day is started early with -preheat")
+)
+
+/**-----------------------------------------------------------------------------------
+ * get preheat
+ * ===========
+ * $ curl http://bangkokguy.ddns.net/rest/v1/temp/preheat
+ *   {"enabled":true,"rate":1.5,"periods":4,"current":18.5,"target":"22.00","day":"...","offset":"2h20m0s","start":"..."}
+ *------------------------------------------------------------------------------------*/
+
+// minWarmup is the shortest heating period that counts for learning the
+// warm-up rate; shorter ones are mostly the sensor catching up.
+const minWarmup = 15 * time.Minute
+
+// Preheat is the plan for warming up to the day target by the day time.
+// Rate is the warm-up rate learned from the heating periods in the
+// temperature history, in Celsius per hour, 0 while there are none to
+// learn from, in which case the day isn't started early.
+type Preheat struct {
+	Enabled bool      `json:"enabled"`
+	Rate    float64   `json:"rate"`
+	Periods int       `json:"periods"`
+	Current float64   `json:"current"`
+	Target  TempValue `json:"target"`
+	Day     time.Time `json:"day"`
+	Offset  string    `json:"offset"`
+	Start   time.Time `json:"start"`
+
+	offset time.Duration
+}
+
+func (p *Preheat) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// heatingPeriod is a time the heating was on.
+type heatingPeriod struct {
+	from, to time.Time
+}
+
+// heatingPeriods returns when the heating was on, from the transition log
+// (oldest first). A period still running ends at now.
+func heatingPeriods(transitions []Transition, now time.Time) []heatingPeriod {
+	var periods []heatingPeriod
+	var on *time.Time
+	for _, t := range transitions {
+		if t.Type != "heating" {
+			continue
+		}
+		switch {
+		case t.To == "on" && on == nil:
+			at := t.At
+			on = &at
+		case t.To != "on" && on != nil:
+			periods = append(periods, heatingPeriod{*on, t.At})
+			on = nil
+		}
+	}
+	if on != nil {
+		periods = append(periods, heatingPeriod{*on, now})
+	}
+	return periods
+}
+
+// warmupRate estimates how fast the heating warms the room, in Celsius per
+// hour: the total rise over the total time of the heating periods of at
+// least minWarmup that warmed the room at all, each from the first to the
+// last sample taken in it, as span finds them. It returns the number of
+// periods used, 0 if there were none.
+func warmupRate(span func(from, to time.Time) (Sample, Sample, bool), periods []heatingPeriod) (float64, int) {
+	var rise, hours float64
+	n := 0
+	for _, p := range periods {
+		first, last, ok := span(p.from, p.to)
+		if !ok || last.At.Sub(first.At) < minWarmup || last.Temp <= first.Temp {
+			continue
+		}
+		rise += last.Temp - first.Temp
+		hours += last.At.Sub(first.At).Hours()
+		n++
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	return rise / hours, n
+}
+
+// preheatOffset returns how long before the day time the heating has to
+// start to warm the room from current to target at rate, up to max.
+func preheatOffset(current, target, rate float64, max time.Duration) time.Duration {
+	if rate <= 0 || current >= target {
+		return 0
+	}
+	offset := time.Duration((target - current) / rate * float64(time.Hour)).Round(time.Minute)
+	if offset > max {
+		return max
+	}
+	return offset
+}
+
+// nextClock returns the next time after now the clock shows hhmm
+// ("HH:MM").
+func nextClock(now time.Time, hhmm string) (time.Time, error) {
+	t, err := time.ParseInLocation("15:04", hhmm, now.Location())
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
+
+// planPreheat works out when to start the day early to reach target by
+// the next day time, day ("HH:MM"), from the current temperature.
+func planPreheat(now time.Time, day string, current float64, target TempValue) (*Preheat, error) {
+	p := &Preheat{Enabled: *preheat, Current: current, Target: target}
+	next, err := nextClock(now, day)
+	if err != nil {
+		return nil, err
+	}
+	p.Day = next
+
+	p.Rate, p.Periods = warmupRate(tempHistory.Span, heatingPeriods(modeHistory.Since(time.Time{}, 0), now))
+	p.Rate = round2(p.Rate)
+	if start, ok := latchedPreheat(next); ok {
+		p.offset = next.Sub(start)
+	} else if t, err := strconv.ParseFloat(string(target), 64); err == nil {
+		p.offset = preheatOffset(current, t, p.Rate, *preheatMax)
+	}
+	p.Offset, p.Start = p.offset.String(), next.Add(-p.offset)
+	return p, nil
+}
+
+// preheatLatch is the preheat under way: the day time it runs up to and
+// when it started. Once started, a preheat runs until the day time, or the
+// room warming up would shorten the offset past now and end it.
+var preheatLatch struct {
+	sync.Mutex
+	day, start time.Time
+}
+
+// latchedPreheat returns when the preheat up to the day time day started,
+// if one did.
+func latchedPreheat(day time.Time) (time.Time, bool) {
+	preheatLatch.Lock()
+	defer preheatLatch.Unlock()
+	return preheatLatch.start, !preheatLatch.day.IsZero() && preheatLatch.day.Equal(day)
+}
+
+// preheating reports whether the night should end early so the day target
+// is reached by the day time.
+func preheating(now time.Time, day string, current float64, target TempValue) bool {
+	if !*preheat {
+		return false
+	}
+	p, err := planPreheat(now, day, current, target)
+	if err != nil || p.offset <= 0 || now.Before(p.Start) {
+		return false
+	}
+	preheatLatch.Lock()
+	preheatLatch.day, preheatLatch.start = p.Day, p.Start
+	preheatLatch.Unlock()
+	return true
+}
+
+func GetPreheat(w http.ResponseWriter, r *http.Request) {
+	precision, err := requestPrecision(r)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	now := clockOf(r.Context()).Now()
+	reading, err := latestReading(r.Context())
+	if err != nil {
+		render.Render(w, r, ErrUnavailable(err))
+		return
+	}
+	times, err := storeOf(r.Context()).GetTime()
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	temp, err := storeOf(r.Context()).GetTemp()
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	day, err := resolveDayTime(times.Day, now)
+	if err != nil {
+		render.Render(w, r, ErrUnavailable(err))
+		return
+	}
+	p, err := planPreheat(now, day, reading.Temp, temp.DayTemp)
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	p.Current = roundFloat(p.Current, precision)
+	if err := render.Render(w, r, p); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPreheatRunsUntilTheDayTime(t *testing.T) {
+	h := newHarness(t, 17)
+	withFlag(t, preheat, true)
+	withFlag(t, preheatMax, 3*time.Hour)
+
+	// Heating since 05:00, the room warms at 1.5C an hour: 24C is over
+	// three hours away, so the day starts now.
+	h.SetReading(17.5)
+	h.Advance(20 * time.Minute)
+	var status Status
+	h.GetJSON("/rest/v1/status", &status)
+	if status.Mode != "day" {
+		t.Fatalf("at 05:20 with 17.5C: mode %q, want day for the preheat", status.Mode)
+	}
+
+	// Nearly there, and warming fast: a plan made now would start a
+	// minute before 06:00, but the preheat under way goes on.
+	for _, v := range []float64{21, 23, 23.9} {
+		h.SetReading(v)
+		h.Advance(10 * time.Minute)
+		h.GetJSON("/rest/v1/status", &status)
+		if status.Mode != "day" {
+			t.Fatalf("at %s with %gC: mode %q, want day until the day time", h.Clock.Now().Format("15:04"), v, status.Mode)
+		}
+	}
+
+	var p Preheat
+	h.GetJSON("/rest/v1/temp/preheat", &p)
+	if want := harnessStart.Add(-2 * time.Hour); !p.Start.Equal(want) {
+		t.Errorf("preheat start %s, want the 03:00 of the plan under way", p.Start.Format("15:04"))
+	}
+}
+
+func TestWarmupRate(t *testing.T) {
+	l := NewSampleLog(100)
+	at := func(m int) time.Time { return harnessStart.Add(time.Duration(m) * time.Minute) }
+	for m, v := range []float64{17, 17.5, 18, 18, 18, 17.5, 17} {
+		l.Append(Sample{At: at(m * 10), Temp: v})
+	}
+	periods := []heatingPeriod{
+		{at(0), at(20)},  // 1C in 20 minutes
+		{at(25), at(35)}, // too short
+		{at(40), at(60)}, // cooled down
+	}
+	rate, n := warmupRate(l.Span, periods)
+	if n != 1 || rate != 3 {
+		t.Errorf("warmupRate = %g from %d periods, want 3 from 1", rate, n)
+	}
+}
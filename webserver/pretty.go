@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/render"
+)
+
+// wantsPretty tells whether the client asked for indented JSON, with
+// ?pretty=true or an X-Pretty: true header, for reading responses in a
+// terminal. Responses are compact otherwise.
+func wantsPretty(r *http.Request) bool {
+	s := r.URL.Query().Get("pretty")
+	if s == "" {
+		s = r.Header.Get("X-Pretty")
+	}
+	pretty, _ := strconv.ParseBool(s)
+	return pretty
+}
+
+// writeJSON writes v as JSON like render.JSON, but indented by two spaces
+// if the client asked for it.
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	if !wantsPretty(r) {
+		render.JSON(w, r, v)
+		return
+	}
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(true)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
+		w.WriteHeader(status)
+	}
+	w.Write(buf.Bytes())
+}
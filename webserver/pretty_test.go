@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPrettyJSON(t *testing.T) {
+	h := newHarness(t, 20)
+	for _, tc := range []struct {
+		path   string
+		header []string
+		pretty bool
+	}{
+		{"/rest/v1/temp", nil, false},
+		{"/rest/v1/temp?pretty=true", nil, true},
+		{"/rest/v1/temp?pretty=0", []string{"X-Pretty", "true"}, false},
+		{"/rest/v1/temp", []string{"X-Pretty", "1"}, true},
+		{"/rest/v1/temp?pretty=yes", nil, false},
+	} {
+		resp, body := h.Do(http.MethodGet, tc.path, nil, tc.header...)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: %s %s", tc.path, resp.Status, body)
+		}
+		if pretty := strings.HasPrefix(string(body), "{\n  \""); pretty != tc.pretty {
+			t.Errorf("GET %s with %q: %s, want it indented %t", tc.path, tc.header, body, tc.pretty)
+		}
+	}
+
+	// The status set for the response is kept.
+	resp, body := h.Do(http.MethodPut, "/rest/v1/temp?pretty=true", map[string]string{"daytemp": "hot"})
+	if resp.StatusCode != http.StatusBadRequest || !strings.HasPrefix(string(body), "{\n  \"") {
+		t.Errorf("PUT /rest/v1/temp?pretty=true with a bad temp: %s %s, want an indented 400", resp.Status, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type %q", ct)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/render"
+)
+
+// envPrefix is the prefix of the environment variables flags can be set
+// with: WEBSERVER_ADMIN_KEY sets -admin-key.
+const envPrefix = "WEBSERVER_"
+
+// Where a value in effect comes from. Flags are set by, in increasing
+// precedence, their default, the -config file (or a reload of it), the
+// environment and the command line. The thermostat settings start out as
+// their defaults, are restored from -state-file and then changed at
+// runtime through the API.
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceReload  = "reload"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+	sourceState   = "state"
+	sourceRuntime = "runtime"
+)
+
+// redactedFlags hold secrets, or URLs that may carry them, which the
+// effective config only shows as set or not.
+var redactedFlags = map[string]bool{
+	"auth-secret": true,
+	"admin-key":   true,
+	"webhook-url": true,
+}
+
+// provenance tracks the source of every flag and thermostat setting,
+// those not in it being defaults.
+var provenance = struct {
+	sync.Mutex
+	flags    map[string]string
+	settings map[string]string
+	last     map[string]interface{} // the settings last noted
+}{flags: map[string]string{}, settings: map[string]string{}}
+
+func setFlagSource(name, source string) {
+	provenance.Lock()
+	defer provenance.Unlock()
+	provenance.flags[name] = source
+}
+
+// setFlag sets a flag from source while the server is running, under the
+// lock the effective config is read with.
+func setFlag(name, value, source string) error {
+	provenance.Lock()
+	defer provenance.Unlock()
+	if err := flag.Set(name, value); err != nil {
+		return err
+	}
+	provenance.flags[name] = source
+	return nil
+}
+
+func flagSource(name string) string {
+	provenance.Lock()
+	defer provenance.Unlock()
+	if source, ok := provenance.flags[name]; ok {
+		return source
+	}
+	return sourceDefault
+}
+
+// overridden tells whether the flag is set on the command line or in the
+// environment, which the config file doesn't override.
+func overridden(name string) bool {
+	source := flagSource(name)
+	return source == sourceFlag || source == sourceEnv
+}
+
+// loadEnv records the flags given on the command line, and sets the
+// others that have an environment variable.
+func loadEnv() error {
+	flag.Visit(func(f *flag.Flag) {
+		setFlagSource(f.Name, sourceFlag)
+	})
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		if err != nil || overridden(f.Name) {
+			return
+		}
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if err = f.Value.Set(value); err != nil {
+			err = fmt.Errorf("%s: %w", name, err)
+			return
+		}
+		setFlagSource(f.Name, sourceEnv)
+	})
+	return err
+}
+
+// noteSettings records source as the source of the settings in s that
+// changed since they were last noted.
+func noteSettings(s State, source string) {
+	var fields map[string]interface{}
+	data, err := json.Marshal(s)
+	if err == nil {
+		err = json.Unmarshal(data, &fields)
+	}
+	if err != nil {
+		return
+	}
+
+	provenance.Lock()
+	defer provenance.Unlock()
+	if provenance.last != nil {
+		names := map[string]bool{}
+		for name := range fields {
+			names[name] = true
+		}
+		for name := range provenance.last {
+			names[name] = true
+		}
+		for name := range names {
+			if !reflect.DeepEqual(fields[name], provenance.last[name]) {
+				provenance.settings[name] = source
+			}
+		}
+	}
+	provenance.last = fields
+}
+
+// noteState notes the settings as they are, as coming from source.
+func noteState(source string) {
+	if s, err := snapshotState(context.Background()); err == nil {
+		noteSettings(s, source)
+	}
+}
+
+/**-----------------------------------------------------------------------------------
+ * get effective config
+ * ====================
+ * $ curl -H 'Authorization: Bearer <admin-key>' http://bangkokguy.ddns.net/admin/config
+ *   {"flags":{"addr":{"value":":3333","source":"default"},"admin-key":{"value":"[redacted]","source":"env"},...},
+ *    "settings":{"day":{"value":"06:00","source":"default"},"daytemp":{"value":"22.00","source":"runtime"},...}}
+ *------------------------------------------------------------------------------------*/
+
+// EffectiveValue is a value in effect and where it comes from.
+type EffectiveValue struct {
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"`
+}
+
+// EffectiveConfig is the configuration in effect: the flags, by name, and
+// the thermostat settings, by their names in the state file.
+type EffectiveConfig struct {
+	Flags    map[string]EffectiveValue `json:"flags"`
+	Settings map[string]EffectiveValue `json:"settings"`
+}
+
+func (c *EffectiveConfig) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func GetEffectiveConfig(w http.ResponseWriter, r *http.Request) {
+	s, err := snapshotState(r.Context())
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	noteSettings(s, sourceRuntime)
+
+	c := &EffectiveConfig{Flags: map[string]EffectiveValue{}, Settings: map[string]EffectiveValue{}}
+	provenance.Lock()
+	flag.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if redactedFlags[f.Name] && value != "" {
+			value = "[redacted]"
+		}
+		source, ok := provenance.flags[f.Name]
+		if !ok {
+			source = sourceDefault
+		}
+		c.Flags[f.Name] = EffectiveValue{Value: value, Source: source}
+	})
+	for name, value := range provenance.last {
+		source, ok := provenance.settings[name]
+		if !ok {
+			source = sourceDefault
+		}
+		if name == "owner" {
+			value = "[redacted]"
+		}
+		c.Settings[name] = EffectiveValue{Value: value, Source: source}
+	}
+	provenance.Unlock()
+
+	if err := render.Render(w, r, c); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
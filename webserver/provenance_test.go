@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// resetProvenance forgets the settings noted so far, now and when the
+// test is done.
+func resetProvenance(t *testing.T) {
+	reset := func() {
+		provenance.Lock()
+		defer provenance.Unlock()
+		provenance.settings, provenance.last = map[string]string{}, nil
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	h := newHarness(t, 20)
+	resetProvenance(t)
+	restoreFlags(t, "admin-key")
+	setAdminKey("admin-key")
+	setFlag("admin-key", "admin-key", sourceEnv)
+	admin := []string{"Authorization", "Bearer admin-key"}
+
+	c := effectiveConfig(h, admin)
+	if got := c.Settings["daytemp"]; got.Value != "24.00" || got.Source != sourceDefault {
+		t.Errorf("daytemp %+v, want the default", got)
+	}
+
+	resp, body := h.Do(http.MethodPut, "/rest/v1/temp", map[string]string{"daytemp": "23", "nighttemp": "18", "thereshold": "0.2"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /rest/v1/temp: %s %s", resp.Status, body)
+	}
+	c = effectiveConfig(h, admin)
+	for name, want := range map[string]EffectiveValue{
+		"daytemp":   {"23.00", sourceRuntime},
+		"nighttemp": {"18.00", sourceDefault},
+	} {
+		if got := c.Settings[name]; got != want {
+			t.Errorf("setting %s %+v, want %+v", name, got, want)
+		}
+	}
+	for name, want := range map[string]EffectiveValue{
+		"admin-key": {"[redacted]", sourceEnv},
+		"addr":      {":3333", sourceDefault},
+	} {
+		if got := c.Flags[name]; got != want {
+			t.Errorf("flag -%s %+v, want %+v", name, got, want)
+		}
+	}
+
+	if resp, _ := h.Do(http.MethodGet, "/admin/config", nil); resp.StatusCode == http.StatusOK {
+		t.Errorf("GET /admin/config without the admin key: %s", resp.Status)
+	}
+}
+
+func effectiveConfig(h *harness, admin []string) EffectiveConfig {
+	h.t.Helper()
+	var c EffectiveConfig
+	resp, body := h.Do(http.MethodGet, "/admin/config", nil, admin...)
+	if err := json.Unmarshal(body, &c); err != nil || resp.StatusCode != http.StatusOK {
+		h.t.Fatalf("GET /admin/config: %s %s", resp.Status, body)
+	}
+	return c
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+var rampDuration = flag.Duration("ramp-duration", 0, "Ramp large target changes in over this long instead of jumping, disabled if 0")
+var rampThreshold = flag.Float64("ramp-threshold", 2, "Smallest target change in Celsius that is ramped in")
+
+/**-----------------------------------------------------------------------------------
+ * get setpoint ramp
+ * =================
+ * $ curl http://bangkokguy.ddns.net/rest/v1/temp/setpoint-ramp
+ *   {"daytemp":{"target":"22.00","effective":"18.40","from":"16.00","start":"...","end":"..."}}
+ *------------------------------------------------------------------------------------*/
+
+// Ramp moves a target temperature from From to To over Start..End. The
+// controller uses the interpolated effective target meanwhile.
+type Ramp struct {
+	From      TempValue `json:"from"`
+	To        TempValue `json:"target"`
+	Effective TempValue `json:"effective"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+}
+
+// at returns the effective target at now.
+func (r *Ramp) at(now time.Time) TempValue {
+	from, err1 := strconv.ParseFloat(string(r.From), 64)
+	to, err2 := strconv.ParseFloat(string(r.To), 64)
+	if err1 != nil || err2 != nil || !now.Before(r.End) {
+		return r.To
+	}
+	if now.Before(r.Start) {
+		return r.From
+	}
+	f := from + (to-from)*float64(now.Sub(r.Start))/float64(r.End.Sub(r.Start))
+	return TempValue(strconv.FormatFloat(math.Round(f*100)/100, 'f', 2, 64))
+}
+
+// ramps holds the ramps in progress, by target ("daytemp", "nighttemp").
+var ramps = struct {
+	sync.Mutex
+	m map[string]*Ramp
+}{m: map[string]*Ramp{}}
+
+// rampTargets starts a ramp for every target that changes by at least
+// -ramp-threshold. A target changed while it is ramping ramps on from its
+// current effective value.
+func rampTargets(old, updated *Temp, now time.Time) {
+	ramps.Lock()
+	defer ramps.Unlock()
+
+	for _, t := range []struct {
+		name     string
+		old, new TempValue
+	}{{"daytemp", old.DayTemp, updated.DayTemp}, {"nighttemp", old.NightTemp, updated.NightTemp}} {
+		from := t.old
+		if r, ok := ramps.m[t.name]; ok {
+			from = r.at(now)
+		}
+		delete(ramps.m, t.name)
+
+		f, err1 := strconv.ParseFloat(string(from), 64)
+		to, err2 := strconv.ParseFloat(string(t.new), 64)
+		if *rampDuration <= 0 || err1 != nil || err2 != nil || math.Abs(to-f) < *rampThreshold {
+			continue
+		}
+		ramps.m[t.name] = &Ramp{From: from, To: t.new, Start: now, End: now.Add(*rampDuration)}
+	}
+}
+
+// effectiveTarget returns the target the controller should aim for now,
+// given the final target: somewhere along the ramp while one is running.
+func effectiveTarget(name string, final TempValue, now time.Time) TempValue {
+	ramps.Lock()
+	defer ramps.Unlock()
+
+	r, ok := ramps.m[name]
+	if !ok || r.To != final {
+		return final
+	}
+	if !now.Before(r.End) {
+		delete(ramps.m, name)
+		return final
+	}
+	return r.at(now)
+}
+
+// SetpointRamps lists the ramps in progress, by target.
+type SetpointRamps map[string]*Ramp
+
+func (s SetpointRamps) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func GetSetpointRamp(w http.ResponseWriter, r *http.Request) {
+	now := clockOf(r.Context()).Now()
+	list := SetpointRamps{}
+
+	ramps.Lock()
+	for name, ramp := range ramps.m {
+		if !now.Before(ramp.End) {
+			continue
+		}
+		copy := *ramp
+		copy.Effective = ramp.at(now)
+		list[name] = &copy
+	}
+	ramps.Unlock()
+
+	if err := render.Render(w, r, list); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
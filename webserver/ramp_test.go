@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRampAt(t *testing.T) {
+	start := harnessStart
+	r := &Ramp{From: "16.00", To: "22.00", Start: start, End: start.Add(time.Hour)}
+	for _, tc := range []struct {
+		after time.Duration
+		want  TempValue
+	}{
+		{-time.Minute, "16.00"},
+		{0, "16.00"},
+		{15 * time.Minute, "17.50"},
+		{30 * time.Minute, "19.00"},
+		{59 * time.Minute, "21.90"},
+		{time.Hour, "22.00"},
+		{2 * time.Hour, "22.00"},
+	} {
+		if got := r.at(start.Add(tc.after)); got != tc.want {
+			t.Errorf("%s into the ramp: %s, want %s", tc.after, got, tc.want)
+		}
+	}
+
+	// Ramping down interpolates the same way.
+	r = &Ramp{From: "22.00", To: "16.00", Start: start, End: start.Add(time.Hour)}
+	if got := r.at(start.Add(20 * time.Minute)); got != "20.00" {
+		t.Errorf("20m into ramping down: %s, want 20.00", got)
+	}
+}
+
+func TestSetpointRamp(t *testing.T) {
+	h := newHarness(t, 19)
+	withFlag(t, rampDuration, 40*time.Minute)
+	withFlag(t, rampThreshold, 2)
+	ramp := func() SetpointRamps {
+		t.Helper()
+		var list SetpointRamps
+		h.GetJSON("/rest/v1/temp/setpoint-ramp", &list)
+		return list
+	}
+	heating := func() string {
+		t.Helper()
+		modes, err := h.Store.GetMode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return modes.Heating[0]
+	}
+	putTemp := func(night TempValue) {
+		t.Helper()
+		temp := map[string]TempValue{"nighttemp": night, "daytemp": "24.00", "thereshold": "0.20"}
+		if resp, body := h.Do(http.MethodPut, "/rest/v1/temp", temp); resp.StatusCode != http.StatusOK {
+			t.Fatalf("PUT /rest/v1/temp: %s %s", resp.Status, body)
+		}
+	}
+
+	// The night target goes from 18 to 22 over 40 minutes; at 19 the
+	// heating stays off until the effective target passes 19.2.
+	putTemp("22.00")
+	if r := ramp()["nighttemp"]; r == nil || r.From != "18.00" || r.To != "22.00" || r.Effective != "18.00" {
+		t.Fatalf("ramp at the start: %+v, want from 18.00 to 22.00, effective 18.00", r)
+	}
+	if got := heating(); got != "off" {
+		t.Errorf("heating at the start of the ramp: %s, want off", got)
+	}
+
+	h.Advance(20 * time.Minute)
+	if r := ramp()["nighttemp"]; r == nil || r.Effective != "20.00" || r.To != "22.00" {
+		t.Errorf("ramp halfway: %+v, want effective 20.00 on the way to 22.00", r)
+	}
+	if got := heating(); got != "on" {
+		t.Errorf("heating halfway up the ramp: %s, want on", got)
+	}
+
+	// At the end the final target applies and the ramp is gone.
+	h.Advance(20 * time.Minute)
+	if list := ramp(); len(list) != 0 {
+		t.Errorf("ramps after the end: %+v, want none", list)
+	}
+	if got := effectiveTarget("nighttemp", "22.00", h.Clock.Now()); got != "22.00" {
+		t.Errorf("effective target after the ramp: %s, want 22.00", got)
+	}
+
+	// A change under -ramp-threshold applies at once.
+	putTemp("21.00")
+	if list := ramp(); len(list) != 0 {
+		t.Errorf("ramps after a small change: %+v, want none", list)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/go-chi/render"
+)
+
+var panicDetails = flag.Bool("panic-details", false, "Put the panic message in the error response to a handler that panicked, for development; the message may give away internals")
+
+var errPanicked = errors.New("the request failed unexpectedly")
+
+// panics counts the panics recovered from in handlers.
+var panics atomic.Int64
+
+// renderPanic answers a request whose handler panicked with the JSON error
+// every other failure gets, a 500 with the generic message, or the panic
+// message with -panic-details.
+func renderPanic(w http.ResponseWriter, r *http.Request, rvr interface{}) {
+	panics.Add(1)
+	err := errPanicked
+	if *panicDetails {
+		err = fmt.Errorf("panic: %v", rvr)
+	}
+	render.Render(w, r, ErrInternal(err))
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPanicRendersJSONError(t *testing.T) {
+	for _, tc := range []struct {
+		details bool
+		want    string
+	}{
+		{false, errPanicked.Error()},
+		{true, "panic: test"},
+	} {
+		h := newHarness(t, 20)
+		withFlag(t, panicDetails, tc.details)
+		before := panics.Load()
+
+		resp, body := h.Do(http.MethodGet, "/panic", nil)
+		if resp.StatusCode != http.StatusInternalServerError {
+			t.Fatalf("GET /panic: %s, want 500", resp.Status)
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("Content-Type %q, want JSON", ct)
+		}
+		var e struct {
+			Status string    `json:"status"`
+			Code   ErrorCode `json:"code"`
+			Error  string    `json:"error"`
+		}
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Fatalf("%s: %s", err, body)
+		}
+		if e.Code != CodeInternal || e.Status == "" || e.Error != tc.want {
+			t.Errorf("-panic-details=%t: %s, want code %s and error %q", tc.details, body, CodeInternal, tc.want)
+		}
+		if n := panics.Load() - before; n != 1 {
+			t.Errorf("http_panics_total went up by %d, want 1", n)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+var relayKind = flag.String("relay", "log", "Relay driving the heating output: log, or gpio in builds with the pi tag")
+
+// Relay switches the heating output.
+type Relay interface {
+	Set(on bool) error
+}
+
+// relayFactories make the relays selectable with -relay. Hardware relays
+// register themselves from files built only for their platform.
+var relayFactories = map[string]func() (Relay, error){
+	"log": func() (Relay, error) { return logRelay{}, nil },
+}
+
+func relayKinds() []string {
+	kinds := make([]string, 0, len(relayFactories))
+	for kind := range relayFactories {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// newRelay makes the relay chosen with -relay.
+func newRelay(kind string) (Relay, error) {
+	f, ok := relayFactories[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown relay %q, must be one of %s", kind, strings.Join(relayKinds(), ", "))
+	}
+	return f()
+}
+
+// logRelay only logs, for development without hardware.
+type logRelay struct{}
+
+func (logRelay) Set(on bool) error {
+	log.Printf("Relay switched %s", onOff(on))
+	return nil
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+var relay Relay = logRelay{}
+
+// RelayStatus is the diagnostics of the relay: what it should be set to,
+// whether that worked, and the last failure.
+type RelayStatus struct {
+	Kind        string     `json:"kind"`
+	Want        string     `json:"want"`
+	Applied     bool       `json:"applied"`
+	Failures    int        `json:"failures"` // in a row
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+func (s *RelayStatus) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+var relayStatus struct {
+	sync.Mutex
+	RelayStatus
+}
+
+// driveRelay sets the relay to the heating state. If the state already
+// got through to the relay nothing happens; a failure is recorded and the
+// next evaluation retries.
+func driveRelay(heating string, now time.Time) {
+	relayStatus.Lock()
+	defer relayStatus.Unlock()
+
+	s := &relayStatus.RelayStatus
+	if s.Want == heating && s.Applied {
+		return
+	}
+	s.Want, s.Applied = heating, false
+	if err := relay.Set(heating == "on"); err != nil {
+		s.Failures++
+		s.LastError, s.LastErrorAt = err.Error(), &now
+		log.Printf("Switching the relay %s failed: %s", heating, err)
+		return
+	}
+	s.Applied, s.Failures = true, 0
+}
+
+/**-----------------------------------------------------------------------------------
+ * get relay
+ * =========
+ * $ curl http://bangkokguy.ddns.net/rest/v1/device/relay
+ *   {"kind":"gpio","want":"on","applied":false,"failures":2,"last_error":"...","last_error_at":"..."}
+ *------------------------------------------------------------------------------------*/
+func GetRelay(w http.ResponseWriter, r *http.Request) {
+	relayStatus.Lock()
+	status := relayStatus.RelayStatus
+	relayStatus.Unlock()
+
+	status.Kind = *relayKind
+	if err := render.Render(w, r, &status); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
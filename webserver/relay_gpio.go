@@ -0,0 +1,47 @@
+//go:build pi
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+var relayPin = flag.Int("relay-pin", 17, "GPIO pin (BCM numbering) the relay is wired to, for -relay=gpio")
+
+func init() {
+	relayFactories["gpio"] = func() (Relay, error) { return newGPIORelay(*relayPin) }
+}
+
+// gpioRelay drives a dry-contact relay from a Raspberry Pi GPIO pin through
+// the sysfs interface. The relay closes when the pin is high.
+type gpioRelay struct {
+	value string
+}
+
+func newGPIORelay(pin int) (*gpioRelay, error) {
+	dir := fmt.Sprintf("/sys/class/gpio/gpio%d", pin)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if err := os.WriteFile("/sys/class/gpio/export", []byte(strconv.Itoa(pin)), 0); err != nil {
+			return nil, err
+		}
+		// udev needs a moment to make the new files writable.
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "direction"), []byte("out"), 0); err != nil {
+		return nil, err
+	}
+	return &gpioRelay{value: filepath.Join(dir, "value")}, nil
+}
+
+func (g *gpioRelay) Set(on bool) error {
+	v := "0"
+	if on {
+		v = "1"
+	}
+	return os.WriteFile(g.value, []byte(v), 0)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// flakyRelay records what it's set to, failing while err is set.
+type flakyRelay struct {
+	sets []bool
+	err  error
+}
+
+func (r *flakyRelay) Set(on bool) error {
+	r.sets = append(r.sets, on)
+	return r.err
+}
+
+// withRelay drives the heating through r for the rest of the test, from a
+// blank status.
+func withRelay(t *testing.T, r Relay) {
+	t.Helper()
+	reset := func() {
+		relayStatus.Lock()
+		relayStatus.RelayStatus = RelayStatus{}
+		relayStatus.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+	withFlag(t, &relay, r)
+}
+
+func TestDriveRelayRetries(t *testing.T) {
+	h := newHarness(t, 20)
+	fake := &flakyRelay{err: errors.New("gpio: write failed")}
+	withRelay(t, fake)
+
+	driveRelay("on", harnessStart)
+	driveRelay("on", harnessStart)
+	fake.err = nil
+	driveRelay("on", harnessStart)
+	driveRelay("on", harnessStart) // applied already, left alone
+	if len(fake.sets) != 3 {
+		t.Errorf("relay set %d times, want 3: two failures and the one that worked", len(fake.sets))
+	}
+
+	fake.err = errors.New("gpio: write failed")
+	driveRelay("off", harnessStart)
+	var status RelayStatus
+	h.GetJSON("/rest/v1/device/relay", &status)
+	if status.Kind != "log" || status.Want != "off" || status.Applied || status.Failures != 1 || status.LastError != "gpio: write failed" {
+		t.Errorf("GET /rest/v1/device/relay: %+v, want off not applied after a failure", status)
+	}
+}
+
+func TestNewRelay(t *testing.T) {
+	if r, err := newRelay("log"); err != nil || r == nil {
+		t.Errorf("newRelay(log) = %v, %v", r, err)
+	}
+	if _, err := newRelay("x10"); err == nil {
+		t.Errorf("newRelay(x10) made a relay")
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	stack "bangkokguy.dev/middleware"
+)
+
+var (
+	configFile  = flag.String("config", "", "File of flag settings, one name=value per line, read at startup and again on SIGHUP; flags given on the command line or as WEBSERVER_ environment variables take precedence")
+	listenAddr  = flag.String("addr", ":3333", "Address to listen on")
+	corsOrigins = flag.String("cors-origins", "", "Comma separated origins allowed to make cross origin requests (* for any); CORS is off if empty")
+	timezone    = flag.String("timezone", "", "Time zone (e.g. Europe/Budapest) the day and night times and the schedule are in, the one of TZ if empty")
+)
+
+// reloadable are the flags SIGHUP can change without a restart. Each
+// checks the new value and returns how to apply it, so a reload applies
+// all its settings or, if one is invalid, none of them.
+var reloadable = map[string]func(value string) (func(), error){
+	"log-level": func(value string) (func(), error) {
+		level, err := parseLevel(value)
+		return func() { slog.SetLogLoggerLevel(level) }, err
+	},
+	"timezone": func(value string) (func(), error) {
+		loc, err := parseTimezone(value)
+		return func() { zone.Store(loc) }, err
+	},
+	"cors-origins": func(value string) (func(), error) {
+		origins := parseOrigins(value)
+		return func() { setCORS(origins) }, nil
+	},
+	"admin-key": func(value string) (func(), error) {
+		return func() { setAdminKey(value) }, nil
+	},
+}
+
+// readConfigFile reads name=value lines, skipping empty ones and the ones
+// starting with #. Names may have the leading dash of the command line.
+func readConfigFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	settings := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: want name=value", path, n)
+		}
+		name = strings.TrimLeft(strings.TrimSpace(name), "-")
+		if flag.Lookup(name) == nil {
+			return nil, fmt.Errorf("%s:%d: no flag -%s", path, n, name)
+		}
+		settings[name] = strings.TrimSpace(value)
+	}
+	return settings, scanner.Err()
+}
+
+// loadConfigFile sets the flags from the config file at startup, before
+// they're checked, except those set on the command line or in the
+// environment.
+func loadConfigFile(path string) error {
+	settings, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	for name, value := range settings {
+		if overridden(name) {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("-%s: %w", name, err)
+		}
+		setFlagSource(name, sourceFile)
+	}
+	return nil
+}
+
+// reloadConfig rereads the config file, if there is one, and applies the
+// reloadable settings that changed, warning about the others that changed
+// as they need a restart. It rereads the schedule and admin key files as
+// well.
+func reloadConfig(path string) error {
+	if path != "" {
+		settings, err := readConfigFile(path)
+		if err != nil {
+			return err
+		}
+		applies := map[string]func(){}
+		var restart []string
+		for name, value := range settings {
+			if overridden(name) || flag.Lookup(name).Value.String() == value {
+				continue
+			}
+			prepare, ok := reloadable[name]
+			if !ok {
+				restart = append(restart, "-"+name)
+				continue
+			}
+			apply, err := prepare(value)
+			if err != nil {
+				return fmt.Errorf("-%s: %w", name, err)
+			}
+			applies[name] = apply
+		}
+		for name, apply := range applies {
+			setFlag(name, settings[name], sourceReload)
+			apply()
+			log.Printf("Reloaded -%s", name)
+		}
+		if len(restart) > 0 {
+			sort.Strings(restart)
+			log.Printf("Changing %s needs a restart, keeping the old values until then", strings.Join(restart, ", "))
+		}
+	}
+
+	if *scheduleFile != "" {
+		if err := loadSchedule(*scheduleFile); err != nil {
+			return err
+		}
+	}
+	if *adminKeyFile != "" {
+		if _, err := loadAdminKey(*adminKeyFile); err != nil {
+			return err
+		}
+	}
+	evaluate(context.Background(), clock.Now())
+	return nil
+}
+
+// handleReloads reloads the config on every SIGHUP until ctx is done. A
+// failed reload leaves the config as it was.
+func handleReloads(ctx context.Context, path string) error {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-hup:
+			if err := reloadConfig(path); err != nil {
+				log.Printf("Reloading the config failed, keeping it as it was: %s", err)
+				continue
+			}
+			log.Print("Reloaded the config")
+		}
+	}
+}
+
+// zone is the time zone of -timezone, or nil for the one of TZ.
+var zone atomic.Pointer[time.Location]
+
+func parseTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return nil, nil
+	}
+	return time.LoadLocation(name)
+}
+
+func parseOrigins(value string) []string {
+	var origins []string
+	for _, origin := range strings.Split(value, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// corsSwitch is the CORS middleware for -cors-origins in front of one
+// router's handlers, rebuilt when a reload changes them.
+type corsSwitch struct {
+	next    http.Handler
+	handler atomic.Pointer[http.Handler]
+}
+
+// corsSwitches are the switches of every registered router, which a reload
+// sets all at once.
+var corsSwitches struct {
+	mu       sync.Mutex
+	switches []*corsSwitch
+}
+
+// newCORS returns the CORS middleware of a router. Each router gets its
+// own switch, so registering another one leaves its handlers alone.
+func newCORS() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		c := &corsSwitch{next: next}
+		c.set(parseOrigins(*corsOrigins))
+		corsSwitches.mu.Lock()
+		corsSwitches.switches = append(corsSwitches.switches, c)
+		corsSwitches.mu.Unlock()
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			(*c.handler.Load()).ServeHTTP(w, r)
+		})
+	}
+}
+
+// setCORS sets the origins of every router's switch.
+func setCORS(origins []string) {
+	corsSwitches.mu.Lock()
+	defer corsSwitches.mu.Unlock()
+	for _, c := range corsSwitches.switches {
+		c.set(origins)
+	}
+}
+
+func (c *corsSwitch) set(origins []string) {
+	h := c.next
+	if len(origins) > 0 {
+		h = stack.CORS(stack.Config{AllowedOrigins: origins})(c.next)
+	}
+	c.handler.Store(&h)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// restoreFlags puts the named flags, and where they came from, back as
+// they were when the test is done.
+func restoreFlags(t *testing.T, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		name, value := name, flag.Lookup(name).Value.String()
+		provenance.Lock()
+		source, ok := provenance.flags[name]
+		provenance.Unlock()
+		t.Cleanup(func() {
+			flag.Set(name, value)
+			provenance.Lock()
+			defer provenance.Unlock()
+			if ok {
+				provenance.flags[name] = source
+			} else {
+				delete(provenance.flags, name)
+			}
+		})
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		content string
+		ok      bool
+	}{
+		{"# comment\n\n-log-level = debug\naddr=:4444\n", true},
+		{"log-level\n", false},
+		{"no-such-flag=1\n", false},
+	} {
+		path := filepath.Join(dir, "config")
+		os.WriteFile(path, []byte(tc.content), 0o600)
+		settings, err := readConfigFile(path)
+		if (err == nil) != tc.ok {
+			t.Errorf("%q: %v", tc.content, err)
+		}
+		if tc.ok && (len(settings) != 2 || settings["log-level"] != "debug" || settings["addr"] != ":4444") {
+			t.Errorf("%q: read %v", tc.content, settings)
+		}
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	newHarness(t, 20)
+	restoreFlags(t, "log-level", "timezone", "cors-origins", "addr")
+	t.Cleanup(func() {
+		zone.Store(nil)
+		slog.SetLogLoggerLevel(slog.LevelInfo)
+	})
+	setFlagSource("cors-origins", sourceEnv)
+
+	path := filepath.Join(t.TempDir(), "config")
+	os.WriteFile(path, []byte("log-level=debug\ntimezone=Europe/Budapest\ncors-origins=*\naddr=:4444\n"), 0o600)
+	if err := reloadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	if *logLevel != "debug" || flagSource("log-level") != sourceReload {
+		t.Errorf("-log-level %q from %s, want debug from the reload", *logLevel, flagSource("log-level"))
+	}
+	if loc := zone.Load(); loc == nil || loc.String() != "Europe/Budapest" {
+		t.Errorf("time zone %v, want Europe/Budapest", loc)
+	}
+	if *corsOrigins != "" {
+		t.Errorf("-cors-origins %q, want the environment's kept", *corsOrigins)
+	}
+	if *listenAddr != ":3333" {
+		t.Errorf("-addr %q, want it left until a restart", *listenAddr)
+	}
+
+	// An invalid setting fails the reload as a whole.
+	os.WriteFile(path, []byte("log-level=warn\ntimezone=Nowhere/Atlantis\n"), 0o600)
+	if err := reloadConfig(path); err == nil {
+		t.Errorf("a reload with an unknown time zone succeeded")
+	}
+	if *logLevel != "debug" || zone.Load().String() != "Europe/Budapest" {
+		t.Errorf("-log-level %q, time zone %v after a failed reload, want them as they were", *logLevel, zone.Load())
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type loggerCtxKey struct{}
+
+// RequestLog middleware puts a logger on the request context that tags
+// every line with the request ID and method, and the route once it's
+// known, so handlers can log events that can be told apart by request.
+// Get it with LoggerFrom.
+func RequestLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := slog.Default().With(
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("method", r.Method),
+		)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), loggerCtxKey{}, l)))
+	})
+}
+
+// LoggerFrom returns the request's logger, or the default logger outside
+// of a request. The route is added here rather than in RequestLog, which
+// runs before routing.
+func LoggerFrom(ctx context.Context) *slog.Logger {
+	l, ok := ctx.Value(loggerCtxKey{}).(*slog.Logger)
+	if !ok {
+		return slog.Default()
+	}
+	if rctx := chi.RouteContext(ctx); rctx != nil {
+		if route := rctx.RoutePattern(); route != "" {
+			l = l.With(slog.String("route", route))
+		}
+	}
+	return l
+}
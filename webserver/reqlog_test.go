@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// logRecorder collects the JSON lines of a slog handler.
+type logRecorder struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (l *logRecorder) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(p)
+}
+
+// records returns the lines logged with msg.
+func (l *logRecorder) records(msg string) []map[string]interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var list []map[string]interface{}
+	for _, line := range bytes.Split(l.buf.Bytes(), []byte("\n")) {
+		var rec map[string]interface{}
+		if json.Unmarshal(line, &rec) == nil && rec["msg"] == msg {
+			list = append(list, rec)
+		}
+	}
+	return list
+}
+
+// recordLogs sends the default logger's lines to a logRecorder for the
+// rest of the test.
+func recordLogs(t *testing.T) *logRecorder {
+	rec := &logRecorder{}
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(rec, nil)))
+	t.Cleanup(func() { slog.SetDefault(old) })
+	return rec
+}
+
+func TestRequestLoggerTagsChanges(t *testing.T) {
+	h := newHarness(t, 20)
+	logs := recordLogs(t)
+
+	resp, body := h.Do(http.MethodPut, "/rest/v1/temp", map[string]string{"daytemp": "23", "nighttemp": "18", "thereshold": "0.2"}, "X-Request-Id", "req-1")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /rest/v1/temp: %s %s", resp.Status, body)
+	}
+	changed := logs.records("daytemp changed")
+	if len(changed) != 1 {
+		t.Fatalf("%d daytemp changed lines, want 1", len(changed))
+	}
+	rec := changed[0]
+	if rec["request_id"] != "req-1" || rec["method"] != "PUT" || rec["route"] != "/rest/v1/temp/" || rec["to"] != "23.00" {
+		t.Errorf("logged %v, want it tagged with the request, the route and the new value", rec)
+	}
+	if n := len(logs.records("nighttemp changed")); n != 0 {
+		t.Errorf("%d nighttemp changed lines for an unchanged target", n)
+	}
+
+	resp, body = h.Do(http.MethodPut, "/rest/v1/mode", map[string]string{"mode": "day", "heating": "auto"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /rest/v1/mode: %s %s", resp.Status, body)
+	}
+	if set := logs.records("mode set"); len(set) != 1 || set[0]["mode"] != "day" || set[0]["route"] != "/rest/v1/mode/" {
+		t.Errorf("mode set lines %v, want one for day on /rest/v1/mode/", set)
+	}
+}
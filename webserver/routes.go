@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RouteName names a route whose URLs are built with URLFor, so building
+// them doesn't repeat the path, and a misspelled name doesn't compile.
+type RouteName string
+
+const (
+	RouteArticles       RouteName = "articles"
+	RouteArticle        RouteName = "article"
+	RouteArticleBySlug  RouteName = "article.slug"
+	RouteArticleRestore RouteName = "article.restore"
+	RouteModeHistory    RouteName = "mode.history"
+	RouteAlertEvents    RouteName = "temp.alerts.events"
+	RouteSensorReading  RouteName = "sensors.reading"
+	RouteAdminSession   RouteName = "admin.session"
+)
+
+// RouteDef is where a route is registered: Pattern is what's passed to the
+// router, relative to the Prefix it's mounted under.
+type RouteDef struct {
+	Prefix  string
+	Pattern string
+}
+
+// namedRoutes defines the named routes. NewRouter registers them by their
+// pattern from here.
+var namedRoutes = map[RouteName]RouteDef{
+	RouteArticles:       {"/rest/v1", "/"},
+	RouteArticle:        {"/rest/v1", "/{articleID}"},
+	RouteArticleBySlug:  {"/rest/v1", "/{articleSlug:[a-z0-9-]*[a-z-][a-z0-9-]*}"},
+	RouteArticleRestore: {"/rest/v1/{articleID}", "/restore"},
+	RouteModeHistory:    {"/rest/v1/mode", "/history"},
+	RouteAlertEvents:    {"/rest/v1/temp", "/alerts/events"},
+	RouteSensorReading:  {"/rest/v1/sensors", "/{sensorName:[a-z0-9_-]+}/reading"},
+	RouteAdminSession:   {"/admin/sessions", "/{sessionID}"},
+}
+
+// pattern returns the pattern a route is registered with.
+func pattern(name RouteName) string {
+	def, ok := namedRoutes[name]
+	if !ok {
+		panic(fmt.Sprintf("no route %q", name))
+	}
+	return def.Pattern
+}
+
+var routeParam = regexp.MustCompile(`\{([^}:]+)(?::([^}]*))?\}`)
+
+// URLFor builds the path of the named route, filling in its parameters
+// from params, escaped. It fails if the route doesn't exist, if a
+// parameter is missing or doesn't match the route's regexp, or if params
+// has any the route doesn't take.
+func URLFor(name RouteName, params map[string]string) (string, error) {
+	def, ok := namedRoutes[name]
+	if !ok {
+		return "", fmt.Errorf("no route %q", name)
+	}
+	path := def.Prefix + def.Pattern
+
+	used := map[string]bool{}
+	var err error
+	path = routeParam.ReplaceAllStringFunc(path, func(m string) string {
+		sub := routeParam.FindStringSubmatch(m)
+		key, rex := sub[1], sub[2]
+		value, ok := params[key]
+		if !ok || value == "" {
+			if err == nil {
+				err = fmt.Errorf("route %q: missing parameter %q", name, key)
+			}
+			return m
+		}
+		if rex != "" && !regexp.MustCompile("^(?:"+rex+")$").MatchString(value) {
+			if err == nil {
+				err = fmt.Errorf("route %q: parameter %q doesn't match %s", name, key, rex)
+			}
+			return m
+		}
+		used[key] = true
+		return url.PathEscape(value)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var unknown []string
+	for key := range params {
+		if !used[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return "", fmt.Errorf("route %q: unknown parameters %s", name, strings.Join(unknown, ", "))
+	}
+	return path, nil
+}
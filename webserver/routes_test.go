@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestURLFor(t *testing.T) {
+	for _, tc := range []struct {
+		name   RouteName
+		params map[string]string
+		want   string // "" for an error
+	}{
+		{RouteArticles, nil, "/rest/v1/"},
+		{RouteArticle, map[string]string{"articleID": "7"}, "/rest/v1/7"},
+		{RouteArticle, map[string]string{"articleID": "a b/c"}, "/rest/v1/a%20b%2Fc"},
+		{RouteArticleRestore, map[string]string{"articleID": "7"}, "/rest/v1/7/restore"},
+		{RouteArticleBySlug, map[string]string{"articleSlug": "hello-world"}, "/rest/v1/hello-world"},
+		{RouteSensorReading, map[string]string{"sensorName": "attic_2"}, "/rest/v1/sensors/attic_2/reading"},
+		{RouteModeHistory, nil, "/rest/v1/mode/history"},
+
+		{"nope", nil, ""},
+		{RouteArticle, nil, ""},
+		{RouteArticle, map[string]string{"articleID": ""}, ""},
+		{RouteArticleBySlug, map[string]string{"articleSlug": "123"}, ""},
+		{RouteSensorReading, map[string]string{"sensorName": "Attic"}, ""},
+		{RouteArticle, map[string]string{"articleID": "7", "page": "2"}, ""},
+	} {
+		got, err := URLFor(tc.name, tc.params)
+		switch {
+		case tc.want == "" && err == nil:
+			t.Errorf("URLFor(%q, %v) = %q, want an error", tc.name, tc.params, got)
+		case tc.want != "" && (err != nil || got != tc.want):
+			t.Errorf("URLFor(%q, %v) = %q, %v; want %q", tc.name, tc.params, got, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+/**-----------------------------------------------------------------------------------
+ * JSON-RPC
+ * ========
+ * $ curl -X POST -d '{"jsonrpc":"2.0","method":"temp.set","params":{"daytemp":"22.00","nighttemp":"18.00","thereshold":"0.20"},"id":1}' http://bangkokguy.ddns.net/rpc
+ *   {"jsonrpc":"2.0","result":{"currenttemp":"21.30","nighttemp":"18.00","daytemp":"22.00","thereshold":"0.20"},"id":1}
+ * $ curl -X POST -d '[{"jsonrpc":"2.0","method":"mode.get","id":1},{"jsonrpc":"2.0","method":"mode.set","params":{"mode":"noon"},"id":2}]' http://bangkokguy.ddns.net/rpc
+ *   [{"jsonrpc":"2.0","result":{"Mode":["day","auto"],"Heating":["on","auto"]},"id":1},
+ *    {"jsonrpc":"2.0","error":{"code":-32602,"message":"mode: must be one of auto, day, night","data":{"code":"validation.not_allowed"}},"id":2}]
+ *------------------------------------------------------------------------------------*/
+
+// JSON-RPC error codes: the ones the spec defines, and ours for the
+// domain errors that aren't about the params, from -32000 down.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	rpcConflict       = -32001
+	rpcUnavailable    = -32002
+	rpcUnauthorized   = -32003
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"` // missing for a notification
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// rpcError carries the error code of the REST API along, as Data.Code.
+type rpcError struct {
+	Code    int           `json:"code"`
+	Message string        `json:"message"`
+	Data    *rpcErrorData `json:"data,omitempty"`
+}
+
+type rpcErrorData struct {
+	Code ErrorCode `json:"code"`
+}
+
+// rpcMethod runs a method for r, the HTTP request the call came with.
+type rpcMethod func(r *http.Request, params json.RawMessage) (interface{}, error)
+
+// rpcMethods are the methods, going through the same logic as their REST
+// counterparts.
+var rpcMethods = map[string]rpcMethod{
+	"temp.get":   rpcGetTemp,
+	"temp.set":   rpcSetTemp,
+	"mode.get":   rpcGetMode,
+	"mode.set":   rpcSetMode,
+	"status.get": rpcGetStatus,
+}
+
+// maxRPCBatch is the most calls a batch may hold.
+const maxRPCBatch = 32
+
+// ServeRPC answers JSON-RPC 2.0 calls, single or batched, over POST /rpc.
+// Params are passed by name, the way the REST API takes its bodies.
+// Temperatures are in the unit of ?unit= or -default-unit, unless the
+// params have one, and measured ones have the decimals of ?precision=.
+// Settings are applied right away, -temp-debounce aside. The body is
+// limited by -max-body-bytes, a batch to maxRPCBatch calls.
+func ServeRPC(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, *maxBodyBytes))
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	body = bytes.TrimSpace(body)
+
+	if len(body) > 0 && body[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			writeJSON(w, r, rpcFailure(nil, rpcParseError, err))
+			return
+		}
+		if len(batch) == 0 {
+			writeJSON(w, r, rpcFailure(nil, rpcInvalidRequest, errors.New("empty batch")))
+			return
+		}
+		if len(batch) > maxRPCBatch {
+			writeJSON(w, r, rpcFailure(nil, rpcInvalidRequest, fmt.Errorf("batch of %d calls, at most %d are taken", len(batch), maxRPCBatch)))
+			return
+		}
+		responses := []*rpcResponse{}
+		for _, call := range batch {
+			if resp := rpcCall(r, call); resp != nil {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusNoContent) // all notifications
+			return
+		}
+		writeJSON(w, r, responses)
+		return
+	}
+
+	resp := rpcCall(r, body)
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, r, resp)
+}
+
+// rpcCall runs one call, returning its response, or nil for a
+// notification.
+func rpcCall(r *http.Request, call json.RawMessage) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(call, &req); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return rpcFailure(nil, rpcParseError, err)
+		}
+		return rpcFailure(nil, rpcInvalidRequest, err)
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return rpcFailure(req.ID, rpcInvalidRequest, errors.New(`a call needs "jsonrpc":"2.0" and a method`))
+	}
+
+	method, ok := rpcMethods[req.Method]
+	var result interface{}
+	var err error
+	if !ok {
+		err = fmt.Errorf("no method %q", req.Method)
+	} else {
+		result, err = method(r, req.Params)
+	}
+	if req.ID == nil {
+		return nil
+	}
+	if !ok {
+		return rpcFailure(req.ID, rpcMethodNotFound, err)
+	}
+	if err != nil {
+		return &rpcResponse{JSONRPC: "2.0", Error: rpcErrorOf(err), ID: req.ID}
+	}
+	return &rpcResponse{JSONRPC: "2.0", Result: result, ID: req.ID}
+}
+
+func rpcFailure(id json.RawMessage, code int, err error) *rpcResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: code, Message: err.Error()}, ID: id}
+}
+
+// rpcErrorOf maps err to a JSON-RPC error by the status of its code in
+// the catalog: the client errors are invalid params, the rest get a code
+// of their own or are internal errors.
+func rpcErrorOf(err error) *rpcError {
+	code := codeOf(err, CodeInternal)
+	e := &rpcError{Code: rpcInternalError, Message: err.Error(), Data: &rpcErrorData{Code: code}}
+	switch errorCatalog[code].Status {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType:
+		e.Code = rpcInvalidParams
+	case http.StatusUnauthorized:
+		e.Code = rpcUnauthorized
+	case http.StatusConflict:
+		e.Code = rpcConflict
+	case http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		e.Code = rpcUnavailable
+	}
+	return e
+}
+
+// bindParams decodes params into v and binds and validates it, the way
+// decode does a request body. Its errors are invalid params.
+func bindParams(r *http.Request, params json.RawMessage, v render.Binder) error {
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		return withCode(CodeBodyRequired, errors.New("params required"))
+	}
+	err := decodeJSON(bytes.NewReader(params), v, false)
+	if err == nil {
+		err = v.Bind(r)
+	}
+	if err == nil {
+		err = validate(v)
+	}
+	return invalidParams(err)
+}
+
+// invalidParams tags err, if it has no code of its own, as a client error.
+func invalidParams(err error) error {
+	if err != nil && codeOf(err, "") == "" {
+		return withCode(CodeInvalidRequest, err)
+	}
+	return err
+}
+
+func rpcSource(r *http.Request) string {
+	return "rpc:" + middleware.GetReqID(r.Context())
+}
+
+func rpcGetTemp(r *http.Request, params json.RawMessage) (interface{}, error) {
+	unit, err := requestUnit(r)
+	if err != nil {
+		return nil, invalidParams(err)
+	}
+	precision, err := requestPrecision(r)
+	if err != nil {
+		return nil, err
+	}
+	temp, err := loadTemp(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	temp.convert("C", unit)
+	temp.CurrentTemp = roundTemp(temp.CurrentTemp, precision)
+	return temp, nil
+}
+
+func rpcSetTemp(r *http.Request, params json.RawMessage) (interface{}, error) {
+	if !mayChange(r) {
+		return nil, errOwnerRequired
+	}
+	if activeSchedule() != nil {
+		return nil, errScheduled
+	}
+	data := &Temp{}
+	if err := bindParams(r, params, data); err != nil {
+		return nil, err
+	}
+	if err := commitTemp(r.Context(), LoggerFrom(r.Context()), data, rpcSource(r)); err != nil {
+		return nil, err
+	}
+	return rpcGetTemp(r, nil)
+}
+
+func rpcGetMode(r *http.Request, params json.RawMessage) (interface{}, error) {
+	return loadMode(r.Context())
+}
+
+func rpcSetMode(r *http.Request, params json.RawMessage) (interface{}, error) {
+	if !mayChange(r) {
+		return nil, errOwnerRequired
+	}
+	data := &ModesIn{}
+	if err := bindParams(r, params, data); err != nil {
+		return nil, err
+	}
+	if err := commitMode(r.Context(), LoggerFrom(r.Context()), data, rpcSource(r)); err != nil {
+		return nil, invalidParams(err)
+	}
+	return loadMode(r.Context())
+}
+
+func rpcGetStatus(r *http.Request, params json.RawMessage) (interface{}, error) {
+	precision, err := requestPrecision(r)
+	if err != nil {
+		return nil, err
+	}
+	status, err := loadStatus(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	status.Temp = roundFloat(status.Temp, precision)
+	return status, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRPCBodyLimit(t *testing.T) {
+	h := newHarness(t, 20)
+	withFlag(t, maxBodyBytes, 64)
+	call := map[string]interface{}{"jsonrpc": "2.0", "method": "mode.get", "id": strings.Repeat("1", 64)}
+	if resp, body := h.Do(http.MethodPost, "/rpc", call); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST /rpc with a body over -max-body-bytes: %s %s, want 413", resp.Status, body)
+	}
+}
+
+func TestRPCBatchLimit(t *testing.T) {
+	h := newHarness(t, 20)
+	call := map[string]interface{}{"jsonrpc": "2.0", "method": "mode.get", "id": 1}
+
+	for _, tc := range []struct {
+		n     int
+		error bool
+	}{
+		{maxRPCBatch, false},
+		{maxRPCBatch + 1, true},
+	} {
+		batch := make([]interface{}, tc.n)
+		for i := range batch {
+			batch[i] = call
+		}
+		resp, body := h.Do(http.MethodPost, "/rpc", batch)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("batch of %d: %s %s", tc.n, resp.Status, body)
+		}
+		if tc.error {
+			var failure rpcResponse
+			if err := json.Unmarshal(body, &failure); err != nil || failure.Error == nil || failure.Error.Code != rpcInvalidRequest {
+				t.Errorf("batch of %d: %s, want an invalid request error", tc.n, body)
+			}
+			continue
+		}
+		var responses []rpcResponse
+		if err := json.Unmarshal(body, &responses); err != nil || len(responses) != tc.n {
+			t.Errorf("batch of %d: %d responses, %v", tc.n, len(responses), err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// RunGroup starts background workers (tickers, the HTTP listener, ...)
+// with a shared cancelable context and waits for all of them to stop.
+//
+// A worker should return once its context is done. If any worker fails,
+// the context is canceled so the remaining workers shut down as well.
+type RunGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	workers []func(ctx context.Context) error
+}
+
+// NewRunGroup returns a RunGroup whose workers are canceled when parent is
+// done or Stop is called.
+func NewRunGroup(parent context.Context) *RunGroup {
+	ctx, cancel := context.WithCancel(parent)
+	return &RunGroup{ctx: ctx, cancel: cancel}
+}
+
+// Add registers a worker. It must be called before Run.
+func (g *RunGroup) Add(worker func(ctx context.Context) error) {
+	g.workers = append(g.workers, worker)
+}
+
+// Stop cancels the context shared by all workers.
+func (g *RunGroup) Stop() {
+	g.cancel()
+}
+
+// Run starts every worker and blocks until all of them have returned.
+// Errors are aggregated; context cancellation is not treated as an error.
+func (g *RunGroup) Run() error {
+	defer g.cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, worker := range g.workers {
+		wg.Add(1)
+		go func(worker func(ctx context.Context) error) {
+			defer wg.Done()
+			err := worker(g.ctx)
+			if err == nil || errors.Is(err, context.Canceled) {
+				return
+			}
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+			g.cancel()
+		}(worker)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunGroupStop(t *testing.T) {
+	g := NewRunGroup(context.Background())
+	var stopped atomic.Int32
+	started := make(chan struct{}, 2)
+	for i := 0; i < 2; i++ {
+		g.Add(func(ctx context.Context) error {
+			started <- struct{}{}
+			<-ctx.Done()
+			time.Sleep(10 * time.Millisecond)
+			stopped.Add(1)
+			return ctx.Err()
+		})
+	}
+	done := make(chan error)
+	go func() { done <- g.Run() }()
+	<-started
+	<-started
+
+	g.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run after Stop: %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run didn't return after Stop")
+	}
+	if n := stopped.Load(); n != 2 {
+		t.Errorf("Run returned with %d of 2 workers stopped", n)
+	}
+}
+
+func TestRunGroupFailure(t *testing.T) {
+	g := NewRunGroup(context.Background())
+	failure := errors.New("worker failed")
+	canceled := make(chan struct{})
+	g.Add(func(ctx context.Context) error {
+		return failure
+	})
+	g.Add(func(ctx context.Context) error {
+		<-ctx.Done()
+		close(canceled)
+		return ctx.Err()
+	})
+
+	done := make(chan error)
+	go func() { done <- g.Run() }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, failure) {
+			t.Errorf("Run: %v, want the failing worker's error", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run didn't return after a worker failed")
+	}
+	select {
+	case <-canceled:
+	default:
+		t.Error("the other worker wasn't canceled")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"sync"
+)
+
+var safetyCutoff = flag.Float64("safety-cutoff", 32, "Reading in Celsius above which the heating is turned off, whatever the mode, overrides and forced phase say")
+var safetyHysteresis = flag.Float64("safety-hysteresis", 3, "How far in Celsius the reading has to drop below -safety-cutoff before the heating may turn on again")
+
+// checkSafetyFlags makes sure the cutoff can't be reached by a setpoint,
+// or the thermostat and the cutoff would fight, and that the cutoff and
+// frost protection can't both hold.
+func checkSafetyFlags() error {
+	if *safetyCutoff <= *maxSetpoint {
+		return errors.New("-safety-cutoff must be above -max-setpoint")
+	}
+	if *safetyHysteresis < 0 || *frostHysteresis < 0 {
+		return errors.New("-safety-hysteresis and -frost-hysteresis must not be negative")
+	}
+	if *frostProtection+*frostHysteresis >= *safetyCutoff-*safetyHysteresis {
+		return errors.New("-frost-protection must be well below -safety-cutoff")
+	}
+	return nil
+}
+
+// safety latches the cutoff: once tripped, it stays active until the
+// reading has dropped below the cutoff by the hysteresis.
+var safety struct {
+	mu     sync.Mutex
+	active bool
+}
+
+// checkSafety updates the cutoff with the current reading, in Celsius,
+// and reports whether it's active.
+func checkSafety(current float64) bool {
+	safety.mu.Lock()
+	defer safety.mu.Unlock()
+	switch {
+	case current > *safetyCutoff:
+		safety.active = true
+	case current < *safetyCutoff-*safetyHysteresis:
+		safety.active = false
+	}
+	return safety.active
+}
+
+// safetyActive reports whether the cutoff is holding the heating off.
+func safetyActive() bool {
+	safety.mu.Lock()
+	defer safety.mu.Unlock()
+	return safety.active
+}
+
+var frostProtection = flag.Float64("frost-protection", 5, "Reading in Celsius below which the heating is turned on, whatever the mode and overrides say")
+var frostHysteresis = flag.Float64("frost-hysteresis", 1, "How far in Celsius the reading has to rise above -frost-protection before frost protection lets go")
+
+// frost latches frost protection the same way safety latches the cutoff.
+var frost struct {
+	mu     sync.Mutex
+	active bool
+}
+
+// checkFrost updates frost protection with the current reading, in
+// Celsius, and reports whether it's active.
+func checkFrost(current float64) bool {
+	frost.mu.Lock()
+	defer frost.mu.Unlock()
+	switch {
+	case current < *frostProtection:
+		frost.active = true
+	case current > *frostProtection+*frostHysteresis:
+		frost.active = false
+	}
+	return frost.active
+}
+
+// frostActive reports whether frost protection is holding the heating on.
+func frostActive() bool {
+	frost.mu.Lock()
+	defer frost.mu.Unlock()
+	return frost.active
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// heatingAfter sets the reading to temp, lets the evaluator run and
+// returns the mode it leaves.
+func heatingAfter(h *harness, temp float64) Modes {
+	h.t.Helper()
+	h.SetReading(temp)
+	h.Advance(10 * time.Second)
+	var mode Modes
+	h.GetJSON("/rest/v1/mode", &mode)
+	return mode
+}
+
+func TestSafetyCutoff(t *testing.T) {
+	h := newHarness(t, 20)
+	if resp, body := h.Do(http.MethodPut, "/rest/v1/mode", map[string]string{"mode": "auto", "heating": "on"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /rest/v1/mode: %s %s", resp.Status, body)
+	}
+	setForcedPhase("day")
+
+	for _, step := range []struct {
+		temp    float64
+		heating string
+		cutoff  bool
+	}{
+		{31, "on", false},
+		{32.5, "off", true},
+		{30, "off", true}, // within the hysteresis
+		{28.9, "on", false},
+	} {
+		mode := heatingAfter(h, step.temp)
+		if mode.Heating[0] != step.heating || mode.SafetyCutoff != step.cutoff {
+			t.Errorf("at %gC with the heating on manually: heating %s, safety_cutoff %t; want %s, %t",
+				step.temp, mode.Heating[0], mode.SafetyCutoff, step.heating, step.cutoff)
+		}
+	}
+}
+
+func TestFrostProtection(t *testing.T) {
+	h := newHarness(t, 20)
+	if resp, body := h.Do(http.MethodPut, "/rest/v1/mode", map[string]string{"mode": "auto", "heating": "off"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /rest/v1/mode: %s %s", resp.Status, body)
+	}
+
+	for _, step := range []struct {
+		temp    float64
+		heating string
+		frost   bool
+	}{
+		{5.5, "off", false},
+		{4.5, "on", true},
+		{5.8, "on", true}, // within the hysteresis
+		{6.2, "off", false},
+	} {
+		mode := heatingAfter(h, step.temp)
+		if mode.Heating[0] != step.heating || mode.FrostProtection != step.frost {
+			t.Errorf("at %gC with the heating off manually: heating %s, frost_protection %t; want %s, %t",
+				step.temp, mode.Heating[0], mode.FrostProtection, step.heating, step.frost)
+		}
+	}
+}
+
+func TestCheckSafetyFlags(t *testing.T) {
+	withFlag(t, safetyCutoff, 32)
+	if err := checkSafetyFlags(); err != nil {
+		t.Errorf("the defaults: %v", err)
+	}
+	withFlag(t, safetyCutoff, *maxSetpoint)
+	if err := checkSafetyFlags(); err == nil {
+		t.Errorf("a cutoff a setpoint can reach was accepted")
+	}
+	withFlag(t, safetyCutoff, 32)
+	withFlag(t, frostProtection, 28.5)
+	if err := checkSafetyFlags(); err == nil {
+		t.Errorf("frost protection overlapping the cutoff's hysteresis was accepted")
+	}
+}
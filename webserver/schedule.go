@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+var scheduleFile = flag.String("schedule-file", "", "Schedule file with the target temperatures, which disables setting them through the API")
+
+// A schedule file has one rule per line: the time of day (HH:MM) from which
+// a target temperature (in Celsius) applies, up to the next rule. Blank
+// lines and lines starting with # are ignored.
+//
+//	# weekday
+//	06:00 21.5
+//	08:30 18
+//	17:00 22
+//	22:30 17
+
+// ScheduleSlot is a target temperature that applies from At on.
+type ScheduleSlot struct {
+	At     string    `json:"at"`
+	Target TempValue `json:"target"`
+}
+
+// Schedule is a list of slots ordered by time of day. The last slot of the
+// day carries on past midnight until the first one.
+type Schedule []ScheduleSlot
+
+var clockPattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+// ParseSchedule reads schedule rules, one per line.
+func ParseSchedule(r io.Reader) (Schedule, error) {
+	var s Schedule
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: want \"HH:MM target\"", n)
+		}
+		if !clockPattern.MatchString(fields[0]) {
+			return nil, fmt.Errorf("line %d: %q is not a HH:MM time", n, fields[0])
+		}
+		if seen[fields[0]] {
+			return nil, fmt.Errorf("line %d: %s is scheduled twice", n, fields[0])
+		}
+		seen[fields[0]] = true
+		var target TempValue
+		if err := target.UnmarshalJSON([]byte(fields[1])); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		s = append(s, ScheduleSlot{At: fields[0], Target: target})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(s) == 0 {
+		return nil, errors.New("schedule is empty")
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].At < s[j].At })
+	return s, nil
+}
+
+// Target returns the target temperature that applies at now.
+func (s Schedule) Target(now time.Time) TempValue {
+	clock := now.Format("15:04")
+	target := s[len(s)-1].Target
+	for _, slot := range s {
+		if slot.At > clock {
+			break
+		}
+		target = slot.Target
+	}
+	return target
+}
+
+var schedule struct {
+	mu sync.RWMutex
+	s  Schedule
+}
+
+// activeSchedule returns the schedule loaded from -schedule-file, or nil if
+// the targets are set through the API.
+func activeSchedule() Schedule {
+	schedule.mu.RLock()
+	defer schedule.mu.RUnlock()
+	return schedule.s
+}
+
+var errScheduled = errors.New("targets are governed by the schedule file")
+
+// loadSchedule (re)reads the schedule file. A broken file leaves the
+// schedule loaded before in place.
+func loadSchedule(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s, err := ParseSchedule(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	schedule.mu.Lock()
+	schedule.s = s
+	schedule.mu.Unlock()
+	return nil
+}
+
+// watchSchedule reloads the schedule file whenever it changes, until ctx is
+// done. The directory is watched rather than the file, so editors that save
+// by replacing the file are noticed too.
+func watchSchedule(ctx context.Context, path string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	name := filepath.Clean(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) != name || !(event.Has(fsnotify.Write) || event.Has(fsnotify.Create)) {
+				continue
+			}
+			if err := loadSchedule(path); err != nil {
+				log.Printf("Reloading schedule failed: %s", err)
+				continue
+			}
+			log.Printf("Reloaded schedule from %s", path)
+			evaluate(ctx, clock.Now())
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("Watching schedule failed: %s", err)
+		}
+	}
+}
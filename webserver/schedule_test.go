@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	s, err := ParseSchedule(strings.NewReader("# weekday\n\n17:00 22\n06:00 21.5\n  08:30   18  \n22:30 17\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Schedule{{"06:00", "21.50"}, {"08:30", "18.00"}, {"17:00", "22.00"}, {"22:30", "17.00"}}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("parsed %v, want %v", s, want)
+	}
+
+	for _, bad := range []string{
+		"",
+		"# only a comment\n",
+		"06:00\n",
+		"06:00 21 extra\n",
+		"6:00 21\n",
+		"24:00 21\n",
+		"06:00 warm\n",
+		"06:00 21\n06:00 22\n",
+	} {
+		if _, err := ParseSchedule(strings.NewReader(bad)); err == nil {
+			t.Errorf("parsing %q: no error", bad)
+		}
+	}
+}
+
+func TestScheduleTarget(t *testing.T) {
+	s := Schedule{{"06:00", "21.50"}, {"08:30", "18.00"}, {"22:30", "17.00"}}
+	for clock, want := range map[string]TempValue{
+		"00:00": "17.00", // carried on past midnight
+		"05:59": "17.00",
+		"06:00": "21.50",
+		"08:29": "21.50",
+		"08:30": "18.00",
+		"22:30": "17.00",
+		"23:59": "17.00",
+	} {
+		now, _ := time.Parse("15:04", clock)
+		if got := s.Target(now); got != want {
+			t.Errorf("target at %s: %s, want %s", clock, got, want)
+		}
+	}
+}
+
+// withScheduleFile writes a schedule file and loads it, unloading it when
+// the test is done.
+func withScheduleFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "schedule")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		schedule.mu.Lock()
+		schedule.s = nil
+		schedule.mu.Unlock()
+	})
+	if err := loadSchedule(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestScheduleFileRejectsPuts(t *testing.T) {
+	h := newHarness(t, 20)
+	withScheduleFile(t, "06:00 21\n22:00 17\n")
+
+	for path, body := range map[string]interface{}{
+		"/rest/v1/temp": map[string]string{"daytemp": "23", "nighttemp": "18", "thereshold": "0.2"},
+		"/rest/v1/time": map[string]string{"day": "07:00", "night": "22:00"},
+	} {
+		resp, data := h.Do(http.MethodPut, path, body)
+		if resp.StatusCode != http.StatusConflict || !jsonHasCode(data, CodeScheduled) {
+			t.Errorf("PUT %s with a schedule file: %s %s, want 409 %s", path, resp.Status, data, CodeScheduled)
+		}
+	}
+}
+
+func TestWatchScheduleReloads(t *testing.T) {
+	resetState(t)
+	path := withScheduleFile(t, "06:00 21\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- watchSchedule(ctx, path) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	// The watcher may not be watching yet, so the change is written again
+	// until it's noticed.
+	want := Schedule{{"07:00", "19.00"}}
+	deadline := time.Now().Add(5 * time.Second)
+	for !reflect.DeepEqual(activeSchedule(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("schedule %v after the file changed, want %v", activeSchedule(), want)
+		}
+		os.WriteFile(path, []byte("07:00 19\n"), 0o600)
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// A broken file keeps the schedule loaded before.
+	os.WriteFile(path, []byte("not a schedule\n"), 0o600)
+	time.Sleep(200 * time.Millisecond)
+	if got := activeSchedule(); !reflect.DeepEqual(got, want) {
+		t.Errorf("schedule %v after the file broke, want %v kept", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// Search scores, highest first within each field. Title matches always
+// outrank slug matches, and matches at the start of a field outrank
+// matches further in.
+const (
+	scoreTitlePrefix = 8
+	scoreTitle       = 4
+	scoreSlugPrefix  = 2
+	scoreSlug        = 1
+)
+
+// SearchResult is an article found by a search, with its score when the
+// client asked for ?debug=true.
+type SearchResult struct {
+	*ArticleResponse
+
+	Score int `json:"score,omitempty"`
+}
+
+// searchScore rates how well an article matches the (lower case) query.
+// Zero means it doesn't match at all.
+func searchScore(a *Article, q string) int {
+	return fieldScore(strings.ToLower(a.Title), q, scoreTitlePrefix, scoreTitle) +
+		fieldScore(strings.ToLower(a.Slug), q, scoreSlugPrefix, scoreSlug)
+}
+
+func fieldScore(field, q string, prefix, contains int) int {
+	switch {
+	case strings.HasPrefix(field, q):
+		return prefix
+	case strings.Contains(field, q):
+		return contains
+	}
+	return 0
+}
+
+type scoredArticle struct {
+	article *Article
+	score   int
+}
+
+// rankArticles returns the articles matching q, best match first. Equally
+// scored articles keep their order.
+func rankArticles(articles []*Article, q string) []scoredArticle {
+	q = strings.ToLower(strings.TrimSpace(q))
+
+	ranked := []scoredArticle{}
+	for _, a := range articles {
+		if score := searchScore(a, q); score > 0 {
+			ranked = append(ranked, scoredArticle{a, score})
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score > ranked[j].score
+	})
+	return ranked
+}
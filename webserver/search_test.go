@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestRankArticles(t *testing.T) {
+	articles := []*Article{
+		{ID: "slug-mid", Title: "Misc", Slug: "old-heating"},
+		{ID: "unrelated", Title: "Cooling", Slug: "cooling"},
+		{ID: "slug-prefix", Title: "Other", Slug: "heating"},
+		{ID: "title-mid", Title: "About heating", Slug: "heating-about"},
+		{ID: "slug-prefix-2", Title: "More", Slug: "heating-2"},
+		{ID: "title-prefix", Title: "Heating guide", Slug: "guide"},
+		{ID: "both-prefix", Title: "HEATING", Slug: "heating-3"},
+	}
+	var got []string
+	var scores []int
+	for _, m := range rankArticles(articles, " Heating ") {
+		got = append(got, m.article.ID)
+		scores = append(scores, m.score)
+	}
+	// Equally scored articles keep their order.
+	want := []string{"both-prefix", "title-prefix", "title-mid", "slug-prefix", "slug-prefix-2", "slug-mid"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ranked %v, want %v", got, want)
+	}
+	wantScores := []int{
+		scoreTitlePrefix + scoreSlugPrefix,
+		scoreTitlePrefix,
+		scoreTitle + scoreSlugPrefix,
+		scoreSlugPrefix,
+		scoreSlugPrefix,
+		scoreSlug,
+	}
+	if !reflect.DeepEqual(scores, wantScores) {
+		t.Errorf("scores %v, want %v", scores, wantScores)
+	}
+}
+
+func TestSearchArticles(t *testing.T) {
+	h := newHarness(t, 20)
+	for _, a := range []*Article{
+		{UserID: 100, Title: "about boilers", Slug: "about-boilers"},
+		{UserID: 100, Title: "boilers", Slug: "boilers"},
+	} {
+		if _, err := h.Store.NewArticle(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var results []map[string]interface{}
+	h.GetJSON("/rest/v1/search?q=boil&debug=true", &results)
+	if len(results) != 2 || results[0]["title"] != "boilers" || results[1]["title"] != "about boilers" {
+		t.Fatalf("GET /rest/v1/search?q=boil: %v, want the title prefix match first", results)
+	}
+	if results[0]["score"] != float64(scoreTitlePrefix+scoreSlugPrefix) {
+		t.Errorf("score with ?debug=true: %v, want %d", results[0]["score"], scoreTitlePrefix+scoreSlugPrefix)
+	}
+
+	results = nil
+	h.GetJSON("/rest/v1/search?q=boil", &results)
+	if len(results) != 2 {
+		t.Fatalf("GET /rest/v1/search?q=boil without debug: %v", results)
+	}
+	if _, ok := results[0]["score"]; ok {
+		t.Errorf("score without ?debug=true: %v", results[0])
+	}
+
+	if resp, body := h.Do(http.MethodGet, "/rest/v1/search?q=+", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET /rest/v1/search with a blank q: %s %s, want 400", resp.Status, body)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+var configCheck = flag.String("config-check", "lenient", "What to do about invalid settings found at startup: strict refuses to start, lenient falls back to the defaults for them")
+
+// defaultConfig holds the settings used in place of invalid ones in
+// lenient mode.
+var defaultConfig = Config{
+	Day:        "06:00",
+	Night:      "22:00",
+	DayTemp:    "24.00",
+	NightTemp:  "18.00",
+	Thereshold: "0.20",
+	Mode:       "auto",
+	Heating:    "auto",
+}
+
+// configProblem is something wrong with a setting, and how to fix it in
+// lenient mode.
+type configProblem struct {
+	err error
+	fix func(c *Config)
+}
+
+// validateConfig checks the settings the server starts with, typically
+// restored from -state-file, and logs every problem it finds. In strict
+// mode any problem is an error; in lenient mode the offending settings are
+// replaced by their defaults.
+func validateConfig() error {
+	if *configCheck != "strict" && *configCheck != "lenient" {
+		return fmt.Errorf("unknown -config-check %q, must be strict or lenient", *configCheck)
+	}
+	c, err := loadConfig(context.Background())
+	if err != nil {
+		return err
+	}
+
+	problems := checkConfig(c)
+	forced := forcedPhase()
+	if forced != "" && forced != "day" && forced != "night" {
+		problems = append(problems, configProblem{err: fmt.Errorf("forced %q: must be day or night", forced)})
+		forced = ""
+	}
+	if tz := os.Getenv("TZ"); tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			// Nothing to fall back to but UTC, which Go has done already.
+			problems = append(problems, configProblem{err: fmt.Errorf("TZ %q: %w", tz, err)})
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	for _, p := range problems {
+		log.Printf("Config check: %s", p.err)
+	}
+	if *configCheck == "strict" {
+		return fmt.Errorf("%d invalid settings", len(problems))
+	}
+
+	for _, p := range problems {
+		if p.fix != nil {
+			p.fix(c)
+		}
+	}
+	if err := applyState(context.Background(), c.state(forced)); err != nil {
+		return err
+	}
+	persistState(context.Background())
+	log.Printf("Config check: fell back to defaults for the invalid settings")
+	return nil
+}
+
+// checkConfig lists the problems of c. Unlike Config.validate it doesn't
+// stop at the first one.
+func checkConfig(c *Config) []configProblem {
+	var problems []configProblem
+	add := func(err error, fix func(c *Config)) {
+		problems = append(problems, configProblem{err: err, fix: fix})
+	}
+
+	dayOK, nightOK := true, true
+	if err := checkDayTime(c.Day); err != nil {
+		dayOK = false
+		add(fmt.Errorf("day %q: %w", c.Day, err), func(c *Config) { c.Day = defaultConfig.Day })
+	}
+	if err := checkDayTime(c.Night); err != nil {
+		nightOK = false
+		add(fmt.Errorf("night %q: %w", c.Night, err), func(c *Config) { c.Night = defaultConfig.Night })
+	}
+	if dayOK && nightOK && c.Day == c.Night {
+		// The day period may wrap midnight, but it can't be empty.
+		add(fmt.Errorf("day and night both start at %s", c.Day), func(c *Config) {
+			c.Day, c.Night = defaultConfig.Day, defaultConfig.Night
+		})
+	}
+
+	for _, t := range []struct {
+		name  string
+		value TempValue
+		fix   func(c *Config)
+	}{
+		{"daytemp", c.DayTemp, func(c *Config) { c.DayTemp = defaultConfig.DayTemp }},
+		{"nighttemp", c.NightTemp, func(c *Config) { c.NightTemp = defaultConfig.NightTemp }},
+	} {
+		if err := checkTarget(t.value); err != nil {
+			add(fmt.Errorf("%s %q: %w", t.name, t.value, err), t.fix)
+		}
+	}
+	if f, err := strconv.ParseFloat(string(c.Thereshold), 64); err != nil || f <= 0 || f > 10 {
+		add(fmt.Errorf("thereshold %q: must be a number above 0 and at most 10", c.Thereshold),
+			func(c *Config) { c.Thereshold = defaultConfig.Thereshold })
+	}
+
+	switch c.Mode {
+	case "auto", "day", "night":
+	default:
+		add(fmt.Errorf("mode %q: must be auto, day or night", c.Mode), func(c *Config) { c.Mode = defaultConfig.Mode })
+	}
+	switch c.Heating {
+	case "auto", "on", "off":
+	default:
+		add(fmt.Errorf("heating %q: must be auto, on or off", c.Heating), func(c *Config) { c.Heating = defaultConfig.Heating })
+	}
+	return problems
+}
+
+// checkTarget checks a day or night target, in Celsius, against the
+// sensor's range and the setpoint bounds.
+func checkTarget(v TempValue) error {
+	f, err := strconv.ParseFloat(string(v), 64)
+	if err != nil {
+		return errors.New("must be a number")
+	}
+	if f < min || f > max {
+		return fmt.Errorf("must be between %d and %d", min, max)
+	}
+	if f < *minSetpoint || f > *maxSetpoint {
+		return fmt.Errorf("must be between the setpoints %g and %g", *minSetpoint, *maxSetpoint)
+	}
+	return nil
+}
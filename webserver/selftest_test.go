@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCheckConfig(t *testing.T) {
+	if problems := checkConfig(&Config{Day: "06:00", Night: "22:00", DayTemp: "24.00", NightTemp: "18.00", Thereshold: "0.20", Mode: "auto", Heating: "auto"}); len(problems) != 0 {
+		t.Errorf("the defaults have problems: %v", problems)
+	}
+
+	bad := Config{Day: "25:00", Night: "22:00", DayTemp: "99", NightTemp: "cold", Thereshold: "0", Mode: "dusk", Heating: "maybe"}
+	problems := checkConfig(&bad)
+	var errs []string
+	for _, p := range problems {
+		errs = append(errs, p.err.Error())
+		p.fix(&bad)
+	}
+	if len(problems) != 6 {
+		t.Errorf("%d problems, want one per bad setting:\n%s", len(problems), strings.Join(errs, "\n"))
+	}
+	if bad != defaultConfig {
+		t.Errorf("fixed up to %+v, want the defaults", bad)
+	}
+
+	// A day period may wrap midnight, but not be empty.
+	if problems := checkConfig(&Config{Day: "22:00", Night: "06:00", DayTemp: "24.00", NightTemp: "18.00", Thereshold: "0.20", Mode: "auto", Heating: "auto"}); len(problems) != 0 {
+		t.Errorf("a day wrapping midnight: %v", problems)
+	}
+	same := defaultConfig
+	same.Night = same.Day
+	if problems := checkConfig(&same); len(problems) != 1 {
+		t.Errorf("day and night at the same time: %d problems, want 1", len(problems))
+	}
+}
+
+func TestValidateConfigModes(t *testing.T) {
+	resetState(t)
+	for _, mode := range []string{"strict", "lenient"} {
+		withFlag(t, &store, Store(NewInMemoryStore()))
+		withFlag(t, configCheck, mode)
+		bad := defaultConfig
+		bad.DayTemp, bad.Mode = "99.00", "dusk"
+		if err := applyState(context.Background(), bad.state("")); err != nil {
+			t.Fatal(err)
+		}
+
+		err := validateConfig()
+		if mode == "strict" {
+			if err == nil {
+				t.Errorf("-config-check=strict started with invalid settings")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("-config-check=lenient: %v", err)
+		}
+		c, err := loadConfig(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *c != defaultConfig {
+			t.Errorf("-config-check=lenient left %+v, want the defaults for the invalid settings", *c)
+		}
+	}
+
+	withFlag(t, configCheck, "loose")
+	if err := validateConfig(); err == nil {
+		t.Errorf("-config-check=loose accepted")
+	}
+}
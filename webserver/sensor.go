@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var sensorSeed = flag.Int64("sensor-seed", 0, "Seed of the random readings while there's no real sensor, to replay the same ones; random if 0")
+
+// Sensor reads the current temperature, in Celsius. A read gives up when
+// ctx is done.
+type Sensor interface {
+	Read(ctx context.Context) (float64, error)
+}
+
+var sensor Sensor = newRandomSensor(0)
+
+// randomSensor stands in while there's no real sensor: it reads a random
+// value in the sensor's range. The same seed gives the same readings.
+type randomSensor struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// newRandomSensor returns a random sensor seeded with seed, or with the
+// time if it's 0.
+func newRandomSensor(seed int64) *randomSensor {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &randomSensor{rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (s *randomSensor) Read(ctx context.Context) (float64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	f := s.rnd.Float64()
+	s.mu.Unlock()
+	return math.Max(min, math.Min(max, min+f*(max-min))), nil
+}
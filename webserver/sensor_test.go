@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRandomSensorSeed(t *testing.T) {
+	read := func(s Sensor) [8]float64 {
+		var list [8]float64
+		for i := range list {
+			v, err := s.Read(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v < min || v > max {
+				t.Fatalf("read %v, out of the range %v to %v", v, min, max)
+			}
+			list[i] = v
+		}
+		return list
+	}
+
+	if a, b := read(newRandomSensor(42)), read(newRandomSensor(42)); a != b {
+		t.Errorf("the same seed read %v and %v", a, b)
+	}
+	if a, b := read(newRandomSensor(42)), read(newRandomSensor(43)); a == b {
+		t.Errorf("seeds 42 and 43 read the same %v", a)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := newRandomSensor(42).Read(ctx); err == nil {
+		t.Errorf("read with the context done")
+	}
+}
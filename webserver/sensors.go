@@ -0,0 +1,262 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+/**-----------------------------------------------------------------------------------
+ * remote sensors
+ * ==============
+ * $ curl -X POST -H 'X-Sensor-Token: 5b1e...' -H 'Content-Type: application/json' -d '{"temp":"21.5"}' http://bangkokguy.ddns.net/rest/v1/sensors/bedroom/reading
+ *   {"name":"bedroom","temp":21.5,"at":"...","age":"0s","stale":false}
+ * $ curl http://bangkokguy.ddns.net/rest/v1/sensors
+ *   [{"name":"bedroom","temp":21.5,"at":"...","age":"12s","stale":false},{"name":"kitchen","temp":19,"at":"...","age":"9m3s","stale":true}]
+ *------------------------------------------------------------------------------------*/
+
+var (
+	sensorAggregate = flag.String("sensor-aggregate", "mean", "How the readings of the remote sensors make up the control temperature: min, max, mean or sensor:NAME")
+	sensorMaxAge    = flag.Duration("sensor-max-age", 5*time.Minute, "How old a remote sensor's reading may be before it's left out of the control temperature")
+	sensorTokens    = flag.String("sensor-tokens", "", "Comma-separated NAME=TOKEN pairs, the remote sensors and the tokens they post their readings with in X-Sensor-Token; the owner, admins and session holders may post for any sensor")
+)
+
+var errSensorToken = errors.New("posting a reading needs the sensor's token or an authenticated user")
+
+const maxSensors = 16
+
+var errTooManySensors = fmt.Errorf("there can be at most %d sensors", maxSensors)
+
+// checkSensorFlags makes sure -sensor-aggregate names a known aggregation,
+// -sensor-max-age is positive and -sensor-tokens parses.
+func checkSensorFlags() error {
+	switch agg := *sensorAggregate; {
+	case agg == "min", agg == "max", agg == "mean":
+	case strings.HasPrefix(agg, "sensor:") && agg != "sensor:":
+	default:
+		return fmt.Errorf("unknown -sensor-aggregate %q, must be min, max, mean or sensor:NAME", agg)
+	}
+	if *sensorMaxAge <= 0 {
+		return errors.New("-sensor-max-age must be positive")
+	}
+	tokens, err := parseSensorTokens(*sensorTokens)
+	if err != nil {
+		return err
+	}
+	setSensorTokens(tokens)
+	return nil
+}
+
+// parseSensorTokens parses -sensor-tokens into the SHA-256 of each
+// sensor's token, by name.
+func parseSensorTokens(s string) (map[string]string, error) {
+	tokens := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, token, ok := strings.Cut(pair, "=")
+		if !ok || name == "" || token == "" {
+			return nil, fmt.Errorf("-sensor-tokens: %q isn't NAME=TOKEN", pair)
+		}
+		if _, dup := tokens[name]; dup {
+			return nil, fmt.Errorf("-sensor-tokens: sensor %q given twice", name)
+		}
+		tokens[name] = hashOwnerToken(token)
+	}
+	return tokens, nil
+}
+
+// sensorTokenHashes are the hashed tokens of -sensor-tokens, by sensor name.
+var sensorTokenHashes struct {
+	sync.RWMutex
+	m map[string]string
+}
+
+func setSensorTokens(tokens map[string]string) {
+	sensorTokenHashes.Lock()
+	defer sensorTokenHashes.Unlock()
+	sensorTokenHashes.m = tokens
+}
+
+// mayPostReading tells whether the request may post a reading of the
+// named sensor: with that sensor's token, or authenticated. Unlike the
+// settings, readings aren't open while the device is unclaimed; they
+// drive the heating.
+func mayPostReading(r *http.Request, name string) bool {
+	if authenticated(r) {
+		return true
+	}
+	token := r.Header.Get("X-Sensor-Token")
+	if token == "" {
+		return false
+	}
+	sensorTokenHashes.RLock()
+	want, ok := sensorTokenHashes.m[name]
+	sensorTokenHashes.RUnlock()
+	return ok && subtle.ConstantTimeCompare([]byte(hashOwnerToken(token)), []byte(want)) == 1
+}
+
+// remote holds the last reading posted by each named sensor.
+var remote struct {
+	mu       sync.Mutex
+	readings map[string]Reading
+}
+
+// postReading records a reading of the named sensor. A sensor is known
+// from its first reading on; there can be at most maxSensors of them.
+func postReading(name string, reading Reading) error {
+	remote.mu.Lock()
+	defer remote.mu.Unlock()
+	if remote.readings == nil {
+		remote.readings = map[string]Reading{}
+	}
+	if _, ok := remote.readings[name]; !ok && len(remote.readings) >= maxSensors {
+		return errTooManySensors
+	}
+	remote.readings[name] = reading
+	return nil
+}
+
+// aggregate returns the control temperature made up of the remote
+// sensors' readings that are no older than maxAge, as agg says, dated by
+// the oldest reading that went into it. It reports false if there is no
+// such reading, or with sensor:NAME, if that sensor's isn't one of them.
+func aggregate(readings map[string]Reading, agg string, maxAge time.Duration, now time.Time) (Reading, bool) {
+	if name, ok := strings.CutPrefix(agg, "sensor:"); ok {
+		reading, ok := readings[name]
+		if !ok || now.Sub(reading.At) > maxAge {
+			return Reading{}, false
+		}
+		return reading, true
+	}
+
+	var out Reading
+	n := 0
+	for _, reading := range readings {
+		if now.Sub(reading.At) > maxAge {
+			continue
+		}
+		switch {
+		case n == 0:
+			out = reading
+		case agg == "min" && reading.Temp < out.Temp, agg == "max" && reading.Temp > out.Temp:
+			out.Temp = reading.Temp
+		case agg == "mean":
+			out.Temp += reading.Temp
+		}
+		if reading.At.Before(out.At) {
+			out.At = reading.At
+		}
+		n++
+	}
+	if n == 0 {
+		return Reading{}, false
+	}
+	if agg == "mean" {
+		out.Temp /= float64(n)
+	}
+	return out, true
+}
+
+// remoteReading returns the control temperature made up of the remote
+// sensors, if any of them counts.
+func remoteReading(now time.Time) (Reading, bool) {
+	remote.mu.Lock()
+	defer remote.mu.Unlock()
+	return aggregate(remote.readings, *sensorAggregate, *sensorMaxAge, now)
+}
+
+// SensorReading is a reading posted by a remote sensor, in Celsius.
+type SensorReading struct {
+	Temp TempValue `json:"temp" validate:"required,min=-10,max=40"`
+}
+
+func (s *SensorReading) Bind(r *http.Request) error {
+	return nil
+}
+
+// SensorStatus is a remote sensor with its last reading.
+type SensorStatus struct {
+	Name  string    `json:"name"`
+	Temp  float64   `json:"temp"`
+	At    time.Time `json:"at"`
+	Age   string    `json:"age"`
+	Stale bool      `json:"stale"` // left out of the control temperature
+}
+
+func (s *SensorStatus) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func sensorStatus(name string, reading Reading, now time.Time) *SensorStatus {
+	age := now.Sub(reading.At)
+	return &SensorStatus{
+		Name:  name,
+		Temp:  reading.Temp,
+		At:    reading.At,
+		Age:   age.Round(time.Second).String(),
+		Stale: age > *sensorMaxAge,
+	}
+}
+
+// PostSensorReading records a reading of the sensor named in the path. It
+// takes the sensor's token in X-Sensor-Token, or an authenticated user.
+func PostSensorReading(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "sensorName")
+	if !mayPostReading(r, name) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		render.Render(w, r, ErrUnauthorized(errSensorToken))
+		return
+	}
+	data := &SensorReading{}
+	if err := decode(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	temp, err := strconv.ParseFloat(string(data.Temp), 64)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	now := clockOf(r.Context()).Now()
+	if err := postReading(name, Reading{Temp: temp, At: now}); err != nil {
+		render.Render(w, r, ErrConflict(err))
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.Render(w, r, sensorStatus(name, Reading{Temp: temp, At: now}, now))
+}
+
+// ListSensors serves the remote sensors with their last readings, ordered
+// by name.
+func ListSensors(w http.ResponseWriter, r *http.Request) {
+	now := clockOf(r.Context()).Now()
+	remote.mu.Lock()
+	names := make([]string, 0, len(remote.readings))
+	for name := range remote.readings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := []render.Renderer{}
+	for _, name := range names {
+		list = append(list, sensorStatus(name, remote.readings[name], now))
+	}
+	remote.mu.Unlock()
+
+	if err := render.RenderList(w, r, list); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPostSensorReadingNeedsAToken(t *testing.T) {
+	h := newHarness(t, 20)
+	tokens, err := parseSensorTokens("bedroom=bedroom-token, kitchen=kitchen-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setSensorTokens(tokens)
+	t.Cleanup(func() { setSensorTokens(nil) })
+	setAdminKey("admin-key")
+
+	reading := map[string]string{"temp": "21.5"}
+	for _, tc := range []struct {
+		name, sensor string
+		header       []string
+		want         int
+	}{
+		{"no token", "bedroom", nil, http.StatusUnauthorized},
+		{"wrong token", "bedroom", []string{"X-Sensor-Token", "guess"}, http.StatusUnauthorized},
+		{"another sensor's token", "kitchen", []string{"X-Sensor-Token", "bedroom-token"}, http.StatusUnauthorized},
+		{"unknown sensor", "attic", []string{"X-Sensor-Token", "bedroom-token"}, http.StatusUnauthorized},
+		{"its token", "bedroom", []string{"X-Sensor-Token", "bedroom-token"}, http.StatusCreated},
+		{"admin", "attic", []string{"Authorization", "Bearer admin-key"}, http.StatusCreated},
+	} {
+		resp, body := h.Do(http.MethodPost, "/rest/v1/sensors/"+tc.sensor+"/reading", reading, tc.header...)
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: %s %s, want %d", tc.name, resp.Status, body, tc.want)
+		}
+	}
+
+	// The owner's claim doesn't lock the sensors out.
+	if resp, body := h.Do(http.MethodPost, "/rest/v1/device/claim", map[string]string{"token": "correct-horse-battery-staple"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("claiming: %s %s", resp.Status, body)
+	}
+	if resp, body := h.Do(http.MethodPost, "/rest/v1/sensors/kitchen/reading", reading, "X-Sensor-Token", "kitchen-token"); resp.StatusCode != http.StatusCreated {
+		t.Errorf("posting with the sensor's token on a claimed device: %s %s, want 201", resp.Status, body)
+	}
+}
+
+func TestParseSensorTokens(t *testing.T) {
+	for _, s := range []string{"bedroom", "=token", "bedroom=", "a=1,a=2"} {
+		if _, err := parseSensorTokens(s); err == nil {
+			t.Errorf("parseSensorTokens(%q) succeeded, want an error", s)
+		}
+	}
+	if tokens, err := parseSensorTokens(""); err != nil || len(tokens) != 0 {
+		t.Errorf("parseSensorTokens(\"\") = %v, %v; want no tokens", tokens, err)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var tlsCert = flag.String("tls-cert", "", "Certificate file to serve HTTPS with, together with -tls-key")
+var tlsKey = flag.String("tls-key", "", "Key file of -tls-cert")
+var http2 = flag.Bool("http2", true, "Offer HTTP/2 over TLS; turn it off for clients that misbehave with it")
+var keepAlive = flag.Bool("keep-alive", true, "Keep client connections open between requests")
+var idleTimeout = flag.Duration("idle-timeout", 2*time.Minute, "How long an idle kept-alive connection stays open")
+var readHeaderTimeout = flag.Duration("read-header-timeout", 10*time.Second, "How long a client may take to send the request headers")
+var maxHeaderBytes = flag.Int("max-header-bytes", http.DefaultMaxHeaderBytes, "Largest request header accepted, in bytes")
+
+// newServer builds the HTTP server for handler from the flags. HTTP/2 is
+// only negotiated over TLS, so -http2 only matters with -tls-cert.
+func newServer(addr string, handler http.Handler) *http.Server {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		IdleTimeout:       *idleTimeout,
+		ReadHeaderTimeout: *readHeaderTimeout,
+		MaxHeaderBytes:    *maxHeaderBytes,
+		ConnState:         conns.track,
+	}
+	if !*http2 {
+		// A non-nil, empty map keeps net/http from setting up HTTP/2.
+		srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+	srv.SetKeepAlivesEnabled(*keepAlive)
+	return srv
+}
+
+// connTracker keeps the connections the server is responsible for, to
+// tell how many a forced close drops. Hijacked ones, the WebSockets, are
+// the handlers' to close.
+type connTracker struct {
+	mu sync.Mutex
+	m  map[net.Conn]bool
+}
+
+var conns = &connTracker{m: map[net.Conn]bool{}}
+
+func (t *connTracker) track(c net.Conn, state http.ConnState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch state {
+	case http.StateClosed, http.StateHijacked:
+		delete(t.m, c)
+	default:
+		t.m[c] = true
+	}
+}
+
+func (t *connTracker) open() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.m)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewServerFlags(t *testing.T) {
+	withFlag(t, http2, false)
+	withFlag(t, keepAlive, false)
+	withFlag(t, idleTimeout, time.Minute)
+	withFlag(t, readHeaderTimeout, 3*time.Second)
+	withFlag(t, maxHeaderBytes, 4096)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	srv := newServer("127.0.0.1:0", handler)
+	if srv.TLSNextProto == nil || len(srv.TLSNextProto) != 0 {
+		t.Errorf("-http2=false: TLSNextProto %v, want an empty map", srv.TLSNextProto)
+	}
+	if srv.IdleTimeout != time.Minute || srv.ReadHeaderTimeout != 3*time.Second || srv.MaxHeaderBytes != 4096 {
+		t.Errorf("server timeouts %s, %s and header limit %d, not the flags'", srv.IdleTimeout, srv.ReadHeaderTimeout, srv.MaxHeaderBytes)
+	}
+
+	// A server without keep-alives closes the connection after a request.
+	ts := httptest.NewUnstartedServer(handler)
+	ts.Config = srv
+	ts.Start()
+	defer ts.Close()
+	resp, err := ts.Client().Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !resp.Close {
+		t.Errorf("-keep-alive=false: the connection was kept open")
+	}
+
+	withFlag(t, http2, true)
+	if srv := newServer("127.0.0.1:0", handler); srv.TLSNextProto != nil {
+		t.Errorf("-http2=true: TLSNextProto set, which turns HTTP/2 off")
+	}
+}
+
+func TestServeClosesStuckConnections(t *testing.T) {
+	resetState(t)
+	withFlag(t, drainDelay, 0)
+	withFlag(t, shutdownTimeout, 100*time.Millisecond)
+	t.Cleanup(func() { ready.Store(false) })
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	entered, release := make(chan struct{}), make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	srv := newServer(addr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, srv) }()
+
+	requested := make(chan error, 1)
+	go func() {
+		// serve may not be listening yet.
+		for {
+			resp, err := http.Get("http://" + addr + "/stuck")
+			if err == nil {
+				resp.Body.Close()
+			}
+			select {
+			case <-entered:
+				requested <- err
+				return
+			default:
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	}()
+	select {
+	case <-entered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request never reached the handler")
+	}
+
+	start := time.Now()
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("serve: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < *shutdownTimeout {
+			t.Errorf("serve returned after %s, before -shutdown-timeout", elapsed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve still waiting on the stuck request")
+	}
+	if err := <-requested; err == nil {
+		t.Errorf("the stuck request got a response, want its connection closed")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+)
+
+// The setpoint bounds are policy: how low or high anyone may set the day
+// and night targets. They are separate from the sensor's physical range
+// (min and max).
+var minSetpoint = flag.Float64("min-setpoint", 5, "Lowest day/night target that can be set, in Celsius")
+var maxSetpoint = flag.Float64("max-setpoint", 30, "Highest day/night target that can be set, in Celsius")
+
+// checkSetpointFlags makes sure the setpoint bounds make sense.
+func checkSetpointFlags() error {
+	if *minSetpoint > *maxSetpoint {
+		return errors.New("-min-setpoint is above -max-setpoint")
+	}
+	return nil
+}
+
+// checkSetpoints rejects day and night targets (in Celsius) outside the
+// setpoint bounds. Targets left empty aren't checked.
+func checkSetpoints(t *Temp) error {
+	for _, sp := range []struct {
+		name  string
+		value TempValue
+	}{{"daytemp", t.DayTemp}, {"nighttemp", t.NightTemp}} {
+		if sp.value == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(string(sp.value), 64)
+		if err != nil {
+			return fmt.Errorf("%s: %w", sp.name, err)
+		}
+		if f < *minSetpoint {
+			return withCode(CodeTempOutOfRange, fmt.Errorf("%s %sC is below the minimum setpoint %gC", sp.name, sp.value, *minSetpoint))
+		}
+		if f > *maxSetpoint {
+			return withCode(CodeTempOutOfRange, fmt.Errorf("%s %sC is above the maximum setpoint %gC", sp.name, sp.value, *maxSetpoint))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSetpointBounds(t *testing.T) {
+	h := newHarness(t, 20)
+	withFlag(t, minSetpoint, 10.0)
+	withFlag(t, maxSetpoint, 28.0)
+
+	for _, tc := range []struct {
+		day, night string
+		want       int
+		bound      string // named in the error
+	}{
+		{"28", "10", http.StatusOK, ""},
+		{"27.99", "10.01", http.StatusOK, ""},
+		{"28.01", "18", http.StatusBadRequest, "maximum setpoint 28C"},
+		{"24", "9.99", http.StatusBadRequest, "minimum setpoint 10C"},
+		{"29", "18", http.StatusBadRequest, "daytemp 29.00C is above"},
+		{"24", "-5", http.StatusBadRequest, "nighttemp -5.00C is below"},
+	} {
+		resp, body := h.Do(http.MethodPut, "/rest/v1/temp", map[string]string{"daytemp": tc.day, "nighttemp": tc.night, "thereshold": "0.2"})
+		if resp.StatusCode != tc.want {
+			t.Errorf("PUT day %s night %s: %s %s, want %d", tc.day, tc.night, resp.Status, body, tc.want)
+			continue
+		}
+		if tc.want == http.StatusOK {
+			continue
+		}
+		var e struct {
+			Code  ErrorCode `json:"code"`
+			Error string    `json:"error"`
+		}
+		json.Unmarshal(body, &e)
+		if e.Code != CodeTempOutOfRange || !strings.Contains(e.Error, tc.bound) {
+			t.Errorf("PUT day %s night %s: %s, want %s naming %q", tc.day, tc.night, body, CodeTempOutOfRange, tc.bound)
+		}
+	}
+}
+
+func TestCheckSetpointFlags(t *testing.T) {
+	withFlag(t, minSetpoint, 25.0)
+	withFlag(t, maxSetpoint, 20.0)
+	if err := checkSetpointFlags(); err == nil {
+		t.Error("-min-setpoint above -max-setpoint: no error")
+	}
+}
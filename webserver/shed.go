@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/go-chi/render"
+)
+
+var maxInFlight = flag.Int("max-inflight", 0, "Requests served at once before new ones get a 503, unlimited if 0")
+
+// shedRetryAfter is the Retry-After sent with a shed request, in seconds.
+const shedRetryAfter = 1
+
+// streamPaths are the routes of the live streams. They stay open for as
+// long as the client is connected, so they'd hold in-flight slots for
+// good; -max-subscribers bounds them instead.
+var streamPaths = map[string]bool{
+	"/rest/v1/events": true,
+	"/rest/v1/ws":     true,
+}
+
+// Shed middleware turns new requests away with a 503 while more than limit
+// requests are in flight. Health checks are always served, so they keep
+// reporting the truth, and live streams aren't counted. A limit of 0
+// disables it.
+func Shed(limit int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+		var inFlight atomic.Int64
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if path := routePath(r); healthPaths[path] || streamPaths[path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			if n > int64(limit) {
+				w.Header().Set("Retry-After", strconv.Itoa(shedRetryAfter))
+				render.Render(w, r, ErrUnavailable(withCode(CodeOverloaded, errors.New("too many requests in flight"))))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
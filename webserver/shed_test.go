@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShed(t *testing.T) {
+	busy, release := make(chan struct{}), make(chan struct{})
+	h := Shed(1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(busy)
+			<-release
+		}
+	}))
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+		close(done)
+	}()
+	<-busy
+
+	for path, want := range map[string]int{
+		"/rest/v1/temp": http.StatusServiceUnavailable,
+		"/livez":        http.StatusOK,
+		"/readyz":       http.StatusOK,
+		"/metrics":      http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Errorf("GET %s with the limit reached: %d, want %d", path, rec.Code, want)
+		}
+		if want != http.StatusOK && rec.Header().Get("Retry-After") != "1" {
+			t.Errorf("GET %s shed with Retry-After %q, want 1", path, rec.Header().Get("Retry-After"))
+		}
+	}
+
+	close(release)
+	<-done
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rest/v1/temp", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /rest/v1/temp once the slow request is done: %d, want 200", rec.Code)
+	}
+}
+
+func TestShedUnlimited(t *testing.T) {
+	busy, release := make(chan struct{}), make(chan struct{})
+	h := Shed(0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(busy)
+			<-release
+		}
+	}))
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+		close(done)
+	}()
+	<-busy
+	defer func() {
+		close(release)
+		<-done
+	}()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rest/v1/temp", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /rest/v1/temp with no limit: %d, want 200", rec.Code)
+	}
+}
+
+func TestShedDoesNotCountStreams(t *testing.T) {
+	streams := make(chan struct{})
+	h := Shed(1)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/v1/temp" {
+			<-streams
+		}
+	}))
+	var wg sync.WaitGroup
+	for _, path := range []string{"/rest/v1/events", "/rest/v1/ws"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}()
+	}
+	defer func() {
+		close(streams)
+		wg.Wait()
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rest/v1/temp", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /rest/v1/temp with two streams open: %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/**-----------------------------------------------------------------------------------
+ * signed requests
+ * ===============
+ * $ ts=$(date +%s); nonce=$(openssl rand -hex 16)
+ * $ sig=$(printf 'PUT\n/rest/v1/mode/force\n%s\n%s\n%s' $ts $nonce $(printf '{"phase":"day"}' | sha256sum | cut -d' ' -f1) \
+ *     | openssl dgst -sha256 -hmac "$SIGNING_KEY" -binary | basenc --base64url | tr -d =)
+ * $ curl -X PUT -H "X-Signature: $sig" -H "X-Signature-Timestamp: $ts" -H "X-Signature-Nonce: $nonce" \
+ *     -H 'Content-Type: application/json' -d '{"phase":"day"}' http://bangkokguy.ddns.net/rest/v1/mode/force
+ *------------------------------------------------------------------------------------*/
+
+var signatureMaxSkew = flag.Duration("signature-max-skew", 5*time.Minute, "How far the timestamp of a signed request may be from the server's clock")
+var signingKey = flag.String("signing-key", "", "Key requests are HMAC-signed with in X-Signature; signed requests may change the settings but not use the admin routes, and are refused while it's empty. Must differ from -auth-secret")
+
+var (
+	errSigningDisabled  = errors.New("signed requests are disabled, there's no -signing-key")
+	errSignatureInvalid = errors.New("invalid signature")
+	errSignatureStale   = errors.New("signature timestamp out of range")
+	errNonceReused      = errors.New("nonce already used")
+)
+
+// checkSigningKey makes sure -signing-key isn't the -auth-secret: a key
+// that signs session tokens mustn't also sign requests.
+func checkSigningKey() error {
+	if *signingKey != "" && *signingKey == *authSecret {
+		return errors.New("-signing-key must differ from -auth-secret")
+	}
+	return nil
+}
+
+type signedCtxKey struct{}
+
+// Nonces remembers the nonces of signed requests for as long as their
+// timestamps are acceptable, so that a request can't be replayed.
+type Nonces struct {
+	mu   sync.Mutex
+	seen map[string]time.Time // nonce -> when it may be forgotten
+}
+
+var nonces = &Nonces{seen: map[string]time.Time{}}
+
+// Use records nonce until expiry, failing if it's already recorded.
+func (n *Nonces) Use(nonce string, expiry time.Time) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.seen[nonce]; ok {
+		return errNonceReused
+	}
+	n.seen[nonce] = expiry
+	return nil
+}
+
+// Sweep forgets the nonces whose requests would be rejected as stale by
+// now anyway.
+func (n *Nonces) Sweep(now time.Time) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for nonce, expiry := range n.seen {
+		if !now.Before(expiry) {
+			delete(n.seen, nonce)
+		}
+	}
+}
+
+// signature returns the HS256 signature of a request: of its method,
+// request URI, timestamp, nonce and the hex SHA-256 of its body, one per
+// line.
+func signature(key []byte, method, uri, timestamp, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, key)
+	io.WriteString(mac, method+"\n"+uri+"\n"+timestamp+"\n"+nonce+"\n"+hex.EncodeToString(sum[:]))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignature checks the X-Signature of r, signed with key, and that
+// its X-Signature-Timestamp is within maxSkew of now and its
+// X-Signature-Nonce is new. The body is read to check it, and put back.
+func verifySignature(r *http.Request, key []byte, maxSkew time.Duration, now time.Time) error {
+	sig := r.Header.Get("X-Signature")
+	timestamp := r.Header.Get("X-Signature-Timestamp")
+	nonce := r.Header.Get("X-Signature-Nonce")
+	if timestamp == "" || nonce == "" {
+		return errSignatureInvalid
+	}
+	secs, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errSignatureInvalid
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(r.Body, *maxDecompressed+1))
+		if err != nil {
+			return err
+		}
+		if int64(len(body)) > *maxDecompressed {
+			return errDecompressedTooLarge
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(key, r.Method, r.URL.RequestURI(), timestamp, nonce, body))) {
+		return errSignatureInvalid
+	}
+
+	// Only a correctly signed request uses up its nonce, and only while its
+	// timestamp is acceptable, since it's rejected as stale after that.
+	at := time.Unix(secs, 0)
+	if at.Before(now.Add(-maxSkew)) || at.After(now.Add(maxSkew)) {
+		return errSignatureStale
+	}
+	return nonces.Use(nonce, at.Add(maxSkew))
+}
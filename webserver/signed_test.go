@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// signedRequest sends method path with body, signed with key at the
+// harness clock's time.
+func (h *harness) signedRequest(key, method, path, body string) (*http.Response, []byte) {
+	h.t.Helper()
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	ts := strconv.FormatInt(h.Clock.Now().Unix(), 10)
+	sig := signature([]byte(key), method, path, ts, hex.EncodeToString(nonce), []byte(body))
+	var payload interface{}
+	if body != "" {
+		payload = RawJSON(body)
+	}
+	return h.Do(method, path, payload,
+		"X-Signature", sig, "X-Signature-Timestamp", ts, "X-Signature-Nonce", hex.EncodeToString(nonce))
+}
+
+// RawJSON marshals to itself.
+type RawJSON string
+
+func (j RawJSON) MarshalJSON() ([]byte, error) { return []byte(j), nil }
+
+func TestSignedRequestsScope(t *testing.T) {
+	h := newHarness(t, 20)
+	withFlag(t, authSecret, "session-secret")
+	sessions = NewSessions([]byte(*authSecret))
+	times := `{"day":"06:30","night":"22:00"}`
+
+	// With no -signing-key, nothing signed is taken.
+	if resp, body := h.signedRequest("", http.MethodPut, "/rest/v1/time", times); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("signed PUT without a -signing-key: %s %s, want 401", resp.Status, body)
+	}
+
+	withFlag(t, signingKey, "request-key")
+	if resp, body := h.Do(http.MethodPost, "/rest/v1/device/claim", map[string]string{"token": "correct-horse-battery-staple"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("claiming: %s %s", resp.Status, body)
+	}
+	if resp, body := h.signedRequest("request-key", http.MethodPut, "/rest/v1/time", times); resp.StatusCode != http.StatusOK {
+		t.Errorf("PUT signed with the -signing-key on a claimed device: %s %s, want 200", resp.Status, body)
+	}
+	if resp, body := h.signedRequest("session-secret", http.MethodPut, "/rest/v1/time", times); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("PUT signed with the -auth-secret: %s %s, want 401", resp.Status, body)
+	}
+	if resp, body := h.signedRequest("request-key", http.MethodGet, "/admin/sessions", ""); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("signed GET /admin/sessions: %s %s, want 403", resp.Status, body)
+	}
+}
+
+func TestCheckSigningKey(t *testing.T) {
+	withFlag(t, authSecret, "same")
+	withFlag(t, signingKey, "same")
+	if err := checkSigningKey(); err == nil {
+		t.Error("checkSigningKey took the -auth-secret as -signing-key")
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// StripSlashes middleware redirects requests for a path with a trailing
+// slash to the path without it, so every route has a single canonical
+// URL. GET and HEAD get a 301; other methods a 308, which keeps the
+// method and body. The root / is left alone.
+func StripSlashes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if len(path) <= 1 || !strings.HasSuffix(path, "/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		path = "/" + strings.Trim(path, "/")
+		if r.URL.RawQuery != "" {
+			path += "?" + r.URL.RawQuery
+		}
+		status := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			status = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, path, status)
+	})
+}
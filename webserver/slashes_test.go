@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStripSlashes(t *testing.T) {
+	handler := StripSlashes(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	for _, tc := range []struct {
+		method, target string
+		status         int
+		location       string
+	}{
+		{http.MethodGet, "/", http.StatusTeapot, ""},
+		{http.MethodGet, "/rest/v1/temp", http.StatusTeapot, ""},
+		{http.MethodGet, "/rest/v1/temp/", http.StatusMovedPermanently, "/rest/v1/temp"},
+		{http.MethodHead, "/rest/v1/temp/", http.StatusMovedPermanently, "/rest/v1/temp"},
+		{http.MethodGet, "/rest/v1/?page=2", http.StatusMovedPermanently, "/rest/v1?page=2"},
+		{http.MethodGet, "/rest/v1/temp///", http.StatusMovedPermanently, "/rest/v1/temp"},
+		{http.MethodPut, "/rest/v1/temp/", http.StatusPermanentRedirect, "/rest/v1/temp"},
+		{http.MethodPost, "/rest/v1/", http.StatusPermanentRedirect, "/rest/v1"},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, nil))
+		if rec.Code != tc.status || rec.Header().Get("Location") != tc.location {
+			t.Errorf("%s %s: %d to %q, want %d to %q", tc.method, tc.target, rec.Code, rec.Header().Get("Location"), tc.status, tc.location)
+		}
+	}
+}
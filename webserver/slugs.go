@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var slugAliasTTL = flag.Duration("slug-alias-ttl", 30*24*time.Hour, "How long the old slug of a retitled article keeps redirecting to the new one")
+
+var errSlugTaken = errors.New("slug is taken by another article")
+
+// slugify makes a slug of title: its letters and digits, lower case, with
+// the runs of anything else in between turned into hyphens. A slug that
+// would be all digits, and so look like an article ID, is prefixed.
+func slugify(title string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, c := range strings.ToLower(title) {
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			hyphen = false
+			continue
+		}
+		hyphen = true
+	}
+	slug := b.String()
+	if strings.Trim(slug, "0123456789") == "" {
+		slug = strings.TrimSuffix("article-"+slug, "-")
+	}
+	return slug
+}
+
+// uniqueSlug returns base, or if another article than id has it, base with
+// the lowest free suffix from -2 up.
+func uniqueSlug(ctx context.Context, base, id string) (string, error) {
+	for n := 1; ; n++ {
+		slug := base
+		if n > 1 {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		a, err := storeOf(ctx).GetArticleBySlug(slug)
+		if errors.Is(err, errArticleNotFound) || err == nil && a.ID == id {
+			return slug, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// checkSlug fails with errSlugTaken if another article than id has slug.
+func checkSlug(ctx context.Context, slug, id string) error {
+	a, err := storeOf(ctx).GetArticleBySlug(slug)
+	if errors.Is(err, errArticleNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if a.ID != id {
+		return errSlugTaken
+	}
+	return nil
+}
+
+// slugAliases maps the old slugs of retitled articles to their IDs, for
+// -slug-alias-ttl. They're kept in memory only.
+var slugAliases struct {
+	mu      sync.Mutex
+	entries map[string]slugAlias
+}
+
+type slugAlias struct {
+	id      string
+	expires time.Time
+}
+
+func addSlugAlias(slug, id string, now time.Time) {
+	slugAliases.mu.Lock()
+	defer slugAliases.mu.Unlock()
+	if slugAliases.entries == nil {
+		slugAliases.entries = map[string]slugAlias{}
+	}
+	for old, alias := range slugAliases.entries {
+		if !now.Before(alias.expires) {
+			delete(slugAliases.entries, old)
+		}
+	}
+	slugAliases.entries[slug] = slugAlias{id: id, expires: now.Add(*slugAliasTTL)}
+}
+
+// slugAliasOf returns the ID of the article slug used to be the slug of.
+func slugAliasOf(slug string, now time.Time) (string, bool) {
+	slugAliases.mu.Lock()
+	defer slugAliases.mu.Unlock()
+	alias, ok := slugAliases.entries[slug]
+	if !ok || !now.Before(alias.expires) {
+		return "", false
+	}
+	return alias.id, true
+}
+
+// redirectSlugAlias redirects a request for an old slug to the article's
+// current one with a 301, reporting false if slug isn't an old slug.
+func redirectSlugAlias(w http.ResponseWriter, r *http.Request, slug string) bool {
+	id, ok := slugAliasOf(slug, clockOf(r.Context()).Now())
+	if !ok {
+		return false
+	}
+	article, err := storeOf(r.Context()).GetArticle(id)
+	if err != nil || article.Slug == slug {
+		return false
+	}
+	path, err := URLFor(RouteArticleBySlug, map[string]string{"articleSlug": article.Slug})
+	if err != nil {
+		return false
+	}
+	u := *r.URL
+	u.Path, u.RawPath = path, ""
+	http.Redirect(w, r, u.RequestURI(), http.StatusMovedPermanently)
+	return true
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	for title, want := range map[string]string{
+		"Hello, World!":      "hello-world",
+		"  Ünïcode -- Café ": "n-code-caf",
+		"2024":               "article-2024",
+		"":                   "article",
+		"Top 10 tips":        "top-10-tips",
+	} {
+		if got := slugify(title); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
+
+func TestRetitleRegeneratesSlug(t *testing.T) {
+	h := newHarness(t, 20)
+	t.Cleanup(func() {
+		slugAliases.mu.Lock()
+		slugAliases.entries = nil
+		slugAliases.mu.Unlock()
+	})
+	create := func(title string) Article {
+		t.Helper()
+		resp, body := h.Do(http.MethodPost, "/rest/v1", map[string]interface{}{"title": title, "user_id": 100})
+		var a Article
+		if err := json.Unmarshal(body, &a); err != nil || resp.StatusCode >= 300 {
+			t.Fatalf("creating %q: %s %s", title, resp.Status, body)
+		}
+		return a
+	}
+	first, second := create("Slug Test"), create("Slug Test")
+	if first.Slug != "slug-test" || second.Slug != "slug-test-2" {
+		t.Fatalf("slugs %q and %q, want slug-test and slug-test-2", first.Slug, second.Slug)
+	}
+
+	resp, body := h.Do(http.MethodPut, "/rest/v1/"+first.ID, map[string]interface{}{"title": "Renamed Slug Test", "version": first.Version})
+	var renamed Article
+	if err := json.Unmarshal(body, &renamed); err != nil || resp.StatusCode != http.StatusOK || renamed.Slug != "renamed-slug-test" {
+		t.Fatalf("retitling: %s %s, want the slug renamed-slug-test", resp.Status, body)
+	}
+
+	// The old slug redirects to the new one.
+	client := *h.Server.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	old, err := client.Get(h.Server.URL + "/rest/v1/slug-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.Body.Close()
+	if old.StatusCode != http.StatusMovedPermanently || old.Header.Get("Location") != "/rest/v1/renamed-slug-test" {
+		t.Errorf("GET the old slug: %s to %q, want a 301 to the new one", old.Status, old.Header.Get("Location"))
+	}
+
+	// Taking another article's slug explicitly is a conflict.
+	resp, body = h.Do(http.MethodPut, "/rest/v1/"+second.ID, map[string]interface{}{"title": "Slug Test", "slug": "renamed-slug-test", "version": second.Version})
+	if resp.StatusCode != http.StatusConflict || !jsonHasCode(body, CodeSlugTaken) {
+		t.Errorf("taking a slug: %s %s, want 409 %s", resp.Status, body, CodeSlugTaken)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+var location = flag.String("location", "", "Latitude and longitude in degrees (e.g. 47.50,19.04), needed to switch day and night at sunrise or sunset")
+
+// coords is the parsed -location, nil if it isn't set.
+var coords *Coords
+
+// Coords is a position on earth, in degrees. North and east are positive.
+type Coords struct {
+	Lat, Lon float64
+}
+
+// parseCoords parses and validates "lat,lon".
+func parseCoords(s string) (*Coords, error) {
+	lat, lon, ok := strings.Cut(s, ",")
+	if !ok {
+		return nil, fmt.Errorf("%q must be latitude,longitude", s)
+	}
+	c := &Coords{}
+	var err1, err2 error
+	c.Lat, err1 = strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	c.Lon, err2 = strconv.ParseFloat(strings.TrimSpace(lon), 64)
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("%q must be latitude,longitude", s)
+	}
+	if c.Lat < -90 || c.Lat > 90 {
+		return nil, fmt.Errorf("latitude %g is outside -90..90", c.Lat)
+	}
+	if c.Lon < -180 || c.Lon > 180 {
+		return nil, fmt.Errorf("longitude %g is outside -180..180", c.Lon)
+	}
+	return c, nil
+}
+
+var errNoSunrise = errors.New("the sun doesn't rise or set on this day here")
+
+// SunTimes computes the sunrise and sunset on the given day with the
+// sunrise equation (accurate to about a minute), in the location of day.
+func SunTimes(c Coords, day time.Time) (sunrise, sunset time.Time, err error) {
+	const rad = math.Pi / 180
+
+	y, m, d := day.Date()
+	noon := time.Date(y, m, d, 12, 0, 0, 0, time.UTC)
+	jd := float64(noon.Unix())/86400 + 2440587.5
+
+	n := math.Round(jd - 2451545.0 + 0.0008)
+	jStar := n - c.Lon/360
+	meanAnomaly := math.Mod(357.5291+0.98560028*jStar, 360)
+	center := 1.9148*math.Sin(meanAnomaly*rad) + 0.0200*math.Sin(2*meanAnomaly*rad) + 0.0003*math.Sin(3*meanAnomaly*rad)
+	lambda := math.Mod(meanAnomaly+center+180+102.9372, 360)
+	transit := 2451545.0 + jStar + 0.0053*math.Sin(meanAnomaly*rad) - 0.0069*math.Sin(2*lambda*rad)
+
+	sinDecl := math.Sin(lambda*rad) * math.Sin(23.4397*rad)
+	cosDecl := math.Cos(math.Asin(sinDecl))
+	cosHour := (math.Sin(-0.833*rad) - math.Sin(c.Lat*rad)*sinDecl) / (math.Cos(c.Lat*rad) * cosDecl)
+	if cosHour < -1 || cosHour > 1 {
+		return time.Time{}, time.Time{}, errNoSunrise
+	}
+	hour := math.Acos(cosHour) / rad
+
+	fromJulian := func(j float64) time.Time {
+		return time.Unix(0, int64((j-2440587.5)*86400*float64(time.Second))).In(day.Location())
+	}
+	return fromJulian(transit - hour/360), fromJulian(transit + hour/360), nil
+}
+
+// parseSolar splits a "sunrise" or "sunset" time with an optional offset,
+// like "sunrise+30m" or "sunset-1h". ok is false for anything else.
+func parseSolar(s string) (event string, offset time.Duration, ok bool, err error) {
+	for _, e := range []string{"sunrise", "sunset"} {
+		if !strings.HasPrefix(s, e) {
+			continue
+		}
+		rest := s[len(e):]
+		if rest == "" {
+			return e, 0, true, nil
+		}
+		if rest[0] != '+' && rest[0] != '-' {
+			break
+		}
+		offset, err := time.ParseDuration(rest)
+		if err != nil {
+			return "", 0, true, fmt.Errorf("bad offset in %q", s)
+		}
+		return e, offset, true, nil
+	}
+	return "", 0, false, nil
+}
+
+// checkDayTime validates a day or night switch-over time: either HH:MM,
+// or sunrise/sunset with an optional offset if -location is set.
+func checkDayTime(s string) error {
+	if clockPattern.MatchString(s) {
+		return nil
+	}
+	_, _, ok, err := parseSolar(s)
+	if !ok {
+		return errors.New("must be a HH:MM time, or sunrise or sunset with an optional offset")
+	}
+	if err != nil {
+		return err
+	}
+	if coords == nil {
+		return errors.New("sunrise and sunset need -location to be set")
+	}
+	return nil
+}
+
+// resolveDayTime turns a switch-over time into the HH:MM it falls on for
+// the day of now.
+func resolveDayTime(s string, now time.Time) (string, error) {
+	event, offset, ok, err := parseSolar(s)
+	if !ok || err != nil {
+		return s, err
+	}
+	if coords == nil {
+		return "", errors.New("sunrise and sunset need -location to be set")
+	}
+	sunrise, sunset, err := SunTimes(*coords, now)
+	if err != nil {
+		return "", err
+	}
+	t := sunrise
+	if event == "sunset" {
+		t = sunset
+	}
+	return t.Add(offset).Format("15:04"), nil
+}
+
+/**-----------------------------------------------------------------------------------
+ * get sunrise
+ * ===========
+ * $ curl http://bangkokguy.ddns.net/rest/v1/time/sunrise
+ *   {"sunrise":"06:12","sunset":"17:58","day":"06:42","night":"22:00"}
+ *------------------------------------------------------------------------------------*/
+
+// SolarTimes are today's sunrise and sunset, and the day and night
+// switch-over times they resolve to.
+type SolarTimes struct {
+	Sunrise string `json:"sunrise"`
+	Sunset  string `json:"sunset"`
+	Day     string `json:"day"`
+	Night   string `json:"night"`
+}
+
+func (s *SolarTimes) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func GetSunrise(w http.ResponseWriter, r *http.Request) {
+	if coords == nil {
+		render.Render(w, r, ErrNotFound)
+		return
+	}
+	now := clockOf(r.Context()).Now()
+	sunrise, sunset, err := SunTimes(*coords, now)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	times, err := storeOf(r.Context()).GetTime()
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	s := &SolarTimes{Sunrise: sunrise.Format("15:04"), Sunset: sunset.Format("15:04")}
+	if s.Day, err = resolveDayTime(times.Day, now); err == nil {
+		s.Night, err = resolveDayTime(times.Night, now)
+	}
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if err := render.Render(w, r, s); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
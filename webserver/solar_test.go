@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSunTimes(t *testing.T) {
+	for _, tc := range []struct {
+		place           string
+		coords          Coords
+		zone            *time.Location
+		date            string
+		sunrise, sunset string // local times, as published
+	}{
+		{"Budapest", Coords{47.4979, 19.0402}, time.FixedZone("CEST", 2*3600), "2024-06-21", "04:46", "20:45"},
+		{"New York", Coords{40.7128, -74.0060}, time.FixedZone("EST", -5*3600), "2024-01-01", "07:20", "16:39"},
+		{"Sydney", Coords{-33.8688, 151.2093}, time.FixedZone("AEDT", 11*3600), "2024-12-21", "05:41", "20:05"},
+	} {
+		day, err := time.ParseInLocation("2006-01-02", tc.date, tc.zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sunrise, sunset, err := SunTimes(tc.coords, day)
+		if err != nil {
+			t.Errorf("%s on %s: %s", tc.place, tc.date, err)
+			continue
+		}
+		for _, c := range []struct {
+			event string
+			got   time.Time
+			want  string
+		}{{"sunrise", sunrise, tc.sunrise}, {"sunset", sunset, tc.sunset}} {
+			want, _ := time.ParseInLocation("2006-01-02 15:04", tc.date+" "+c.want, tc.zone)
+			if d := c.got.Sub(want); d < -time.Minute || d > time.Minute {
+				t.Errorf("%s %s on %s: %s, want %s within a minute", tc.place, c.event, tc.date, c.got.Format("15:04:05"), c.want)
+			}
+		}
+	}
+
+	// North of the polar circle in midwinter the sun doesn't rise.
+	if _, _, err := SunTimes(Coords{69.6496, 18.9560}, time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC)); err != errNoSunrise {
+		t.Errorf("Tromsø in midwinter: %v, want %v", err, errNoSunrise)
+	}
+}
+
+func TestParseCoords(t *testing.T) {
+	if c, err := parseCoords("47.50, 19.04"); err != nil || *c != (Coords{47.50, 19.04}) {
+		t.Errorf("parseCoords(47.50, 19.04) = %v, %v", c, err)
+	}
+	for _, s := range []string{"", "47.50", "north,east", "91,0", "-90.5,0", "0,180.1", "0,-181"} {
+		if c, err := parseCoords(s); err == nil {
+			t.Errorf("parseCoords(%q) = %v, want an error", s, c)
+		}
+	}
+}
+
+func TestGetSunrise(t *testing.T) {
+	h := newHarness(t, 20)
+	if resp, _ := h.Do(http.MethodGet, "/rest/v1/time/sunrise", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /rest/v1/time/sunrise without -location: %s, want 404", resp.Status)
+	}
+
+	// The switch-over times follow the sun over Budapest, with offsets.
+	withFlag(t, &coords, &Coords{47.4979, 19.0402})
+	if resp, body := h.Do(http.MethodPut, "/rest/v1/time", map[string]string{"day": "sunrise+30m", "night": "sunset-1h"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /rest/v1/time: %s %s", resp.Status, body)
+	}
+	var got SolarTimes
+	h.GetJSON("/rest/v1/time/sunrise", &got)
+	sunrise, sunset, err := SunTimes(*coords, h.Clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SolarTimes{
+		Sunrise: sunrise.Format("15:04"),
+		Sunset:  sunset.Format("15:04"),
+		Day:     sunrise.Add(30 * time.Minute).Format("15:04"),
+		Night:   sunset.Add(-time.Hour).Format("15:04"),
+	}
+	if got != want {
+		t.Errorf("GET /rest/v1/time/sunrise: %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS settings (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS articles (
+	id      TEXT PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	title   TEXT NOT NULL,
+	slug    TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS users (
+	id   INTEGER PRIMARY KEY,
+	name TEXT NOT NULL
+);
+`
+
+// sqliteMigrations upgrade the schema above, in order. The number already
+// applied to a database is kept in its user_version pragma.
+var sqliteMigrations = []string{
+	`ALTER TABLE articles ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+	`ALTER TABLE articles ADD COLUMN deleted_at TEXT`,
+}
+
+// sqliteStore persists everything in a SQLite database. The thermostat
+// settings are kept as key/value pairs in the settings table.
+type sqliteStore struct {
+	db      *sql.DB
+	started string
+}
+
+// NewSQLiteStore opens (or creates) the database at path. On first run the
+// schema is created and seeded with the same fixture data the in-memory
+// store starts with.
+func NewSQLiteStore(path string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; sharing one connection avoids
+	// SQLITE_BUSY errors between concurrent requests.
+	db.SetMaxOpenConns(1)
+
+	s := &sqliteStore{db: db, started: time.Now().Format("2006-01-02 15:04:05")}
+	if err := s.init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *sqliteStore) init() error {
+	if _, err := s.db.Exec(sqliteSchema); err != nil {
+		return err
+	}
+	if err := s.migrate(); err != nil {
+		return err
+	}
+
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM settings`).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	seed := NewInMemoryStore()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	settings := map[string]string{
+		"ip":              seed.device.IP,
+		"ssid":            seed.device.SSID,
+		"passphrase":      seed.device.PassPhrase,
+		"daytemp":         string(seed.temp.DayTemp),
+		"nighttemp":       string(seed.temp.NightTemp),
+		"thereshold":      string(seed.temp.Thereshold),
+		"day":             seed.times.Day,
+		"night":           seed.times.Night,
+		"mode":            seed.modes.Mode[0],
+		"mode_setting":    seed.modes.Mode[1],
+		"heating":         seed.modes.Heating[0],
+		"heating_setting": seed.modes.Heating[1],
+	}
+	for key, value := range settings {
+		if _, err := tx.Exec(`INSERT INTO settings (key, value) VALUES (?, ?)`, key, value); err != nil {
+			return err
+		}
+	}
+	for _, a := range seed.articles {
+		if _, err := tx.Exec(`INSERT INTO articles (id, user_id, title, slug, version) VALUES (?, ?, ?, ?, ?)`,
+			a.ID, a.UserID, a.Title, a.Slug, a.Version); err != nil {
+			return err
+		}
+	}
+	for _, u := range seed.users {
+		if _, err := tx.Exec(`INSERT INTO users (id, name) VALUES (?, ?)`, u.ID, u.Name); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) migrate() error {
+	var applied int
+	if err := s.db.QueryRow(`PRAGMA user_version`).Scan(&applied); err != nil {
+		return err
+	}
+	for i := applied; i < len(sqliteMigrations); i++ {
+		if _, err := s.db.Exec(sqliteMigrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := s.db.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, i+1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getSettings reads the given settings, in order.
+func (s *sqliteStore) getSettings(keys ...string) ([]string, error) {
+	values := make([]string, len(keys))
+	for i, key := range keys {
+		err := s.db.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&values[i])
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("setting %q missing", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// setSettings writes the given key/value pairs in one transaction.
+func (s *sqliteStore) setSettings(kv ...string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := 0; i+1 < len(kv); i += 2 {
+		if _, err := tx.Exec(`INSERT INTO settings (key, value) VALUES (?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value`, kv[i], kv[i+1]); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) GetDevice() (*Device, error) {
+	v, err := s.getSettings("ip", "ssid", "passphrase")
+	if err != nil {
+		return nil, err
+	}
+	return &Device{IP: v[0], SSID: v[1], PassPhrase: v[2], CurrentTime: s.started}, nil
+}
+
+func (s *sqliteStore) UpdateDevice(device *Device) (*Device, error) {
+	if err := s.setSettings("ip", device.IP, "ssid", device.SSID, "passphrase", device.PassPhrase); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+func (s *sqliteStore) GetTemp() (*Temp, error) {
+	v, err := s.getSettings("daytemp", "nighttemp", "thereshold")
+	if err != nil {
+		return nil, err
+	}
+	return &Temp{DayTemp: TempValue(v[0]), NightTemp: TempValue(v[1]), Thereshold: TempValue(v[2])}, nil
+}
+
+func (s *sqliteStore) UpdateTemp(temp *Temp) (*Temp, error) {
+	err := s.setSettings(
+		"daytemp", string(temp.DayTemp),
+		"nighttemp", string(temp.NightTemp),
+		"thereshold", string(temp.Thereshold))
+	if err != nil {
+		return nil, err
+	}
+	return temp, nil
+}
+
+func (s *sqliteStore) GetTime() (*Times, error) {
+	v, err := s.getSettings("day", "night")
+	if err != nil {
+		return nil, err
+	}
+	return &Times{Day: v[0], Night: v[1]}, nil
+}
+
+func (s *sqliteStore) UpdateTime(times *Times) (*Times, error) {
+	if err := s.setSettings("day", times.Day, "night", times.Night); err != nil {
+		return nil, err
+	}
+	return times, nil
+}
+
+func (s *sqliteStore) GetMode() (*Modes, error) {
+	v, err := s.getSettings("mode", "mode_setting", "heating", "heating_setting")
+	if err != nil {
+		return nil, err
+	}
+	return &Modes{Mode: [2]string{v[0], v[1]}, Heating: [2]string{v[2], v[3]}}, nil
+}
+
+func (s *sqliteStore) UpdateMode(mode *ModesIn) (*ModesIn, error) {
+	if err := s.setSettings("mode_setting", mode.Mode, "heating_setting", mode.Heating); err != nil {
+		return nil, err
+	}
+	return mode, nil
+}
+
+func (s *sqliteStore) SetCurrentState(mode, heating string) error {
+	return s.setSettings("mode", mode, "heating", heating)
+}
+
+const articleColumns = `id, user_id, title, slug, version, deleted_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanArticle(row scanner) (*Article, error) {
+	a := &Article{}
+	var deletedAt sql.NullString
+	if err := row.Scan(&a.ID, &a.UserID, &a.Title, &a.Slug, &a.Version, &deletedAt); err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		t, err := time.Parse(time.RFC3339Nano, deletedAt.String)
+		if err != nil {
+			return nil, err
+		}
+		a.DeletedAt = &t
+	}
+	return a, nil
+}
+
+func (s *sqliteStore) ListArticles(includeDeleted bool) ([]*Article, error) {
+	query := `SELECT ` + articleColumns + ` FROM articles`
+	if !includeDeleted {
+		query += ` WHERE deleted_at IS NULL`
+	}
+	rows, err := s.db.Query(query + ` ORDER BY rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*Article{}
+	for rows.Next() {
+		a, err := scanArticle(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqliteStore) NewArticle(article *Article) (string, error) {
+	article.ID = fmt.Sprintf("%d", rand.Intn(100)+10)
+	article.Version = 1
+	article.DeletedAt = nil
+	_, err := s.db.Exec(`INSERT INTO articles (id, user_id, title, slug, version) VALUES (?, ?, ?, ?, ?)`,
+		article.ID, article.UserID, article.Title, article.Slug, article.Version)
+	if err != nil {
+		return "", err
+	}
+	return article.ID, nil
+}
+
+func (s *sqliteStore) getArticle(where string, arg interface{}) (*Article, error) {
+	a, err := scanArticle(s.db.QueryRow(`SELECT `+articleColumns+` FROM articles WHERE `+where, arg))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errArticleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (s *sqliteStore) GetArticle(id string) (*Article, error) {
+	return s.getArticle("id = ? AND deleted_at IS NULL", id)
+}
+
+func (s *sqliteStore) GetArticleBySlug(slug string) (*Article, error) {
+	return s.getArticle("slug = ? AND deleted_at IS NULL", slug)
+}
+
+func (s *sqliteStore) UpdateArticle(id string, article *Article) (*Article, error) {
+	res, err := s.db.Exec(`UPDATE articles SET id = ?, user_id = ?, title = ?, slug = ?, version = version + 1
+		WHERE id = ? AND version = ? AND deleted_at IS NULL`,
+		article.ID, article.UserID, article.Title, article.Slug, id, article.Version)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		if _, err := s.GetArticle(id); err != nil {
+			return nil, err
+		}
+		return nil, errVersionConflict
+	}
+	article.Version++
+	return article, nil
+}
+
+func (s *sqliteStore) RemoveArticle(id string) (*Article, error) {
+	a, err := s.GetArticle(id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if _, err := s.db.Exec(`UPDATE articles SET deleted_at = ? WHERE id = ?`, now.Format(time.RFC3339Nano), id); err != nil {
+		return nil, err
+	}
+	a.DeletedAt = &now
+	return a, nil
+}
+
+func (s *sqliteStore) RestoreArticle(id string) (*Article, error) {
+	a, err := s.getArticle("id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(`UPDATE articles SET deleted_at = NULL WHERE id = ?`, id); err != nil {
+		return nil, err
+	}
+	a.DeletedAt = nil
+	return a, nil
+}
+
+func (s *sqliteStore) PurgeArticle(id string) (*Article, error) {
+	a, err := s.getArticle("id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(`DELETE FROM articles WHERE id = ?`, id); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (s *sqliteStore) GetUser(id int64) (*User, error) {
+	u := &User{}
+	err := s.db.QueryRow(`SELECT id, name FROM users WHERE id = ?`, id).Scan(&u.ID, &u.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func openSQLiteStore(t *testing.T, path string) *sqliteStore {
+	t.Helper()
+	s, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLiteStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		return openSQLiteStore(t, filepath.Join(t.TempDir(), "webserver.db"))
+	})
+}
+
+func TestSQLiteStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webserver.db")
+	s := openSQLiteStore(t, path)
+	if _, err := s.UpdateTemp(&Temp{DayTemp: "22.00", NightTemp: "16.50", Thereshold: "0.50"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateTime(&Times{Day: "05:45", Night: "21:15"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateMode(&ModesIn{Mode: "night", Heating: "off"}); err != nil {
+		t.Fatal(err)
+	}
+	id, err := s.NewArticle(&Article{UserID: 200, Title: "kept", Slug: "kept"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// Reopening neither recreates the schema nor seeds the fixtures again.
+	s = openSQLiteStore(t, path)
+	temp, err := s.GetTemp()
+	if err != nil || temp.DayTemp != "22.00" || temp.NightTemp != "16.50" || temp.Thereshold != "0.50" {
+		t.Errorf("GetTemp after reopening: %+v, %v", temp, err)
+	}
+	times, err := s.GetTime()
+	if err != nil || times.Day != "05:45" || times.Night != "21:15" {
+		t.Errorf("GetTime after reopening: %+v, %v", times, err)
+	}
+	modes, err := s.GetMode()
+	if err != nil || modes.Mode[1] != "night" || modes.Heating[1] != "off" {
+		t.Errorf("GetMode after reopening: %+v, %v", modes, err)
+	}
+	if a, err := s.GetArticle(id); err != nil || a.Title != "kept" {
+		t.Errorf("GetArticle after reopening: %+v, %v", a, err)
+	}
+	list, err := s.ListArticles(false)
+	if err != nil || len(list) != 6 {
+		t.Errorf("ListArticles after reopening: %d articles, %v, want the 5 fixtures and the new one", len(list), err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+var sseHeartbeat = flag.Duration("sse-heartbeat", 15*time.Second, "How often an event stream gets a heartbeat comment, to find dead connections")
+var sseMaxLifetime = flag.Duration("sse-max-lifetime", 30*time.Minute, "How long an event stream stays open before it's closed for the client to reconnect")
+
+const sseWriteTimeout = 10 * time.Second
+
+/**-----------------------------------------------------------------------------------
+ * event stream
+ * ============
+ * $ curl -N http://bangkokguy.ddns.net/rest/v1/events
+ *   event: telemetry
+ *   data: {"type":"telemetry","source":"evaluator","data":{"at":"...","currenttemp":21.3,"mode":"day","heating":"on"}}
+ *
+ *   : heartbeat
+ *------------------------------------------------------------------------------------*/
+
+// ServeEvents streams the broker's events as server-sent events, for
+// clients that only need to listen. A write that doesn't go through within
+// a few seconds, heartbeats included, ends the stream, and so does
+// reaching -sse-max-lifetime. Telemetry comes with ?precision= decimals.
+func ServeEvents(w http.ResponseWriter, r *http.Request) {
+	precision, err := requestPrecision(r)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if !requireFlush(w, r) {
+		return
+	}
+	rc := http.NewResponseController(w)
+	write := func(format string, args ...interface{}) error {
+		if err := rc.SetWriteDeadline(time.Now().Add(sseWriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	events, unsubscribe, err := broker.Subscribe()
+	if err != nil {
+		render.Render(w, r, ErrUnavailable(err))
+		return
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // don't let proxies buffer the stream
+	w.WriteHeader(http.StatusOK)
+
+	client := middleware.GetReqID(r.Context())
+	heartbeat := time.NewTicker(*sseHeartbeat)
+	defer heartbeat.Stop()
+	lifetime := time.NewTimer(*sseMaxLifetime)
+	defer lifetime.Stop()
+
+	err = write("retry: 3000\n\n")
+	for err == nil {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-lifetime.C:
+			return
+		case <-heartbeat.C:
+			err = write(": heartbeat\n\n")
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			data, jerr := json.Marshal(e.withPrecision(precision))
+			if jerr != nil {
+				log.Printf("Event stream %s: %s", client, jerr)
+				continue
+			}
+			err = write("event: %s\ndata: %s\n\n", e.Type, data)
+		}
+	}
+	log.Printf("Event stream %s: %s", client, err)
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+var stateFile = flag.String("state-file", "", "File the thermostat settings are persisted to, not persisted if empty")
+
+// State is the persisted part of the thermostat settings.
+type State struct {
+	Day        string `json:"day"`
+	Night      string `json:"night"`
+	DayTemp    string `json:"daytemp"`
+	NightTemp  string `json:"nighttemp"`
+	Thereshold string `json:"thereshold"`
+	Mode       string `json:"mode"`
+	Heating    string `json:"heating"`
+	Forced     string `json:"forced,omitempty"`
+
+	AlertLow        string `json:"alert_low,omitempty"`
+	AlertHigh       string `json:"alert_high,omitempty"`
+	AlertHysteresis string `json:"alert_hysteresis,omitempty"`
+
+	PID *PIDConfig `json:"pid,omitempty"`
+
+	Owner     string     `json:"owner,omitempty"` // SHA-256 of the owner token
+	ClaimedAt *time.Time `json:"claimed_at,omitempty"`
+
+	DisabledFeatures []string `json:"disabled_features,omitempty"`
+}
+
+func snapshotState(ctx context.Context) (State, error) {
+	temp, err := storeOf(ctx).GetTemp()
+	if err != nil {
+		return State{}, err
+	}
+	times, err := storeOf(ctx).GetTime()
+	if err != nil {
+		return State{}, err
+	}
+	modes, err := storeOf(ctx).GetMode()
+	if err != nil {
+		return State{}, err
+	}
+	ac := alertConfig()
+	claim.RLock()
+	owner, claimedAt := claim.ownerHash, &claim.at
+	claim.RUnlock()
+	if owner == "" {
+		claimedAt = nil
+	}
+	var pc *PIDConfig
+	if c := pidConfig(); c != (PIDConfig{}) {
+		pc = &c
+	}
+
+	return State{
+		Day:        times.Day,
+		Night:      times.Night,
+		DayTemp:    string(temp.DayTemp),
+		NightTemp:  string(temp.NightTemp),
+		Thereshold: string(temp.Thereshold),
+		Mode:       modes.Mode[1],
+		Heating:    modes.Heating[1],
+		Forced:     forcedPhase(),
+
+		AlertLow:        string(ac.Low),
+		AlertHigh:       string(ac.High),
+		AlertHysteresis: string(ac.Hysteresis),
+
+		PID: pc,
+
+		Owner:     owner,
+		ClaimedAt: claimedAt,
+
+		DisabledFeatures: disabledFeatureNames(),
+	}, nil
+}
+
+func applyState(ctx context.Context, s State) error {
+	temp := &Temp{DayTemp: TempValue(s.DayTemp), NightTemp: TempValue(s.NightTemp), Thereshold: TempValue(s.Thereshold)}
+	if _, err := storeOf(ctx).UpdateTemp(temp); err != nil {
+		return err
+	}
+	if _, err := storeOf(ctx).UpdateTime(&Times{Day: s.Day, Night: s.Night}); err != nil {
+		return err
+	}
+	if _, err := storeOf(ctx).UpdateMode(&ModesIn{Mode: s.Mode, Heating: s.Heating}); err != nil {
+		return err
+	}
+	setForcedPhase(s.Forced)
+	return nil
+}
+
+// persistState saves the current settings to -state-file, if set. As
+// it's called after every change, it notes the settings changed at
+// runtime, too.
+func persistState(ctx context.Context) {
+	s, err := snapshotState(ctx)
+	if err == nil {
+		noteSettings(s, sourceRuntime)
+	}
+	if *stateFile == "" {
+		return
+	}
+	if err == nil {
+		err = saveState(*stateFile, s)
+	}
+	if err != nil {
+		log.Printf("Saving state failed: %s", err)
+	}
+}
+
+// restoreState loads the settings from -state-file, if set. A missing file
+// just means there's nothing to restore yet.
+func restoreState() error {
+	if *stateFile == "" {
+		return nil
+	}
+	s, err := loadState(*stateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := applyState(context.Background(), s); err != nil {
+		return err
+	}
+	// Alerts, PID control, the claim and the features are only set on their
+	// own, so they're restored here rather than in applyState, which config
+	// changes go through as well.
+	setAlertConfig(AlertConfig{
+		Low:        TempValue(s.AlertLow),
+		High:       TempValue(s.AlertHigh),
+		Hysteresis: TempValue(s.AlertHysteresis),
+	})
+	if s.PID != nil {
+		setPIDConfig(*s.PID)
+	}
+	if s.Owner != "" && s.ClaimedAt != nil {
+		setClaim(s.Owner, *s.ClaimedAt)
+	}
+	setDisabledFeatures(s.DisabledFeatures)
+	return nil
+}
+
+// loadState reads the state from path, falling back to the backup of the
+// previous good state if the primary file is missing or doesn't parse.
+func loadState(path string) (State, error) {
+	s, err := readState(path)
+	if err == nil {
+		return s, nil
+	}
+	s, bakErr := readState(path + ".bak")
+	if bakErr != nil {
+		return State{}, err
+	}
+	log.Printf("State file %s unusable (%s), recovered from backup", path, err)
+	return s, nil
+}
+
+func readState(path string) (State, error) {
+	var s State
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// saveState atomically replaces the state file: the new state is written
+// and fsynced to a temporary file which is then renamed over the primary.
+// A primary that still parses is kept as a backup first, and the directory
+// is fsynced so the renames survive a crash as well.
+func saveState(path string, s State) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := os.CreateTemp(dir, base+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if _, err := readState(path); err == nil {
+		if err := os.Rename(path, path+".bak"); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir flushes directory entries to disk. Windows can't fsync
+// directories, and doesn't need to.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveStateKeepsBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "thermostat.json")
+	first := State{Day: "06:00", Night: "22:00", DayTemp: "23.00", NightTemp: "18.00", Thereshold: "0.20", Mode: "auto", Heating: "auto"}
+	second := first
+	second.DayTemp = "24.00"
+	for _, s := range []State{first, second} {
+		if err := saveState(path, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if s, err := readState(path); err != nil || s.DayTemp != "24.00" {
+		t.Errorf("primary: %+v, %v, want the second state", s, err)
+	}
+	if s, err := readState(path + ".bak"); err != nil || s.DayTemp != "23.00" {
+		t.Errorf("backup: %+v, %v, want the first state", s, err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 2 {
+		t.Errorf("%d files next to the state file, want it and its backup only", len(entries))
+	}
+
+	// A corrupt primary isn't kept as the backup.
+	os.WriteFile(path, []byte(`{"day":`), 0o600)
+	if err := saveState(path, second); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := readState(path + ".bak"); err != nil || s.DayTemp != "23.00" {
+		t.Errorf("backup after saving over a corrupt primary: %+v, %v, want the first state kept", s, err)
+	}
+}
+
+func TestRestoreStateFromBackup(t *testing.T) {
+	resetState(t)
+	withFlag(t, &store, Store(NewInMemoryStore()))
+	path := filepath.Join(t.TempDir(), "thermostat.json")
+	withFlag(t, stateFile, path)
+	good := State{Day: "05:30", Night: "21:30", DayTemp: "22.50", NightTemp: "17.00", Thereshold: "0.30", Mode: "auto", Heating: "auto"}
+	if err := saveState(path, good); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveState(path, good); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path, []byte("not json"), 0o600)
+
+	if err := restoreState(); err != nil {
+		t.Fatalf("restoring with a corrupt primary: %v", err)
+	}
+	times, err := store.GetTime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	temp, err := store.GetTemp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if times.Day != "05:30" || times.Night != "21:30" || temp.DayTemp != "22.50" || temp.NightTemp != "17.00" {
+		t.Errorf("restored %+v and %+v, want the backup's settings", times, temp)
+	}
+
+	// With the backup corrupt as well, startup fails.
+	os.WriteFile(path+".bak", []byte("not json either"), 0o600)
+	if err := restoreState(); err == nil {
+		t.Error("restoring with both files corrupt: no error")
+	}
+}
+
+func TestRestoreStateMissing(t *testing.T) {
+	resetState(t)
+	withFlag(t, stateFile, filepath.Join(t.TempDir(), "thermostat.json"))
+	if err := restoreState(); err != nil {
+		t.Errorf("restoring without a state file: %v, want nothing restored", err)
+	}
+}
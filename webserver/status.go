@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+/**-----------------------------------------------------------------------------------
+ * get status
+ * ==========
+ * $ curl http://bangkokguy.ddns.net/rest/v1/status
+ *   {"temp":19.2,"target":18,"mode":"night","mode_setting":"auto","heating":"on",...}
+ * $ curl http://bangkokguy.ddns.net/rest/v1/status.txt
+ *   temp=19.2
+ *   target=18
+ *   mode=night
+ *   ...
+ *------------------------------------------------------------------------------------*/
+
+// Status is a snapshot of the thermostat: the control temperature, in
+// Celsius, the target of the current phase, and the mode and heating
+// states along with their settings.
+type Status struct {
+	Temp            float64   `json:"temp"`
+	ReadingAt       time.Time `json:"reading_at"`
+	Target          float64   `json:"target"`
+	Mode            string    `json:"mode"`
+	ModeSetting     string    `json:"mode_setting"`
+	Heating         string    `json:"heating"`
+	HeatingSetting  string    `json:"heating_setting"`
+	Forced          string    `json:"forced"`
+	SafetyCutoff    bool      `json:"safety_cutoff"`
+	FrostProtection bool      `json:"frost_protection"`
+}
+
+func (s *Status) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// loadStatus takes a snapshot of the thermostat.
+func loadStatus(ctx context.Context) (*Status, error) {
+	stop := startPhase(ctx, "sensor")
+	reading, err := latestReading(ctx)
+	stop()
+	if err != nil {
+		return nil, err
+	}
+	modes, err := storeOf(ctx).GetMode()
+	if err != nil {
+		return nil, err
+	}
+	temp, err := storeOf(ctx).GetTemp()
+	if err != nil {
+		return nil, err
+	}
+	now := clockOf(ctx).Now()
+	forced := forcedPhase()
+	target, err := strconv.ParseFloat(string(phaseTarget(temp, modes.Mode[0], forced, now)), 64)
+	if err != nil {
+		return nil, err
+	}
+	return &Status{
+		Temp:            reading.Temp,
+		ReadingAt:       reading.At,
+		Target:          target,
+		Mode:            modes.Mode[0],
+		ModeSetting:     modes.Mode[1],
+		Heating:         modes.Heating[0],
+		HeatingSetting:  modes.Heating[1],
+		Forced:          forced,
+		SafetyCutoff:    safetyActive(),
+		FrostProtection: frostActive(),
+	}, nil
+}
+
+// text renders the status as key=value lines, the keys being the JSON
+// keys, named by -json-keys, for clients that can't parse JSON.
+func (s *Status) text() string {
+	var b strings.Builder
+	v := reflect.ValueOf(s).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if *jsonKeys != "default" {
+			key = styleKey(keyWords(field.Name, key), *jsonKeys)
+		}
+		var value string
+		switch f := v.Field(i).Interface().(type) {
+		case float64:
+			value = strconv.FormatFloat(f, 'f', -1, 64)
+		case time.Time:
+			value = f.Format(time.RFC3339Nano) // as in the JSON
+		default:
+			value = fmt.Sprint(f)
+		}
+		fmt.Fprintf(&b, "%s=%s\n", key, value)
+	}
+	return b.String()
+}
+
+// GetStatus serves the status, as JSON, or as plain text with the .txt
+// extension.
+func GetStatus(w http.ResponseWriter, r *http.Request) {
+	precision, err := requestPrecision(r)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if err := refreshReading(w, r); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	status, err := loadStatus(r.Context())
+	if errors.Is(err, errNoReading) || errors.Is(err, errStaleReading) {
+		render.Render(w, r, ErrUnavailable(err))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+	status.Temp = roundFloat(status.Temp, precision)
+	if format, _ := r.Context().Value(middleware.URLFormatCtxKey).(string); format == "txt" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(status.text()))
+		return
+	}
+	if err := render.Render(w, r, status); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
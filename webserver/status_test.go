@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStatusTextKeys(t *testing.T) {
+	for _, tc := range []struct {
+		style string
+		keys  []string
+	}{
+		{"default", []string{"temp=", "reading_at=", "mode_setting=", "safety_cutoff="}},
+		{"snake", []string{"temp=", "reading_at=", "mode_setting=", "safety_cutoff="}},
+		{"camel", []string{"temp=", "readingAt=", "modeSetting=", "safetyCutoff="}},
+	} {
+		h := newHarness(t, 20)
+		withFlag(t, jsonKeys, tc.style)
+		resp, body := h.Do(http.MethodGet, "/rest/v1/status.txt", nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET /rest/v1/status.txt: %s %s", resp.Status, body)
+		}
+		for _, key := range tc.keys {
+			if !strings.Contains("\n"+string(body), "\n"+key) {
+				t.Errorf("-json-keys=%s: no %s line in\n%s", tc.style, key, body)
+			}
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Store is the persistence layer the handlers work against. The thermostat
+// settings are kept in Celsius; the current temperature reading isn't part
+// of the store.
+type Store interface {
+	GetDevice() (*Device, error)
+	// UpdateDevice stores the device's IP, SSID and passphrase.
+	UpdateDevice(device *Device) (*Device, error)
+
+	GetTemp() (*Temp, error)
+	UpdateTemp(temp *Temp) (*Temp, error)
+	GetTime() (*Times, error)
+	UpdateTime(times *Times) (*Times, error)
+	GetMode() (*Modes, error)
+	UpdateMode(mode *ModesIn) (*ModesIn, error)
+	// SetCurrentState records the phase and heating state the evaluator
+	// decided on.
+	SetCurrentState(mode, heating string) error
+
+	// ListArticles lists the articles, leaving out soft-deleted ones unless
+	// includeDeleted is set.
+	ListArticles(includeDeleted bool) ([]*Article, error)
+	NewArticle(article *Article) (string, error)
+	GetArticle(id string) (*Article, error)
+	GetArticleBySlug(slug string) (*Article, error)
+	// UpdateArticle replaces the article if article.Version matches the
+	// stored version, and bumps the version. Otherwise it fails with
+	// errVersionConflict.
+	UpdateArticle(id string, article *Article) (*Article, error)
+	// RemoveArticle soft-deletes the article: it's hidden, but can be
+	// restored with RestoreArticle until PurgeArticle drops it for good.
+	RemoveArticle(id string) (*Article, error)
+	RestoreArticle(id string) (*Article, error)
+	PurgeArticle(id string) (*Article, error)
+
+	GetUser(id int64) (*User, error)
+}
+
+var store Store = NewInMemoryStore()
+
+var errArticleNotFound = errors.New("article not found")
+var errUserNotFound = errors.New("user not found")
+var errVersionConflict = errors.New("article was modified concurrently, reload and retry")
+var errVersionRequired = errors.New("article version missing, send the version the update is based on")
+
+// inMemoryStore keeps everything in memory, seeded with fixture data. It
+// hands out copies, so callers can't modify its contents behind its back.
+type inMemoryStore struct {
+	mu sync.Mutex
+
+	device Device
+	temp   Temp
+	times  Times
+	modes  Modes
+
+	articles []*Article
+	users    []*User
+}
+
+func NewInMemoryStore() *inMemoryStore {
+	return &inMemoryStore{
+		device: Device{
+			IP:          "192.168.1.123",
+			SSID:        "MrWhite",
+			PassPhrase:  "F",
+			CurrentTime: time.Now().Format("2006-01-02 15:04:05"),
+		},
+		temp: Temp{
+			NightTemp:  "18.00",
+			DayTemp:    "24.00",
+			Thereshold: "0.20",
+		},
+		times: Times{
+			Day:   "06:00",
+			Night: "22:00",
+		},
+		modes: Modes{
+			Mode:    [2]string{"night", "auto"},
+			Heating: [2]string{"off", "auto"},
+		},
+
+		// Article fixture data
+		articles: []*Article{
+			{ID: "1", UserID: 100, Title: "Hi", Slug: "hi", Version: 1},
+			{ID: "2", UserID: 200, Title: "sup", Slug: "sup", Version: 1},
+			{ID: "3", UserID: 300, Title: "alo", Slug: "alo", Version: 1},
+			{ID: "4", UserID: 400, Title: "bonjour", Slug: "bonjour", Version: 1},
+			{ID: "5", UserID: 500, Title: "whats up", Slug: "whats-up", Version: 1},
+		},
+
+		// User fixture data
+		users: []*User{
+			{ID: 100, Name: "Peter"},
+			{ID: 200, Name: "Julia"},
+		},
+	}
+}
+
+func (s *inMemoryStore) GetDevice() (*Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	device := s.device
+	return &device, nil
+}
+
+func (s *inMemoryStore) UpdateDevice(device *Device) (*Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.device.IP = device.IP
+	s.device.SSID = device.SSID
+	s.device.PassPhrase = device.PassPhrase
+	return device, nil
+}
+
+func (s *inMemoryStore) GetTemp() (*Temp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	temp := s.temp
+	return &temp, nil
+}
+
+func (s *inMemoryStore) UpdateTemp(temp *Temp) (*Temp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.temp.DayTemp = temp.DayTemp
+	s.temp.NightTemp = temp.NightTemp
+	s.temp.Thereshold = temp.Thereshold
+	return temp, nil
+}
+
+func (s *inMemoryStore) GetTime() (*Times, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	times := s.times
+	return &times, nil
+}
+
+func (s *inMemoryStore) UpdateTime(times *Times) (*Times, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.times.Day = times.Day
+	s.times.Night = times.Night
+	return times, nil
+}
+
+func (s *inMemoryStore) GetMode() (*Modes, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	modes := s.modes
+	return &modes, nil
+}
+
+func (s *inMemoryStore) UpdateMode(mode *ModesIn) (*ModesIn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.modes.Mode[1] = mode.Mode
+	s.modes.Heating[1] = mode.Heating
+	return mode, nil
+}
+
+func (s *inMemoryStore) SetCurrentState(mode, heating string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.modes.Mode[0] = mode
+	s.modes.Heating[0] = heating
+	return nil
+}
+
+func (s *inMemoryStore) ListArticles(includeDeleted bool) ([]*Article, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*Article, 0, len(s.articles))
+	for _, a := range s.articles {
+		if a.DeletedAt != nil && !includeDeleted {
+			continue
+		}
+		article := *a
+		list = append(list, &article)
+	}
+	return list, nil
+}
+
+func (s *inMemoryStore) NewArticle(article *Article) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	article.ID = fmt.Sprintf("%d", rand.Intn(100)+10)
+	article.Version = 1
+	article.DeletedAt = nil
+	stored := *article
+	s.articles = append(s.articles, &stored)
+	return article.ID, nil
+}
+
+// find returns the index of the article with the given id, or -1. Soft-
+// deleted articles are only found with includeDeleted.
+func (s *inMemoryStore) find(id string, includeDeleted bool) int {
+	for i, a := range s.articles {
+		if a.ID == id && (a.DeletedAt == nil || includeDeleted) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *inMemoryStore) GetArticle(id string) (*Article, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.find(id, false)
+	if i < 0 {
+		return nil, errArticleNotFound
+	}
+	article := *s.articles[i]
+	return &article, nil
+}
+
+func (s *inMemoryStore) GetArticleBySlug(slug string) (*Article, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range s.articles {
+		if a.Slug == slug && a.DeletedAt == nil {
+			article := *a
+			return &article, nil
+		}
+	}
+	return nil, errArticleNotFound
+}
+
+func (s *inMemoryStore) UpdateArticle(id string, article *Article) (*Article, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.find(id, false)
+	if i < 0 {
+		return nil, errArticleNotFound
+	}
+	if article.Version != s.articles[i].Version {
+		return nil, errVersionConflict
+	}
+	article.Version++
+	stored := *article
+	s.articles[i] = &stored
+	return article, nil
+}
+
+func (s *inMemoryStore) RemoveArticle(id string) (*Article, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.find(id, false)
+	if i < 0 {
+		return nil, errArticleNotFound
+	}
+	now := time.Now().UTC()
+	s.articles[i].DeletedAt = &now
+	article := *s.articles[i]
+	return &article, nil
+}
+
+func (s *inMemoryStore) RestoreArticle(id string) (*Article, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.find(id, true)
+	if i < 0 {
+		return nil, errArticleNotFound
+	}
+	s.articles[i].DeletedAt = nil
+	article := *s.articles[i]
+	return &article, nil
+}
+
+func (s *inMemoryStore) PurgeArticle(id string) (*Article, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.find(id, true)
+	if i < 0 {
+		return nil, errArticleNotFound
+	}
+	a := s.articles[i]
+	s.articles = append(s.articles[:i], s.articles[i+1:]...)
+	return a, nil
+}
+
+func (s *inMemoryStore) GetUser(id int64) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.ID == id {
+			user := *u
+			return &user, nil
+		}
+	}
+	return nil, errUserNotFound
+}
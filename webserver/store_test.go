@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+// testStore exercises every resource of a Store through the interface.
+// newStore returns an empty store seeded with the fixture data.
+func testStore(t *testing.T, newStore func(t *testing.T) Store) {
+	t.Run("device", func(t *testing.T) {
+		s := newStore(t)
+		if _, err := s.UpdateDevice(&Device{IP: "10.0.0.2", SSID: "Home", PassPhrase: "secret"}); err != nil {
+			t.Fatal(err)
+		}
+		d, err := s.GetDevice()
+		if err != nil || d.IP != "10.0.0.2" || d.SSID != "Home" || d.PassPhrase != "secret" {
+			t.Errorf("GetDevice after an update: %+v, %v", d, err)
+		}
+	})
+
+	t.Run("temp", func(t *testing.T) {
+		s := newStore(t)
+		if _, err := s.UpdateTemp(&Temp{DayTemp: "23.50", NightTemp: "17.00", Thereshold: "0.30"}); err != nil {
+			t.Fatal(err)
+		}
+		temp, err := s.GetTemp()
+		if err != nil || temp.DayTemp != "23.50" || temp.NightTemp != "17.00" || temp.Thereshold != "0.30" {
+			t.Errorf("GetTemp after an update: %+v, %v", temp, err)
+		}
+	})
+
+	t.Run("time", func(t *testing.T) {
+		s := newStore(t)
+		if _, err := s.UpdateTime(&Times{Day: "07:15", Night: "23:00"}); err != nil {
+			t.Fatal(err)
+		}
+		times, err := s.GetTime()
+		if err != nil || times.Day != "07:15" || times.Night != "23:00" {
+			t.Errorf("GetTime after an update: %+v, %v", times, err)
+		}
+	})
+
+	t.Run("mode", func(t *testing.T) {
+		s := newStore(t)
+		if _, err := s.UpdateMode(&ModesIn{Mode: "day", Heating: "on"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetCurrentState("night", "off"); err != nil {
+			t.Fatal(err)
+		}
+		m, err := s.GetMode()
+		if err != nil || m.Mode != [2]string{"night", "day"} || m.Heating != [2]string{"off", "on"} {
+			t.Errorf("GetMode after an update: %+v, %v, want the current state first and the settings second", m, err)
+		}
+	})
+
+	t.Run("articles", func(t *testing.T) {
+		s := newStore(t)
+		list, err := s.ListArticles(false)
+		if err != nil || len(list) != 5 {
+			t.Fatalf("ListArticles of the fixtures: %d articles, %v, want 5", len(list), err)
+		}
+
+		id, err := s.NewArticle(&Article{UserID: 100, Title: "new", Slug: "new"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := s.GetArticle(id)
+		if err != nil || a.Title != "new" || a.Version != 1 {
+			t.Fatalf("GetArticle of a new article: %+v, %v", a, err)
+		}
+		if bySlug, err := s.GetArticleBySlug("new"); err != nil || bySlug.ID != id {
+			t.Errorf("GetArticleBySlug: %+v, %v, want %s", bySlug, err, id)
+		}
+
+		// Changing the returned copy doesn't change the stored article.
+		a.Title = "changed behind the store's back"
+		if stored, _ := s.GetArticle(id); stored.Title != "new" {
+			t.Errorf("stored title %q changed through a returned copy", stored.Title)
+		}
+
+		a.Title = "renamed"
+		updated, err := s.UpdateArticle(id, a)
+		if err != nil || updated.Version != 2 {
+			t.Fatalf("UpdateArticle: %+v, %v, want version 2", updated, err)
+		}
+		stale := *a
+		stale.Version = 1
+		if _, err := s.UpdateArticle(id, &stale); !errors.Is(err, errVersionConflict) {
+			t.Errorf("UpdateArticle with a stale version: %v, want errVersionConflict", err)
+		}
+		if a, _ := s.GetArticle(id); a.Title != "renamed" || a.Version != 2 {
+			t.Errorf("after the updates: %+v, want renamed at version 2", a)
+		}
+
+		if _, err := s.PurgeArticle(id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.GetArticle(id); !errors.Is(err, errArticleNotFound) {
+			t.Errorf("GetArticle after purging: %v, want errArticleNotFound", err)
+		}
+		if _, err := s.UpdateArticle(id, a); !errors.Is(err, errArticleNotFound) {
+			t.Errorf("UpdateArticle after purging: %v, want errArticleNotFound", err)
+		}
+	})
+
+	t.Run("soft delete", func(t *testing.T) {
+		s := newStore(t)
+		removed, err := s.RemoveArticle("1")
+		if err != nil || removed.DeletedAt == nil {
+			t.Fatalf("RemoveArticle: %+v, %v, want it with DeletedAt", removed, err)
+		}
+		if _, err := s.GetArticle("1"); !errors.Is(err, errArticleNotFound) {
+			t.Errorf("GetArticle of a removed article: %v, want errArticleNotFound", err)
+		}
+		if _, err := s.GetArticleBySlug("hi"); !errors.Is(err, errArticleNotFound) {
+			t.Errorf("GetArticleBySlug of a removed article: %v, want errArticleNotFound", err)
+		}
+		if list, _ := s.ListArticles(false); len(list) != 4 {
+			t.Errorf("ListArticles: %d articles, want the 4 not removed", len(list))
+		}
+		if list, _ := s.ListArticles(true); len(list) != 5 {
+			t.Errorf("ListArticles including deleted: %d articles, want 5", len(list))
+		}
+
+		restored, err := s.RestoreArticle("1")
+		if err != nil || restored.DeletedAt != nil {
+			t.Fatalf("RestoreArticle: %+v, %v", restored, err)
+		}
+		if a, err := s.GetArticle("1"); err != nil || a.Title != "Hi" {
+			t.Errorf("GetArticle of a restored article: %+v, %v", a, err)
+		}
+
+		s.RemoveArticle("1")
+		if _, err := s.PurgeArticle("1"); err != nil {
+			t.Fatalf("PurgeArticle of a removed article: %v", err)
+		}
+		if _, err := s.RestoreArticle("1"); !errors.Is(err, errArticleNotFound) {
+			t.Errorf("RestoreArticle of a purged article: %v, want errArticleNotFound", err)
+		}
+	})
+
+	t.Run("users", func(t *testing.T) {
+		s := newStore(t)
+		if u, err := s.GetUser(100); err != nil || u.Name != "Peter" {
+			t.Errorf("GetUser(100): %+v, %v, want Peter", u, err)
+		}
+		if _, err := s.GetUser(999); !errors.Is(err, errUserNotFound) {
+			t.Errorf("GetUser(999): %v, want errUserNotFound", err)
+		}
+	})
+}
+
+func TestInMemoryStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store { return NewInMemoryStore() })
+}
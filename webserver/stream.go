@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+/**-----------------------------------------------------------------------------------
+ * stream articles
+ * ===============
+ * $ curl http://bangkokguy.ddns.net/rest/v1/articles.ndjson
+ *   {"id":"1","user_id":100,"title":"Hi","slug":"hi","version":1,...}
+ *   {"id":"2","user_id":200,"title":"sup","slug":"sup","version":1,...}
+ *------------------------------------------------------------------------------------*/
+
+// ndjsonFlushEvery is how many lines are written between flushes.
+const ndjsonFlushEvery = 50
+
+// StreamArticles writes the articles as newline delimited JSON, one article
+// per line, so clients can process them as they arrive. It stops as soon as
+// the client goes away. Without the .ndjson extension it serves the regular
+// JSON list.
+func StreamArticles(w http.ResponseWriter, r *http.Request) {
+	if format, _ := r.Context().Value(middleware.URLFormatCtxKey).(string); format != "ndjson" {
+		ListArticles(w, r)
+		return
+	}
+
+	articles, err := storeOf(r.Context()).ListArticles(false)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if !requireFlush(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	for i, article := range articles {
+		if r.Context().Err() != nil {
+			return
+		}
+		// Render the nested user as well, the way render.RenderList would.
+		resp := NewArticleResponse(article)
+		if err := resp.Render(w, r); err != nil {
+			return
+		}
+		if resp.User != nil {
+			if err := resp.User.Render(w, r); err != nil {
+				return
+			}
+		}
+		// Encode terminates every value with a newline.
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+		if (i+1)%ndjsonFlushEvery == 0 {
+			if err := rc.Flush(); err != nil {
+				LoggerFrom(r.Context()).Warn("streaming articles failed", "error", err)
+				return
+			}
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		LoggerFrom(r.Context()).Warn("streaming articles failed", "error", err)
+	}
+}
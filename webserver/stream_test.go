@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStreamArticles(t *testing.T) {
+	h := newHarness(t, 20)
+	// Enough articles for a few flushes.
+	extra := 2*ndjsonFlushEvery + 7
+	for i := 0; i < extra; i++ {
+		if _, err := h.Store.NewArticle(&Article{UserID: 100, Title: fmt.Sprintf("article %d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp, body := h.Do(http.MethodGet, "/rest/v1/articles.ndjson", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("GET /rest/v1/articles.ndjson: %s with Content-Type %q", resp.Status, resp.Header.Get("Content-Type"))
+	}
+	n := 0
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		var a Article
+		if err := json.Unmarshal(scanner.Bytes(), &a); err != nil || a.ID == "" || a.Title == "" {
+			t.Fatalf("line %d: %s doesn't unmarshal to an article: %v", n+1, scanner.Bytes(), err)
+		}
+		n++
+	}
+	if want := 5 + extra; n != want {
+		t.Errorf("streamed %d articles, want %d", n, want)
+	}
+
+	// Without the extension it's the regular list.
+	var page ArticlePageResponse
+	h.GetJSON("/rest/v1/articles", &page)
+	if len(page.Items) == 0 {
+		t.Error("GET /rest/v1/articles listed no articles")
+	}
+}
+
+func TestStreamArticlesCanceled(t *testing.T) {
+	h := newHarness(t, 20)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec := httptest.NewRecorder()
+	h.Server.Config.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rest/v1/articles.ndjson", nil).WithContext(ctx))
+	if rec.Body.Len() != 0 {
+		t.Errorf("streamed %q to a client that's gone", rec.Body)
+	}
+}
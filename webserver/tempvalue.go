@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// TempValue is a temperature sent as a string on the wire ("24.00"). For
+// clients that don't quote numbers it also decodes from a bare JSON number
+// (24), and either form is normalized to two decimals.
+type TempValue string
+
+func (v *TempValue) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("invalid temperature %s", data)
+	}
+	*v = TempValue(strconv.FormatFloat(f, 'f', 2, 64))
+	return nil
+}
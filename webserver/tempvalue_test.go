@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestTempValueUnmarshal(t *testing.T) {
+	for in, want := range map[string]TempValue{
+		`"24.00"`:  "24.00",
+		`"24"`:     "24.00",
+		`24`:       "24.00",
+		`24.0`:     "24.00",
+		`-3.456`:   "-3.46",
+		`" 18.5 "`: "18.50",
+	} {
+		var v TempValue
+		if err := json.Unmarshal([]byte(in), &v); err != nil || v != want {
+			t.Errorf("unmarshalling %s: %q, %v, want %q", in, v, err, want)
+		}
+	}
+	for _, in := range []string{`"warm"`, `""`, `"NaN"`, `"Inf"`, `true`, `[24]`} {
+		var v TempValue
+		if err := json.Unmarshal([]byte(in), &v); err == nil {
+			t.Errorf("unmarshalling %s: %q, want an error", in, v)
+		}
+	}
+
+	// null leaves the value alone.
+	v := TempValue("21.00")
+	if err := json.Unmarshal([]byte(`null`), &v); err != nil || v != "21.00" {
+		t.Errorf("unmarshalling null: %q, %v, want 21.00 kept", v, err)
+	}
+}
+
+func TestTempValueMarshal(t *testing.T) {
+	data, err := json.Marshal(Temp{DayTemp: "24.00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	json.Unmarshal(data, &fields)
+	if fields["daytemp"] != "24.00" {
+		t.Errorf("marshalled daytemp as %#v, want the string 24.00", fields["daytemp"])
+	}
+}
+
+func TestPutTempNumbers(t *testing.T) {
+	h := newHarness(t, 20)
+	var stored [2]Temp
+	for i, body := range []interface{}{
+		map[string]string{"daytemp": "24.00", "nighttemp": "18.00", "thereshold": "0.20"},
+		map[string]float64{"daytemp": 24, "nighttemp": 18, "thereshold": 0.2},
+	} {
+		if resp, data := h.Do(http.MethodPut, "/rest/v1/temp", body); resp.StatusCode != http.StatusOK {
+			t.Fatalf("PUT /rest/v1/temp %v: %s %s", body, resp.Status, data)
+		}
+		h.GetJSON("/rest/v1/temp", &stored[i])
+	}
+	if stored[0] != stored[1] {
+		t.Errorf("targets sent as numbers stored as %+v, want %+v as for strings", stored[1], stored[0])
+	}
+	if stored[1].DayTemp != "24.00" || stored[1].Thereshold != "0.20" {
+		t.Errorf("stored %+v, want daytemp 24.00 and thereshold 0.20", stored[1])
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/render"
+)
+
+var errTimesOverlap = errors.New("must differ from day, or the day or the night would be empty")
+
+/**-----------------------------------------------------------------------------------
+ * validate time
+ * =============
+ * $ curl -X POST -H 'Content-Type: application/json' -d '{"day":"06:00","night":"22:00"}' http://bangkokguy.ddns.net/rest/v1/time/validate
+ *   {"valid":true}
+ * $ curl -X POST -H 'Content-Type: application/json' -d '{"day":"6 am","night":"22:00"}' http://bangkokguy.ddns.net/rest/v1/time/validate
+ *   {"valid":false,"problems":[{"field":"day","code":"validation.bad_format","message":"must be a HH:MM time, or sunrise or sunset with an optional offset"}]}
+ *------------------------------------------------------------------------------------*/
+
+// timeProblem is what's wrong with one field of the day and night times.
+type timeProblem struct {
+	field string
+	err   error
+}
+
+func (p timeProblem) Error() string { return p.field + ": " + p.err.Error() }
+func (p timeProblem) Unwrap() error { return p.err }
+
+// check lists everything wrong with the times, which PUT /time rejects
+// with the first of and POST /time/validate reports in full: missing or
+// badly formatted times, and a day that starts when the night does. The
+// day may wrap midnight, so there's no order to keep beyond that.
+func (a *Times) check() []timeProblem {
+	var problems []timeProblem
+	for _, f := range []struct{ name, value string }{{"day", a.Day}, {"night", a.Night}} {
+		if f.value == "" {
+			problems = append(problems, timeProblem{f.name, withCode(CodeRequired, errors.New("is required"))})
+		} else if err := checkDayTime(f.value); err != nil {
+			problems = append(problems, timeProblem{f.name, withCode(CodeBadFormat, err)})
+		}
+	}
+	if len(problems) == 0 && a.Day == a.Night {
+		problems = append(problems, timeProblem{"night", errTimesOverlap})
+	}
+	return problems
+}
+
+// TimeProblem is a problem POST /time/validate found.
+type TimeProblem struct {
+	Field   string    `json:"field"`
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// TimeValidation is the verdict of POST /time/validate.
+type TimeValidation struct {
+	Valid    bool          `json:"valid"`
+	Problems []TimeProblem `json:"problems,omitempty"`
+}
+
+func (v *TimeValidation) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// ValidateTime checks day and night times the way PUT /time does, and
+// lists every problem instead of stopping at the first. Nothing changes,
+// so it's a preflight for forms. A body that isn't JSON is still a 400.
+func ValidateTime(w http.ResponseWriter, r *http.Request) {
+	data := &Times{}
+	err := requireBody(r)
+	if err == nil {
+		err = render.Decode(r, data)
+	}
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	data.Day = strings.ToLower(data.Day)
+	data.Night = strings.ToLower(data.Night)
+
+	v := &TimeValidation{Valid: true}
+	for _, p := range data.check() {
+		v.Valid = false
+		v.Problems = append(v.Problems, TimeProblem{Field: p.field, Code: codeOf(p.err, CodeInvalidRequest), Message: p.err.Error()})
+	}
+	if err := render.Render(w, r, v); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
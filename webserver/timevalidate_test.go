@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestValidateTime(t *testing.T) {
+	h := newHarness(t, 20)
+	for _, tc := range []struct {
+		body map[string]string
+		want []TimeProblem // nil for valid
+	}{
+		{map[string]string{"day": "07:00", "night": "23:00"}, nil},
+		{map[string]string{"day": "23:00", "night": "07:00"}, nil},
+		{map[string]string{"day": "6 am"}, []TimeProblem{
+			{Field: "day", Code: CodeBadFormat},
+			{Field: "night", Code: CodeRequired},
+		}},
+		{map[string]string{"day": "07:00", "night": "07:00"}, []TimeProblem{
+			{Field: "night", Code: CodeTimesOverlap},
+		}},
+		// Without -location there is no sunrise.
+		{map[string]string{"day": "Sunrise", "night": "22:00"}, []TimeProblem{
+			{Field: "day", Code: CodeBadFormat},
+		}},
+	} {
+		resp, body := h.Do(http.MethodPost, "/rest/v1/time/validate", tc.body)
+		var v TimeValidation
+		if err := json.Unmarshal(body, &v); err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST /rest/v1/time/validate %v: %s %s", tc.body, resp.Status, body)
+		}
+		if v.Valid != (tc.want == nil) || len(v.Problems) != len(tc.want) {
+			t.Errorf("%v: %s, want %d problems", tc.body, body, len(tc.want))
+			continue
+		}
+		for i, p := range v.Problems {
+			if p.Field != tc.want[i].Field || p.Code != tc.want[i].Code || p.Message == "" {
+				t.Errorf("%v: problem %+v, want %s %s", tc.body, p, tc.want[i].Field, tc.want[i].Code)
+			}
+		}
+	}
+
+	if times, _ := h.Store.GetTime(); times.Day != "06:00" || times.Night != "22:00" {
+		t.Errorf("validating changed the times to %s and %s", times.Day, times.Night)
+	}
+	if resp, _ := h.Do(http.MethodPost, "/rest/v1/time/validate", json.RawMessage(`"06:00"`)); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("POST /rest/v1/time/validate with a string: %s, want 400", resp.Status)
+	}
+}
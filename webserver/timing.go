@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timings collects how long the phases of handling a request took, for
+// the Server-Timing header.
+type Timings struct {
+	mu     sync.Mutex
+	start  time.Time
+	phases []*timingPhase
+}
+
+type timingPhase struct {
+	name       string
+	start, end time.Time
+}
+
+type timingsCtxKey struct{}
+
+// startPhase starts timing the named phase of the request handled with
+// ctx, and returns the function that ends it. Phases still running when
+// the header is written are cut off there. Without ServerTiming in the
+// chain, it does nothing.
+func startPhase(ctx context.Context, name string) func() {
+	t, ok := ctx.Value(timingsCtxKey{}).(*Timings)
+	if !ok {
+		return func() {}
+	}
+	p := &timingPhase{name: name, start: time.Now()}
+	t.mu.Lock()
+	t.phases = append(t.phases, p)
+	t.mu.Unlock()
+	return func() {
+		t.mu.Lock()
+		if p.end.IsZero() {
+			p.end = time.Now()
+		}
+		t.mu.Unlock()
+	}
+}
+
+// header formats the phases as a Server-Timing header value, with
+// durations in milliseconds, followed by the total so far. Phases that
+// ran more than once are summed up.
+func (t *Timings) header(now time.Time) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var names []string
+	sums := map[string]time.Duration{}
+	for _, p := range t.phases {
+		if p.end.IsZero() {
+			p.end = now
+		}
+		if _, ok := sums[p.name]; !ok {
+			names = append(names, p.name)
+		}
+		sums[p.name] += p.end.Sub(p.start)
+	}
+
+	metrics := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		metrics = append(metrics, fmt.Sprintf("%s;dur=%.3f", name, ms(sums[name])))
+	}
+	metrics = append(metrics, fmt.Sprintf("total;dur=%.3f", ms(now.Sub(t.start))))
+	return strings.Join(metrics, ", ")
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// ServerTiming middleware puts a Timings on the request context, and sets
+// the Server-Timing header from it right before the response header is
+// written.
+func ServerTiming(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := &Timings{start: time.Now()}
+		tw := &timingWriter{ResponseWriter: w, timings: t}
+		next.ServeHTTP(tw, r.WithContext(context.WithValue(r.Context(), timingsCtxKey{}, t)))
+	})
+}
+
+// timingWriter adds the Server-Timing header on the first WriteHeader or
+// Write.
+type timingWriter struct {
+	http.ResponseWriter
+	timings     *Timings
+	wroteHeader bool
+}
+
+func (w *timingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Set("Server-Timing", w.timings.header(time.Now()))
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *timingWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// FlushError flushes through the writers underneath, reporting it if they
+// can't, rather than leaving the response in their buffers.
+func (w *timingWriter) FlushError() error {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *timingWriter) Flush() {
+	w.FlushError()
+}
+
+// Hijack lets the WebSocket upgrade take over the connection.
+func (w *timingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not implement http.Hijacker")
+	}
+	return h.Hijack()
+}
+
+// Unwrap gives http.ResponseController access to the underlying writer.
+func (w *timingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimingsHeader(t *testing.T) {
+	start := harnessStart
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	timings := &Timings{start: start, phases: []*timingPhase{
+		{name: "decode", start: at(0), end: at(2)},
+		{name: "sensor", start: at(2), end: at(10)},
+		{name: "sensor", start: at(10), end: at(11)},
+		{name: "render", start: at(11)}, // still running
+	}}
+	want := "decode;dur=2.000, sensor;dur=9.000, render;dur=1.500, total;dur=12.500"
+	if got := timings.header(at(0).Add(12500 * time.Microsecond)); got != want {
+		t.Errorf("Server-Timing %q, want %q", got, want)
+	}
+}
+
+// timingNames returns the metric names of a Server-Timing header.
+func timingNames(header string) []string {
+	var names []string
+	for _, metric := range strings.Split(header, ", ") {
+		name, _, _ := strings.Cut(metric, ";")
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestServerTimingPhases(t *testing.T) {
+	h := newHarness(t, 20)
+	withFlag(t, freshReadTimeout, time.Second)
+
+	for _, tc := range []struct {
+		method, path string
+		body         interface{}
+		want         string
+	}{
+		{http.MethodPut, "/rest/v1/temp", map[string]string{"daytemp": "23", "nighttemp": "17", "thereshold": "0.2"}, "decode validate sensor render total"},
+		{http.MethodGet, "/rest/v1/status?fresh=true", nil, "sensor render total"},
+	} {
+		resp, body := h.Do(tc.method, tc.path, tc.body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s %s: %s %s", tc.method, tc.path, resp.Status, body)
+		}
+		header := resp.Header.Get("Server-Timing")
+		if got := strings.Join(timingNames(header), " "); got != tc.want {
+			t.Errorf("%s %s: Server-Timing %q, want the phases %s", tc.method, tc.path, header, tc.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans has the package's tracer export to memory for the test.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	old := tracer
+	tracer = tp.Tracer("bangkokguy.dev/webserver")
+	t.Cleanup(func() {
+		tracer = old
+		tp.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+func TestEvaluateSpanIsChildOfRequest(t *testing.T) {
+	h := newHarness(t, 20)
+	exporter := recordSpans(t)
+
+	if resp, body := h.Do(http.MethodPut, "/rest/v1/mode", map[string]string{"mode": "day", "heating": "auto"}); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT /rest/v1/mode: %s %s", resp.Status, body)
+	}
+
+	var server, eval *tracetest.SpanStub
+	spans := exporter.GetSpans()
+	for i := range spans {
+		switch s := &spans[i]; {
+		case s.Name == "evaluate":
+			eval = s
+		case strings.HasPrefix(s.Name, http.MethodPut):
+			server = s
+		}
+	}
+	if server == nil || eval == nil {
+		t.Fatalf("recorded %d spans without both the request's and the evaluation's", len(spans))
+	}
+	if eval.Parent.SpanID() != server.SpanContext.SpanID() || eval.SpanContext.TraceID() != server.SpanContext.TraceID() {
+		t.Errorf("evaluate span has parent %s in trace %s, want the request's span %s in trace %s",
+			eval.Parent.SpanID(), eval.SpanContext.TraceID(), server.SpanContext.SpanID(), server.SpanContext.TraceID())
+	}
+}
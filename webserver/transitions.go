@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+/**-----------------------------------------------------------------------------------
+ * get mode history
+ * ================
+ * $ curl http://bangkokguy.ddns.net/rest/v1/mode/history?type=heating&since=2021-12-01T00:00:00Z&until=2021-12-02T00:00:00Z&limit=10
+ *   {"items":[{"at":"2021-12-01T22:00:02Z","type":"heating","from":"on","to":"off","reason":"threshold"}],"next_cursor":"MTI"}
+ * $ curl http://bangkokguy.ddns.net/rest/v1/mode/history?type=heating&limit=10&cursor=MTI
+ *------------------------------------------------------------------------------------*/
+
+// Transition records a change of the mode or heating state, and why it
+// happened.
+type Transition struct {
+	At     time.Time `json:"at"`
+	Type   string    `json:"type"` // "mode" or "heating"
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Reason string    `json:"reason"`
+}
+
+func (t *Transition) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// TransitionLog is a bounded, oldest-first log of transitions. Once full,
+// appending drops the oldest entry. With a retention set, appending also
+// drops the entries older than the retention, counted back from the new
+// entry.
+type TransitionLog struct {
+	mu        sync.Mutex
+	size      int
+	retention time.Duration
+	entries   []Transition
+	dropped   int64 // entries dropped so far, so entries[0] is the dropped-th ever
+}
+
+func NewTransitionLog(size int) *TransitionLog {
+	return &TransitionLog{size: size}
+}
+
+func (l *TransitionLog) Append(t Transition) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, t)
+	drop := 0
+	if len(l.entries) > l.size {
+		drop = len(l.entries) - l.size
+	}
+	if l.retention > 0 {
+		cutoff := t.At.Add(-l.retention)
+		for drop < len(l.entries) && l.entries[drop].At.Before(cutoff) {
+			drop++
+		}
+	}
+	if drop > 0 {
+		l.entries = append(l.entries[:0], l.entries[drop:]...)
+		l.dropped += int64(drop)
+	}
+}
+
+// SetRetention sets how long entries are kept, 0 meaning until the log
+// is full.
+func (l *TransitionLog) SetRetention(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.retention = d
+}
+
+// Stats returns the number of entries and the time of the oldest one.
+func (l *TransitionLog) Stats() HistoryStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := HistoryStats{Count: len(l.entries), Capacity: l.size}
+	if len(l.entries) > 0 {
+		oldest := l.entries[0].At
+		stats.Oldest = &oldest
+	}
+	return stats
+}
+
+// Since returns the transitions recorded after since, oldest first. A
+// positive limit keeps only the most recent limit entries.
+func (l *TransitionLog) Since(since time.Time, limit int) []Transition {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	list := []Transition{}
+	for _, t := range l.entries {
+		if t.At.After(since) {
+			list = append(list, t)
+		}
+	}
+	if limit > 0 && len(list) > limit {
+		list = list[len(list)-limit:]
+	}
+	return list
+}
+
+// Query returns a page of the transitions matching q, newest first, and
+// the cursor of the next page.
+func (l *TransitionLog) Query(q HistoryQuery) ([]Transition, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	picked, next := q.page(len(l.entries), l.dropped, func(i int) (time.Time, string) {
+		return l.entries[i].At, l.entries[i].Type
+	})
+	list := make([]Transition, len(picked))
+	for j, i := range picked {
+		list[j] = l.entries[i]
+	}
+	return list, next
+}
+
+var modeHistory = NewTransitionLog(500)
+
+func GetModeHistory(w http.ResponseWriter, r *http.Request) {
+	q, err := parseHistoryQuery(r, "mode", "heating")
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	list, next := modeHistory.Query(q)
+	if err := render.Render(w, r, &HistoryPage{Items: list, NextCursor: next}); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
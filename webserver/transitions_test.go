@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTransitionLog(t *testing.T) {
+	start := time.Date(2024, 1, 15, 6, 0, 0, 0, time.UTC)
+	l := NewTransitionLog(3)
+	for i := 0; i < 5; i++ {
+		l.Append(Transition{At: start.Add(time.Duration(i) * time.Minute), Type: "mode", To: fmt.Sprint(i)})
+	}
+
+	to := func(list []Transition) string {
+		s := ""
+		for _, tr := range list {
+			s += tr.To
+		}
+		return s
+	}
+	for _, tc := range []struct {
+		since time.Time
+		limit int
+		want  string
+	}{
+		{time.Time{}, 0, "234"}, // the oldest two dropped
+		{time.Time{}, 2, "34"},
+		{start.Add(3 * time.Minute), 0, "4"},
+		{start.Add(4 * time.Minute), 0, ""},
+	} {
+		if got := to(l.Since(tc.since, tc.limit)); got != tc.want {
+			t.Errorf("Since(%s, %d) = %q, want %q", tc.since.Format("15:04"), tc.limit, got, tc.want)
+		}
+	}
+}
+
+func TestSchedulePhase(t *testing.T) {
+	at := func(clock string) time.Time {
+		tm, _ := time.Parse("2006-01-02 15:04", "2024-01-15 "+clock)
+		return tm
+	}
+	for _, tc := range []struct {
+		now, day, night, want string
+	}{
+		{"05:59", "06:00", "22:00", "night"},
+		{"06:00", "06:00", "22:00", "day"},
+		{"21:59", "06:00", "22:00", "day"},
+		{"22:00", "06:00", "22:00", "night"},
+		// A day wrapping midnight, for night shifts.
+		{"23:00", "20:00", "04:00", "day"},
+		{"03:59", "20:00", "04:00", "day"},
+		{"04:00", "20:00", "04:00", "night"},
+	} {
+		if got := schedulePhase(at(tc.now), tc.day, tc.night); got != tc.want {
+			t.Errorf("%s with the day %s to %s: %s, want %s", tc.now, tc.day, tc.night, got, tc.want)
+		}
+	}
+}
+
+func TestModeHistoryParams(t *testing.T) {
+	for _, query := range []string{"since=yesterday", "limit=0", "limit=ten"} {
+		rec := httptest.NewRecorder()
+		GetModeHistory(rec, httptest.NewRequest(http.MethodGet, "/rest/v1/mode/history?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("?%s: %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+// modeHistoryPage is the envelope of GET /rest/v1/mode/history.
+type modeHistoryPage struct {
+	Items      []Transition `json:"items"`
+	NextCursor string       `json:"next_cursor"`
+}
+
+// fillModeHistory replaces the mode history with n transitions a minute
+// apart, ending at harnessStart, alternating between mode and heating.
+func fillModeHistory(n int) {
+	modeHistory = NewTransitionLog(500)
+	for i := 0; i < n; i++ {
+		appendTransition(i, n)
+	}
+}
+
+func appendTransition(i, n int) {
+	kind := "mode"
+	if i%2 == 1 {
+		kind = "heating"
+	}
+	modeHistory.Append(Transition{
+		At:   harnessStart.Add(time.Duration(i-n+1) * time.Minute),
+		Type: kind,
+		From: "a",
+		To:   fmt.Sprint(i),
+	})
+}
+
+func TestModeHistoryPaging(t *testing.T) {
+	h := newHarness(t, 20)
+	fillModeHistory(1200)
+
+	var seen []Transition
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatalf("still paging after %d pages", pages)
+		}
+		path := "/rest/v1/mode/history?limit=100"
+		if cursor != "" {
+			path += "&cursor=" + cursor
+		}
+		var page modeHistoryPage
+		h.GetJSON(path, &page)
+		seen = append(seen, page.Items...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(seen) != 500 {
+		t.Fatalf("paged through %d transitions, want the 500 kept", len(seen))
+	}
+	for i, tr := range seen {
+		if want := fmt.Sprint(1199 - i); tr.To != want {
+			t.Fatalf("transition %d is #%s, want #%s, newest first", i, tr.To, want)
+		}
+	}
+}
+
+func TestModeHistoryCursorSurvivesDrops(t *testing.T) {
+	h := newHarness(t, 20)
+	fillModeHistory(1200)
+
+	var first modeHistoryPage
+	h.GetJSON("/rest/v1/mode/history?limit=100", &first)
+	// 50 more push 50 of the oldest out, none of the next page's.
+	for i := 1200; i < 1250; i++ {
+		appendTransition(i, 1200)
+	}
+	var second modeHistoryPage
+	h.GetJSON("/rest/v1/mode/history?limit=100&cursor="+first.NextCursor, &second)
+	if len(second.Items) != 100 || second.Items[0].To != "1099" {
+		t.Fatalf("the next page has %d transitions, want 100 from #1099 on: %+v", len(second.Items), second.Items)
+	}
+}
+
+func TestModeHistoryFilters(t *testing.T) {
+	h := newHarness(t, 20)
+	fillModeHistory(1200)
+
+	var heating modeHistoryPage
+	h.GetJSON("/rest/v1/mode/history?type=heating&limit=500", &heating)
+	if len(heating.Items) != 250 {
+		t.Errorf("%d heating transitions, want 250", len(heating.Items))
+	}
+	for _, tr := range heating.Items {
+		if tr.Type != "heating" {
+			t.Fatalf("?type=heating returned a %s transition", tr.Type)
+		}
+	}
+
+	// ?since= is inclusive: #1100 is at since itself.
+	since := harnessStart.Add(-99 * time.Minute).Format(time.RFC3339)
+	var recent modeHistoryPage
+	h.GetJSON("/rest/v1/mode/history?limit=500&since="+url.QueryEscape(since), &recent)
+	if n := len(recent.Items); n != 100 || recent.Items[n-1].To != "1100" {
+		t.Errorf("since %s: %d transitions, want 100 down to #1100", since, n)
+	}
+	if recent.NextCursor != "" {
+		t.Errorf("since %s: next_cursor %q on the last page", since, recent.NextCursor)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var defaultUnit = flag.String("default-unit", "C", "Temperature unit used when a request doesn't ask for one with ?unit= (C or F)")
+var defaultPrecision = flag.Int("default-precision", 1, "Decimals the measured temperature is reported with when a request doesn't ask for a number with ?precision= (0 to 3)")
+
+const maxPrecision = 3
+
+// parseUnit validates a temperature unit, accepting either case.
+func parseUnit(unit string) (string, error) {
+	switch strings.ToUpper(unit) {
+	case "C":
+		return "C", nil
+	case "F":
+		return "F", nil
+	}
+	return "", withCode(CodeUnknownUnit, fmt.Errorf("unknown temperature unit %q, must be C or F", unit))
+}
+
+// requestUnit returns the temperature unit asked for by ?unit=, falling back
+// to the server's -default-unit.
+func requestUnit(r *http.Request) (string, error) {
+	if unit := r.URL.Query().Get("unit"); unit != "" {
+		return parseUnit(unit)
+	}
+	return parseUnit(*defaultUnit)
+}
+
+// checkPrecision validates a number of decimals for ?precision=.
+func checkPrecision(decimals int) error {
+	if decimals < 0 || decimals > maxPrecision {
+		return withCode(CodeOutOfRange, fmt.Errorf("precision must be 0 to %d decimals", maxPrecision))
+	}
+	return nil
+}
+
+// requestPrecision returns the decimals asked for by ?precision=, falling
+// back to the server's -default-precision.
+func requestPrecision(r *http.Request) (int, error) {
+	s := r.URL.Query().Get("precision")
+	if s == "" {
+		return *defaultPrecision, nil
+	}
+	decimals, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, withCode(CodeOutOfRange, fmt.Errorf("precision must be 0 to %d decimals", maxPrecision))
+	}
+	return decimals, checkPrecision(decimals)
+}
+
+// roundTemp rounds a temperature to decimals. Values that aren't numbers
+// are returned as they are.
+func roundTemp(v TempValue, decimals int) TempValue {
+	f, err := strconv.ParseFloat(string(v), 64)
+	if err != nil {
+		return v
+	}
+	return TempValue(strconv.FormatFloat(f, 'f', decimals, 64))
+}
+
+// roundFloat rounds f to decimals.
+func roundFloat(f float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(f*p) / p
+}
+
+// convertTemp converts a temperature between units, keeping the number of
+// decimals it was written with. A delta (such as a threshold) is only
+// scaled, not offset. Values that aren't numbers are returned as they are.
+func convertTemp(v TempValue, from, to string, delta bool) TempValue {
+	f, err := strconv.ParseFloat(string(v), 64)
+	if from == to || err != nil {
+		return v
+	}
+
+	switch {
+	case to == "F" && delta:
+		f = f * 9 / 5
+	case to == "F":
+		f = f*9/5 + 32
+	case delta:
+		f = f * 5 / 9
+	default:
+		f = (f - 32) * 5 / 9
+	}
+
+	decimals := 0
+	if i := strings.IndexByte(string(v), '.'); i >= 0 {
+		decimals = len(v) - i - 1
+	}
+	return TempValue(strconv.FormatFloat(f, 'f', decimals, 64))
+}
+
+// convertFloat converts a temperature in Celsius to unit, a delta being
+// only scaled, as convertTemp does, and rounds it to decimals.
+func convertFloat(f float64, unit string, delta bool, decimals int) float64 {
+	switch {
+	case unit == "F" && delta:
+		f = f * 9 / 5
+	case unit == "F":
+		f = f*9/5 + 32
+	}
+	return roundFloat(f, decimals)
+}
+
+// convert converts every value of t, which is in unit from, to unit to.
+func (t *Temp) convert(from, to string) {
+	t.CurrentTemp = convertTemp(t.CurrentTemp, from, to, false)
+	t.NightTemp = convertTemp(t.NightTemp, from, to, false)
+	t.DayTemp = convertTemp(t.DayTemp, from, to, false)
+	t.Thereshold = convertTemp(t.Thereshold, from, to, true)
+	t.Unit = to
+}
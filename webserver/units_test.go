@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConvertTemp(t *testing.T) {
+	for _, tc := range []struct {
+		v        TempValue
+		from, to string
+		delta    bool
+		want     TempValue
+	}{
+		{"20.00", "C", "F", false, "68.00"},
+		{"68", "F", "C", false, "20"},
+		{"-40.0", "C", "F", false, "-40.0"},
+		{"0.50", "C", "F", true, "0.90"},
+		{"0.90", "F", "C", true, "0.50"},
+		{"21.5", "C", "C", false, "21.5"},
+		{"warm", "C", "F", false, "warm"},
+	} {
+		if got := convertTemp(tc.v, tc.from, tc.to, tc.delta); got != tc.want {
+			t.Errorf("convertTemp(%q, %s, %s, delta %t) = %q, want %q", tc.v, tc.from, tc.to, tc.delta, got, tc.want)
+		}
+	}
+}
+
+func TestTempConvert(t *testing.T) {
+	temp := Temp{CurrentTemp: "20.00", DayTemp: "24.00", NightTemp: "18.00", Thereshold: "0.50"}
+	temp.convert("C", "F")
+	if temp.CurrentTemp != "68.00" || temp.DayTemp != "75.20" || temp.NightTemp != "64.40" || temp.Thereshold != "0.90" || temp.Unit != "F" {
+		t.Errorf("in F: %+v", temp)
+	}
+}
+
+func TestRequestUnit(t *testing.T) {
+	withDefault := func(unit string) {
+		old := *defaultUnit
+		*defaultUnit = unit
+		t.Cleanup(func() { *defaultUnit = old })
+	}
+	withDefault("C")
+	for _, tc := range []struct {
+		query, want string
+	}{
+		{"", "C"},
+		{"?unit=f", "F"},
+		{"?unit=C", "C"},
+		{"?unit=K", ""},
+	} {
+		got, err := requestUnit(httptest.NewRequest(http.MethodGet, "/rest/v1/temp"+tc.query, nil))
+		if (err != nil) != (tc.want == "") || got != tc.want {
+			t.Errorf("%q: %q, %v; want %q", tc.query, got, err, tc.want)
+		}
+	}
+
+	withDefault("F")
+	if got, err := requestUnit(httptest.NewRequest(http.MethodGet, "/rest/v1/temp", nil)); err != nil || got != "F" {
+		t.Errorf("with -default-unit F: %q, %v", got, err)
+	}
+}
+
+func TestPrecisionOfMeasuredTemps(t *testing.T) {
+	h := newHarness(t, 20.456)
+
+	var d Deadband
+	h.GetJSON("/rest/v1/temp/deadband?precision=2", &d)
+	if d.Current == nil || *d.Current != 20.46 || len(d.Readings) == 0 || d.Readings[0].Temp != 20.46 {
+		t.Errorf("deadband at precision 2: current %v, readings %v; want 20.46", d.Current, d.Readings)
+	}
+	if d.OnBelow != 17.8 || d.OffAbove != 18.2 {
+		t.Errorf("deadband band %g to %g, want the night's 17.8 to 18.2", d.OnBelow, d.OffAbove)
+	}
+	h.GetJSON("/rest/v1/temp/deadband?precision=0", &d)
+	if d.Current == nil || *d.Current != 20 {
+		t.Errorf("deadband at precision 0: current %v, want 20", d.Current)
+	}
+
+	var p Preheat
+	h.GetJSON("/rest/v1/temp/preheat?precision=1", &p)
+	if p.Current != 20.5 {
+		t.Errorf("preheat at precision 1: current %g, want 20.5", p.Current)
+	}
+
+	var hist Histogram
+	h.GetJSON("/rest/v1/temp/histogram?precision=3", &hist)
+	if hist.Min != 20.456 || hist.Median != 20.456 {
+		t.Errorf("histogram at precision 3: min %g, median %g; want 20.456", hist.Min, hist.Median)
+	}
+
+	for _, path := range []string{"/rest/v1/temp/deadband", "/rest/v1/temp/preheat", "/rest/v1/temp/histogram", "/rest/v1/events", "/rest/v1/ws"} {
+		if resp, body := h.Do(http.MethodGet, path+"?precision=4", nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("GET %s?precision=4: %s %s, want 400", path, resp.Status, body)
+		}
+	}
+}
+
+func TestPrecisionOfTelemetry(t *testing.T) {
+	h := newHarness(t, 20.456)
+
+	resp, err := h.Server.Client().Get(h.Server.URL + "/rest/v1/events?precision=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	lines := bufio.NewScanner(resp.Body)
+	lines.Scan() // retry:
+	h.Advance(10 * time.Second)
+
+	deadline := time.AfterFunc(5*time.Second, func() { resp.Body.Close() })
+	defer deadline.Stop()
+	for lines.Scan() {
+		data, ok := strings.CutPrefix(lines.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var e struct {
+			Type string    `json:"type"`
+			Data Telemetry `json:"data"`
+		}
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			t.Fatalf("%s: %s", err, data)
+		}
+		if e.Type == "telemetry" {
+			if e.Data.CurrentTemp != 20.46 {
+				t.Errorf("telemetry at precision 2: currenttemp %g, want 20.46", e.Data.CurrentTemp)
+			}
+			return
+		}
+	}
+	t.Fatal("no telemetry on the event stream")
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+// maxUpcomingHours is the longest window ?hours= can ask for, a week.
+const maxUpcomingHours = 7 * 24
+
+/**-----------------------------------------------------------------------------------
+ * get upcoming targets
+ * ====================
+ * $ curl http://bangkokguy.ddns.net/rest/v1/temp/upcoming?hours=24
+ *   {"hours":24,"unit":"C","items":[{"at":"2021-12-01T20:15:00Z","target":"21.00","phase":"day"},
+ *    {"at":"2021-12-01T22:00:00Z","target":"18.00","phase":"night"},{"at":"2021-12-02T06:00:00Z","target":"24.00","phase":"day"}]}
+ *------------------------------------------------------------------------------------*/
+
+// Setpoint is a target the thermostat aims for from At on.
+type Setpoint struct {
+	At     time.Time `json:"at"`
+	Target TempValue `json:"target"`
+	Phase  string    `json:"phase"`
+}
+
+// Upcoming is the timeline of targets over the next Hours, starting with
+// the one in effect now.
+type Upcoming struct {
+	Hours int        `json:"hours"`
+	Unit  string     `json:"unit"`
+	Items []Setpoint `json:"items"`
+}
+
+func (u *Upcoming) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// setpointPlan is what decides the targets: the settings, the forced
+// phase and schedule file overriding them, and the ramps in progress, by
+// target.
+type setpointPlan struct {
+	modes    *Modes
+	times    *Times
+	temp     *Temp
+	forced   string
+	schedule Schedule
+	ramps    map[string]Ramp
+}
+
+// at returns the phase and target at t, the way the evaluator decides
+// them, leaving out preheating, which depends on how the room cools.
+func (p *setpointPlan) at(t time.Time) (Setpoint, error) {
+	phase := p.modes.Mode[1]
+	if p.forced != "" {
+		phase = p.forced
+	} else if phase != "day" && phase != "night" {
+		day, err := resolveDayTime(p.times.Day, t)
+		if err != nil {
+			return Setpoint{}, err
+		}
+		night, err := resolveDayTime(p.times.Night, t)
+		if err != nil {
+			return Setpoint{}, err
+		}
+		phase = schedulePhase(t, day, night)
+	}
+
+	if p.schedule != nil && p.forced == "" {
+		return Setpoint{At: t, Target: p.schedule.Target(t), Phase: phase}, nil
+	}
+	name, target := "nighttemp", p.temp.NightTemp
+	if phase == "day" {
+		name, target = "daytemp", p.temp.DayTemp
+	}
+	if r, ok := p.ramps[name]; ok && r.To == target && t.Before(r.End) {
+		target = r.at(t)
+	}
+	return Setpoint{At: t, Target: target, Phase: phase}, nil
+}
+
+// changes returns the times in (from, to] the target or phase may change:
+// the day and night times and the schedule file's slots of every day in
+// between, and the ends of the ramps.
+func (p *setpointPlan) changes(from, to time.Time) ([]time.Time, error) {
+	var clocks []string
+	if p.forced == "" {
+		if m := p.modes.Mode[1]; m != "day" && m != "night" {
+			clocks = append(clocks, p.times.Day, p.times.Night)
+		}
+	}
+	var slots []string
+	if p.schedule != nil && p.forced == "" {
+		for _, slot := range p.schedule {
+			slots = append(slots, slot.At)
+		}
+	}
+
+	var list []time.Time
+	add := func(t time.Time) {
+		if t.After(from) && !t.After(to) {
+			list = append(list, t)
+		}
+	}
+	y, m, d := from.Date()
+	for date := time.Date(y, m, d, 12, 0, 0, 0, from.Location()); !date.AddDate(0, 0, -1).After(to); date = date.AddDate(0, 0, 1) {
+		for _, c := range clocks {
+			// Sunrise and sunset resolve differently from day to day.
+			hhmm, err := resolveDayTime(c, date)
+			if err != nil {
+				return nil, err
+			}
+			t, err := onDate(date, hhmm)
+			if err != nil {
+				return nil, err
+			}
+			add(t)
+		}
+		for _, hhmm := range slots {
+			t, err := onDate(date, hhmm)
+			if err != nil {
+				return nil, err
+			}
+			add(t)
+		}
+	}
+	for _, r := range p.ramps {
+		add(r.End)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Before(list[j]) })
+	return list, nil
+}
+
+// onDate returns the time hhmm ("HH:MM") on the date of day.
+func onDate(day time.Time, hhmm string) (time.Time, error) {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return time.Time{}, err
+	}
+	y, m, d := day.Date()
+	return time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, day.Location()), nil
+}
+
+// timeline returns the target in effect at from, followed by every change
+// of the target or phase up to to.
+func (p *setpointPlan) timeline(from, to time.Time) ([]Setpoint, error) {
+	first, err := p.at(from)
+	if err != nil {
+		return nil, err
+	}
+	list := []Setpoint{first}
+	changes, err := p.changes(from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range changes {
+		sp, err := p.at(t)
+		if err != nil {
+			return nil, err
+		}
+		if last := list[len(list)-1]; sp.Target != last.Target || sp.Phase != last.Phase {
+			list = append(list, sp)
+		}
+	}
+	return list, nil
+}
+
+// loadSetpointPlan gathers what decides the targets as it stands.
+func loadSetpointPlan(ctx context.Context) (*setpointPlan, error) {
+	modes, err := storeOf(ctx).GetMode()
+	if err != nil {
+		return nil, err
+	}
+	times, err := storeOf(ctx).GetTime()
+	if err != nil {
+		return nil, err
+	}
+	temp, err := storeOf(ctx).GetTemp()
+	if err != nil {
+		return nil, err
+	}
+	p := &setpointPlan{modes: modes, times: times, temp: temp, forced: forcedPhase(), schedule: activeSchedule(), ramps: map[string]Ramp{}}
+	ramps.Lock()
+	for name, r := range ramps.m {
+		p.ramps[name] = *r
+	}
+	ramps.Unlock()
+	return p, nil
+}
+
+// GetUpcoming lists the targets the thermostat will aim for over the next
+// ?hours= (24 by default, a week at most), in the unit of ?unit=, as the
+// settings, the schedule file, a forced phase and the ramps in progress
+// have it now.
+func GetUpcoming(w http.ResponseWriter, r *http.Request) {
+	hours := 24
+	if s := r.URL.Query().Get("hours"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxUpcomingHours {
+			render.Render(w, r, ErrInvalidRequest(withCode(CodeOutOfRange, errors.New("hours must be between 1 and 168"))))
+			return
+		}
+		hours = n
+	}
+	unit, err := requestUnit(r)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	p, err := loadSetpointPlan(r.Context())
+	if err != nil {
+		render.Render(w, r, ErrInternal(err))
+		return
+	}
+
+	now := clockOf(r.Context()).Now()
+	list, err := p.timeline(now, now.Add(time.Duration(hours)*time.Hour))
+	if err != nil {
+		render.Render(w, r, ErrUnavailable(err))
+		return
+	}
+	for i := range list {
+		list[i].Target = convertTemp(list[i].Target, "C", unit, false)
+	}
+	if err := render.Render(w, r, &Upcoming{Hours: hours, Unit: unit, Items: list}); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// timelineString writes a timeline as "15:04 phase target" entries, so a
+// test can compare it whole.
+func timelineString(list []Setpoint) string {
+	var b strings.Builder
+	for i, sp := range list {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s %s %s", sp.At.Format("Mon 15:04"), sp.Phase, sp.Target)
+	}
+	return b.String()
+}
+
+func TestUpcoming(t *testing.T) {
+	h := newHarness(t, 20)
+
+	for _, tc := range []struct {
+		hours int
+		want  string
+	}{
+		{24, "Mon 05:00 night 18.00, Mon 06:00 day 24.00, Mon 22:00 night 18.00"},
+		{48, "Mon 05:00 night 18.00, Mon 06:00 day 24.00, Mon 22:00 night 18.00, Tue 06:00 day 24.00, Tue 22:00 night 18.00"},
+	} {
+		var u Upcoming
+		h.GetJSON(fmt.Sprintf("/rest/v1/temp/upcoming?hours=%d", tc.hours), &u)
+		if got := timelineString(u.Items); got != tc.want {
+			t.Errorf("?hours=%d: %s\nwant %s", tc.hours, got, tc.want)
+		}
+	}
+
+	var f Upcoming
+	h.GetJSON("/rest/v1/temp/upcoming?hours=48&unit=F", &f)
+	if f.Unit != "F" || f.Items[1].Target != "75.20" {
+		t.Errorf("?unit=F: %s in %s, want the day target at 75.20", timelineString(f.Items), f.Unit)
+	}
+
+	setForcedPhase("day")
+	var forced Upcoming
+	h.GetJSON("/rest/v1/temp/upcoming?hours=48", &forced)
+	if got := timelineString(forced.Items); got != "Mon 05:00 day 24.00" {
+		t.Errorf("forced day: %s, want a single entry", got)
+	}
+
+	if resp, _ := h.Do(http.MethodGet, "/rest/v1/temp/upcoming?hours=169", nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("?hours=169: %s, want 400", resp.Status)
+	}
+}
+
+func TestSetpointPlanRampsAndSchedule(t *testing.T) {
+	from := harnessStart
+	p := &setpointPlan{
+		modes: &Modes{Mode: [2]string{"day", "day"}},
+		times: &Times{Day: "06:00", Night: "22:00"},
+		temp:  &Temp{DayTemp: "24.00", NightTemp: "18.00"},
+		ramps: map[string]Ramp{
+			"daytemp": {From: "20.00", To: "24.00", Start: from.Add(-time.Hour), End: from.Add(time.Hour)},
+		},
+	}
+	list, err := p.timeline(from, from.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Pinned to day, only the ramp ending changes the target.
+	if got, want := timelineString(list), "Mon 05:00 day 22.00, Mon 06:00 day 24.00"; got != want {
+		t.Errorf("ramping: %s\nwant %s", got, want)
+	}
+
+	p.modes.Mode[1] = "auto"
+	p.ramps = nil
+	p.schedule = Schedule{{At: "07:30", Target: "21.00"}, {At: "23:00", Target: "16.50"}}
+	list, err = p.timeline(from, from.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Mon 05:00 night 16.50, Mon 06:00 day 16.50, Mon 07:30 day 21.00, Mon 22:00 night 21.00, Mon 23:00 night 16.50"
+	if got := timelineString(list); got != want {
+		t.Errorf("with a schedule: %s\nwant %s", got, want)
+	}
+}
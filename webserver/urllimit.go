@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"net/http"
+
+	"github.com/go-chi/render"
+)
+
+var (
+	maxURLLength   = flag.Int("max-url-length", 2048, "Longest request URI accepted, path and query, in bytes; 0 for no limit")
+	maxQueryLength = flag.Int("max-query-length", 1024, "Longest query string accepted, in bytes; 0 for no limit")
+)
+
+var (
+	errURLTooLong   = errors.New("request URI too long")
+	errQueryTooLong = errors.New("query string too long")
+)
+
+// LimitURL middleware rejects requests whose URI is longer than maxURL
+// bytes, or whose query string is longer than maxQuery, with a 414, so
+// the handlers parsing paths and queries never see pathological ones. A
+// limit of 0 disables it.
+func LimitURL(maxURL, maxQuery int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if maxURL > 0 && len(r.RequestURI) > maxURL {
+				render.Render(w, r, ErrURITooLong(errURLTooLong))
+				return
+			}
+			if maxQuery > 0 && len(r.URL.RawQuery) > maxQuery {
+				render.Render(w, r, ErrURITooLong(errQueryTooLong))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitURL(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	for _, tc := range []struct {
+		maxURL, maxQuery int
+		uri              string
+		want             int
+	}{
+		{32, 16, "/rest/v1/temp", http.StatusOK},
+		{32, 16, "/rest/v1/" + strings.Repeat("a", 23), http.StatusOK},
+		{32, 16, "/rest/v1/" + strings.Repeat("a", 24), http.StatusRequestURITooLong},
+		{64, 16, "/rest/v1/?q=" + strings.Repeat("a", 14), http.StatusOK},
+		{64, 16, "/rest/v1/?q=" + strings.Repeat("a", 15), http.StatusRequestURITooLong},
+		{0, 0, "/rest/v1/?q=" + strings.Repeat("a", 4096), http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		LimitURL(tc.maxURL, tc.maxQuery)(ok).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.uri, nil))
+		if rec.Code != tc.want {
+			t.Errorf("%d byte URI with limits %d and %d: %d, want %d", len(tc.uri), tc.maxURL, tc.maxQuery, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestLimitURLQuery(t *testing.T) {
+	h := newHarness(t, 20)
+	resp, body := h.Do(http.MethodGet, "/rest/v1/search?q="+strings.Repeat("a", *maxQueryLength), nil)
+	if resp.StatusCode != http.StatusRequestURITooLong || !strings.Contains(string(body), errQueryTooLong.Error()) {
+		t.Errorf("GET with a query over -max-query-length: %s %s, want 414", resp.Status, body)
+	}
+}
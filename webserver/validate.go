@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/render"
+)
+
+var maxBodyBytes = flag.Int64("max-body-bytes", 1<<20, "Largest request body read, after decompressing; larger ones get a 413")
+
+var errBodyRequired = errors.New("request body required")
+
+// decode binds the request body to v, like render.Bind, and then checks v
+// against its validate tags. An empty body is rejected up front, so Bind
+// methods always see a decoded value.
+func decode(r *http.Request, v render.Binder) error {
+	if err := requireBody(r); err != nil {
+		return err
+	}
+	stop := startPhase(r.Context(), "decode")
+	err := render.Bind(r, v)
+	stop()
+	if err != nil {
+		return err
+	}
+	defer startPhase(r.Context(), "validate")()
+	return validate(v)
+}
+
+// requireBody fails with errBodyRequired if the request body is empty or
+// only whitespace, and with an *http.MaxBytesError if it's over
+// -max-body-bytes. The body can still be read afterwards.
+func requireBody(r *http.Request) error {
+	if r.Body == nil {
+		return errBodyRequired
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, *maxBodyBytes))
+	r.Body.Close()
+	if err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if len(bytes.TrimSpace(data)) == 0 {
+		return errBodyRequired
+	}
+	return nil
+}
+
+// validate checks the fields of the struct v points to against the rules
+// in their validate tags, separated by commas:
+//
+//	required       the field must not be empty
+//	min=N, max=N   the number (or numeric string) must be in range
+//	oneof=a b c    the value must be one of the space separated words
+//	regex=RE       the value must match RE; it has to be the last rule
+//
+// Except for required, rules are only checked on non-empty values. Errors
+// name the field by its JSON name.
+func validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			if err := validate(rv.Field(i).Interface()); err != nil {
+				return err
+			}
+			continue
+		}
+		tag := f.Tag.Get("validate")
+		if tag == "" || f.PkgPath != "" {
+			continue
+		}
+		name := fieldName(f)
+		if err := checkRules(rv.Field(i), tag); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func checkRules(v reflect.Value, tag string) error {
+	for tag != "" {
+		var rule string
+		if strings.HasPrefix(tag, "regex=") {
+			rule, tag = tag, ""
+		} else if i := strings.Index(tag, ","); i >= 0 {
+			rule, tag = tag[:i], tag[i+1:]
+		} else {
+			rule, tag = tag, ""
+		}
+		name, arg, _ := strings.Cut(rule, "=")
+
+		if name == "required" {
+			if v.IsZero() {
+				return withCode(CodeRequired, errors.New("is required"))
+			}
+			continue
+		}
+		if v.IsZero() {
+			continue
+		}
+
+		switch name {
+		case "min", "max":
+			bound, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return fmt.Errorf("bad %s rule %q", name, arg)
+			}
+			n, err := number(v)
+			if err != nil {
+				return err
+			}
+			if name == "min" && n < bound {
+				return withCode(CodeOutOfRange, fmt.Errorf("must be at least %s", arg))
+			}
+			if name == "max" && n > bound {
+				return withCode(CodeOutOfRange, fmt.Errorf("must be at most %s", arg))
+			}
+		case "oneof":
+			words := strings.Fields(arg)
+			found := false
+			for _, w := range words {
+				if fmt.Sprint(v.Interface()) == w {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return withCode(CodeNotAllowed, fmt.Errorf("must be one of %s", strings.Join(words, ", ")))
+			}
+		case "regex":
+			re, err := compileRule(arg)
+			if err != nil {
+				return fmt.Errorf("bad regex rule %q", arg)
+			}
+			if !re.MatchString(fmt.Sprint(v.Interface())) {
+				return withCode(CodeBadFormat, fmt.Errorf("must match %s", arg))
+			}
+		default:
+			return fmt.Errorf("unknown validate rule %q", name)
+		}
+	}
+	return nil
+}
+
+// number returns the value of a numeric field, or of a string field (such
+// as a TempValue) holding a number.
+func number(v reflect.Value) (float64, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", v.String())
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("can't compare a %s to a number", v.Kind())
+}
+
+// Compiled regex rules, keyed by their expression.
+var ruleRegexps sync.Map
+
+func compileRule(expr string) (*regexp.Regexp, error) {
+	if re, ok := ruleRegexps.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	ruleRegexps.Store(expr, re)
+	return re, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBodyLimit(t *testing.T) {
+	h := newHarness(t, 20)
+	withFlag(t, maxBodyBytes, 64)
+	big := map[string]string{"day": "06:00", "night": "22:00", "padding": strings.Repeat("x", 64)}
+
+	resp, body := h.Do(http.MethodPut, "/rest/v1/time", big)
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("PUT /rest/v1/time with a body over -max-body-bytes: %s %s, want 413", resp.Status, body)
+	}
+	if resp, body := h.Do(http.MethodPut, "/rest/v1/time", map[string]string{"day": "06:00", "night": "22:00"}); resp.StatusCode != http.StatusOK {
+		t.Errorf("PUT /rest/v1/time under the limit: %s %s", resp.Status, body)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	type body struct {
+		Name  string    `json:"name" validate:"required"`
+		Temp  TempValue `json:"temp" validate:"min=-10,max=40"`
+		Count int       `json:"count" validate:"min=1,max=3"`
+		Mode  string    `json:"mode" validate:"oneof=auto day night"`
+		At    string    `json:"at" validate:"regex=^([01][0-9]|2[0-3]):[0-5][0-9]$"`
+	}
+	for _, c := range []struct {
+		desc string
+		body body
+		code ErrorCode // "" if valid
+	}{
+		{"valid", body{Name: "x", Temp: "21.5", Count: 2, Mode: "day", At: "06:30"}, ""},
+		{"only required", body{Name: "x"}, ""},
+		{"required missing", body{Temp: "21.5"}, CodeRequired},
+		{"at min", body{Name: "x", Temp: "-10", Count: 1}, ""},
+		{"at max", body{Name: "x", Temp: "40", Count: 3}, ""},
+		{"string below min", body{Name: "x", Temp: "-10.5"}, CodeOutOfRange},
+		{"string above max", body{Name: "x", Temp: "40.5"}, CodeOutOfRange},
+		{"int above max", body{Name: "x", Count: 4}, CodeOutOfRange},
+		{"int below min", body{Name: "x", Count: -1}, CodeOutOfRange},
+		{"oneof", body{Name: "x", Mode: "auto"}, ""},
+		{"not oneof", body{Name: "x", Mode: "dusk"}, CodeNotAllowed},
+		{"regex", body{Name: "x", At: "23:59"}, ""},
+		{"not regex", body{Name: "x", At: "24:00"}, CodeBadFormat},
+		{"not regex either", body{Name: "x", At: "6:30"}, CodeBadFormat},
+	} {
+		err := validate(&c.body)
+		if c.code == "" {
+			if err != nil {
+				t.Errorf("%s: %v", c.desc, err)
+			}
+			continue
+		}
+		if err == nil || codeOf(err, "") != c.code {
+			t.Errorf("%s: %v, want %s", c.desc, err, c.code)
+		}
+	}
+
+	// Errors name the field by its JSON name.
+	if err := validate(&body{Name: "x", Mode: "dusk"}); err == nil || !strings.HasPrefix(err.Error(), "mode: ") {
+		t.Errorf("error %q doesn't start with the field's JSON name", err)
+	}
+	if err := validate(&struct {
+		N int `validate:"between=1 3"`
+	}{N: 2}); err == nil {
+		t.Error("an unknown rule passed")
+	}
+}
+
+func TestValidateBodies(t *testing.T) {
+	h := newHarness(t, 20)
+	for _, c := range []struct {
+		path string
+		body interface{}
+		code ErrorCode
+	}{
+		{"/rest/v1/temp", map[string]string{"nighttemp": "18", "daytemp": "22"}, CodeRequired},
+		{"/rest/v1/temp", map[string]string{"nighttemp": "18", "daytemp": "22", "thereshold": "11"}, CodeOutOfRange},
+		{"/rest/v1/mode", map[string]string{"mode": "dusk", "heating": "auto"}, CodeNotAllowed},
+		{"/rest/v1/mode", map[string]string{"mode": "auto"}, CodeRequired},
+	} {
+		if resp, body := h.Do(http.MethodPut, c.path, c.body); resp.StatusCode != http.StatusBadRequest || !jsonHasCode(body, c.code) {
+			t.Errorf("PUT %s %v: %s %s, want 400 %s", c.path, c.body, resp.Status, body, c.code)
+		}
+	}
+	if resp, body := h.Do(http.MethodPut, "/rest/v1/mode", map[string]string{"mode": "day", "heating": "off"}); resp.StatusCode != http.StatusOK {
+		t.Errorf("PUT /rest/v1/mode with a valid body: %s %s", resp.Status, body)
+	}
+}
+
+func TestEmptyBody(t *testing.T) {
+	h := newHarness(t, 20)
+	setAdminKey("admin-key")
+	for _, c := range []struct{ method, path string }{
+		{http.MethodPut, "/rest/v1/time"},
+		{http.MethodPut, "/rest/v1/temp"},
+		{http.MethodPut, "/rest/v1/mode"},
+		{http.MethodPut, "/rest/v1/mode/force"},
+		{http.MethodPut, "/rest/v1/temp/pid"},
+		{http.MethodPut, "/rest/v1/temp/alerts"},
+		{http.MethodPost, "/rest/v1/temp/compare"},
+		{http.MethodPut, "/rest/v1/device"},
+		{http.MethodPost, "/rest/v1/device/claim"},
+		{http.MethodPost, "/rest/v1/sensors/bedroom/reading"},
+		{http.MethodPost, "/rest/v1/import"},
+		{http.MethodPost, "/rest/v1"},
+		{http.MethodPut, "/rest/v1/1"},
+		{http.MethodPut, "/admin/features"},
+		{http.MethodPost, "/admin/sessions"},
+	} {
+		for _, body := range []string{"", " \n\t"} {
+			code, resp := send(t, h.Server, c.method, c.path, body, "Authorization", "Bearer admin-key")
+			if code != http.StatusBadRequest || !jsonHasCode(resp, CodeBodyRequired) {
+				t.Errorf("%s %s with the body %q: %d %s, want 400 %s", c.method, c.path, body, code, resp, CodeBodyRequired)
+			}
+		}
+	}
+
+	// A merge patch is checked the same way.
+	code, resp := send(t, h.Server, http.MethodPatch, "/rest/v1/config", " ", "Content-Type", "application/merge-patch+json")
+	if code != http.StatusBadRequest || !jsonHasCode(resp, CodeBodyRequired) {
+		t.Errorf("PATCH /rest/v1/config with a blank body: %d %s, want 400 %s", code, resp, CodeBodyRequired)
+	}
+}
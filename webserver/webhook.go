@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var webhookURL = flag.String("webhook-url", "", "URL events like temperature alerts are POSTed to as JSON; none are sent if empty")
+
+var (
+	webhookBreakerFailures = flag.Int("webhook-breaker-failures", 5, "Webhook deliveries failing in a row before deliveries are skipped for -webhook-breaker-cooldown")
+	webhookBreakerCooldown = flag.Duration("webhook-breaker-cooldown", 30*time.Second, "How long webhook deliveries are skipped once the webhook keeps failing, before one is tried again")
+)
+
+var webhookDedupe = flag.Duration("webhook-dedupe", 0, "Window after a webhook event in which further events about the same thing are held back, only the last being sent at its end, and only if it changes the state sent; 0 sends every event")
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// webhookBreaker stops deliveries to a webhook that keeps failing, so they
+// don't pile up. Events skipped while it's open are only counted.
+var webhookBreaker = NewBreaker(5, 30*time.Second)
+
+// postWebhook sends e to -webhook-url, going through the -webhook-dedupe
+// window if e is about a state.
+func postWebhook(e Event) {
+	if *webhookURL == "" {
+		return
+	}
+	if s, ok := e.Data.(webhookState); ok && *webhookDedupe > 0 {
+		subject, state := s.webhookState()
+		webhookStates.offer(e.Type+":"+subject, state, e, *webhookDedupe, sendWebhook)
+		return
+	}
+	sendWebhook(e)
+}
+
+// sendWebhook sends e to -webhook-url in the background, unless the
+// breaker is open. Failures are only logged; the webhook is a
+// notification, nothing waits for it.
+func sendWebhook(e Event) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("Webhook %s: %s", e.Type, err)
+		return
+	}
+	if err := webhookBreaker.Allow(clock.Now()); err != nil {
+		return
+	}
+	go func() {
+		resp, err := webhookClient.Post(*webhookURL, "application/json", bytes.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				err = fmt.Errorf("status %s", resp.Status)
+			}
+		}
+		webhookBreaker.Done(err, clock.Now())
+		if err != nil {
+			log.Printf("Webhook %s: %s", e.Type, err)
+		}
+	}()
+}
+
+// webhookState is implemented by the event data that reports the state of
+// something, like an alert firing or the heating going on, so that a
+// flapping state doesn't flood the webhook.
+type webhookState interface {
+	webhookState() (subject, state string)
+}
+
+func (e AlertEvent) webhookState() (string, string) { return e.Kind, e.State }
+func (t Transition) webhookState() (string, string) { return t.Type, t.To }
+
+// stateDedupe holds back events about a subject that come within a window
+// of the last one sent, and sends the last of them once the window is
+// over, if it changes the state sent. Flapping on, off and on again within
+// the window sends the first on only.
+type stateDedupe struct {
+	mu       sync.Mutex
+	subjects map[string]*dedupeSubject
+}
+
+type dedupeSubject struct {
+	sent    string    // state last sent
+	until   time.Time // end of the window of the last one sent
+	pending *Event    // last event held back, if any
+	state   string    // its state
+}
+
+var webhookStates = &stateDedupe{subjects: map[string]*dedupeSubject{}}
+
+// offer sends e about subject, in state, right away if it's outside the
+// window of the last one sent, or holds it back until the end of the
+// window.
+func (d *stateDedupe) offer(subject, state string, e Event, window time.Duration, send func(Event)) {
+	now := clock.Now()
+	d.mu.Lock()
+	s, ok := d.subjects[subject]
+	if ok && now.Before(s.until) {
+		if s.pending == nil {
+			time.AfterFunc(s.until.Sub(now), func() { d.flush(subject, window, send) })
+		}
+		s.pending, s.state = &e, state
+		d.mu.Unlock()
+		return
+	}
+	d.subjects[subject] = &dedupeSubject{sent: state, until: now.Add(window)}
+	d.mu.Unlock()
+	send(e)
+}
+
+// flush sends the event held back about subject at the end of a window,
+// unless it's in the state last sent. Either way a new window starts, so
+// a state that keeps flapping stays held back.
+func (d *stateDedupe) flush(subject string, window time.Duration, send func(Event)) {
+	d.mu.Lock()
+	s := d.subjects[subject]
+	e, state := s.pending, s.state
+	s.pending, s.until = nil, clock.Now().Add(window)
+	if state == s.sent {
+		d.mu.Unlock()
+		return
+	}
+	s.sent = state
+	d.mu.Unlock()
+	send(*e)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector gathers the events a stateDedupe sends, safe for the timers
+// sending from their own goroutines.
+type collector struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (c *collector) send(e Event) {
+	c.mu.Lock()
+	c.events = append(c.events, e)
+	c.mu.Unlock()
+}
+
+func (c *collector) states() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var list []string
+	for _, e := range c.events {
+		list = append(list, e.Data.(Transition).To)
+	}
+	return list
+}
+
+func TestWebhookDedupe(t *testing.T) {
+	const window = 100 * time.Millisecond
+	offer := func(d *stateDedupe, c *collector, state string) {
+		tr := Transition{Type: "heating", To: state}
+		subject, state := tr.webhookState()
+		d.offer("heating:"+subject, state, Event{Type: "heating", Data: tr}, window, c.send)
+	}
+
+	t.Run("flapping", func(t *testing.T) {
+		d, c := &stateDedupe{subjects: map[string]*dedupeSubject{}}, &collector{}
+		offer(d, c, "on")
+		offer(d, c, "off")
+		offer(d, c, "on")
+		time.Sleep(3 * window)
+		if got := c.states(); len(got) != 1 || got[0] != "on" {
+			t.Errorf("on, off, on within the window sent %v, want [on]", got)
+		}
+	})
+
+	t.Run("change", func(t *testing.T) {
+		d, c := &stateDedupe{subjects: map[string]*dedupeSubject{}}, &collector{}
+		offer(d, c, "on")
+		offer(d, c, "off")
+		if got := c.states(); len(got) != 1 {
+			t.Fatalf("sent %v before the window ended, want [on]", got)
+		}
+		time.Sleep(3 * window)
+		if got := c.states(); len(got) != 2 || got[1] != "off" {
+			t.Errorf("on then off sent %v, want [on off]", got)
+		}
+	})
+}
@@ -14,7 +14,7 @@
 // Client requests:
 // ----------------
 // $ curl http://localhost:3333/
-// root.
+// {"service":"thermoman","version":"dev","endpoints":[{"method":"GET","path":"/"},...]}
 //
 // $ curl http://localhost:3333/articles
 // [{"id":"1","title":"Hi"},{"id":"2","title":"sup"}]
@@ -28,7 +28,7 @@
 // $ curl http://localhost:3333/articles/1
 // "Not Found"
 //
-// $ curl -X POST -d '{"id":"will-be-omitted","title":"awesomeness"}' http://localhost:3333/articles
+// $ curl -X POST -H 'Content-Type: application/json' -d '{"id":"will-be-omitted","title":"awesomeness"}' http://localhost:3333/articles
 // {"id":"97","title":"awesomeness"}
 //
 // $ curl http://localhost:3333/articles/97
@@ -44,120 +44,526 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"math/rand"
+	"log"
+	"log/slog"
+	"mime"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/docgen"
 	"github.com/go-chi/render"
+	"go.opentelemetry.io/otel/attribute"
+
+	stack "bangkokguy.dev/middleware"
 )
 
 var routes = flag.Bool("routes", false, "Generate router documentation")
+var dbPath = flag.String("db", "", "SQLite database to store data in, kept in memory if empty")
+var drainDelay = flag.Duration("drain-delay", 0, "How long to keep serving after /readyz starts failing on shutdown")
+var streamDrain = flag.Duration("stream-drain", 2*time.Second, "How long live clients get on shutdown to receive the shutdown event and close their streams")
+var shutdownTimeout = flag.Duration("shutdown-timeout", 5*time.Second, "How long in-flight requests get to finish on shutdown before their connections are closed")
 
 func main() {
 	flag.Parse()
+	if err := loadEnv(); err != nil {
+		log.Fatal(err)
+	}
+	if *configFile != "" {
+		if err := loadConfigFile(*configFile); err != nil {
+			log.Fatalf("-config: %s", err)
+		}
+	}
+	level, err := parseLevel(*logLevel)
+	if err != nil {
+		log.Fatalf("-log-level: %s", err)
+	}
+	slog.SetLogLoggerLevel(level)
+	if *logBuffer < 0 {
+		log.Fatal("-log-buffer can't be negative")
+	}
+	if _, err := parseUnit(*defaultUnit); err != nil {
+		log.Fatalf("-default-unit: %s", err)
+	}
+	if err := checkPrecision(*defaultPrecision); err != nil {
+		log.Fatalf("-default-precision: %s", err)
+	}
+	loc, err := parseTimezone(*timezone)
+	if err != nil {
+		log.Fatalf("-timezone: %s", err)
+	}
+	zone.Store(loc)
+	if (*tlsCert == "") != (*tlsKey == "") {
+		log.Fatal("-tls-cert and -tls-key go together")
+	}
+	if err := checkSetpointFlags(); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkSafetyFlags(); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkJSONKeys(); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkSensorFlags(); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkDeviceFlags(); err != nil {
+		log.Fatal(err)
+	}
+	rl, err := newRelay(*relayKind)
+	if err != nil {
+		log.Fatalf("-relay: %s", err)
+	}
+	relay = rl
+	id, err := newIdentifier(*identifierKind)
+	if err != nil {
+		log.Fatalf("-identifier: %s", err)
+	}
+	identifier = id
+	sensor = newRandomSensor(*sensorSeed)
+	tempHistory.SetRetention(*historyRetention)
+	modeHistory.SetRetention(*historyRetention)
+	if *webhookBreakerFailures < 1 {
+		log.Fatal("-webhook-breaker-failures must be at least 1")
+	}
+	tempWrites = NewDebouncer(*tempDebounce)
+	if *maxSubscribers < 0 {
+		log.Fatal("-max-subscribers can't be negative")
+	}
+	broker.SetLimit(*maxSubscribers)
+	if *shutdownTimeout <= 0 {
+		log.Fatal("-shutdown-timeout must be positive")
+	}
+	webhookBreaker = NewBreaker(*webhookBreakerFailures, *webhookBreakerCooldown)
+	if *authSecret != "" {
+		sessions = NewSessions([]byte(*authSecret))
+	}
+	if err := checkSigningKey(); err != nil {
+		log.Fatal(err)
+	}
+	if *location != "" {
+		c, err := parseCoords(*location)
+		if err != nil {
+			log.Fatalf("-location: %s", err)
+		}
+		coords = c
+	}
+	if *dbPath != "" {
+		s, err := NewSQLiteStore(*dbPath)
+		if err != nil {
+			log.Fatalf("-db: %s", err)
+		}
+		defer s.Close()
+		store = s
+	}
+	noteState(sourceDefault)
+	if err := restoreState(); err != nil {
+		log.Fatalf("-state-file: %s", err)
+	}
+	noteState(sourceState)
+	if err := validateConfig(); err != nil {
+		log.Fatalf("Config check: %s", err)
+	}
+	noteState(sourceDefault) // the invalid settings replaced
+	if *scheduleFile != "" {
+		if err := loadSchedule(*scheduleFile); err != nil {
+			log.Fatalf("-schedule-file: %s", err)
+		}
+	}
+	if *adminKeyFile != "" {
+		if _, err := loadAdminKey(*adminKeyFile); err != nil {
+			log.Fatalf("-admin-key-file: %s", err)
+		}
+	}
+	setAdminKey(*adminKey)
 
+	r, err := NewRouter()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Passing -routes to the program will generate docs for the above
+	// router definition. See the `routes.json` file in this folder for
+	// the output.
+	if *routes {
+		// fmt.Println(docgen.JSONRoutesDoc(r))
+		fmt.Println(docgen.MarkdownRoutesDoc(r, docgen.MarkdownOpts{
+			ProjectPath: "github.com/go-chi/chi/v5",
+			Intro:       "Welcome to the chi/_examples/rest generated docs.",
+		}))
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := setupTracing(ctx)
+	if err != nil {
+		log.Fatalf("tracing: %s", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// Log in the background only once nothing is left to fail on startup:
+	// log.Fatal exits before the lines queued ahead of it are written.
+	if *logBuffer > 0 {
+		// slog's default handler logs through the log package, so this
+		// takes both off the request path.
+		logs = newAsyncWriter(log.Writer(), *logBuffer)
+		log.SetOutput(logs)
+	}
+
+	// Have a reading before the first request or evaluation needs one.
+	pollSensor(context.Background(), clock.Now())
+
+	group := NewRunGroup(ctx)
+	group.Add(func(ctx context.Context) error {
+		return runPoller(ctx, *pollInterval)
+	})
+	group.Add(func(ctx context.Context) error {
+		return serve(ctx, newServer(*listenAddr, r))
+	})
+	group.Add(func(ctx context.Context) error {
+		return runEvaluator(ctx, *evalInterval)
+	})
+	group.Add(func(ctx context.Context) error {
+		return sweepSessions(ctx, time.Minute)
+	})
+	if *scheduleFile != "" {
+		group.Add(func(ctx context.Context) error {
+			return watchSchedule(ctx, *scheduleFile)
+		})
+	}
+	if *adminKeyFile != "" {
+		group.Add(func(ctx context.Context) error {
+			return watchAdminKey(ctx, *adminKeyFile)
+		})
+	}
+	group.Add(func(ctx context.Context) error {
+		return handleReloads(ctx, *configFile)
+	})
+	err = group.Run()
+	// Apply a debounced write still waiting for its window.
+	tempWrites.Flush()
+	if logs != nil {
+		if err := logs.Close(2 * time.Second); err != nil {
+			log.Print(err)
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// NewRouter sets up the routes and middlewares of the server, working
+// against the package's store, sensor and clock, configured by the flags.
+func NewRouter() (chi.Router, error) {
 	r := chi.NewRouter()
+	if err := RegisterRoutes(r, Deps{}); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// RegisterRoutes sets up the routes and middlewares of the server on r,
+// which mustn't have any routes yet; to serve them under a prefix of
+// another router, register them on a new one and mount that. The handlers
+// work against deps, so routers with different ones can be served side by
+// side; the background workers main runs, and the histories, alerts and
+// live clients they feed, stay the package's own.
+func RegisterRoutes(r chi.Router, deps Deps) error {
+	cfg := deps.Config
+	if cfg == nil {
+		cfg = flagRouterConfig()
+	}
+	sample, err := parseLogSample(cfg.LogSample)
+	if err != nil {
+		return err
+	}
+	levels, err := parseAccessLogLevels(cfg.AccessLogLevels)
+	if err != nil {
+		return err
+	}
 
-	r.Use(middleware.RequestID)
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
+	stackCfg := stack.Config{LogSample: sample, LogFormatter: &accessLogFormatter{levels: levels}, PanicResponse: renderPanic}
+	if cfg.PanicAlerts {
+		stackCfg.OnPanic = newPanicAlerter(cfg.PanicAlertWindow, sendPanicAlert).OnPanic
+	}
+	r.Use(provideDeps(deps))
+	r.Use(stack.DefaultStack(stackCfg)...)
+	r.Use(newCORS())
+	r.Use(LimitURL(cfg.MaxURLLength, cfg.MaxQueryLength))
+	r.Use(Trace)
+	r.Use(RequestLog)
+	r.Use(CountRequests)
+	r.Use(ServerTiming)
+	r.Use(Authenticate)
+	r.Use(RequireOwner)
+	r.Use(Shed(cfg.MaxInFlight))
+	if cfg.Chaos {
+		r.Use(Chaos(cfg.ChaosErrorRate, cfg.ChaosMaxDelay, cfg.ChaosSeed))
+	}
+	r.Use(DecompressBody(cfg.MaxDecompressed))
+	r.Use(StripSlashes)
 	r.Use(middleware.URLFormat)
 	r.Use(render.SetContentType(render.ContentTypeJSON))
 
+	// The descriptor lists the routes, so it's filled in once they're all
+	// registered.
+	descriptor := &ServiceDescriptor{}
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("root."))
+		render.Render(w, r, descriptor)
 	})
 
 	r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("pong"))
 	})
 
+	r.Get("/livez", Livez)
+	r.Get("/readyz", Readyz)
+	r.Get("/metrics", GetMetrics)
+	r.Post("/rpc", ServeRPC) // JSON-RPC 2.0, see rpc.go
+
 	r.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
 		panic("test")
 	})
 
-	// $ curl http://localhost:3333/	// root.
+	// $ curl http://localhost:3333/	// {"service":"thermoman","version":"dev","endpoints":[{"method":"GET","path":"/"},...]}
 	// $ curl http://localhost:3333/articles	// [{"id":"1","title":"Hi"},{"id":"2","title":"sup"}]
+	// $ curl http://localhost:3333/articles?expand=user	// {"items":[{"id":"1","title":"Hi",...,"user":{"id":100,"name":"Peter","role":"collaborator"}},...],...}
 	// $ curl http://localhost:3333/articles/1	// {"id":"1","title":"Hi"}
 	// $ curl -X DELETE http://localhost:3333/articles/1	// {"id":"1","title":"Hi"}
 	// $ curl http://localhost:3333/articles/1	// "Not Found"
-	// $ curl -X POST -d '{"id":"will-be-omitted","title":"awesomeness"}' http://localhost:3333/articles	// {"id":"97","title":"awesomeness"}
+	// $ curl -X POST -H 'Content-Type: application/json' -d '{"id":"will-be-omitted","title":"awesomeness"}' http://localhost:3333/articles	// {"id":"97","title":"awesomeness"}
 	// $ curl http://localhost:3333/articles/97	// {"id":"97","title":"awesomeness"}
 	// $ curl http://localhost:3333/articles	// [{"id":"2","title":"sup"},{"id":"97","title":"awesomeness"}]
 
 	/* $ curl http://bangkokguy.ddns.net/rest/v1/device // {"ip":"192.168.1.1","ssid":"MrWhite","passphrase":"f","currenttime":"08:00"}
-	 * $ curl http://bangkokguy.ddns.net/rest/v1/temp // {"currenttemp":"24.00","nighttemp":"18.00","daytemp":"24.00","thereshold":"0.20"}
+	 * $ curl http://bangkokguy.ddns.net/rest/v1/temp // {"currenttemp":"24.0","nighttemp":"18.00","daytemp":"24.00","thereshold":"0.20"}
+	 * $ curl http://bangkokguy.ddns.net/rest/v1/temp?precision=2 // {"currenttemp":"23.97",...}
+	 * $ curl http://bangkokguy.ddns.net/rest/v1/temp?fresh=true // read from the sensor now, X-Reading: fresh, or cached if it was too slow
 	 * $ curl http://bangkokguy.ddns.net/rest/v1/time // {"day":"06:00","night":"22:00"}
 	 * $ curl http://bangkokguy.ddns.net/rest/v1/mode // {"mode":{"night|day" "auto|manual"},"heating":{"off":"manual|auto"}}
-	 * $ curl -X PUT -d '{"day":"24.00","night":"18.00"}' http://bangkokguy.ddns.net/rest/v1/temp
-	 * $ curl -X PUT -d '{"day":"06:00","night":"22:00"}' http://bangkokguy.ddns.net/rest/v1/time
-	 * $ curl -X PUT -d '{"ssid":"Faszom","passphrase":"f"}' http://bangkokguy.ddns.net/rest/v1/device
-	 * $ curl -X PUT -d '{"mode":"night|day|auto","heating":"on|off|auto"}' http://bangkokguy.ddns.net/rest/v1/mode
+	 * $ curl http://bangkokguy.ddns.net/rest/v1/mode/history?type=mode&since=2021-12-01T06:00:00Z&limit=10 // {"items":[{"at":"...","type":"mode","from":"night","to":"day","reason":"schedule"}],"next_cursor":""}
+	 * $ curl -X PUT -H 'Content-Type: application/json' -d '{"day":"24.00","night":"18.00"}' http://bangkokguy.ddns.net/rest/v1/temp
+	 * $ curl -X PUT -H 'Content-Type: application/json' -d '{"day":"06:00","night":"22:00"}' http://bangkokguy.ddns.net/rest/v1/time
+	 * $ curl -X PUT -H 'Content-Type: application/json' -d '{"ssid":"Faszom","passphrase":"f"}' http://bangkokguy.ddns.net/rest/v1/device
+	 * $ curl -X PUT -H 'Content-Type: application/json' -d '{"mode":"night|day|auto","heating":"on|off|auto"}' http://bangkokguy.ddns.net/rest/v1/mode
+	 * $ curl -X OPTIONS http://bangkokguy.ddns.net/rest/v1/temp // {"methods":["GET","HEAD","PUT","OPTIONS"],"request":[...],"response":[...]}
+	 * $ curl -I http://bangkokguy.ddns.net/rest/v1/temp // headers only, with Content-Length and ETag
 	 */
 
 	// RESTy routes for "articles" resource
 	r.Route("/rest/v1",
 		func(r chi.Router) {
-			r.With(paginate).Get("/", ListArticles)
-			r.Post("/", CreateArticle)  // POST /articles
-			r.Get("/device", GetDevice) // GET /rest/v1/device
+			r.Use(RequireContentType("application/json", "application/merge-patch+json"))
+
+			r.With(paginate).Get(pattern(RouteArticles), ListArticles)
+			r.Post("/", CreateArticle)         // POST /articles
+			r.Get("/search", SearchArticles)   // GET /articles/search?q=hi
+			r.Get("/articles", StreamArticles) // GET /articles.ndjson
+			r.Options("/", Describe([]string{"GET", "POST"}, &ArticleRequest{}, &ArticleResponse{}))
+			r.Get("/device", GetDevice)                          // GET /rest/v1/device
+			r.With(Feature("wifi")).Put("/device", UpdateDevice) // PUT /rest/v1/device
+			r.Options("/device", Describe([]string{"GET", "PUT"}, &Device{}, &Device{}))
+			r.With(Feature("wifi")).Get("/device/scan", GetDeviceScan)                // GET /rest/v1/device/scan
+			r.Get("/device/relay", GetRelay)                                          // GET /rest/v1/device/relay
+			r.Get("/device/firmware", GetFirmware)                                    // GET /rest/v1/device/firmware
+			r.With(Feature("firmware")).Post("/device/firmware/check", CheckFirmware) // POST /rest/v1/device/firmware/check
+			r.With(Feature("identify")).Post("/device/identify", Identify)            // POST /rest/v1/device/identify
+			r.Post("/device/claim", ClaimDevice)                                      // POST /rest/v1/device/claim
+			r.With(Feature("reset")).Post("/device/reset", ResetDevice)               // POST /rest/v1/device/reset
 
 			r.Route("/time",
 				func(r chi.Router) {
-					r.Get("/", GetTime)    // GET /temp
-					r.Put("/", UpdateTime) // PUT /temp
+					r.With(ETag).Get("/", GetTime)    // GET /time
+					r.With(ETag).Head("/", GetTime)   // HEAD /time
+					r.Put("/", UpdateTime)            // PUT /time
+					r.Post("/validate", ValidateTime) // POST /time/validate
+					r.Options("/", Describe([]string{"GET", "HEAD", "PUT"}, &Times{}, &Times{}))
+					r.Get("/sunrise", GetSunrise) // GET /time/sunrise
 				},
 			)
 			r.Route("/temp",
 				func(r chi.Router) {
-					r.Get("/", GetTemp)    // GET /temp
-					r.Put("/", UpdateTemp) // PUT /temp
+					r.With(ETag, Collapse).Get("/", GetTemp)  // GET /temp
+					r.With(ETag, Collapse).Head("/", GetTemp) // HEAD /temp
+					r.Put("/", UpdateTemp)                    // PUT /temp
+					r.Options("/", Describe([]string{"GET", "HEAD", "PUT"}, &Temp{}, &Temp{}))
+					r.Get("/setpoint-ramp", GetSetpointRamp)          // GET /temp/setpoint-ramp
+					r.Get("/preheat", GetPreheat)                     // GET /temp/preheat
+					r.Get("/upcoming", GetUpcoming)                   // GET /temp/upcoming?hours=24
+					r.Post("/compare", CompareSettings)               // POST /temp/compare
+					r.Get("/histogram", GetTempHistogram)             // GET /temp/histogram?buckets=0.5
+					r.Get("/deadband", GetDeadband)                   // GET /temp/deadband?window=1h
+					r.Get("/alerts", GetAlerts)                       // GET /temp/alerts
+					r.Get("/pid", GetPID)                             // GET /temp/pid
+					r.Put("/pid", UpdatePID)                          // PUT /temp/pid
+					r.Put("/alerts", UpdateAlerts)                    // PUT /temp/alerts
+					r.Get(pattern(RouteAlertEvents), ListAlertEvents) // GET /temp/alerts/events
 				},
 			)
 			r.Route("/mode",
 				func(r chi.Router) {
-					r.Get("/", GetMode)    // GET /temp
-					r.Put("/", UpdateMode) // PUT /temp
+					r.With(ETag).Get("/", GetMode)  // GET /mode
+					r.With(ETag).Head("/", GetMode) // HEAD /mode
+					r.Put("/", UpdateMode)          // PUT /mode
+					r.Options("/", Describe([]string{"GET", "HEAD", "PUT"}, &ModesIn{}, &Modes{}))
+					r.Get(pattern(RouteModeHistory), GetModeHistory) // GET /mode/history
+					r.Put("/force", ForceMode)                       // PUT /mode/force
+					r.Delete("/force", UnforceMode)                  // DELETE /mode/force
+				},
+			)
+			r.With(Collapse).Get("/energy", GetEnergy)                  // GET /rest/v1/energy?window=today
+			r.Get("/stats/heating", GetHeatingStats)                    // GET /rest/v1/stats/heating?window=week
+			r.Get("/errors", ListErrors)                                // GET /rest/v1/errors
+			r.Get("/export", ExportSettings)                            // GET /rest/v1/export
+			r.Post("/import", ImportSettings)                           // POST /rest/v1/import
+			r.Get("/diagnostics/history", GetHistoryDiagnostics)        // GET /rest/v1/diagnostics/history
+			r.Get("/diagnostics/webhook", GetWebhookDiagnostics)        // GET /rest/v1/diagnostics/webhook
+			r.Get("/diagnostics/identify", GetIdentifyDiagnostics)      // GET /rest/v1/diagnostics/identify
+			r.Get("/diagnostics/subscribers", GetSubscriberDiagnostics) // GET /rest/v1/diagnostics/subscribers
+			r.Get("/ws", ServeWS)                                       // GET /rest/v1/ws, upgrades to a WebSocket
+			r.Get("/events", ServeEvents)                               // GET /rest/v1/events, server-sent events
+			r.With(Collapse).Get("/status", GetStatus)                  // GET /rest/v1/status, or status.txt for key=value lines
+
+			r.Route("/sensors",
+				func(r chi.Router) {
+					r.Get("/", ListSensors)                                // GET /sensors
+					r.Post(pattern(RouteSensorReading), PostSensorReading) // POST /sensors/bedroom/reading
+				},
+			)
+
+			r.Route("/config",
+				func(r chi.Router) {
+					r.Get("/", GetConfig)
+					r.With(RequireContentType("application/merge-patch+json")).Patch("/", PatchConfig)
+					r.Options("/", Describe([]string{"GET", "PATCH"}, &Config{}, &Config{}))
 				},
 			)
-			r.Route("/{articleID}",
+			r.Route(pattern(RouteArticle),
 				func(r chi.Router) {
-					r.Use(ArticleCtx)            // Load the *Article on the request context
-					r.Get("/", GetArticle)       // GET /articles/123
-					r.Put("/", UpdateArticle)    // PUT /articles/123
-					r.Delete("/", DeleteArticle) // DELETE /articles/123
+					r.Options("/", Describe([]string{"GET", "PUT", "DELETE"}, &ArticleRequest{}, &ArticleResponse{}))
+					r.Post(pattern(RouteArticleRestore), RestoreArticle) // POST /articles/123/restore
+					r.Group(func(r chi.Router) {
+						r.Use(ArticleCtx)            // Load the *Article on the request context
+						r.Get("/", GetArticle)       // GET /articles/123
+						r.Put("/", UpdateArticle)    // PUT /articles/123
+						r.Delete("/", DeleteArticle) // DELETE /articles/123
+					})
 				},
 			)
 
-			// GET /articles/whats-up
-			r.With(ArticleCtx).Get("/{articleSlug:[a-z-]+}", GetArticle)
+			// GET /articles/whats-up, or a 301 from its old slug if it's been retitled
+			r.With(ArticleCtx).Get(pattern(RouteArticleBySlug), GetArticle)
 		})
 
 	// Mount the admin sub-router, which btw is the same as:
 	// r.Route("/admin", func(r chi.Router) { admin routes here })
 	r.Mount("/admin", adminRouter())
 
-	// Passing -routes to the program will generate docs for the above
-	// router definition. See the `routes.json` file in this folder for
-	// the output.
-	if *routes {
-		// fmt.Println(docgen.JSONRoutesDoc(r))
-		fmt.Println(docgen.MarkdownRoutesDoc(r, docgen.MarkdownOpts{
-			ProjectPath: "github.com/go-chi/chi/v5",
-			Intro:       "Welcome to the chi/_examples/rest generated docs.",
-		}))
-		return
+	d, err := NewServiceDescriptor(r)
+	if err != nil {
+		return err
 	}
+	*descriptor = *d
 
-	http.ListenAndServe(":3333", r)
+	return nil
 }
 
+// serve runs srv until ctx is done, then sends live clients a shutdown
+// event and gives in-flight requests -shutdown-timeout to finish, after
+// which it closes the connections still open. The server reports ready
+// while it is listening and not yet draining.
+func serve(ctx context.Context, srv *http.Server) error {
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+	errc := make(chan error, 1)
+	go func() {
+		if *tlsCert != "" {
+			errc <- srv.ServeTLS(ln, *tlsCert, *tlsKey)
+			return
+		}
+		errc <- srv.Serve(ln)
+	}()
+	ready.Store(true)
+	log.Printf("Server listening on %s", srv.Addr)
+
+	select {
+	case err := <-errc:
+		ready.Store(false)
+		return err
+	case <-ctx.Done():
+	}
+	ready.Store(false)
+	log.Print("Server stopping")
+
+	// Keep serving while load balancers notice /readyz failing.
+	time.Sleep(*drainDelay)
+
+	// Tell live clients and let their streams end before the server stops
+	// waiting for requests.
+	streamCtx, cancelStreams := context.WithTimeout(context.Background(), *streamDrain)
+	defer cancelStreams()
+	if err := broker.Shutdown(streamCtx, Event{Type: "shutdown", Source: "server"}); err != nil {
+		log.Printf("Live clients not all gone after -stream-drain: %s", err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			return err
+		}
+		// A client that won't let go, like a stuck stream, mustn't keep
+		// the process from exiting.
+		n := conns.open()
+		if err := srv.Close(); err != nil {
+			return err
+		}
+		log.Printf("Requests not all done after -shutdown-timeout, closed %d connections", n)
+	}
+	if err := <-errc; err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// ListArticles lists a page of articles, with their users only with
+// ?expand=user.
 func ListArticles(w http.ResponseWriter, r *http.Request) {
-	if err := render.RenderList(w, r, NewArticleListResponse(articles)); err != nil {
+	expand, err := parseExpand(r, "user")
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	includeDeleted := false
+	if s := r.URL.Query().Get("include_deleted"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			render.Render(w, r, ErrInvalidRequest(errors.New("include_deleted must be true or false")))
+			return
+		}
+		includeDeleted = b
+	}
+
+	articles, err := storeOf(r.Context()).ListArticles(includeDeleted)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	start, end, info := pageOf(r).bounds(len(articles))
+	setLinkHeader(w, r, info)
+	if err := render.Render(w, r, NewArticlePageResponse(articles[start:end], info, expand["user"])); err != nil {
 		render.Render(w, r, ErrRender(err))
 		return
 	}
@@ -172,9 +578,12 @@ func ArticleCtx(next http.Handler) http.Handler {
 		var err error
 
 		if articleID := chi.URLParam(r, "articleID"); articleID != "" {
-			article, err = dbGetArticle(articleID)
+			article, err = storeOf(r.Context()).GetArticle(articleID)
 		} else if articleSlug := chi.URLParam(r, "articleSlug"); articleSlug != "" {
-			article, err = dbGetArticleBySlug(articleSlug)
+			article, err = storeOf(r.Context()).GetArticleBySlug(articleSlug)
+			if errors.Is(err, errArticleNotFound) && redirectSlugAlias(w, r, articleSlug) {
+				return
+			}
 		} else {
 			render.Render(w, r, ErrNotFound)
 			return
@@ -189,115 +598,174 @@ func ArticleCtx(next http.Handler) http.Handler {
 	})
 }
 
-// SearchArticles searches the Articles data for a matching article.
-// It's just a stub, but you get the idea.
+// SearchArticles searches the Articles data for articles matching ?q= in
+// their title or slug, best match first. With ?debug=true every result
+// carries its score.
 func SearchArticles(w http.ResponseWriter, r *http.Request) {
-	//render.RenderList(w, r, NewArticleListResponse(articles))
-	render.Render(w, r,
-		&ErrResponse{
-			HTTPStatusCode: 400,
-			StatusText:     "Invalid request.",
-		})
+	q := r.URL.Query().Get("q")
+	if strings.TrimSpace(q) == "" {
+		render.Render(w, r, ErrInvalidRequest(errors.New("missing search query q")))
+		return
+	}
+	debug, _ := strconv.ParseBool(r.URL.Query().Get("debug"))
+
+	articles, err := storeOf(r.Context()).ListArticles(false)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	list := []render.Renderer{}
+	for _, match := range rankArticles(articles, q) {
+		result := &SearchResult{ArticleResponse: NewArticleResponse(match.article)}
+		if debug {
+			result.Score = match.score
+		}
+		list = append(list, result)
+	}
+	if err := render.RenderList(w, r, list); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
 }
 
 // CreateArticle persists the posted Article and returns it
 // back to the client as an acknowledgement.
 func CreateArticle(w http.ResponseWriter, r *http.Request) {
 	data := &ArticleRequest{}
-	if err := render.Bind(r, data); err != nil {
+	if err := decode(r, data); err != nil {
 		render.Render(w, r, ErrInvalidRequest(err))
 		return
 	}
 
 	article := data.Article
-	dbNewArticle(article)
+	if article.Slug == "" {
+		slug, err := uniqueSlug(r.Context(), slugify(article.Title), "")
+		if err != nil {
+			render.Render(w, r, ErrInternal(err))
+			return
+		}
+		article.Slug = slug
+	} else if err := checkSlug(r.Context(), article.Slug, ""); err != nil {
+		render.Render(w, r, ErrConflict(err))
+		return
+	}
+	if _, err := storeOf(r.Context()).NewArticle(article); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
 
+	if location, err := URLFor(RouteArticle, map[string]string{"articleID": article.ID}); err == nil {
+		w.Header().Set("Location", location)
+	}
 	render.Status(r, http.StatusCreated)
 	render.Render(w, r, NewArticleResponse(article))
 }
 
-var IP string = "192.168.1.123"
-var SSID = "MrWhite"
-var PassPhrase = "F"
-var CurrentTime = time.Now().Format("2006-01-02 15:04:05")
-var CurrentTemp = "22.00"
-var NightTemp = "18.00"
-var DayTemp = "24.00"
-var Thereshold = "0.20"
-var Day string = "06:00"
-var Night string = "22:00"
-var CurrentStateOfMode = "night"
-var CurrentStateOfHeating = "off"
-var Mode [2]string = [2]string{CurrentStateOfMode, "auto"}
-var Heating [2]string = [2]string{CurrentStateOfHeating, "auto"}
-
 /**-----------------------------------------------------------------------------------
  * get device
  * ==========
  * $ curl http://bangkokguy.ddns.net/rest/v1/device // {"ip":"192.168.1.1","ssid":"MrWhite","passphrase":"f","currenttime":"08:00"}
  *------------------------------------------------------------------------------------*/
 type Device struct {
-	IP          string `json:"ip"`
-	SSID        string `json:"ssid"`
-	PassPhrase  string `json:"passphrase"`
-	CurrentTime string `json:"currenttime"`
+	IP          string     `json:"ip"`
+	SSID        string     `json:"ssid"`
+	PassPhrase  string     `json:"passphrase"`
+	CurrentTime string     `json:"currenttime"`
+	Claim       string     `json:"claim"` // "claimed" or "unclaimed", see claim.go
+	ClaimedAt   *time.Time `json:"claimed_at,omitempty"`
 }
 
 func GetDevice(w http.ResponseWriter, r *http.Request) {
-	if err := render.Render(w, r, dbGetDevice()); err != nil {
+	device, err := storeOf(r.Context()).GetDevice()
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if err := render.Render(w, r, device); err != nil {
 		render.Render(w, r, ErrRender(err))
 		return
 	}
 }
 func (rd *Device) Render(w http.ResponseWriter, r *http.Request) error {
 	// Pre-processing before a response is marshalled and sent across the wire
+	rd.Claim, rd.ClaimedAt = claimState()
 	return nil
 }
-func dbGetDevice() *Device {
-	var u Device
-	u.CurrentTime = CurrentTime //"20:00:00"
-	u.IP = IP                   //"192.168.1.123"
-	u.PassPhrase = PassPhrase   //"F"
-	u.SSID = SSID               //"MrWhite"
-	return &u
-	//return nil, errors.New("user not found.")
-}
 
 /**-----------------------------------------------------------------------------------
 * get temp
 * ==========
 * $ curl http://bangkokguy.ddns.net/rest/v1/temp //
-		  {"currenttemp":"24.00","nighttemp":"18.00","daytemp":"24.00","thereshold":"0.20"}
+		  {"currenttemp":"24.0","nighttemp":"18.00","daytemp":"24.00","thereshold":"0.20"}
 *------------------------------------------------------------------------------------*/
 type Temp struct {
-	CurrentTemp string `json:"currenttemp"`
-	NightTemp   string `json:"nighttemp"`
-	DayTemp     string `json:"daytemp"`
-	Thereshold  string `json:"thereshold"`
+	CurrentTemp TempValue `json:"currenttemp"`
+	NightTemp   TempValue `json:"nighttemp" validate:"required,min=-10,max=40"`
+	DayTemp     TempValue `json:"daytemp" validate:"required,min=-10,max=40"`
+	Thereshold  TempValue `json:"thereshold" validate:"required,min=0,max=10"`
+	Unit        string    `json:"unit,omitempty"` // "C" or "F", defaults to -default-unit
 }
 
 const min = -10
 const max = 40
 
 func GetTemp(w http.ResponseWriter, r *http.Request) {
-	if err := render.Render(w, r, dbGetTemp()); err != nil {
+	if _, err := requestUnit(r); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if _, err := requestPrecision(r); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if err := refreshReading(w, r); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	temp, err := loadTemp(r.Context())
+	if errors.Is(err, errNoReading) || errors.Is(err, errStaleReading) {
+		render.Render(w, r, ErrUnavailable(err))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if err := render.Render(w, r, temp); err != nil {
 		render.Render(w, r, ErrRender(err))
 		return
 	}
 }
 func (rd *Temp) Render(w http.ResponseWriter, r *http.Request) error {
 	// Pre-processing before a response is marshalled and sent across the wire
+	unit, err := requestUnit(r)
+	if err != nil {
+		return err
+	}
+	precision, err := requestPrecision(r)
+	if err != nil {
+		return err
+	}
+	rd.convert("C", unit) // stored in Celsius
+	rd.CurrentTemp = roundTemp(rd.CurrentTemp, precision)
 	return nil
 }
-func dbGetTemp() *Temp {
-	var t Temp
-	//t.CurrentTemp = CurrentTemp //"20.00"
-	t.CurrentTemp = fmt.Sprintf("%f", min+rand.Float64()*(max-min))
-	t.DayTemp = DayTemp       //"23.00"
-	t.NightTemp = NightTemp   //"18.00"
-	t.Thereshold = Thereshold //"0.20"
-	return &t
-	//return nil, errors.New("user not found.")
+// loadTemp returns the temperature settings along with the current
+// reading.
+func loadTemp(ctx context.Context) (*Temp, error) {
+	t, err := storeOf(ctx).GetTemp()
+	if err != nil {
+		return nil, err
+	}
+	stop := startPhase(ctx, "sensor")
+	reading, err := latestReading(ctx)
+	stop()
+	if err != nil {
+		return nil, err
+	}
+	t.CurrentTemp = TempValue(fmt.Sprintf("%f", reading.Temp))
+	return t, nil
 }
 
 /**-----------------------------------------------------------------------------------
@@ -306,12 +774,17 @@ func dbGetTemp() *Temp {
  * $ curl http://bangkokguy.ddns.net/rest/v1/time // {"day":"06:00","night":"22:00"}
  *------------------------------------------------------------------------------------*/
 type Times struct {
-	Day   string `json:"day"`
-	Night string `json:"night"`
+	Day   string `json:"day" validate:"required"`   // HH:MM, or sunrise/sunset with an optional offset like "+30m"
+	Night string `json:"night" validate:"required"` // HH:MM, or sunrise/sunset with an optional offset like "-1h"
 }
 
 func GetTime(w http.ResponseWriter, r *http.Request) {
-	if err := render.Render(w, r, dbGetTime()); err != nil {
+	times, err := storeOf(r.Context()).GetTime()
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if err := render.Render(w, r, times); err != nil {
 		render.Render(w, r, ErrRender(err))
 		return
 	}
@@ -320,12 +793,6 @@ func (rd *Times) Render(w http.ResponseWriter, r *http.Request) error {
 	// Pre-processing before a response is marshalled and sent across the wire
 	return nil
 }
-func dbGetTime() *Times {
-	var t Times
-	t.Day = Day
-	t.Night = Night
-	return &t
-}
 
 /**-----------------------------------------------------------------------------------
  * get mode
@@ -334,30 +801,49 @@ func dbGetTime() *Times {
  *------------------------------------------------------------------------------------*/
 
 type Modes struct {
-	Mode    [2]string `json:["night", "day"] ["auto", "manual"]`
-	Heating [2]string `json:["on", "off] ["auto", "manual"]`
+	Mode    [2]string `json:"Mode"`             // ["night", "day"] ["auto", "manual"]
+	Heating [2]string `json:"Heating"`          // ["on", "off"] ["auto", "manual"]
+	Forced  string    `json:"forced,omitempty"` // "day" or "night" while pinned with PUT /mode/force
+
+	SafetyCutoff    bool `json:"safety_cutoff,omitempty"`    // the heating is held off by -safety-cutoff
+	FrostProtection bool `json:"frost_protection,omitempty"` // the heating is held on by -frost-protection
+
+	PIDOutput *float64 `json:"pid_output,omitempty"` // 0-100%, while PUT /temp/pid control decides the heating
 }
 type ModesIn struct {
-	Mode    string `json:"mode"`
-	Heating string `json:"heting"`
+	Mode    string `json:"mode" validate:"required,oneof=auto day night"`
+	Heating string `json:"heating" validate:"required,oneof=auto on off"`
 }
 
 func GetMode(w http.ResponseWriter, r *http.Request) {
-	if err := render.Render(w, r, dbGetMode()); err != nil {
+	modes, err := loadMode(r.Context())
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if err := render.Render(w, r, modes); err != nil {
 		render.Render(w, r, ErrRender(err))
 		return
 	}
 }
+// loadMode returns the mode settings along with what overrides them.
+func loadMode(ctx context.Context) (*Modes, error) {
+	modes, err := storeOf(ctx).GetMode()
+	if err != nil {
+		return nil, err
+	}
+	modes.Forced = forcedPhase()
+	modes.SafetyCutoff = safetyActive()
+	modes.FrostProtection = frostActive()
+	if output, ok := pidOutput(); ok {
+		modes.PIDOutput = &output
+	}
+	return modes, nil
+}
 func (rd *Modes) Render(w http.ResponseWriter, r *http.Request) error {
 	// Pre-processing before a response is marshalled and sent across the wire
 	return nil
 }
-func dbGetMode() *Modes {
-	var m Modes
-	m.Mode = Mode
-	m.Heating = Heating
-	return &m
-}
 
 /**-----------------------------------------------------------------------------------
  * put time
@@ -367,98 +853,176 @@ func dbGetMode() *Modes {
 func UpdateTime(w http.ResponseWriter, r *http.Request) {
 	var time *Times
 
+	if activeSchedule() != nil {
+		render.Render(w, r, ErrConflict(errScheduled))
+		return
+	}
+
 	data := &Times{}
-	if err := render.Bind(r, data); err != nil {
+	if err := decode(r, data); err != nil {
 		render.Render(w, r, ErrInvalidRequest(err))
 		return
 	}
 	time = data
-	dbUpdateTime(time)
+	if _, err := storeOf(r.Context()).UpdateTime(time); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	persistState(r.Context())
+	publishConfig(r.Context(), requestSource(r))
 
-	render.Render(w, r, dbGetTime())
+	GetTime(w, r)
 }
 func (a *Times) Bind(r *http.Request) error {
-	if a == nil {
-		return errors.New("missing required Article fields")
-	}
 	a.Day = strings.ToLower(a.Day) // as an example, we down-case
+	a.Night = strings.ToLower(a.Night)
+	if problems := a.check(); len(problems) > 0 {
+		return problems[0]
+	}
 	return nil
 }
-func dbUpdateTime(time *Times) (*Times, error) {
-	Day = time.Day
-	Night = time.Night
-	return time, nil
-}
 
 /**-----------------------------------------------------------------------------------
 * put temp
 * ========
-* $ curl -X PUT -d '{"daytemp":"24.00","nighttemp":"18.00", "thereshold":"0.20"}'
+* $ curl -X PUT -H 'Content-Type: application/json' -d '{"daytemp":"24.00","nighttemp":"18.00", "thereshold":"0.20"}'
+		http://bangkokguy.ddns.net/rest/v1/temp
+* $ curl -X PUT -H 'Content-Type: application/json' -d '{"daytemp":24,"nighttemp":18,"thereshold":0.2}'
 		http://bangkokguy.ddns.net/rest/v1/temp
 *------------------------------------------------------------------------------------*/
 func UpdateTemp(w http.ResponseWriter, r *http.Request) {
 	var temp *Temp
 
+	if activeSchedule() != nil {
+		render.Render(w, r, ErrConflict(errScheduled))
+		return
+	}
+	if _, err := requestPrecision(r); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
 	data := &Temp{}
-	if err := render.Bind(r, data); err != nil {
+	if err := decode(r, data); err != nil {
 		render.Render(w, r, ErrInvalidRequest(err))
 		return
 	}
 	temp = data
 	println(temp.CurrentTemp + temp.DayTemp + temp.NightTemp + temp.Thereshold)
-	dbUpdateTemp(temp)
+	traceAttributes(r,
+		attribute.String("thermostat.daytemp", string(temp.DayTemp)),
+		attribute.String("thermostat.nighttemp", string(temp.NightTemp)),
+		attribute.String("thermostat.thereshold", string(temp.Thereshold)))
+
+	if *tempDebounce > 0 {
+		// Only the last of a burst is applied, after the window, with the
+		// logger and the store of its request.
+		ctx, l, source := context.WithoutCancel(r.Context()), LoggerFrom(r.Context()), requestSource(r)
+		pending := *temp // rendering converts temp to the request's unit
+		tempWrites.Submit(func() {
+			if err := commitTemp(ctx, l, &pending, source); err != nil {
+				l.Error("applying the temperatures failed", "error", err)
+			}
+		})
+		render.Status(r, http.StatusAccepted)
+		render.Render(w, r, temp)
+		return
+	}
 
-	render.Render(w, r, dbGetTemp())
+	if err := commitTemp(r.Context(), LoggerFrom(r.Context()), temp, requestSource(r)); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	GetTemp(w, r)
 }
-func (a *Temp) Bind(r *http.Request) error {
-	if a == nil {
-		return errors.New("missing required Temp fields")
+
+// commitTemp stores new temperature settings, and sees them through: it
+// logs the changes, ramps the targets, persists the state and tells live
+// clients.
+func commitTemp(ctx context.Context, l *slog.Logger, temp *Temp, source string) error {
+	old, err := storeOf(ctx).GetTemp()
+	if err != nil {
+		return err
 	}
-	//a.Day = strings.ToLower(a.Day) // as an example, we down-case
+	if _, err := storeOf(ctx).UpdateTemp(temp); err != nil {
+		return err
+	}
+	logTempChanges(l, old, temp)
+	rampTargets(old, temp, clockOf(ctx).Now())
+	persistState(ctx)
+	publishConfig(ctx, source)
 	return nil
 }
-func dbUpdateTemp(temp *Temp) (*Temp, error) {
-	DayTemp = temp.DayTemp
-	NightTemp = temp.NightTemp
-	Thereshold = temp.Thereshold
-	return temp, nil
+// logTempChanges logs each target that an update changed.
+func logTempChanges(l *slog.Logger, old, new *Temp) {
+	for _, c := range []struct {
+		name     string
+		old, new TempValue
+	}{
+		{"daytemp", old.DayTemp, new.DayTemp},
+		{"nighttemp", old.NightTemp, new.NightTemp},
+		{"thereshold", old.Thereshold, new.Thereshold},
+	} {
+		if c.old != c.new {
+			l.Info(c.name+" changed", "from", string(c.old), "to", string(c.new))
+		}
+	}
+}
+
+func (a *Temp) Bind(r *http.Request) error {
+	//a.Day = strings.ToLower(a.Day) // as an example, we down-case
+	unit, err := requestUnit(r)
+	if a.Unit != "" {
+		unit, err = parseUnit(a.Unit)
+	}
+	if err != nil {
+		return err
+	}
+	a.convert(unit, "C") // stored in Celsius
+	return checkSetpoints(a)
 }
 
 /**-----------------------------------------------------------------------------------
  * put mode
  * ========
- * $ curl -X PUT -d '{"mode":"night|day|auto","heating":"on|off|auto"}' http://bangkokguy.ddns.net/rest/v1/mode
+ * $ curl -X PUT -H 'Content-Type: application/json' -d '{"mode":"night|day|auto","heating":"on|off|auto"}' http://bangkokguy.ddns.net/rest/v1/mode
  *------------------------------------------------------------------------------------*/
 func UpdateMode(w http.ResponseWriter, r *http.Request) {
 	var mode *ModesIn
 
 	data := &ModesIn{}
-	if err := render.Bind(r, data); err != nil {
+	if err := decode(r, data); err != nil {
 		render.Render(w, r, ErrInvalidRequest(err))
 		return
 	}
 	mode = data
-	dbUpdateMode(mode)
+	traceAttributes(r,
+		attribute.String("thermostat.mode", mode.Mode),
+		attribute.String("thermostat.heating", mode.Heating))
+	if err := commitMode(r.Context(), LoggerFrom(r.Context()), mode, requestSource(r)); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
 
-	render.Render(w, r, dbGetMode())
+	GetMode(w, r)
 }
 
-func (a *ModesIn) Bind(r *http.Request) error {
-	if a == nil {
-		return errors.New("missing required Mode fields")
+// commitMode stores a new mode setting and sees it through: it logs it,
+// persists the state, re-evaluates the heating right away and tells live
+// clients.
+func commitMode(ctx context.Context, l *slog.Logger, mode *ModesIn, source string) error {
+	if _, err := storeOf(ctx).UpdateMode(mode); err != nil {
+		return err
 	}
+	l.Info("mode set", "mode", mode.Mode, "heating", mode.Heating)
+	persistState(ctx)
+	evaluate(ctx, clockOf(ctx).Now())
+	publishConfig(ctx, source)
 	return nil
 }
-func dbUpdateMode(mode *ModesIn) (*ModesIn, error) {
-	Heating[1] = mode.Heating
-	Heating[0] = CurrentStateOfHeating
-	Mode[1] = mode.Mode
-	Mode[0] = CurrentStateOfMode
 
-	//var Mode [2]string = [2]string{"night", "auto"}
-	//var Heating [2]string = [2]string{"off", "auto"}
-
-	return mode, nil
+func (a *ModesIn) Bind(r *http.Request) error {
+	return nil
 }
 
 /*------------------------------------------------------------------------------------*/
@@ -481,20 +1045,60 @@ func GetArticle(w http.ResponseWriter, r *http.Request) {
 // UpdateArticle updates an existing Article in our persistent store.
 func UpdateArticle(w http.ResponseWriter, r *http.Request) {
 	println("UpdateArticle")
-	article := r.Context().Value("article").(*Article)
+	stored := r.Context().Value("article").(*Article)
+	oldTitle, oldSlug := stored.Title, stored.Slug
 
-	data := &ArticleRequest{Article: article}
-	if err := render.Bind(r, data); err != nil {
+	// Decode into a fresh payload, so fields the client left out are told
+	// apart from the stored ones.
+	data := &ArticleRequest{}
+	if err := decode(r, data); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	if data.Version == 0 {
+		render.Render(w, r, ErrInvalidRequest(errVersionRequired))
+		return
+	}
+	article := *stored
+	article.Version = data.Version
+	if data.Title != "" {
+		article.Title = data.Title
+	}
+	if data.UserID != 0 {
+		article.UserID = data.UserID
+	}
+	switch {
+	case data.Slug != "":
+		article.Slug = data.Slug
+		if err := checkSlug(r.Context(), article.Slug, article.ID); err != nil {
+			render.Render(w, r, ErrConflict(err))
+			return
+		}
+	case article.Title != oldTitle:
+		slug, err := uniqueSlug(r.Context(), slugify(article.Title), article.ID)
+		if err != nil {
+			render.Render(w, r, ErrInternal(err))
+			return
+		}
+		article.Slug = slug
+	}
+	if _, err := storeOf(r.Context()).UpdateArticle(article.ID, &article); err != nil {
+		if errors.Is(err, errVersionConflict) {
+			render.Render(w, r, ErrConflict(err))
+			return
+		}
 		render.Render(w, r, ErrInvalidRequest(err))
 		return
 	}
-	article = data.Article
-	dbUpdateArticle(article.ID, article)
 
-	render.Render(w, r, NewArticleResponse(article))
+	if article.Slug != oldSlug {
+		addSlugAlias(oldSlug, article.ID, clockOf(r.Context()).Now())
+	}
+	render.Render(w, r, NewArticleResponse(&article))
 }
 
-// DeleteArticle removes an existing Article from our persistent store.
+// DeleteArticle soft-deletes an existing Article from our persistent store,
+// it can be brought back with RestoreArticle.
 func DeleteArticle(w http.ResponseWriter, r *http.Request) {
 	var err error
 
@@ -503,7 +1107,7 @@ func DeleteArticle(w http.ResponseWriter, r *http.Request) {
 	// middleware. The worst case, the recoverer middleware will save us.
 	article := r.Context().Value("article").(*Article)
 
-	article, err = dbRemoveArticle(article.ID)
+	article, err = storeOf(r.Context()).RemoveArticle(article.ID)
 	if err != nil {
 		render.Render(w, r, ErrInvalidRequest(err))
 		return
@@ -512,6 +1116,28 @@ func DeleteArticle(w http.ResponseWriter, r *http.Request) {
 	render.Render(w, r, NewArticleResponse(article))
 }
 
+// RestoreArticle undoes the soft delete of an Article.
+func RestoreArticle(w http.ResponseWriter, r *http.Request) {
+	article, err := storeOf(r.Context()).RestoreArticle(chi.URLParam(r, "articleID"))
+	if err != nil {
+		render.Render(w, r, ErrNotFound)
+		return
+	}
+
+	render.Render(w, r, NewArticleResponse(article))
+}
+
+// PurgeArticle permanently removes an Article, deleted or not.
+func PurgeArticle(w http.ResponseWriter, r *http.Request) {
+	article, err := storeOf(r.Context()).PurgeArticle(chi.URLParam(r, "articleID"))
+	if err != nil {
+		render.Render(w, r, ErrNotFound)
+		return
+	}
+
+	render.Render(w, r, NewArticleResponse(article))
+}
+
 // A completely separate router for administrator routes
 func adminRouter() chi.Router {
 	r := chi.NewRouter()
@@ -525,6 +1151,15 @@ func adminRouter() chi.Router {
 	r.Get("/users/{userId}", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(fmt.Sprintf("admin: view user id %v", chi.URLParam(r, "userId"))))
 	})
+	r.Delete("/articles/{articleID}", PurgeArticle)
+	r.Get("/config", GetEffectiveConfig) // GET /admin/config
+	r.Get("/features", GetFeatures)      // GET /admin/features
+	r.Put("/features", UpdateFeatures)   // PUT /admin/features
+	r.Route("/sessions", func(r chi.Router) {
+		r.Get("/", ListSessions)                            // GET /admin/sessions
+		r.Post("/", IssueSession)                           // POST /admin/sessions
+		r.Delete(pattern(RouteAdminSession), RevokeSession) // DELETE /admin/sessions/9f2c...
+	})
 	return r
 }
 
@@ -540,21 +1175,39 @@ func AdminOnly(next http.Handler) http.Handler {
 	})
 }
 
-// paginate is a stub, but very possible to implement middleware logic
-// to handle the request params for handling a paginated request.
-func paginate(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// just a stub.. some ideas are to look at URL query params for something like
-		// the page number, or the limit, and send a query cursor down the chain
-		println("paginate inside " + r.URL.Host + " the end")
-		next.ServeHTTP(w, r)
-	})
+// RequireContentType middleware rejects request bodies sent with any other
+// media type than the given ones with a 415, so that render.Bind doesn't
+// mis-parse form or text bodies. Parameters like "; charset=utf-8" are
+// ignored.
+func RequireContentType(types ...string) func(next http.Handler) http.Handler {
+	allowed := map[string]bool{}
+	for _, t := range types {
+		allowed[strings.ToLower(t)] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || !allowed[mediaType] {
+				render.Render(w, r, ErrUnsupportedMediaType(
+					fmt.Errorf("Content-Type must be one of %s", strings.Join(types, ", "))))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 // This is entirely optional, but I wanted to demonstrate how you could easily
 // add your own logic to the render.Respond method.
 func init() {
 	render.Respond = func(w http.ResponseWriter, r *http.Request, v interface{}) {
+		defer startPhase(r.Context(), "render")()
+
 		if err, ok := v.(error); ok {
 
 			// We set a default error status response code if one hasn't been set.
@@ -572,6 +1225,14 @@ func init() {
 			return
 		}
 
+		if *jsonKeys != "default" {
+			v = renameKeys(v, *jsonKeys)
+		}
+		// DefaultResponder answers in JSON unless XML was asked for.
+		if render.GetAcceptedContentType(r) != render.ContentTypeXML {
+			writeJSON(w, r, v)
+			return
+		}
 		render.DefaultResponder(w, r, v)
 	}
 }
@@ -653,32 +1314,64 @@ type ArticleResponse struct {
 	// We add an additional field to the response here.. such as this
 	// elapsed computed property
 	Elapsed int64 `json:"elapsed"`
+
+	withUser bool // Render looks the user up
 }
 
 func NewArticleResponse(article *Article) *ArticleResponse {
-	resp := &ArticleResponse{Article: article}
-
-	if resp.User == nil {
-		if user, _ := dbGetUser(resp.UserID); user != nil {
-			resp.User = NewUserPayloadResponse(user)
-		}
-	}
+	return newArticleResponse(article, true)
+}
 
-	return resp
+// newArticleResponse leaves the user out, without looking it up, unless
+// withUser is set.
+func newArticleResponse(article *Article, withUser bool) *ArticleResponse {
+	return &ArticleResponse{Article: article, withUser: withUser}
 }
 
 func (rd *ArticleResponse) Render(w http.ResponseWriter, r *http.Request) error {
 	// Pre-processing before a response is marshalled and sent across the wire
 	rd.Elapsed = 10
+
+	// The user is looked up in the store the request is served from.
+	if rd.withUser && rd.User == nil {
+		if user, _ := storeOf(r.Context()).GetUser(rd.UserID); user != nil {
+			rd.User = NewUserPayloadResponse(user)
+		}
+	}
 	return nil
 }
 
-func NewArticleListResponse(articles []*Article) []render.Renderer {
-	list := []render.Renderer{}
+// ArticlePageResponse is a page of articles along with its pagination
+// metadata.
+type ArticlePageResponse struct {
+	Items []*ArticleResponse `json:"items"`
+	PageInfo
+}
+
+// NewArticlePageResponse builds a page of articles, with their users if
+// withUser is set.
+func NewArticlePageResponse(articles []*Article, info PageInfo, withUser bool) *ArticlePageResponse {
+	resp := &ArticlePageResponse{Items: []*ArticleResponse{}, PageInfo: info}
 	for _, article := range articles {
-		list = append(list, NewArticleResponse(article))
+		resp.Items = append(resp.Items, newArticleResponse(article, withUser))
 	}
-	return list
+	return resp
+}
+
+func (rd *ArticlePageResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	// render.Render doesn't walk slices, so render the items and their
+	// users the way render.RenderList would.
+	for _, item := range rd.Items {
+		if err := item.Render(w, r); err != nil {
+			return err
+		}
+		if item.User != nil {
+			if err := item.User.Render(w, r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // NOTE: as a thought, the request and response payloads for an Article could be the
@@ -700,9 +1393,9 @@ type ErrResponse struct {
 	Err            error `json:"-"` // low-level runtime error
 	HTTPStatusCode int   `json:"-"` // http response status code
 
-	StatusText string `json:"status"`          // user-level status message
-	AppCode    int64  `json:"code,omitempty"`  // application-specific error code
-	ErrorText  string `json:"error,omitempty"` // application-level error message, for debugging
+	StatusText string    `json:"status"`          // user-level status message
+	AppCode    ErrorCode `json:"code,omitempty"`  // application-specific error code, see catalog.go
+	ErrorText  string    `json:"error,omitempty"` // application-level error message, for debugging
 }
 
 func (e *ErrResponse) Render(w http.ResponseWriter, r *http.Request) error {
@@ -710,25 +1403,53 @@ func (e *ErrResponse) Render(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
+// ErrInvalidRequest renders a 400, or a 413 if err is a body over
+// -max-body-bytes.
 func ErrInvalidRequest(err error) render.Renderer {
-	return &ErrResponse{
-		Err:            err,
-		HTTPStatusCode: 400,
-		StatusText:     "Invalid request.",
-		ErrorText:      err.Error(),
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return newErrResponse(CodeTooLarge, err)
 	}
+	return newErrResponse(CodeInvalidRequest, err)
+}
+
+func ErrUnauthorized(err error) render.Renderer {
+	return newErrResponse(CodeUnauthorized, err)
+}
+
+func ErrTooLarge(err error) render.Renderer {
+	return newErrResponse(CodeTooLarge, err)
+}
+
+func ErrURITooLong(err error) render.Renderer {
+	return newErrResponse(CodeURITooLong, err)
 }
 
 func ErrRender(err error) render.Renderer {
-	return &ErrResponse{
-		Err:            err,
-		HTTPStatusCode: 422,
-		StatusText:     "Error rendering response.",
-		ErrorText:      err.Error(),
-	}
+	return newErrResponse(CodeRender, err)
+}
+
+func ErrConflict(err error) render.Renderer {
+	return newErrResponse(CodeConflict, err)
+}
+
+func ErrUnsupportedMediaType(err error) render.Renderer {
+	return newErrResponse(CodeUnsupportedMediaType, err)
+}
+
+func ErrInternal(err error) render.Renderer {
+	return newErrResponse(CodeInternal, err)
 }
 
-var ErrNotFound = &ErrResponse{HTTPStatusCode: 404, StatusText: "Resource not found."}
+func ErrUnavailable(err error) render.Renderer {
+	return newErrResponse(CodeUnavailable, err)
+}
+
+func ErrTimeout(err error) render.Renderer {
+	return newErrResponse(CodeTimeout, err)
+}
+
+var ErrNotFound = &ErrResponse{HTTPStatusCode: 404, StatusText: "Resource not found.", AppCode: CodeNotFound}
 
 //--
 // Data model objects and persistence mocks:
@@ -747,72 +1468,11 @@ type Article struct {
 	UserID int64  `json:"user_id"` // the author
 	Title  string `json:"title"`
 	Slug   string `json:"slug"`
-}
-
-// Article fixture data
-var articles = []*Article{
-	{ID: "1", UserID: 100, Title: "Hi", Slug: "hi"},
-	{ID: "2", UserID: 200, Title: "sup", Slug: "sup"},
-	{ID: "3", UserID: 300, Title: "alo", Slug: "alo"},
-	{ID: "4", UserID: 400, Title: "bonjour", Slug: "bonjour"},
-	{ID: "5", UserID: 500, Title: "whats up", Slug: "whats-up"},
-}
-
-// User fixture data
-var users = []*User{
-	{ID: 100, Name: "Peter"},
-	{ID: 200, Name: "Julia"},
-}
-
-func dbNewArticle(article *Article) (string, error) {
-	article.ID = fmt.Sprintf("%d", rand.Intn(100)+10)
-	articles = append(articles, article)
-	return article.ID, nil
-}
-
-func dbGetArticle(id string) (*Article, error) {
-	for _, a := range articles {
-		if a.ID == id {
-			return a, nil
-		}
-	}
-	return nil, errors.New("article not found")
-}
-
-func dbGetArticleBySlug(slug string) (*Article, error) {
-	for _, a := range articles {
-		if a.Slug == slug {
-			return a, nil
-		}
-	}
-	return nil, errors.New("article not found")
-}
-
-func dbUpdateArticle(id string, article *Article) (*Article, error) {
-	for i, a := range articles {
-		if a.ID == id {
-			articles[i] = article
-			return article, nil
-		}
-	}
-	return nil, errors.New("article not found")
-}
-
-func dbRemoveArticle(id string) (*Article, error) {
-	for i, a := range articles {
-		if a.ID == id {
-			articles = append((articles)[:i], (articles)[i+1:]...)
-			return a, nil
-		}
-	}
-	return nil, errors.New("article not found")
-}
-
-func dbGetUser(id int64) (*User, error) {
-	for _, u := range users {
-		if u.ID == id {
-			return u, nil
-		}
-	}
-	return nil, errors.New("user not found")
+	// Version is bumped on every update. An update has to send the version
+	// it was based on: without one it's rejected with 400 Bad Request, with
+	// a stale one with 409 Conflict.
+	Version int `json:"version"`
+	// DeletedAt is set once the article is deleted; it stays restorable
+	// until purged by an admin.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
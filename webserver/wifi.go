@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+/**-----------------------------------------------------------------------------------
+ * get device scan
+ * ===============
+ * $ curl http://bangkokguy.ddns.net/rest/v1/device/scan // [{"ssid":"MrWhite","signal":72,"secured":true}]
+ *------------------------------------------------------------------------------------*/
+
+var scanTimeout = flag.Duration("scan-timeout", 10*time.Second, "How long a WiFi scan may take before giving up")
+
+// Network is a WiFi network found by a scan.
+type Network struct {
+	SSID    string `json:"ssid"`
+	Signal  int    `json:"signal"` // 0-100
+	Secured bool   `json:"secured"`
+}
+
+func (n *Network) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+// WiFiScanner lists the WiFi networks in range.
+type WiFiScanner interface {
+	Scan(ctx context.Context) ([]Network, error)
+}
+
+var wifiScanner WiFiScanner = NewCachedScanner(nmcliScanner{}, 30*time.Second)
+
+func GetDeviceScan(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), *scanTimeout)
+	defer cancel()
+
+	networks, err := wifiScanner.Scan(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		render.Render(w, r, ErrTimeout(errors.New("WiFi scan timed out")))
+		return
+	}
+	if err != nil {
+		render.Render(w, r, ErrUnavailable(err))
+		return
+	}
+
+	list := []render.Renderer{}
+	for i := range networks {
+		list = append(list, &networks[i])
+	}
+	if err := render.RenderList(w, r, list); err != nil {
+		render.Render(w, r, ErrRender(err))
+		return
+	}
+}
+
+// CachedScanner remembers the last successful scan for a while so polling
+// clients don't hammer the WiFi hardware, and stops waiting on a scan that
+// hangs once the caller's context is done.
+type CachedScanner struct {
+	scanner WiFiScanner
+	ttl     time.Duration
+
+	mu       sync.Mutex
+	networks []Network
+	scanned  time.Time
+}
+
+func NewCachedScanner(scanner WiFiScanner, ttl time.Duration) *CachedScanner {
+	return &CachedScanner{scanner: scanner, ttl: ttl}
+}
+
+func (c *CachedScanner) Scan(ctx context.Context) ([]Network, error) {
+	c.mu.Lock()
+	if c.networks != nil && time.Since(c.scanned) < c.ttl {
+		networks := c.networks
+		c.mu.Unlock()
+		return networks, nil
+	}
+	c.mu.Unlock()
+
+	type result struct {
+		networks []Network
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		networks, err := c.scanner.Scan(ctx)
+		done <- result{networks, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-done:
+		if res.err != nil {
+			return nil, res.err
+		}
+		c.mu.Lock()
+		c.networks, c.scanned = res.networks, time.Now()
+		c.mu.Unlock()
+		return res.networks, nil
+	}
+}
+
+// nmcliScanner scans using NetworkManager's command line client.
+type nmcliScanner struct{}
+
+func (nmcliScanner) Scan(ctx context.Context) ([]Network, error) {
+	out, err := exec.CommandContext(ctx, "nmcli", "-t", "-f", "SSID,SIGNAL,SECURITY", "dev", "wifi", "list").Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return parseNmcli(string(out)), nil
+}
+
+// parseNmcli parses nmcli's terse output, where fields are separated by
+// ':' and literal colons are escaped as '\:'.
+func parseNmcli(out string) []Network {
+	networks := []Network{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := splitEscaped(line, ':')
+		if len(fields) != 3 || fields[0] == "" {
+			continue
+		}
+		signal, _ := strconv.Atoi(fields[1])
+		security := strings.TrimSpace(fields[2])
+		networks = append(networks, Network{
+			SSID:    fields[0],
+			Signal:  signal,
+			Secured: security != "" && security != "--",
+		})
+	}
+	return networks
+}
+
+func splitEscaped(s string, sep byte) []string {
+	var fields []string
+	var field strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			field.WriteByte(s[i])
+		case s[i] == sep:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(s[i])
+		}
+	}
+	return append(fields, field.String())
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubScanner returns networks, or with hang set, blocks until the scan is
+// canceled.
+type stubScanner struct {
+	networks []Network
+	hang     bool
+	scans    atomic.Int32
+}
+
+func (s *stubScanner) Scan(ctx context.Context) ([]Network, error) {
+	s.scans.Add(1)
+	if s.hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return s.networks, nil
+}
+
+func withScanner(t *testing.T, s WiFiScanner) {
+	t.Helper()
+	old := wifiScanner
+	wifiScanner = s
+	t.Cleanup(func() { wifiScanner = old })
+}
+
+func TestDeviceScan(t *testing.T) {
+	h := newHarness(t, 20)
+	stub := &stubScanner{networks: []Network{{SSID: "MrWhite", Signal: 72, Secured: true}, {SSID: "Cafe", Signal: 40}}}
+	withScanner(t, NewCachedScanner(stub, time.Minute))
+
+	for i := 0; i < 2; i++ {
+		resp, body := h.Do(http.MethodGet, "/rest/v1/device/scan", nil)
+		var got []Network
+		if err := json.Unmarshal(body, &got); err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET /rest/v1/device/scan: %s %s", resp.Status, body)
+		}
+		if !reflect.DeepEqual(got, stub.networks) {
+			t.Errorf("GET /rest/v1/device/scan: %+v, want %+v", got, stub.networks)
+		}
+	}
+	if n := stub.scans.Load(); n != 1 {
+		t.Errorf("scanned %d times for two requests, want the second served from the cache", n)
+	}
+}
+
+func TestDeviceScanTimeout(t *testing.T) {
+	h := newHarness(t, 20)
+	withFlag(t, scanTimeout, 20*time.Millisecond)
+	withScanner(t, NewCachedScanner(&stubScanner{hang: true}, time.Minute))
+
+	resp, body := h.Do(http.MethodGet, "/rest/v1/device/scan", nil)
+	if resp.StatusCode != http.StatusGatewayTimeout || !jsonHasCode(body, CodeTimeout) {
+		t.Errorf("GET /rest/v1/device/scan with a hanging scan: %s %s, want 504 %s", resp.Status, body, CodeTimeout)
+	}
+}
+
+func TestParseNmcli(t *testing.T) {
+	out := "MrWhite:72:WPA2\nCafe\\:Bar:40:--\n:30:WPA2\nOpen:55:\n\n"
+	want := []Network{
+		{SSID: "MrWhite", Signal: 72, Secured: true},
+		{SSID: "Cafe:Bar", Signal: 40},
+		{SSID: "Open", Signal: 55},
+	}
+	if got := parseNmcli(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNmcli = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/gorilla/websocket"
+)
+
+/**-----------------------------------------------------------------------------------
+ * live updates
+ * ============
+ * $ websocat ws://bangkokguy.ddns.net/rest/v1/ws
+ *   {"type":"telemetry","source":"evaluator","data":{"at":"...","currenttemp":21.3,"mode":"day","heating":"on"}}
+ *   > {"type":"patch_config","data":{"daytemp":22}}
+ *   {"type":"config_changed","source":"ws:vm/abc-000003","data":{"day":"06:00",...,"daytemp":"22.00",...}}
+ *------------------------------------------------------------------------------------*/
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPingInterval = 30 * time.Second
+	wsReadTimeout  = wsPingInterval + 10*time.Second
+)
+
+var upgrader = websocket.Upgrader{}
+
+// wsMessage is a message a client sends over the socket. The only type is
+// "patch_config", with a JSON Merge Patch of the config as data.
+type wsMessage struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// ServeWS pushes the broker's events (telemetry and config changes) to the
+// client, and lets it edit the config. Its changes are broadcast to every
+// client, itself included; errors only go back to it. Telemetry comes with
+// ?precision= decimals.
+func ServeWS(w http.ResponseWriter, r *http.Request) {
+	precision, err := requestPrecision(r)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade has replied already
+	}
+	defer conn.Close()
+
+	source := "ws:" + middleware.GetReqID(r.Context())
+	events, unsubscribe, err := broker.Subscribe()
+	if err != nil {
+		// The upgrade is done, so the refusal goes in the close frame.
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseTryAgainLater, err.Error()),
+			time.Now().Add(wsWriteTimeout))
+		return
+	}
+	defer unsubscribe()
+
+	// Only the writer goroutine writes to the connection.
+	replies := make(chan Event, 4)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer conn.Close() // unblocks the reader if writing failed
+		ping := time.NewTicker(wsPingInterval)
+		defer ping.Stop()
+		for {
+			var e Event
+			var ok bool
+			select {
+			case e, ok = <-events:
+				if !ok {
+					// The subscription ended, by shutdown or because the
+					// client went away: say goodbye properly.
+					conn.WriteControl(websocket.CloseMessage,
+						websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+						time.Now().Add(wsWriteTimeout))
+					return
+				}
+			case e = <-replies:
+			case <-ping.C:
+				conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					return
+				}
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteJSON(e.withPrecision(precision)); err != nil {
+				return
+			}
+		}
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsReadTimeout))
+	})
+	for {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				log.Printf("Live client %s: %s", source, err)
+			}
+			break
+		}
+		err := errOwnerRequired
+		if mayChange(r) {
+			err = handleWSMessage(r.Context(), msg, source)
+		}
+		if err != nil {
+			select {
+			case replies <- Event{Type: "error", Data: errorEvent(err)}:
+			case <-done:
+			}
+		}
+	}
+	unsubscribe()
+	<-done
+}
+
+func handleWSMessage(ctx context.Context, msg wsMessage, source string) error {
+	switch msg.Type {
+	case "patch_config":
+		var patch interface{}
+		if err := json.Unmarshal(msg.Data, &patch); err != nil {
+			return err
+		}
+		_, err := applyConfigPatch(ctx, patch, source)
+		return err
+	}
+	return errors.New("unknown message type " + msg.Type)
+}
+
+// errorEvent describes err the way an error response would.
+func errorEvent(err error) *ErrResponse {
+	general := CodeInvalidRequest
+	if errors.Is(err, errScheduled) {
+		general = CodeConflict
+	}
+	return newErrResponse(general, err)
+}
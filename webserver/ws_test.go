@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialWS connects a live client to the harness.
+func (h *harness) dialWS() *websocket.Conn {
+	h.t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(h.Server.URL, "http")+"/rest/v1/ws", nil)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	h.t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// nextWS reads events off conn until one of type kind comes, and returns
+// its source and data.
+func nextWS(t *testing.T, conn *websocket.Conn, kind string) (string, json.RawMessage) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var e struct {
+			Type   string          `json:"type"`
+			Source string          `json:"source"`
+			Data   json.RawMessage `json:"data"`
+		}
+		if err := conn.ReadJSON(&e); err != nil {
+			t.Fatalf("waiting for a %s event: %s", kind, err)
+		}
+		if e.Type == kind {
+			return e.Source, e.Data
+		}
+	}
+}
+
+func TestWSTelemetryAndConfig(t *testing.T) {
+	h := newHarness(t, 20)
+	conn := h.dialWS()
+	other := h.dialWS()
+
+	h.Advance(10 * time.Second)
+	var telemetry Telemetry
+	_, data := nextWS(t, conn, "telemetry")
+	if err := json.Unmarshal(data, &telemetry); err != nil || telemetry.CurrentTemp != 20 {
+		t.Errorf("telemetry %s, want currenttemp 20", data)
+	}
+
+	if err := conn.WriteJSON(map[string]interface{}{"type": "patch_config", "data": map[string]interface{}{"daytemp": 22}}); err != nil {
+		t.Fatal(err)
+	}
+	// Every client hears of the change, the one that made it included.
+	for _, c := range []*websocket.Conn{conn, other} {
+		source, data := nextWS(t, c, "config_changed")
+		if !strings.HasPrefix(source, "ws:") || !strings.Contains(string(data), `"daytemp":"22.00"`) {
+			t.Errorf("config_changed from %q: %s, want daytemp 22.00 from the socket", source, data)
+		}
+	}
+	if temp, err := h.Store.GetTemp(); err != nil || temp.DayTemp != "22.00" {
+		t.Errorf("stored day temp %v (%v), want 22.00", temp.DayTemp, err)
+	}
+
+	if err := conn.WriteJSON(map[string]string{"type": "reboot"}); err != nil {
+		t.Fatal(err)
+	}
+	_, data = nextWS(t, conn, "error")
+	var e struct {
+		Code ErrorCode `json:"code"`
+	}
+	if err := json.Unmarshal(data, &e); err != nil || e.Code != CodeInvalidRequest {
+		t.Errorf("an unknown message got %s, want code %s", data, CodeInvalidRequest)
+	}
+}
+
+func TestLiveClientLimit(t *testing.T) {
+	h := newHarness(t, 20)
+	broker.SetLimit(1)
+	h.dialWS()
+
+	// The WebSocket subscribes once it's upgraded, which Dial doesn't
+	// wait for.
+	var stats SubscriberStats
+	deadline := time.Now().Add(5 * time.Second)
+	for stats.Active == 0 && time.Now().Before(deadline) {
+		h.GetJSON("/rest/v1/diagnostics/subscribers", &stats)
+	}
+	if stats.Active != 1 {
+		t.Fatalf("subscribers %+v, want the WebSocket", stats)
+	}
+
+	resp, body := h.Do(http.MethodGet, "/rest/v1/events", nil)
+	if resp.StatusCode != http.StatusServiceUnavailable || !jsonHasCode(body, CodeTooManySubscribers) {
+		t.Errorf("GET /rest/v1/events with the limit reached: %s %s, want 503 %s", resp.Status, body, CodeTooManySubscribers)
+	}
+
+	conn := h.dialWS()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseTryAgainLater) {
+		t.Errorf("a WebSocket over the limit read %v, want it closed to try again later", err)
+	}
+
+	h.GetJSON("/rest/v1/diagnostics/subscribers", &stats)
+	if want := (SubscriberStats{Active: 1, Limit: 1, Rejected: 2}); stats != want {
+		t.Errorf("subscribers %+v, want %+v", stats, want)
+	}
+}